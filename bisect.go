@@ -0,0 +1,814 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	stdioutil "io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	cgobject "gopkg.in/src-d/go-git.v4/plumbing/object/commitgraph"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	bisectStartFile       = "BISECT_START"
+	bisectTermsFile       = "BISECT_TERMS"
+	bisectNamesFile       = "BISECT_NAMES"
+	bisectLogFile         = "BISECT_LOG"
+	bisectExpectedRevFile = "BISECT_EXPECTED_REV"
+
+	bisectHead      plumbing.ReferenceName = "BISECT_HEAD"
+	bisectRefPrefix                        = "refs/bisect/"
+	bisectSkipTerm                         = "skip"
+)
+
+var (
+	ErrBisectNotStarted   = errors.New("bisect not started")
+	ErrBisectInProgress   = errors.New("bisect already in progress")
+	ErrBisectNotSupported = errors.New("bisect is only supported on filesystem storages")
+	ErrBisectNeedsBad     = errors.New("bisect needs a bad commit")
+	ErrBisectNeedsGood    = errors.New("bisect needs at least a good commit")
+	ErrBisectOnlySkipped  = errors.New("only skipped commits left to test")
+)
+
+// BisectResult is the result of testing a commit during a bisect session.
+type BisectResult int
+
+const (
+	// BisectGood marks the commit as good.
+	BisectGood BisectResult = iota
+	// BisectBad marks the commit as bad.
+	BisectBad
+	// BisectSkip skips the commit, it can't be tested.
+	BisectSkip
+)
+
+// BisectStep is the next step of a bisect session.
+type BisectStep struct {
+	// Commit is the next commit to be tested, or the first bad commit when
+	// Done is true.
+	Commit *object.Commit
+	// Done is true when the first bad commit was found.
+	Done bool
+	// Remaining is the number of commits that still can be the first bad one.
+	Remaining int
+}
+
+// Bisect is a bisect session, used to find the commit introducing a bug by
+// binary search. The state is stored the same way that git does, in the
+// BISECT_* files and the refs/bisect/* references, so a session can be
+// continued with the git command line and vice versa.
+type Bisect struct {
+	r  *Repository
+	fs billy.Filesystem
+
+	termBad, termGood string
+	pathSpecs         []string
+	noCheckout        bool
+}
+
+// BisectStart starts a new bisect session, the current HEAD is restored when
+// the session is reset. The first commit to test is returned by Next.
+func (r *Repository) BisectStart(o *BisectOptions) (*Bisect, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	fs, err := r.bisectFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.Stat(bisectStartFile); err == nil {
+		return nil, ErrBisectInProgress
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	start := head.Target().Short()
+	if head.Type() == plumbing.HashReference {
+		start = head.Hash().String()
+	}
+
+	b := &Bisect{
+		r:          r,
+		fs:         fs,
+		termBad:    o.TermBad,
+		termGood:   o.TermGood,
+		pathSpecs:  o.PathSpecs,
+		noCheckout: o.NoCheckout || r.wt == nil,
+	}
+
+	if b.noCheckout {
+		h, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+
+		ref := plumbing.NewHashReference(bisectHead, h.Hash())
+		if err := r.Storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.writeFile(bisectStartFile, start+"\n"); err != nil {
+		return nil, err
+	}
+
+	terms := fmt.Sprintf("%s\n%s\n", b.termBad, b.termGood)
+	if err := b.writeFile(bisectTermsFile, terms); err != nil {
+		return nil, err
+	}
+
+	if err := b.writeFile(bisectNamesFile, quoteBisectNames(b.pathSpecs)+"\n"); err != nil {
+		return nil, err
+	}
+
+	if err := b.log(bisectStartLog(o)); err != nil {
+		return nil, err
+	}
+
+	if !o.Bad.IsZero() {
+		if err := b.Bad(o.Bad); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(o.Good) != 0 {
+		if err := b.Good(o.Good...); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// Bisect returns the bisect session in progress, it returns
+// ErrBisectNotStarted if there isn't any.
+func (r *Repository) Bisect() (*Bisect, error) {
+	fs, err := r.bisectFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bisect{r: r, fs: fs, termBad: "bad", termGood: "good"}
+	if _, err := b.readFile(bisectStartFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBisectNotStarted
+		}
+
+		return nil, err
+	}
+
+	terms, err := b.readFile(bisectTermsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if lines := strings.Split(strings.TrimSpace(terms), "\n"); len(lines) == 2 {
+		b.termBad, b.termGood = lines[0], lines[1]
+	}
+
+	names, err := b.readFile(bisectNamesFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if b.pathSpecs, err = unquoteBisectNames(strings.TrimSpace(names)); err != nil {
+		return nil, err
+	}
+
+	_, err = r.Storer.Reference(bisectHead)
+	switch err {
+	case nil:
+		b.noCheckout = true
+	case plumbing.ErrReferenceNotFound:
+	default:
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (r *Repository) bisectFilesystem() (billy.Filesystem, error) {
+	s, ok := r.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, ErrBisectNotSupported
+	}
+
+	return s.Filesystem(), nil
+}
+
+// Terms returns the terms used for the bad and the good commits.
+func (b *Bisect) Terms() (bad, good string) {
+	return b.termBad, b.termGood
+}
+
+// Bad marks the given commit as bad, replacing the previous bad commit. The
+// commit being tested is used if the hash is zero.
+func (b *Bisect) Bad(h plumbing.Hash) error {
+	return b.mark(b.termBad, h)
+}
+
+// Good marks the given commits as good. The commit being tested is used if no
+// hash is given.
+func (b *Bisect) Good(hs ...plumbing.Hash) error {
+	return b.markAll(b.termGood, hs)
+}
+
+// Skip marks the given commits as untestable, they are never returned as the
+// commit to be tested. The commit being tested is used if no hash is given.
+func (b *Bisect) Skip(hs ...plumbing.Hash) error {
+	return b.markAll(bisectSkipTerm, hs)
+}
+
+func (b *Bisect) markAll(term string, hs []plumbing.Hash) error {
+	if len(hs) == 0 {
+		hs = []plumbing.Hash{plumbing.ZeroHash}
+	}
+
+	for _, h := range hs {
+		if err := b.mark(term, h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Bisect) mark(term string, h plumbing.Hash) error {
+	if h.IsZero() {
+		var err error
+		if h, err = b.current(); err != nil {
+			return err
+		}
+	}
+
+	commit, err := b.r.CommitObject(h)
+	if err != nil {
+		return err
+	}
+
+	name := plumbing.ReferenceName(bisectRefPrefix + term)
+	if term != b.termBad {
+		name = plumbing.ReferenceName(fmt.Sprintf("%s%s-%s", bisectRefPrefix, term, h))
+	}
+
+	if err := b.r.Storer.SetReference(plumbing.NewHashReference(name, h)); err != nil {
+		return err
+	}
+
+	subject := strings.SplitN(commit.Message, "\n", 2)[0]
+	return b.log(fmt.Sprintf("# %s: [%s] %s\ngit bisect %s %s", term, h, subject, term, h))
+}
+
+// current returns the commit being tested.
+func (b *Bisect) current() (plumbing.Hash, error) {
+	name := plumbing.HEAD
+	if b.noCheckout {
+		name = bisectHead
+	}
+
+	ref, err := storer.ResolveReference(b.r.Storer, name)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return ref.Hash(), nil
+}
+
+// Next computes the next commit to be tested, halving the commits that can be
+// the first bad one, and checks it out. When the first bad commit is found it
+// is returned with Done set. It returns ErrBisectOnlySkipped if the remaining
+// commits were all skipped.
+func (b *Bisect) Next() (*BisectStep, error) {
+	bad, goods, skips, err := b.marks()
+	if err != nil {
+		return nil, err
+	}
+
+	if bad.IsZero() {
+		return nil, ErrBisectNeedsBad
+	}
+
+	if len(goods) == 0 {
+		return nil, ErrBisectNeedsGood
+	}
+
+	step, err := b.bestCandidate(bad, goods, skips)
+	if err != nil {
+		return nil, err
+	}
+
+	if step.Done {
+		return step, b.log(fmt.Sprintf("# first %s commit: [%s] %s",
+			b.termBad, step.Commit.Hash, strings.SplitN(step.Commit.Message, "\n", 2)[0]))
+	}
+
+	if err := b.checkout(step.Commit.Hash); err != nil {
+		return nil, err
+	}
+
+	return step, nil
+}
+
+// Run drives the bisect session until the first bad commit is found, calling
+// fn with every commit to be tested, once checked out. An error returned by
+// fn stops the session.
+func (b *Bisect) Run(fn func(*object.Commit) (BisectResult, error)) (*object.Commit, error) {
+	for {
+		step, err := b.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if step.Done {
+			return step.Commit, nil
+		}
+
+		result, err := fn(step.Commit)
+		if err != nil {
+			return nil, err
+		}
+
+		switch result {
+		case BisectGood:
+			err = b.Good(step.Commit.Hash)
+		case BisectBad:
+			err = b.Bad(step.Commit.Hash)
+		case BisectSkip:
+			err = b.Skip(step.Commit.Hash)
+		default:
+			err = fmt.Errorf("invalid bisect result: %d", result)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Reset ends the bisect session, checking out back the original HEAD and
+// removing the bisect state.
+func (b *Bisect) Reset() error {
+	start, err := b.readFile(bisectStartFile)
+	if err != nil {
+		return err
+	}
+
+	start = strings.TrimSpace(start)
+	if !b.noCheckout && start != "" {
+		w, err := b.r.Worktree()
+		if err != nil {
+			return err
+		}
+
+		// BISECT_START holds the branch name, or the hash when HEAD was
+		// detached.
+		opts := &CheckoutOptions{Branch: plumbing.NewBranchReferenceName(start)}
+		if h := plumbing.NewHash(start); h.String() == start {
+			opts = &CheckoutOptions{Hash: h}
+		}
+
+		if err := w.Checkout(opts); err != nil {
+			return err
+		}
+	}
+
+	refs, err := b.r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var names []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), bisectRefPrefix) {
+			names = append(names, ref.Name())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	names = append(names, bisectHead)
+	for _, name := range names {
+		if err := b.r.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range []string{
+		bisectExpectedRevFile, bisectNamesFile, bisectTermsFile,
+		bisectLogFile, bisectStartFile,
+	} {
+		if err := b.fs.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// marks returns the bad, good and skipped commits of the session.
+func (b *Bisect) marks() (bad plumbing.Hash, goods, skips []plumbing.Hash, err error) {
+	refs, err := b.r.Storer.IterReferences()
+	if err != nil {
+		return
+	}
+
+	goodPrefix := bisectRefPrefix + b.termGood + "-"
+	skipPrefix := bisectRefPrefix + bisectSkipTerm + "-"
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		switch {
+		case name == bisectRefPrefix+b.termBad:
+			bad = ref.Hash()
+		case strings.HasPrefix(name, goodPrefix):
+			goods = append(goods, ref.Hash())
+		case strings.HasPrefix(name, skipPrefix):
+			skips = append(skips, ref.Hash())
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// bestCandidate returns the commit splitting in halves the commits reachable
+// from the bad commit and not from the good ones, weighting every candidate
+// by the number of candidates reachable from it. With pathspecs, only the
+// commits changing them are weighted and tested.
+func (b *Bisect) bestCandidate(bad plumbing.Hash, goods, skips []plumbing.Hash) (*BisectStep, error) {
+	index := b.r.commitNodeIndex()
+	badNode, err := index.Get(bad)
+	if err != nil {
+		return nil, err
+	}
+
+	var goodNodes []cgobject.CommitNode
+	for _, h := range goods {
+		n, err := index.Get(h)
+		if err != nil {
+			return nil, err
+		}
+
+		goodNodes = append(goodNodes, n)
+	}
+
+	candidates, err := cgobject.ExclusiveAncestors(badNode, goodNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("bisect: %s commit %s is an ancestor of a %s commit", b.termBad, bad, b.termGood)
+	}
+
+	positions := make(map[plumbing.Hash]int, len(candidates))
+	for i, c := range candidates {
+		positions[c.ID()] = i
+	}
+
+	// Bits of the candidates that count, all of them unless pathspecs are
+	// given. The bad commit always counts, being the answer when nothing
+	// else is left.
+	bits := make(map[plumbing.Hash]int)
+	for _, c := range candidates {
+		ok := c.ID() == bad
+		if !ok {
+			if ok, err = b.isInteresting(c); err != nil {
+				return nil, err
+			}
+		}
+
+		if ok {
+			bits[c.ID()] = len(bits)
+		}
+	}
+
+	reach, err := bisectReachability(candidates, positions, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := make(map[plumbing.Hash]bool, len(skips))
+	for _, h := range skips {
+		skipped[h] = true
+	}
+
+	total := len(bits)
+	best, bestScore := bad, -1
+	pendingSkipped := false
+	for _, c := range candidates {
+		if _, ok := bits[c.ID()]; !ok {
+			continue
+		}
+
+		if skipped[c.ID()] {
+			pendingSkipped = true
+			continue
+		}
+
+		weight := reach[positions[c.ID()]].count()
+		score := weight
+		if total-weight < score {
+			score = total - weight
+		}
+
+		if score > bestScore {
+			best, bestScore = c.ID(), score
+		}
+	}
+
+	if best == bad && pendingSkipped {
+		return nil, ErrBisectOnlySkipped
+	}
+
+	commit, err := b.r.CommitObject(best)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BisectStep{Commit: commit, Done: best == bad, Remaining: total}, nil
+}
+
+// isInteresting returns true if the commit changes the pathspecs compared to
+// all its parents, like the history simplification of git does.
+func (b *Bisect) isInteresting(c cgobject.CommitNode) (bool, error) {
+	if len(b.pathSpecs) == 0 {
+		return true, nil
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return false, err
+	}
+
+	opts := &object.DiffTreeOptions{PathSpecs: b.pathSpecs}
+	if c.NumParents() == 0 {
+		changes, err := object.DiffTreeWithOptions(context.Background(), nil, tree, opts)
+		return len(changes) != 0, err
+	}
+
+	iter := c.ParentNodes()
+	defer iter.Close()
+
+	interesting := true
+	err = iter.ForEach(func(parent cgobject.CommitNode) error {
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err
+		}
+
+		changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, opts)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			interesting = false
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	return interesting, err
+}
+
+// bisectBitset is a set of candidate bits.
+type bisectBitset []uint64
+
+func (s bisectBitset) set(i int) {
+	s[i/64] |= 1 << uint(i%64)
+}
+
+func (s bisectBitset) or(other bisectBitset) {
+	for i := range s {
+		s[i] |= other[i]
+	}
+}
+
+func (s bisectBitset) count() int {
+	n := 0
+	for _, w := range s {
+		for ; w != 0; w &= w - 1 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// bisectReachability returns, for every candidate, the set of counted
+// candidates reachable from it, itself included. The candidates are visited
+// parents first.
+func bisectReachability(
+	candidates []cgobject.CommitNode,
+	positions map[plumbing.Hash]int,
+	bits map[plumbing.Hash]int,
+) ([]bisectBitset, error) {
+	words := (len(bits) + 63) / 64
+	reach := make([]bisectBitset, len(candidates))
+	done := make([]bool, len(candidates))
+
+	for i := range candidates {
+		stack := []int{i}
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			if done[current] {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			var pending []int
+			for _, h := range candidates[current].ParentHashes() {
+				if p, ok := positions[h]; ok && !done[p] {
+					pending = append(pending, p)
+				}
+			}
+
+			if len(pending) != 0 {
+				stack = append(stack, pending...)
+				continue
+			}
+
+			stack = stack[:len(stack)-1]
+			set := make(bisectBitset, words)
+			for _, h := range candidates[current].ParentHashes() {
+				if p, ok := positions[h]; ok {
+					set.or(reach[p])
+				}
+			}
+
+			if bit, ok := bits[candidates[current].ID()]; ok {
+				set.set(bit)
+			}
+
+			reach[current] = set
+			done[current] = true
+		}
+	}
+
+	return reach, nil
+}
+
+// checkout checks out the commit to be tested, or just updates BISECT_HEAD
+// when the session doesn't check out commits.
+func (b *Bisect) checkout(h plumbing.Hash) error {
+	if b.noCheckout {
+		ref := plumbing.NewHashReference(bisectHead, h)
+		if err := b.r.Storer.SetReference(ref); err != nil {
+			return err
+		}
+	} else {
+		w, err := b.r.Worktree()
+		if err != nil {
+			return err
+		}
+
+		if err := w.Checkout(&CheckoutOptions{Hash: h}); err != nil {
+			return err
+		}
+	}
+
+	return b.writeFile(bisectExpectedRevFile, h.String()+"\n")
+}
+
+func (b *Bisect) readFile(name string) (s string, err error) {
+	f, err := b.fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	content, err := stdioutil.ReadAll(f)
+	return string(content), err
+}
+
+func (b *Bisect) writeFile(name, content string) (err error) {
+	f, err := b.fs.Create(name)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	_, err = f.Write([]byte(content))
+	return err
+}
+
+func (b *Bisect) log(line string) (err error) {
+	f, err := b.fs.OpenFile(bisectLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	_, err = f.Write([]byte(line + "\n"))
+	return err
+}
+
+func bisectStartLog(o *BisectOptions) string {
+	args := []string{"git bisect start"}
+	if o.TermBad != "bad" || o.TermGood != "good" {
+		args = append(args, shellQuote("--term-new="+o.TermBad), shellQuote("--term-old="+o.TermGood))
+	}
+
+	if o.NoCheckout {
+		args = append(args, shellQuote("--no-checkout"))
+	}
+
+	if !o.Bad.IsZero() {
+		args = append(args, shellQuote(o.Bad.String()))
+	}
+
+	for _, h := range o.Good {
+		args = append(args, shellQuote(h.String()))
+	}
+
+	args = append(args, shellQuote("--"))
+	for _, p := range o.PathSpecs {
+		args = append(args, shellQuote(p))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// quoteBisectNames quotes the pathspecs as git does in BISECT_NAMES, every
+// one of them preceded by a space.
+func quoteBisectNames(names []string) string {
+	buf := bytes.NewBuffer(nil)
+	for _, name := range names {
+		buf.WriteByte(' ')
+		buf.WriteString(shellQuote(name))
+	}
+
+	return buf.String()
+}
+
+// shellQuote quotes the string between single quotes, escaping the single
+// quotes and exclamation marks the way git does.
+func shellQuote(s string) string {
+	s = strings.Replace(s, "'", `'\''`, -1)
+	s = strings.Replace(s, "!", `'\!'`, -1)
+	return "'" + s + "'"
+}
+
+// unquoteBisectNames parses the content of BISECT_NAMES.
+func unquoteBisectNames(s string) ([]string, error) {
+	var (
+		names   []string
+		current []byte
+		quoted  bool
+		inName  bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quoted && ch == '\'':
+			quoted = false
+		case quoted:
+			current = append(current, ch)
+		case ch == '\'':
+			quoted, inName = true, true
+		case ch == '\\' && i+1 < len(s):
+			i++
+			current = append(current, s[i])
+		case ch == ' ':
+			if inName {
+				names = append(names, string(current))
+				current, inName = nil, false
+			}
+		default:
+			return nil, fmt.Errorf("malformed %s: %q", bisectNamesFile, s)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("malformed %s: %q", bisectNamesFile, s)
+	}
+
+	if inName {
+		names = append(names, string(current))
+	}
+
+	return names, nil
+}
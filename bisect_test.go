@@ -0,0 +1,248 @@
+package git
+
+import (
+	"io/ioutil"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	fixtures "gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+type BisectSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&BisectSuite{})
+
+var (
+	bisectBad  = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	bisectGood = plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+)
+
+func (s *BisectSuite) newRepository(c *C) *Repository {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Checkout(&CheckoutOptions{Force: true}), IsNil)
+
+	return r
+}
+
+// hasFile returns a bisect run callback, marking as bad the commits having
+// the given file, and counting the tested commits.
+func hasFile(c *C, r *Repository, name string, tested *int) func(*object.Commit) (BisectResult, error) {
+	return func(commit *object.Commit) (BisectResult, error) {
+		*tested++
+
+		head, err := r.Head()
+		c.Assert(err, IsNil)
+		c.Assert(head.Hash(), Equals, commit.Hash)
+
+		w, err := r.Worktree()
+		c.Assert(err, IsNil)
+
+		if _, err := w.Filesystem.Stat(name); err == nil {
+			return BisectBad, nil
+		}
+
+		return BisectGood, nil
+	}
+}
+
+func (s *BisectSuite) TestRun(c *C) {
+	for name, expected := range map[string]string{
+		"json/short.json": "af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		"CHANGELOG":       "b8e471f58bcbca63b07bda20e428190409c2db47",
+		"vendor/foo.go":   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	} {
+		r := s.newRepository(c)
+		b, err := r.BisectStart(&BisectOptions{
+			Bad:  bisectBad,
+			Good: []plumbing.Hash{bisectGood},
+		})
+		c.Assert(err, IsNil)
+
+		var tested int
+		commit, err := b.Run(hasFile(c, r, name, &tested))
+		c.Assert(err, IsNil)
+		c.Assert(commit.Hash.String(), Equals, expected, Commentf("file %s", name))
+		c.Assert(tested <= 3, Equals, true)
+
+		c.Assert(b.Reset(), IsNil)
+	}
+}
+
+func (s *BisectSuite) TestRunPathSpecs(c *C) {
+	r := s.newRepository(c)
+	b, err := r.BisectStart(&BisectOptions{
+		Bad:       bisectBad,
+		Good:      []plumbing.Hash{bisectGood},
+		PathSpecs: []string{"json"},
+	})
+	c.Assert(err, IsNil)
+
+	var tested int
+	commit, err := b.Run(hasFile(c, r, "json/short.json", &tested))
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash.String(), Equals, "af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+	c.Assert(tested, Equals, 1)
+}
+
+func (s *BisectSuite) TestOnlySkipped(c *C) {
+	r := s.newRepository(c)
+	b, err := r.BisectStart(&BisectOptions{
+		Bad:  bisectBad,
+		Good: []plumbing.Hash{bisectGood},
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := b.Run(func(*object.Commit) (BisectResult, error) {
+		return BisectSkip, nil
+	})
+	c.Assert(err, Equals, ErrBisectOnlySkipped)
+	c.Assert(commit, IsNil)
+}
+
+func (s *BisectSuite) TestNextNeedsMarks(c *C) {
+	r := s.newRepository(c)
+	b, err := r.BisectStart(&BisectOptions{})
+	c.Assert(err, IsNil)
+
+	_, err = b.Next()
+	c.Assert(err, Equals, ErrBisectNeedsBad)
+
+	c.Assert(b.Bad(plumbing.ZeroHash), IsNil)
+	_, err = b.Next()
+	c.Assert(err, Equals, ErrBisectNeedsGood)
+
+	c.Assert(b.Good(bisectGood), IsNil)
+	step, err := b.Next()
+	c.Assert(err, IsNil)
+	c.Assert(step.Done, Equals, false)
+	c.Assert(step.Remaining, Equals, 7)
+}
+
+func (s *BisectSuite) TestState(c *C) {
+	r := s.newRepository(c)
+	_, err := r.Bisect()
+	c.Assert(err, Equals, ErrBisectNotStarted)
+
+	b, err := r.BisectStart(&BisectOptions{
+		Bad:       bisectBad,
+		Good:      []plumbing.Hash{bisectGood},
+		PathSpecs: []string{"json", "it's"},
+		TermBad:   "new",
+		TermGood:  "old",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.BisectStart(&BisectOptions{})
+	c.Assert(err, Equals, ErrBisectInProgress)
+
+	step, err := b.Next()
+	c.Assert(err, IsNil)
+	c.Assert(b.Skip(), IsNil)
+
+	fs := r.Storer.(*filesystem.Storage).Filesystem()
+	for file, expected := range map[string]string{
+		"BISECT_START":        "master\n",
+		"BISECT_TERMS":        "new\nold\n",
+		"BISECT_NAMES":        " 'json' 'it'\\''s'\n",
+		"BISECT_EXPECTED_REV": step.Commit.Hash.String() + "\n",
+	} {
+		content := readFile(c, fs, file)
+		c.Assert(string(content), Equals, expected)
+	}
+
+	log := readFile(c, fs, "BISECT_LOG")
+	c.Assert(strings.HasPrefix(string(log), "git bisect start '--term-new=new' '--term-old=old'"), Equals, true)
+	c.Assert(strings.Contains(string(log), "git bisect skip "+step.Commit.Hash.String()), Equals, true)
+
+	AssertReferences(c, r, map[string]string{
+		"refs/bisect/new":                               bisectBad.String(),
+		"refs/bisect/old-" + bisectGood.String():        bisectGood.String(),
+		"refs/bisect/skip-" + step.Commit.Hash.String(): step.Commit.Hash.String(),
+	})
+
+	loaded, err := r.Bisect()
+	c.Assert(err, IsNil)
+	bad, good := loaded.Terms()
+	c.Assert(bad, Equals, "new")
+	c.Assert(good, Equals, "old")
+	c.Assert(loaded.pathSpecs, DeepEquals, []string{"json", "it's"})
+
+	c.Assert(loaded.Reset(), IsNil)
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	for _, file := range []string{"BISECT_START", "BISECT_TERMS", "BISECT_NAMES", "BISECT_LOG", "BISECT_EXPECTED_REV"} {
+		_, err := fs.Stat(file)
+		c.Assert(err, NotNil)
+	}
+
+	_, err = r.Storer.Reference("refs/bisect/new")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = r.Bisect()
+	c.Assert(err, Equals, ErrBisectNotStarted)
+}
+
+func (s *BisectSuite) TestNoCheckout(c *C) {
+	r := s.newRepository(c)
+	b, err := r.BisectStart(&BisectOptions{
+		Bad:        bisectBad,
+		Good:       []plumbing.Hash{bisectGood},
+		NoCheckout: true,
+	})
+	c.Assert(err, IsNil)
+
+	step, err := b.Next()
+	c.Assert(err, IsNil)
+
+	ref, err := r.Storer.Reference("BISECT_HEAD")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, step.Commit.Hash)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, bisectBad)
+
+	c.Assert(b.Reset(), IsNil)
+	_, err = r.Storer.Reference("BISECT_HEAD")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *BisectSuite) TestInvalidTerms(c *C) {
+	r := s.newRepository(c)
+	_, err := r.BisectStart(&BisectOptions{TermBad: "good"})
+	c.Assert(err, Equals, ErrBisectTerms)
+
+	_, err = r.BisectStart(&BisectOptions{TermGood: "skip"})
+	c.Assert(err, Equals, ErrBisectTerms)
+}
+
+func (s *BisectSuite) TestNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.BisectStart(&BisectOptions{})
+	c.Assert(err, Equals, ErrBisectNotSupported)
+}
+
+func readFile(c *C, fs billy.Filesystem, name string) []byte {
+	f, err := fs.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	return content
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdBranch struct {
+	repositoryCmd
+
+	Delete      bool `short:"d" long:"delete" description:"Delete a branch, which must be merged in HEAD"`
+	ForceDelete bool `short:"D" description:"Delete a branch, even if it isn't merged"`
+	Remotes     bool `short:"r" long:"remotes" description:"List the remote-tracking branches"`
+	All         bool `short:"a" long:"all" description:"List both the local and the remote-tracking branches"`
+
+	Args struct {
+		Name       string `positional-arg-name:"branchname"`
+		StartPoint string `positional-arg-name:"start-point"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdBranch) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.Delete || c.ForceDelete:
+		return c.delete(r)
+	case c.Args.Name != "":
+		return c.create(r)
+	}
+
+	return c.list(r)
+}
+
+func (c *CmdBranch) list(r *git.Repository) error {
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return err
+	}
+
+	refs, err := r.References()
+	if err != nil {
+		return err
+	}
+
+	var local, remote []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		switch {
+		case ref.Name().IsBranch():
+			local = append(local, ref.Name())
+		case ref.Name().IsRemote():
+			remote = append(remote, ref.Name())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(local, func(i, j int) bool { return local[i] < local[j] })
+	sort.Slice(remote, func(i, j int) bool { return remote[i] < remote[j] })
+
+	if !c.Remotes {
+		for _, name := range local {
+			prefix := "  "
+			if head.Type() == plumbing.SymbolicReference && head.Target() == name {
+				prefix = "* "
+			}
+
+			fmt.Fprintf(stdout, "%s%s\n", prefix, name.Short())
+		}
+	}
+
+	if c.Remotes || c.All {
+		for _, name := range remote {
+			short := name.Short()
+			if c.All {
+				short = "remotes/" + short
+			}
+
+			fmt.Fprintf(stdout, "  %s\n", short)
+		}
+	}
+
+	return nil
+}
+
+func (c *CmdBranch) create(r *git.Repository) error {
+	name := plumbing.NewBranchReferenceName(c.Args.Name)
+	if _, err := r.Reference(name, false); err == nil {
+		return fmt.Errorf("a branch named '%s' already exists", c.Args.Name)
+	}
+
+	start := c.Args.StartPoint
+	if start == "" {
+		start = "HEAD"
+	}
+
+	commit, err := resolveCommit(r, start)
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(name, commit.Hash))
+}
+
+func (c *CmdBranch) delete(r *git.Repository) error {
+	if c.Args.Name == "" {
+		return fmt.Errorf("branch name required")
+	}
+
+	name := plumbing.NewBranchReferenceName(c.Args.Name)
+	ref, err := r.Reference(name, false)
+	if err != nil {
+		return fmt.Errorf("branch '%s' not found", c.Args.Name)
+	}
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return err
+	}
+
+	if head.Type() == plumbing.SymbolicReference && head.Target() == name {
+		return fmt.Errorf("cannot delete the checked out branch '%s'", c.Args.Name)
+	}
+
+	if !c.ForceDelete {
+		merged, err := isMerged(r, ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		if !merged {
+			return fmt.Errorf("the branch '%s' is not fully merged, use -D to delete it", c.Args.Name)
+		}
+	}
+
+	if err := r.Storer.RemoveReference(name); err != nil {
+		return err
+	}
+
+	if err := r.DeleteBranch(c.Args.Name); err != nil && err != git.ErrBranchNotFound {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Deleted branch %s (was %s).\n", c.Args.Name, shortHash(ref.Hash()))
+	return nil
+}
+
+// isMerged returns true if the commit is reachable from HEAD.
+func isMerged(r *git.Repository, hash plumbing.Hash) (bool, error) {
+	head, err := resolveCommit(r, "HEAD")
+	if err != nil {
+		return false, err
+	}
+
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return false, err
+	}
+
+	if commit.Hash == head.Hash {
+		return true, nil
+	}
+
+	return commit.IsAncestor(head)
+}
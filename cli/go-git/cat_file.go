@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+)
+
+type CmdCatFile struct {
+	repositoryCmd
+
+	Type   bool `short:"t" description:"Show the type of the object"`
+	Size   bool `short:"s" description:"Show the size of the object"`
+	Pretty bool `short:"p" description:"Pretty-print the content of the object"`
+	Exists bool `short:"e" description:"Exit with zero status if the object exists"`
+
+	Args struct {
+		First  string `positional-arg-name:"type" required:"true"`
+		Object string `positional-arg-name:"object"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdCatFile) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	typ, name := plumbing.AnyObject, c.Args.First
+	if c.Args.Object != "" {
+		if typ, err = plumbing.ParseObjectType(c.Args.First); err != nil {
+			return err
+		}
+
+		name = c.Args.Object
+	}
+
+	hash, err := objectHash(r, name)
+	if err != nil {
+		return err
+	}
+
+	obj, err := r.Storer.EncodedObject(typ, hash)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.Exists:
+		return nil
+	case c.Type:
+		fmt.Fprintln(stdout, obj.Type())
+		return nil
+	case c.Size:
+		fmt.Fprintln(stdout, obj.Size())
+		return nil
+	case c.Pretty && obj.Type() == plumbing.TreeObject:
+		return printTree(r, hash)
+	}
+
+	reader, err := obj.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer reader.Close()
+	_, err = io.Copy(stdout, reader)
+	return err
+}
+
+// printTree prints the entries of a tree, as git cat-file -p does.
+func printTree(r *git.Repository, hash plumbing.Hash) error {
+	tree, err := r.TreeObject(hash)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range tree.Entries {
+		typ := plumbing.BlobObject
+		switch e.Mode {
+		case filemode.Dir:
+			typ = plumbing.TreeObject
+		case filemode.Submodule:
+			typ = plumbing.CommitObject
+		}
+
+		fmt.Fprintf(stdout, "%06o %s %s\t%s\n", uint32(e.Mode), typ, e.Hash, e.Name)
+	}
+
+	return nil
+}
+
+var hashRegExp = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// objectHash returns the hash of the object with the given name, a full hash,
+// a reference, without peeling the annotated tags, or any other revision.
+func objectHash(r *git.Repository, name string) (plumbing.Hash, error) {
+	if hashRegExp.MatchString(name) {
+		return plumbing.NewHash(name), nil
+	}
+
+	if ref, err := r.Reference(expandReferenceName(r, name), true); err == nil {
+		return ref.Hash(), nil
+	}
+
+	h, err := r.ResolveRevision(plumbing.Revision(name))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return *h, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdCheckout struct {
+	repositoryCmd
+
+	Branch   string `short:"b" description:"Create a new branch and check it out"`
+	Force    bool   `short:"f" long:"force" description:"Discard the local changes"`
+	NoVerify bool   `long:"no-verify" description:"Bypass the post-checkout and reference-transaction hooks"`
+
+	Args struct {
+		Target string `positional-arg-name:"branch"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdCheckout) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	o := &git.CheckoutOptions{Force: c.Force, NoHooks: c.NoVerify}
+	if c.Branch != "" {
+		start := c.Args.Target
+		if start == "" {
+			start = "HEAD"
+		}
+
+		commit, err := resolveCommit(r, start)
+		if err != nil {
+			return err
+		}
+
+		o.Create = true
+		o.Hash = commit.Hash
+		o.Branch = plumbing.NewBranchReferenceName(c.Branch)
+		if err := w.Checkout(o); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stderr, "Switched to a new branch '%s'\n", c.Branch)
+		return nil
+	}
+
+	if c.Args.Target == "" {
+		return fmt.Errorf("branch or commit required")
+	}
+
+	branch := plumbing.NewBranchReferenceName(c.Args.Target)
+	if _, err := r.Reference(branch, false); err == nil {
+		o.Branch = branch
+		if err := w.Checkout(o); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stderr, "Switched to branch '%s'\n", c.Args.Target)
+		return nil
+	}
+
+	commit, err := resolveCommit(r, c.Args.Target)
+	if err != nil {
+		return err
+	}
+
+	o.Hash = commit.Hash
+	if err := w.Checkout(o); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stderr, "HEAD is now at %s %s\n", shortHash(commit.Hash), subject(commit))
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdClone struct {
+	cmd
+
+	Bare         bool   `long:"bare" description:"Make a bare repository"`
+	NoCheckout   bool   `short:"n" long:"no-checkout" description:"Don't checkout HEAD after the clone is complete"`
+	Origin       string `short:"o" long:"origin" default:"origin" description:"Name of the remote"`
+	Branch       string `short:"b" long:"branch" description:"Checkout the given branch instead of the remote HEAD"`
+	SingleBranch bool   `long:"single-branch" description:"Clone only the history leading to the tip of a single branch"`
+	Depth        int    `long:"depth" description:"Create a shallow clone truncated to the given number of commits"`
+
+	Args struct {
+		URL string `positional-arg-name:"repository" required:"true"`
+		Dir string `positional-arg-name:"directory"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdClone) Execute(args []string) error {
+	dir := c.Args.Dir
+	if dir == "" {
+		dir = humanishName(c.Args.URL, c.Bare)
+	}
+
+	if c.Bare {
+		fmt.Fprintf(stderr, "Cloning into bare repository '%s'...\n", dir)
+	} else {
+		fmt.Fprintf(stderr, "Cloning into '%s'...\n", dir)
+	}
+
+	o := &git.CloneOptions{
+		URL:          c.Args.URL,
+		RemoteName:   c.Origin,
+		SingleBranch: c.SingleBranch,
+		NoCheckout:   c.NoCheckout,
+		Depth:        c.Depth,
+		Progress:     c.progress(),
+	}
+
+	if c.Branch != "" {
+		o.ReferenceName = plumbing.NewBranchReferenceName(c.Branch)
+	}
+
+	_, err := git.PlainClone(dir, c.Bare, o)
+	return err
+}
+
+// humanishName returns the directory of a clone of url, as git does, the last
+// component of the path without the .git suffix.
+func humanishName(url string, bare bool) string {
+	name := strings.TrimSuffix(strings.TrimRight(url, "/"), "/.git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	name = strings.TrimSuffix(name, ".git")
+	if bare {
+		name += ".git"
+	}
+
+	return filepath.Clean(name)
+}
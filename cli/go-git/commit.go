@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdCommit struct {
+	repositoryCmd
+
+	Message  []string `short:"m" long:"message" description:"Use the given message, several ones are concatenated as paragraphs"`
+	All      bool     `short:"a" long:"all" description:"Stage the modified and deleted files before committing"`
+	Amend    bool     `long:"amend" description:"Replace the tip of the current branch"`
+	NoVerify bool     `short:"n" long:"no-verify" description:"Bypass the pre-commit and commit-msg hooks"`
+}
+
+func (c *CmdCommit) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	o := &git.CommitOptions{All: c.All, Amend: c.Amend, NoHooks: c.NoVerify}
+	if o.Committer, err = signature(r, "GIT_COMMITTER"); err != nil {
+		return err
+	}
+
+	msg := strings.Join(c.Message, "\n\n")
+	if c.Amend {
+		head, err := resolveCommit(r, "HEAD")
+		if err != nil {
+			return err
+		}
+
+		// as git does, the amended commit keeps its author
+		o.Author = &head.Author
+		if msg == "" {
+			msg = head.Message
+		}
+	} else if o.Author, err = signature(r, "GIT_AUTHOR"); err != nil {
+		return err
+	}
+
+	if msg == "" {
+		return fmt.Errorf("empty commit message, use -m")
+	}
+
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+
+	hash, err := w.Commit(msg, o)
+	if err != nil {
+		return err
+	}
+
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	branch := "detached HEAD"
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return err
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		branch = head.Target().Short()
+	}
+
+	if commit.NumParents() == 0 {
+		branch += " (root-commit)"
+	}
+
+	fmt.Fprintf(stdout, "[%s %s] %s\n", branch, shortHash(hash), subject(commit))
+	return nil
+}
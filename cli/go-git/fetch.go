@@ -0,0 +1,45 @@
+package main
+
+import (
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+)
+
+type CmdFetch struct {
+	repositoryCmd
+
+	Depth int  `long:"depth" description:"Limit fetching to the given number of commits"`
+	Force bool `short:"f" long:"force" description:"Update the local references even if they aren't fast-forwards"`
+	Tags  bool `short:"t" long:"tags" description:"Fetch all the tags"`
+
+	Args struct {
+		Remote   string           `positional-arg-name:"repository"`
+		RefSpecs []config.RefSpec `positional-arg-name:"refspec"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdFetch) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	o := &git.FetchOptions{
+		RemoteName: c.Args.Remote,
+		RefSpecs:   c.Args.RefSpecs,
+		Depth:      c.Depth,
+		Force:      c.Force,
+		Progress:   c.progress(),
+	}
+
+	if c.Tags {
+		o.Tags = git.AllTags
+	}
+
+	err = r.Fetch(o)
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+
+	return err
+}
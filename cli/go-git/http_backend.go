@@ -0,0 +1,246 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/file"
+)
+
+const (
+	uploadPackService  = "git-upload-pack"
+	receivePackService = "git-receive-pack"
+	exportOkFile       = "git-daemon-export-ok"
+)
+
+// CmdHTTPBackend serves the repositories over HTTP as a CGI program, like
+// `git http-backend`. Only the smart protocol is supported, besides the plain
+// files read by the dumb one.
+type CmdHTTPBackend struct {
+	cmd
+}
+
+func (CmdHTTPBackend) Usage() string {
+	return fmt.Sprintf("usage: %s http-backend", os.Args[0])
+}
+
+var (
+	infoRefsPath = regexp.MustCompile(`^(.*)/info/refs$`)
+	servicePath  = regexp.MustCompile(`^(.*)/(git-upload-pack|git-receive-pack)$`)
+	dumbPath     = regexp.MustCompile(`^(.*)/(HEAD|objects/info/(alternates|http-alternates|packs)|` +
+		`objects/[0-9a-f]{2}/[0-9a-f]{38}|objects/pack/pack-[0-9a-f]{40}\.(pack|idx))$`)
+)
+
+func (c *CmdHTTPBackend) Execute(args []string) error {
+	method := os.Getenv("REQUEST_METHOD")
+	query, err := url.ParseQuery(os.Getenv("QUERY_STRING"))
+	if err != nil {
+		return c.fail(http.StatusBadRequest, "invalid query")
+	}
+
+	pathInfo := os.Getenv("PATH_INFO")
+	switch {
+	case infoRefsPath.MatchString(pathInfo):
+		m := infoRefsPath.FindStringSubmatch(pathInfo)
+		service := query.Get("service")
+		if service == "" {
+			return c.serveFile(method, m[1], "info/refs")
+		}
+
+		return c.advertiseRefs(method, m[1], service)
+	case servicePath.MatchString(pathInfo):
+		m := servicePath.FindStringSubmatch(pathInfo)
+		return c.serveService(method, m[1], m[2])
+	case dumbPath.MatchString(pathInfo):
+		m := dumbPath.FindStringSubmatch(pathInfo)
+		return c.serveFile(method, m[1], m[2])
+	default:
+		return c.fail(http.StatusNotFound, "Not Found")
+	}
+}
+
+func (c *CmdHTTPBackend) advertiseRefs(method, repo, service string) error {
+	if method != "GET" && method != "HEAD" {
+		return c.fail(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	dir, status := c.repository(repo, service)
+	if status != http.StatusOK {
+		return c.fail(status, http.StatusText(status))
+	}
+
+	writeHeader(http.StatusOK, fmt.Sprintf("application/x-%s-advertisement", service))
+	e := pktline.NewEncoder(stdout)
+	if err := e.EncodeString(fmt.Sprintf("# service=%s\n", service)); err != nil {
+		return err
+	}
+
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	return c.serve(service, dir, &file.ServeOptions{
+		StatelessRPC:  true,
+		AdvertiseRefs: true,
+		Stdout:        stdout,
+	})
+}
+
+func (c *CmdHTTPBackend) serveService(method, repo, service string) error {
+	if method != "POST" {
+		return c.fail(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	if os.Getenv("CONTENT_TYPE") != fmt.Sprintf("application/x-%s-request", service) {
+		return c.fail(http.StatusUnsupportedMediaType, "Unsupported Media Type")
+	}
+
+	dir, status := c.repository(repo, service)
+	if status != http.StatusOK {
+		return c.fail(status, http.StatusText(status))
+	}
+
+	var in io.Reader = stdin
+	switch os.Getenv("HTTP_CONTENT_ENCODING") {
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(stdin)
+		if err != nil {
+			return c.fail(http.StatusBadRequest, "invalid gzip request body")
+		}
+
+		defer r.Close()
+		in = r
+	}
+
+	writeHeader(http.StatusOK, fmt.Sprintf("application/x-%s-result", service))
+	return c.serve(service, dir, &file.ServeOptions{
+		StatelessRPC: true,
+		Stdin:        in,
+		Stdout:       stdout,
+	})
+}
+
+func (c *CmdHTTPBackend) serve(service, dir string, o *file.ServeOptions) error {
+	if service == uploadPackService {
+		return file.ServeUploadPackWithOptions(dir, o)
+	}
+
+	return file.ServeReceivePackWithOptions(dir, o)
+}
+
+func (c *CmdHTTPBackend) serveFile(method, repo, name string) error {
+	if method != "GET" && method != "HEAD" {
+		return c.fail(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	dir, status := c.repository(repo, "")
+	if status != http.StatusOK {
+		return c.fail(status, http.StatusText(status))
+	}
+
+	f, err := os.Open(filepath.Join(gitDir(dir), filepath.FromSlash(name)))
+	if err != nil {
+		return c.fail(http.StatusNotFound, "Not Found")
+	}
+
+	defer f.Close()
+
+	contentType := "text/plain"
+	switch {
+	case strings.HasPrefix(name, "objects/pack/") && strings.HasSuffix(name, ".pack"):
+		contentType = "application/x-git-packed-objects"
+	case strings.HasPrefix(name, "objects/pack/"):
+		contentType = "application/x-git-packed-objects-toc"
+	case strings.HasPrefix(name, "objects/") && !strings.HasPrefix(name, "objects/info/"):
+		contentType = "application/x-git-loose-object"
+	}
+
+	writeHeader(http.StatusOK, contentType)
+	if method == "HEAD" {
+		return nil
+	}
+
+	_, err = io.Copy(stdout, f)
+	return err
+}
+
+// repository returns the path of the repository, relative to GIT_PROJECT_ROOT,
+// and whether the service is allowed on it, as an HTTP status.
+func (c *CmdHTTPBackend) repository(repo, service string) (string, int) {
+	for _, e := range strings.Split(repo, "/") {
+		if e == ".." {
+			return "", http.StatusForbidden
+		}
+	}
+
+	root := os.Getenv("GIT_PROJECT_ROOT")
+	if root == "" {
+		root = strings.TrimSuffix(os.Getenv("PATH_TRANSLATED"), os.Getenv("PATH_INFO"))
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(path.Clean("/"+repo)))
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+
+	if os.Getenv("GIT_HTTP_EXPORT_ALL") == "" {
+		if _, err := os.Stat(filepath.Join(gitDir(dir), exportOkFile)); err != nil {
+			return "", http.StatusNotFound
+		}
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return "", http.StatusInternalServerError
+	}
+
+	switch service {
+	case uploadPackService:
+		if cfg.Raw.Section("http").Option("uploadpack") == "false" {
+			return "", http.StatusForbidden
+		}
+	case receivePackService:
+		enabled := cfg.Raw.Section("http").Option("receivepack")
+		if enabled == "false" || enabled == "" && os.Getenv("REMOTE_USER") == "" {
+			return "", http.StatusForbidden
+		}
+	}
+
+	return dir, http.StatusOK
+}
+
+func (c *CmdHTTPBackend) fail(status int, msg string) error {
+	writeHeader(status, "text/plain; charset=utf-8")
+	_, err := fmt.Fprintln(stdout, msg)
+	return err
+}
+
+// gitDir returns the git directory of the repository at dir, bare or not.
+func gitDir(dir string) string {
+	if fi, err := os.Stat(filepath.Join(dir, git.GitDirName)); err == nil && fi.IsDir() {
+		return filepath.Join(dir, git.GitDirName)
+	}
+
+	return dir
+}
+
+// writeHeader writes the header of a CGI response, disabling the caching as
+// git does for the dynamic content.
+func writeHeader(status int, contentType string) {
+	fmt.Fprintf(stdout, "Status: %d %s\r\n", status, http.StatusText(status))
+	fmt.Fprint(stdout, "Expires: Fri, 01 Jan 1980 00:00:00 GMT\r\n")
+	fmt.Fprint(stdout, "Pragma: no-cache\r\n")
+	fmt.Fprint(stdout, "Cache-Control: no-cache, max-age=0, must-revalidate\r\n")
+	fmt.Fprintf(stdout, "Content-Type: %s\r\n\r\n", contentType)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// logDateFormat is the format of the dates printed by git log.
+const logDateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+type CmdLog struct {
+	repositoryCmd
+
+	MaxCount int  `short:"n" long:"max-count" description:"Limit the number of commits to output"`
+	Oneline  bool `long:"oneline" description:"Print each commit in a single line"`
+	All      bool `long:"all" description:"Show the commits reachable from all the references"`
+
+	Args struct {
+		Revision  string   `positional-arg-name:"revision"`
+		PathSpecs []string `positional-arg-name:"pathspec"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdLog) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	o := &git.LogOptions{All: c.All, PathSpecs: c.Args.PathSpecs}
+	if c.Args.Revision != "" {
+		commit, err := resolveCommit(r, c.Args.Revision)
+		if err != nil {
+			return err
+		}
+
+		o.From = commit.Hash
+	}
+
+	iter, err := r.Log(o)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	return iter.ForEach(func(commit *object.Commit) error {
+		if c.MaxCount > 0 && count == c.MaxCount {
+			return storer.ErrStop
+		}
+
+		if c.Oneline {
+			fmt.Fprintf(stdout, "%s %s\n", shortHash(commit.Hash), subject(commit))
+		} else {
+			if count > 0 {
+				fmt.Fprintln(stdout)
+			}
+
+			printCommit(commit)
+		}
+
+		count++
+		return nil
+	})
+}
+
+// printCommit prints the commit in the medium format of git log.
+func printCommit(commit *object.Commit) {
+	fmt.Fprintf(stdout, "commit %s\n", commit.Hash)
+	if commit.NumParents() > 1 {
+		var parents []string
+		for _, p := range commit.ParentHashes {
+			parents = append(parents, shortHash(p))
+		}
+
+		fmt.Fprintf(stdout, "Merge: %s\n", strings.Join(parents, " "))
+	}
+
+	fmt.Fprintf(stdout, "Author: %s <%s>\n", commit.Author.Name, commit.Author.Email)
+	fmt.Fprintf(stdout, "Date:   %s\n\n", commit.Author.When.Format(logDateFormat))
+	for _, line := range strings.Split(strings.TrimRight(commit.Message, "\n"), "\n") {
+		fmt.Fprintf(stdout, "    %s\n", line)
+	}
+}
+
+// subject returns the first line of the message of the commit.
+func subject(commit *object.Commit) string {
+	return strings.SplitN(commit.Message, "\n", 2)[0]
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+type CmdLsRemote struct {
+	repositoryCmd
+
+	Heads bool `long:"heads" description:"Limit to the branches"`
+	Tags  bool `short:"t" long:"tags" description:"Limit to the tags"`
+
+	Args struct {
+		Repository string   `positional-arg-name:"repository"`
+		Patterns   []string `positional-arg-name:"patterns"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdLsRemote) Execute(args []string) error {
+	remote, err := c.remote()
+	if err != nil {
+		return err
+	}
+
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for _, ref := range refs {
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Name()] = ref.Hash()
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name() == plumbing.HEAD || refs[j].Name() == plumbing.HEAD {
+			return refs[i].Name() == plumbing.HEAD
+		}
+
+		return refs[i].Name() < refs[j].Name()
+	})
+
+	for _, ref := range refs {
+		if !c.match(ref.Name()) {
+			continue
+		}
+
+		hash := ref.Hash()
+		if ref.Type() == plumbing.SymbolicReference {
+			var ok bool
+			if hash, ok = hashes[ref.Target()]; !ok {
+				continue
+			}
+		}
+
+		fmt.Fprintf(stdout, "%s\t%s\n", hash, ref.Name())
+	}
+
+	return nil
+}
+
+// remote returns the remote with the given name in the repository, origin by
+// default, or an anonymous remote if the argument is an URL.
+func (c *CmdLsRemote) remote() (*git.Remote, error) {
+	name := c.Args.Repository
+	if name == "" {
+		name = git.DefaultRemoteName
+	}
+
+	if r, err := c.repository(); err == nil {
+		if remote, err := r.Remote(name); err == nil {
+			return remote, nil
+		}
+	}
+
+	if c.Args.Repository == "" {
+		return nil, fmt.Errorf("no remote configured to list refs from")
+	}
+
+	return git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{c.Args.Repository},
+	}), nil
+}
+
+func (c *CmdLsRemote) match(name plumbing.ReferenceName) bool {
+	if (c.Heads || c.Tags) && !(c.Heads && name.IsBranch() || c.Tags && name.IsTag()) {
+		return false
+	}
+
+	return matchReference(name, c.Args.Patterns)
+}
+
+// matchReference returns true if there are no patterns or one of them matches
+// the end of the reference name, after a slash.
+func matchReference(name plumbing.ReferenceName, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		if string(name) == p || strings.HasSuffix(string(name), "/"+p) {
+			return true
+		}
+	}
+
+	return false
+}
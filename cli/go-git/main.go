@@ -1,10 +1,17 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/jessevdk/go-flags"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 )
 
 const (
@@ -13,6 +20,14 @@ const (
 	uploadPackBin  = "git-upload-pack"
 )
 
+// stdin is where the commands read their input from, and stdout and stderr
+// where they write their output, replaced by the tests.
+var (
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
 func main() {
 	switch filepath.Base(os.Args[0]) {
 	case receivePackBin:
@@ -21,21 +36,129 @@ func main() {
 		os.Args = append([]string{"git", "upload-pack"}, os.Args[1:]...)
 	}
 
-	parser := flags.NewNamedParser(bin, flags.Default)
-	parser.AddCommand("receive-pack", "", "", &CmdReceivePack{})
-	parser.AddCommand("upload-pack", "", "", &CmdUploadPack{})
-	parser.AddCommand("version", "Show the version information.", "", &CmdVersion{})
-
+	parser := newParser(flags.Default)
 	_, err := parser.Parse()
 	if err != nil {
 		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrCommandRequired {
 			parser.WriteHelp(os.Stdout)
 		}
 
-		os.Exit(1)
+		os.Exit(exitCode(os.Args[1:], err))
 	}
 }
 
+// exitCode returns the exit code of a failed command: as git, upload-pack,
+// receive-pack and http-backend exit with 129 given invalid arguments.
+func exitCode(args []string, err error) int {
+	if _, ok := err.(*flags.Error); !ok || len(args) == 0 {
+		return 1
+	}
+
+	switch args[0] {
+	case "upload-pack", "receive-pack", "http-backend":
+		return 129
+	default:
+		return 1
+	}
+}
+
+func newParser(options flags.Options) *flags.Parser {
+	parser := flags.NewNamedParser(bin, options)
+	parser.AddCommand("receive-pack", "", "", &CmdReceivePack{})
+	parser.AddCommand("upload-pack", "", "", &CmdUploadPack{})
+	parser.AddCommand("http-backend", "Server side implementation of Git over HTTP.", "", &CmdHTTPBackend{})
+	parser.AddCommand("version", "Show the version information.", "", &CmdVersion{})
+
+	parser.AddCommand("clone", "Clone a repository into a new directory.", "", &CmdClone{})
+	parser.AddCommand("fetch", "Download objects and refs from another repository.", "", &CmdFetch{})
+	parser.AddCommand("push", "Update remote refs along with associated objects.", "", &CmdPush{})
+	parser.AddCommand("log", "Show commit logs.", "", &CmdLog{})
+	parser.AddCommand("status", "Show the working tree status.", "", &CmdStatus{})
+	parser.AddCommand("checkout", "Switch branches or restore working tree files.", "", &CmdCheckout{})
+	parser.AddCommand("commit", "Record changes to the repository.", "", &CmdCommit{})
+	parser.AddCommand("branch", "List, create, or delete branches.", "", &CmdBranch{})
+	parser.AddCommand("tag", "List, create, or delete tags.", "", &CmdTag{})
+	parser.AddCommand("ls-remote", "List references in a remote repository.", "", &CmdLsRemote{})
+	parser.AddCommand("cat-file", "Provide content or type and size information for repository objects.", "", &CmdCatFile{})
+	parser.AddCommand("rev-parse", "Pick out and massage parameters.", "", &CmdRevParse{})
+	parser.AddCommand("show-ref", "List references in a local repository.", "", &CmdShowRef{})
+
+	return parser
+}
+
 type cmd struct {
 	Verbose bool `short:"v" description:"Activates the verbose mode"`
 }
+
+// progress returns where the progress of the transport operations is written,
+// only in verbose mode.
+func (c *cmd) progress() sideband.Progress {
+	if !c.Verbose {
+		return nil
+	}
+
+	return stderr
+}
+
+// repositoryCmd is the base of the commands run on an existing repository.
+type repositoryCmd struct {
+	cmd
+	Path string `short:"C" default:"." description:"Run as if started in the given path"`
+}
+
+// repository opens the repository at the path, looking for it in the parent
+// directories when the path isn't a bare repository or a worktree root.
+func (c *repositoryCmd) repository() (*git.Repository, error) {
+	r, err := git.PlainOpen(c.Path)
+	if err != git.ErrRepositoryNotExists {
+		return r, err
+	}
+
+	return git.PlainOpenWithOptions(c.Path, &git.PlainOpenOptions{DetectDotGit: true})
+}
+
+// resolveCommit returns the commit of the given revision, peeling the tags.
+func resolveCommit(r *git.Repository, rev string) (*object.Commit, error) {
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(*h)
+}
+
+// signature returns the signature of the user, from the environment variables
+// with the given prefix, GIT_AUTHOR or GIT_COMMITTER, or the user section of
+// the config.
+func signature(r *git.Repository, prefix string) (*object.Signature, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	user := cfg.Raw.Section("user")
+	s := &object.Signature{
+		Name:  os.Getenv(prefix + "_NAME"),
+		Email: os.Getenv(prefix + "_EMAIL"),
+		When:  time.Now(),
+	}
+
+	if s.Name == "" {
+		s.Name = user.Option("name")
+	}
+
+	if s.Email == "" {
+		s.Email = user.Option("email")
+	}
+
+	if s.Name == "" || s.Email == "" {
+		return nil, fmt.Errorf("%s identity unknown, set user.name and user.email in the config", prefix)
+	}
+
+	return s, nil
+}
+
+// shortHash returns the abbreviated form of h, as printed by git.
+func shortHash(h plumbing.Hash) string {
+	return h.String()[:7]
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CLISuite struct {
+	dir string
+}
+
+var _ = Suite(&CLISuite{})
+
+func (s *CLISuite) SetUpSuite(c *C) {
+	os.Setenv("GIT_AUTHOR_NAME", "Foo")
+	os.Setenv("GIT_AUTHOR_EMAIL", "foo@example.local")
+	os.Setenv("GIT_COMMITTER_NAME", "Foo")
+	os.Setenv("GIT_COMMITTER_EMAIL", "foo@example.local")
+}
+
+func (s *CLISuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	_, err := git.PlainInit(s.dir, false)
+	c.Assert(err, IsNil)
+}
+
+// run runs the command with the given arguments, returning its output.
+func (s *CLISuite) run(c *C, args ...string) (string, error) {
+	out := bytes.NewBuffer(nil)
+	stdout, stderr = out, ioutil.Discard
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	_, err := newParser(flags.HelpFlag | flags.PassDoubleDash).ParseArgs(args)
+	return out.String(), err
+}
+
+// git runs the command on the repository of the test, failing if it fails.
+func (s *CLISuite) git(c *C, cmd string, args ...string) string {
+	out, err := s.run(c, append([]string{cmd, "-C", s.dir}, args...)...)
+	c.Assert(err, IsNil)
+	return out
+}
+
+func (s *CLISuite) write(c *C, name, content string) {
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, name), []byte(content), 0644), IsNil)
+}
+
+// commit adds the given file and commits it, returning its hash.
+func (s *CLISuite) commit(c *C, name, content, msg string) string {
+	s.write(c, name, content)
+
+	r, err := git.PlainOpen(s.dir)
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	_, err = w.Add(name)
+	c.Assert(err, IsNil)
+
+	s.git(c, "commit", "-m", msg)
+	return s.revParse(c, "HEAD")
+}
+
+func (s *CLISuite) revParse(c *C, rev string) string {
+	out := s.git(c, "rev-parse", rev)
+	return out[:len(out)-1]
+}
+
+func (s *CLISuite) TestLog(c *C) {
+	first := s.commit(c, "foo", "foo\n", "add foo")
+	second := s.commit(c, "bar", "bar\n", "add bar\n\nwith a body")
+
+	c.Assert(s.git(c, "log", "--oneline"), Equals, ""+
+		second[:7]+" add bar\n"+
+		first[:7]+" add foo\n")
+
+	out := s.git(c, "log", "-n", "1")
+	c.Assert(out, Matches, "commit "+second+"\n"+
+		"Author: Foo <foo@example.local>\n"+
+		"Date:   .*\n"+
+		"\n"+
+		"    add bar\n"+
+		"    \n"+
+		"    with a body\n")
+
+	c.Assert(s.git(c, "log", "--oneline", "HEAD", "foo"), Equals, first[:7]+" add foo\n")
+	c.Assert(s.git(c, "log", "--oneline", "HEAD", ":!foo"), Equals, second[:7]+" add bar\n")
+}
+
+func (s *CLISuite) TestStatus(c *C) {
+	c.Assert(s.git(c, "status"), Equals, ""+
+		"On branch master\n"+
+		"\n"+
+		"No commits yet\n"+
+		"nothing to commit, working tree clean\n")
+
+	s.commit(c, "foo", "foo\n", "add foo")
+	s.commit(c, "bar", "bar\n", "add bar")
+	s.write(c, "foo", "qux\n")
+	s.write(c, "qux", "qux\n")
+	c.Assert(os.Remove(filepath.Join(s.dir, "bar")), IsNil)
+
+	c.Assert(s.git(c, "status", "-s"), Equals, " D bar\n M foo\n?? qux\n")
+	c.Assert(s.git(c, "status"), Equals, ""+
+		"On branch master\n"+
+		"Changes not staged for commit:\n"+
+		"\tdeleted:    bar\n"+
+		"\tmodified:   foo\n"+
+		"\n"+
+		"Untracked files:\n"+
+		"\tqux\n"+
+		"\n"+
+		"no changes added to commit\n")
+
+	out := s.git(c, "commit", "-a", "-m", "update")
+	c.Assert(out, Matches, `\[master [0-9a-f]{7}\] update\n`)
+	c.Assert(s.git(c, "status", "--porcelain"), Equals, "?? qux\n")
+}
+
+func (s *CLISuite) TestCommit(c *C) {
+	s.write(c, "foo", "foo\n")
+	r, err := git.PlainOpen(s.dir)
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	out := s.git(c, "commit", "-m", "add foo", "-m", "body")
+	c.Assert(out, Matches, `\[master \(root-commit\) [0-9a-f]{7}\] add foo\n`)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "add foo\n\nbody\n")
+	c.Assert(commit.Author.Email, Equals, "foo@example.local")
+
+	s.git(c, "commit", "--amend", "-m", "amended")
+	commit, err = r.CommitObject(plumbing.NewHash(s.revParse(c, "HEAD")))
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "amended\n")
+	c.Assert(commit.NumParents(), Equals, 0)
+}
+
+func (s *CLISuite) TestCheckoutAndBranch(c *C) {
+	first := s.commit(c, "foo", "foo\n", "add foo")
+	s.git(c, "checkout", "-b", "feature")
+	second := s.commit(c, "bar", "bar\n", "add bar")
+
+	c.Assert(s.git(c, "branch"), Equals, "* feature\n  master\n")
+	c.Assert(s.git(c, "rev-parse", "--abbrev-ref", "HEAD"), Equals, "feature\n")
+
+	s.git(c, "checkout", "master")
+	c.Assert(s.revParse(c, "HEAD"), Equals, first)
+	_, err := os.Stat(filepath.Join(s.dir, "bar"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = s.run(c, "branch", "-C", s.dir, "-d", "feature")
+	c.Assert(err, ErrorMatches, ".*not fully merged.*")
+
+	s.git(c, "branch", "old", first)
+	c.Assert(s.git(c, "branch"), Equals, "  feature\n* master\n  old\n")
+	c.Assert(s.git(c, "branch", "-d", "old"), Equals, "Deleted branch old (was "+first[:7]+").\n")
+	c.Assert(s.git(c, "branch", "-D", "feature"), Equals, "Deleted branch feature (was "+second[:7]+").\n")
+
+	s.git(c, "checkout", first)
+	c.Assert(s.git(c, "rev-parse", "--abbrev-ref", "HEAD"), Equals, "HEAD\n")
+	c.Assert(s.git(c, "status"), Equals, "HEAD detached at "+first[:7]+"\n"+
+		"nothing to commit, working tree clean\n")
+}
+
+func (s *CLISuite) TestTagAndShowRef(c *C) {
+	hash := s.commit(c, "foo", "foo\n", "add foo")
+	s.git(c, "tag", "v1.0.0")
+	s.git(c, "tag", "-a", "-m", "release", "v2.0.0")
+
+	c.Assert(s.git(c, "tag"), Equals, "v1.0.0\nv2.0.0\n")
+
+	annotated := s.revParse(c, "v2.0.0")
+	c.Assert(annotated, Not(Equals), hash)
+	c.Assert(s.git(c, "cat-file", "-t", "v2.0.0"), Equals, "tag\n")
+
+	c.Assert(s.git(c, "show-ref"), Equals, ""+
+		hash+" refs/heads/master\n"+
+		hash+" refs/tags/v1.0.0\n"+
+		annotated+" refs/tags/v2.0.0\n")
+	c.Assert(s.git(c, "show-ref", "--heads"), Equals, hash+" refs/heads/master\n")
+	c.Assert(s.git(c, "show-ref", "-s", "v1.0.0"), Equals, hash+"\n")
+
+	c.Assert(s.git(c, "tag", "-d", "v1.0.0"), Equals, "Deleted tag 'v1.0.0' (was "+hash[:7]+")\n")
+	_, err := s.run(c, "show-ref", "-C", s.dir, "v1.0.0")
+	c.Assert(err, NotNil)
+}
+
+func (s *CLISuite) TestCatFile(c *C) {
+	hash := s.commit(c, "foo", "foo\n", "add foo")
+
+	c.Assert(s.git(c, "cat-file", "-t", hash), Equals, "commit\n")
+	c.Assert(s.git(c, "cat-file", "-p", "HEAD"), Matches, "tree [0-9a-f]{40}\n(?s).*\n\nadd foo\n")
+
+	r, err := git.PlainOpen(s.dir)
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(plumbing.NewHash(hash))
+	c.Assert(err, IsNil)
+	file, err := commit.File("foo")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.git(c, "cat-file", "-p", commit.TreeHash.String()), Equals,
+		"100644 blob "+file.Hash.String()+"\tfoo\n")
+	c.Assert(s.git(c, "cat-file", "blob", file.Hash.String()), Equals, "foo\n")
+	c.Assert(s.git(c, "cat-file", "-s", file.Hash.String()), Equals, "4\n")
+	c.Assert(s.git(c, "rev-parse", "--short", "HEAD"), Equals, hash[:7]+"\n")
+
+	_, err = s.run(c, "cat-file", "-C", s.dir, "-e", "0123456789012345678901234567890123456789")
+	c.Assert(err, NotNil)
+}
+
+func (s *CLISuite) TestCloneFetchPush(c *C) {
+	hash := s.commit(c, "foo", "foo\n", "add foo")
+
+	clone := filepath.Join(c.MkDir(), "clone")
+	_, err := s.run(c, "clone", s.dir, clone)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(clone, "foo"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo\n")
+
+	out, err := s.run(c, "ls-remote", s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, hash+"\tHEAD\n"+hash+"\trefs/heads/master\n")
+
+	out, err = s.run(c, "ls-remote", "-C", clone, "--heads")
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, hash+"\trefs/heads/master\n")
+
+	second := s.commit(c, "bar", "bar\n", "add bar")
+	_, err = s.run(c, "fetch", "-C", clone)
+	c.Assert(err, IsNil)
+
+	out, err = s.run(c, "rev-parse", "-C", clone, "refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, second+"\n")
+
+	bare := c.MkDir()
+	_, err = git.PlainInit(bare, true)
+	c.Assert(err, IsNil)
+
+	_, err = s.run(c, "push", "-C", s.dir, bare, "master", "HEAD:refs/heads/other")
+	c.Assert(err, IsNil)
+
+	out, err = s.run(c, "show-ref", "-C", bare)
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, second+" refs/heads/master\n"+second+" refs/heads/other\n")
+}
+
+// writeConfig writes the config of the repository of the test, required to
+// serve it.
+func (s *CLISuite) writeConfig(c *C) {
+	r, err := git.PlainOpen(s.dir)
+	c.Assert(err, IsNil)
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+}
+
+func (s *CLISuite) TestUploadPackAdvertiseRefs(c *C) {
+	hash := s.commit(c, "foo", "foo\n", "add foo")
+	s.writeConfig(c)
+
+	out, err := s.run(c, "upload-pack", "--advertise-refs", s.dir)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(out, hash+" HEAD\x00"), Equals, true)
+	c.Assert(strings.Contains(out, hash+" refs/heads/master\n"), Equals, true)
+	c.Assert(strings.HasSuffix(out, "0000"), Equals, true)
+
+	c.Assert(exitCode([]string{"upload-pack"}, &flags.Error{}), Equals, 129)
+	c.Assert(exitCode([]string{"log"}, &flags.Error{}), Equals, 1)
+}
+
+func (s *CLISuite) TestHTTPBackend(c *C) {
+	hash := s.commit(c, "foo", "foo\n", "add foo")
+	s.writeConfig(c)
+
+	env := map[string]string{
+		"GIT_PROJECT_ROOT": filepath.Dir(s.dir),
+		"REQUEST_METHOD":   "GET",
+		"PATH_INFO":        "/" + filepath.Base(s.dir) + "/info/refs",
+		"QUERY_STRING":     "service=git-upload-pack",
+	}
+
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	out, err := s.run(c, "http-backend")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, "Status: 404 Not Found\r\n(?s).*")
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, ".git", "git-daemon-export-ok"), nil, 0644), IsNil)
+	out, err = s.run(c, "http-backend")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, "Status: 200 OK\r\n(?s).*")
+	c.Assert(strings.Contains(out, "Content-Type: application/x-git-upload-pack-advertisement\r\n\r\n"+
+		"001e# service=git-upload-pack\n0000"), Equals, true)
+	c.Assert(strings.Contains(out, hash+" refs/heads/master\n"), Equals, true)
+
+	os.Setenv("QUERY_STRING", "service=git-receive-pack")
+	out, err = s.run(c, "http-backend")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, "Status: 403 Forbidden\r\n(?s).*")
+
+	os.Setenv("REMOTE_USER", "foo")
+	defer os.Unsetenv("REMOTE_USER")
+	out, err = s.run(c, "http-backend")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, "Status: 200 OK\r\n(?s).*")
+
+	os.Setenv("REQUEST_METHOD", "POST")
+	os.Setenv("PATH_INFO", "/"+filepath.Base(s.dir)+"/git-upload-pack")
+	out, err = s.run(c, "http-backend")
+	c.Assert(err, IsNil)
+	c.Assert(out, Matches, "Status: 415 Unsupported Media Type\r\n(?s).*")
+}
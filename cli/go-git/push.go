@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdPush struct {
+	repositoryCmd
+
+	Force    bool `short:"f" long:"force" description:"Update the remote references even if they aren't fast-forwards"`
+	Prune    bool `long:"prune" description:"Remove the remote references without a local counterpart"`
+	NoVerify bool `long:"no-verify" description:"Bypass the pre-push hook"`
+
+	Args struct {
+		Remote   string   `positional-arg-name:"repository"`
+		RefSpecs []string `positional-arg-name:"refspec"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdPush) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	var refspecs []config.RefSpec
+	for _, arg := range c.Args.RefSpecs {
+		rs := pushRefSpec(r, string(arg))
+		if c.Force && !rs.IsForceUpdate() {
+			rs = "+" + rs
+		}
+
+		refspecs = append(refspecs, rs)
+	}
+
+	remote, err := pushRemote(r, c.Args.Remote)
+	if err != nil {
+		return err
+	}
+
+	err = remote.Push(&git.PushOptions{
+		RemoteName: remote.Config().Name,
+		RefSpecs:   refspecs,
+		Prune:      c.Prune,
+		Progress:   c.progress(),
+		NoHooks:    c.NoVerify,
+	})
+
+	if err == git.NoErrAlreadyUpToDate {
+		fmt.Fprintln(stderr, "Everything up-to-date")
+		return nil
+	}
+
+	return err
+}
+
+// pushRemote returns the remote with the given name, origin by default, or an
+// anonymous remote if there isn't one with that name, taking it as an URL.
+func pushRemote(r *git.Repository, name string) (*git.Remote, error) {
+	if name == "" {
+		name = git.DefaultRemoteName
+	}
+
+	remote, err := r.Remote(name)
+	if err != git.ErrRemoteNotFound || name == git.DefaultRemoteName {
+		return remote, err
+	}
+
+	return r.CreateRemoteAnonymous(&config.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{name},
+	})
+}
+
+// pushRefSpec expands the short forms of the refspecs accepted by git push,
+// such as "master" or "v1.0:release", to full refspecs.
+func pushRefSpec(r *git.Repository, spec string) config.RefSpec {
+	var force string
+	if strings.HasPrefix(spec, "+") {
+		force, spec = "+", spec[1:]
+	}
+
+	src, dst := spec, spec
+	if i := strings.Index(spec, ":"); i >= 0 {
+		src, dst = spec[:i], spec[i+1:]
+	}
+
+	if src != "" {
+		src = expandReferenceName(r, src).String()
+	}
+
+	if dst == "HEAD" {
+		dst = src
+	}
+
+	if !strings.HasPrefix(dst, "refs/") {
+		prefix := "refs/heads/"
+		if strings.HasPrefix(src, "refs/tags/") {
+			prefix = "refs/tags/"
+		}
+
+		dst = prefix + dst
+	}
+
+	return config.RefSpec(force + src + ":" + dst)
+}
+
+// expandReferenceName returns the full name of the local reference with the
+// given short name, looking for a branch first and then for a tag.
+func expandReferenceName(r *git.Repository, name string) plumbing.ReferenceName {
+	if name == "HEAD" {
+		head, err := r.Reference(plumbing.HEAD, false)
+		if err == nil && head.Type() == plumbing.SymbolicReference {
+			return head.Target()
+		}
+	}
+
+	if strings.HasPrefix(name, "refs/") || name == "HEAD" {
+		return plumbing.ReferenceName(name)
+	}
+
+	for _, full := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(name),
+		plumbing.NewTagReferenceName(name),
+	} {
+		if _, err := r.Reference(full, false); err == nil {
+			return full
+		}
+	}
+
+	return plumbing.NewBranchReferenceName(name)
+}
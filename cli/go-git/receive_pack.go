@@ -11,14 +11,16 @@ import (
 type CmdReceivePack struct {
 	cmd
 
+	StatelessRPC  bool `long:"stateless-rpc" description:"Serve a single request without advertising the references first."`
+	AdvertiseRefs bool `long:"advertise-refs" description:"Only advertise the references and exit."`
+
 	Args struct {
 		GitDir string `positional-arg-name:"git-dir" required:"true"`
 	} `positional-args:"yes"`
 }
 
 func (CmdReceivePack) Usage() string {
-	//TODO: git-receive-pack returns error code 129 if arguments are invalid.
-	return fmt.Sprintf("usage: %s <git-dir>", os.Args[0])
+	return fmt.Sprintf("usage: %s [--stateless-rpc] [--advertise-refs] <git-dir>", os.Args[0])
 }
 
 func (c *CmdReceivePack) Execute(args []string) error {
@@ -27,8 +29,14 @@ func (c *CmdReceivePack) Execute(args []string) error {
 		return err
 	}
 
-	if err := file.ServeReceivePack(gitDir); err != nil {
-		fmt.Fprintln(os.Stderr, "ERR:", err)
+	err = file.ServeReceivePackWithOptions(gitDir, &file.ServeOptions{
+		StatelessRPC:  c.StatelessRPC,
+		AdvertiseRefs: c.AdvertiseRefs,
+		Stdin:         stdin,
+		Stdout:        stdout,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
 		os.Exit(128)
 	}
 
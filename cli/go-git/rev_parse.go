@@ -0,0 +1,56 @@
+package main
+
+import "fmt"
+
+type CmdRevParse struct {
+	repositoryCmd
+
+	AbbrevRef    bool `long:"abbrev-ref" description:"Print the short name of the references"`
+	Short        bool `long:"short" description:"Print the abbreviated hashes"`
+	ShowToplevel bool `long:"show-toplevel" description:"Print the path of the top-level directory of the worktree"`
+
+	Args struct {
+		Revisions []string `positional-arg-name:"revision"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdRevParse) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	if c.ShowToplevel {
+		w, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(stdout, w.Filesystem.Root())
+	}
+
+	for _, rev := range c.Args.Revisions {
+		if c.AbbrevRef {
+			name := expandReferenceName(r, rev)
+			if _, err := r.Reference(name, false); err != nil {
+				return fmt.Errorf("ambiguous argument '%s': unknown revision", rev)
+			}
+
+			fmt.Fprintln(stdout, name.Short())
+			continue
+		}
+
+		hash, err := objectHash(r, rev)
+		if err != nil {
+			return fmt.Errorf("ambiguous argument '%s': %s", rev, err)
+		}
+
+		if c.Short {
+			fmt.Fprintln(stdout, shortHash(hash))
+		} else {
+			fmt.Fprintln(stdout, hash)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdShowRef struct {
+	repositoryCmd
+
+	Heads bool `long:"heads" description:"Limit to the branches"`
+	Tags  bool `long:"tags" description:"Limit to the tags"`
+	Hash  bool `short:"s" long:"hash" description:"Only show the hashes"`
+
+	Args struct {
+		Patterns []string `positional-arg-name:"pattern"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdShowRef) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	iter, err := r.References()
+	if err != nil {
+		return err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if ref.Type() != plumbing.HashReference || name == plumbing.HEAD {
+			return nil
+		}
+
+		if (c.Heads || c.Tags) && !(c.Heads && name.IsBranch() || c.Tags && name.IsTag()) {
+			return nil
+		}
+
+		if matchReference(name, c.Args.Patterns) {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(refs) == 0 {
+		return fmt.Errorf("no matching references")
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name() < refs[j].Name() })
+	for _, ref := range refs {
+		if c.Hash {
+			fmt.Fprintln(stdout, ref.Hash())
+		} else {
+			fmt.Fprintf(stdout, "%s %s\n", ref.Hash(), ref.Name())
+		}
+	}
+
+	return nil
+}
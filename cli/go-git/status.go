@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdStatus struct {
+	repositoryCmd
+
+	Short     bool `short:"s" long:"short" description:"Give the output in the short format"`
+	Porcelain bool `long:"porcelain" description:"Give the output in an easy-to-parse format for scripts"`
+
+	Args struct {
+		PathSpecs []string `positional-arg-name:"pathspec"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdStatus) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	status, err := w.StatusWithOptions(&git.StatusOptions{PathSpecs: c.Args.PathSpecs})
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path, s := range status {
+		if s.Staging != git.Unmodified || s.Worktree != git.Unmodified {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	if c.Short || c.Porcelain {
+		for _, path := range paths {
+			s := status[path]
+			fmt.Fprintf(stdout, "%c%c %s\n", s.Staging, s.Worktree, path)
+		}
+
+		return nil
+	}
+
+	if err := printBranch(r); err != nil {
+		return err
+	}
+
+	var staged, unstaged, untracked []string
+	for _, path := range paths {
+		s := status[path]
+		if s.Worktree == git.Untracked {
+			untracked = append(untracked, path)
+			continue
+		}
+
+		if s.Staging != git.Unmodified {
+			staged = append(staged, fmt.Sprintf("%-12s%s", statusLabel(s.Staging), path))
+		}
+
+		if s.Worktree != git.Unmodified {
+			unstaged = append(unstaged, fmt.Sprintf("%-12s%s", statusLabel(s.Worktree), path))
+		}
+	}
+
+	printSection("Changes to be committed:", staged)
+	printSection("Changes not staged for commit:", unstaged)
+	printSection("Untracked files:", untracked)
+
+	switch {
+	case len(staged) != 0:
+	case len(unstaged) != 0:
+		fmt.Fprintln(stdout, "no changes added to commit")
+	case len(untracked) != 0:
+		fmt.Fprintln(stdout, "nothing added to commit but untracked files present")
+	default:
+		fmt.Fprintln(stdout, "nothing to commit, working tree clean")
+	}
+
+	return nil
+}
+
+// printBranch prints the branch of HEAD, as the first line of git status.
+func printBranch(r *git.Repository) error {
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return err
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		fmt.Fprintf(stdout, "On branch %s\n", head.Target().Short())
+		if _, err := r.Reference(head.Target(), false); err == plumbing.ErrReferenceNotFound {
+			fmt.Fprintf(stdout, "\nNo commits yet\n")
+		}
+	} else {
+		fmt.Fprintf(stdout, "HEAD detached at %s\n", shortHash(head.Hash()))
+	}
+
+	return nil
+}
+
+func printSection(title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Fprintln(stdout, title)
+	for _, line := range lines {
+		fmt.Fprintf(stdout, "\t%s\n", line)
+	}
+
+	fmt.Fprintln(stdout)
+}
+
+func statusLabel(code git.StatusCode) string {
+	switch code {
+	case git.Added:
+		return "new file:"
+	case git.Modified:
+		return "modified:"
+	case git.Deleted:
+		return "deleted:"
+	case git.Renamed:
+		return "renamed:"
+	case git.Copied:
+		return "copied:"
+	case git.UpdatedButUnmerged:
+		return "unmerged:"
+	}
+
+	return string(code)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+type CmdTag struct {
+	repositoryCmd
+
+	Annotate bool   `short:"a" long:"annotate" description:"Create an annotated tag"`
+	Message  string `short:"m" long:"message" description:"Use the given message, creating an annotated tag"`
+	Delete   bool   `short:"d" long:"delete" description:"Delete the tag"`
+
+	Args struct {
+		Name   string `positional-arg-name:"tagname"`
+		Commit string `positional-arg-name:"commit"`
+	} `positional-args:"yes"`
+}
+
+func (c *CmdTag) Execute(args []string) error {
+	r, err := c.repository()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c.Delete:
+		return c.delete(r)
+	case c.Args.Name != "":
+		return c.create(r)
+	}
+
+	return c.list(r)
+}
+
+func (c *CmdTag) list(r *git.Repository) error {
+	tags, err := r.Tags()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintln(stdout, name)
+	}
+
+	return nil
+}
+
+func (c *CmdTag) create(r *git.Repository) error {
+	target := c.Args.Commit
+	if target == "" {
+		target = "HEAD"
+	}
+
+	commit, err := resolveCommit(r, target)
+	if err != nil {
+		return err
+	}
+
+	var o *git.CreateTagOptions
+	if c.Annotate || c.Message != "" {
+		if c.Message == "" {
+			return fmt.Errorf("annotated tags require a message, use -m")
+		}
+
+		tagger, err := signature(r, "GIT_COMMITTER")
+		if err != nil {
+			return err
+		}
+
+		o = &git.CreateTagOptions{Tagger: tagger, Message: c.Message}
+	}
+
+	_, err = r.CreateTag(c.Args.Name, commit.Hash, o)
+	return err
+}
+
+func (c *CmdTag) delete(r *git.Repository) error {
+	if c.Args.Name == "" {
+		return fmt.Errorf("tag name required")
+	}
+
+	ref, err := r.Tag(c.Args.Name)
+	if err != nil {
+		return fmt.Errorf("tag '%s' not found", c.Args.Name)
+	}
+
+	if err := r.DeleteTag(c.Args.Name); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Deleted tag '%s' (was %s)\n", c.Args.Name, shortHash(ref.Hash()))
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/file"
 )
@@ -11,15 +12,18 @@ import (
 type CmdUploadPack struct {
 	cmd
 
+	Strict        bool `long:"strict" description:"Do not try <dir>/.git/ if <dir> is not a git directory."`
+	Timeout       uint `long:"timeout" description:"Interrupt transfer after <n> seconds of inactivity." value-name:"n"`
+	StatelessRPC  bool `long:"stateless-rpc" description:"Serve a single request without advertising the references first."`
+	AdvertiseRefs bool `long:"advertise-refs" description:"Only advertise the references and exit."`
+
 	Args struct {
 		GitDir string `positional-arg-name:"git-dir" required:"true"`
 	} `positional-args:"yes"`
 }
 
 func (CmdUploadPack) Usage() string {
-	//TODO: usage: git upload-pack [--strict] [--timeout=<n>] <dir>
-	//TODO: git-upload-pack returns error code 129 if arguments are invalid.
-	return fmt.Sprintf("usage: %s <git-dir>", os.Args[0])
+	return fmt.Sprintf("usage: %s [--strict] [--timeout=<n>] [--stateless-rpc] [--advertise-refs] <dir>", os.Args[0])
 }
 
 func (c *CmdUploadPack) Execute(args []string) error {
@@ -28,8 +32,16 @@ func (c *CmdUploadPack) Execute(args []string) error {
 		return err
 	}
 
-	if err := file.ServeUploadPack(gitDir); err != nil {
-		fmt.Fprintln(os.Stderr, "ERR:", err)
+	err = file.ServeUploadPackWithOptions(gitDir, &file.ServeOptions{
+		Strict:        c.Strict,
+		Timeout:       time.Duration(c.Timeout) * time.Second,
+		StatelessRPC:  c.StatelessRPC,
+		AdvertiseRefs: c.AdvertiseRefs,
+		Stdin:         stdin,
+		Stdout:        stdout,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, "ERR:", err)
 		os.Exit(128)
 	}
 
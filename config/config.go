@@ -19,6 +19,18 @@ const (
 	DefaultPushRefSpec = "refs/heads/*:refs/heads/*"
 )
 
+// RepositoryFormatVersion is the version of the format of a repository, as
+// described at https://git-scm.com/docs/repository-version.
+type RepositoryFormatVersion string
+
+const (
+	// Version0 is the original format of the repositories.
+	Version0 RepositoryFormatVersion = "0"
+	// Version1 is the format of the repositories using extensions, all the
+	// extensions.* options must be understood to use them.
+	Version1 RepositoryFormatVersion = "1"
+)
+
 // ConfigStorer generic storage of Config object
 type ConfigStorer interface {
 	Config() (*Config, error)
@@ -36,6 +48,9 @@ var (
 // https://www.kernel.org/pub/software/scm/git/docs/git-config.html#FILES
 type Config struct {
 	Core struct {
+		// RepositoryFormatVersion is the version of the format of the
+		// repository, empty if not set, which means Version0.
+		RepositoryFormatVersion RepositoryFormatVersion
 		// IsBare if true this repository is assumed to be bare and has no
 		// working directory associated with it.
 		IsBare bool
@@ -44,6 +59,29 @@ type Config struct {
 		// CommentChar is the character indicating the start of a
 		// comment for commands like commit and tag
 		CommentChar string
+		// HooksPath is the directory of the hooks, by default the hooks
+		// directory of the repository. A relative path is taken as relative
+		// to the directory where the hooks are run.
+		HooksPath string
+	}
+
+	// Extensions are the extensions.* options, which the implementations of
+	// the repository format Version1 must understand.
+	Extensions struct {
+		// ObjectFormat is the hash algorithm of the objects, empty if not
+		// set, which means sha1.
+		ObjectFormat string
+	}
+
+	// Init are the init.* options, used creating the repositories, usually
+	// set in the global config.
+	Init struct {
+		// DefaultBranch is the name of the branch HEAD points to in the new
+		// repositories.
+		DefaultBranch string
+		// TemplateDir is the directory whose files are copied in the new
+		// repositories.
+		TemplateDir string
 	}
 
 	Pack struct {
@@ -108,19 +146,26 @@ func (c *Config) Validate() error {
 }
 
 const (
-	remoteSection    = "remote"
-	submoduleSection = "submodule"
-	branchSection    = "branch"
-	coreSection      = "core"
-	packSection      = "pack"
-	fetchKey         = "fetch"
-	urlKey           = "url"
-	bareKey          = "bare"
-	worktreeKey      = "worktree"
-	commentCharKey   = "commentChar"
-	windowKey        = "window"
-	mergeKey         = "merge"
-	rebaseKey        = "rebase"
+	remoteSection              = "remote"
+	submoduleSection           = "submodule"
+	branchSection              = "branch"
+	coreSection                = "core"
+	packSection                = "pack"
+	extensionsSection          = "extensions"
+	initSection                = "init"
+	fetchKey                   = "fetch"
+	urlKey                     = "url"
+	bareKey                    = "bare"
+	repositoryFormatVersionKey = "repositoryformatversion"
+	objectFormatKey            = "objectformat"
+	defaultBranchKey           = "defaultBranch"
+	templateDirKey             = "templateDir"
+	worktreeKey                = "worktree"
+	commentCharKey             = "commentChar"
+	hooksPathKey               = "hooksPath"
+	windowKey                  = "window"
+	mergeKey                   = "merge"
+	rebaseKey                  = "rebase"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -138,6 +183,8 @@ func (c *Config) Unmarshal(b []byte) error {
 	}
 
 	c.unmarshalCore()
+	c.unmarshalExtensions()
+	c.unmarshalInit()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -156,8 +203,21 @@ func (c *Config) unmarshalCore() {
 		c.Core.IsBare = true
 	}
 
+	c.Core.RepositoryFormatVersion = RepositoryFormatVersion(s.Options.Get(repositoryFormatVersionKey))
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
+	c.Core.HooksPath = s.Options.Get(hooksPathKey)
+}
+
+func (c *Config) unmarshalExtensions() {
+	s := c.Raw.Section(extensionsSection)
+	c.Extensions.ObjectFormat = s.Options.Get(objectFormatKey)
+}
+
+func (c *Config) unmarshalInit() {
+	s := c.Raw.Section(initSection)
+	c.Init.DefaultBranch = s.Options.Get(defaultBranchKey)
+	c.Init.TemplateDir = s.Options.Get(templateDirKey)
 }
 
 func (c *Config) unmarshalPack() error {
@@ -220,6 +280,8 @@ func (c *Config) unmarshalBranches() error {
 // Marshal returns Config encoded as a git-config file.
 func (c *Config) Marshal() ([]byte, error) {
 	c.marshalCore()
+	c.marshalExtensions()
+	c.marshalInit()
 	c.marshalPack()
 	c.marshalRemotes()
 	c.marshalSubmodules()
@@ -235,11 +297,36 @@ func (c *Config) Marshal() ([]byte, error) {
 
 func (c *Config) marshalCore() {
 	s := c.Raw.Section(coreSection)
+	if c.Core.RepositoryFormatVersion != "" {
+		s.SetOption(repositoryFormatVersionKey, string(c.Core.RepositoryFormatVersion))
+	}
+
 	s.SetOption(bareKey, fmt.Sprintf("%t", c.Core.IsBare))
 
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.HooksPath != "" {
+		s.SetOption(hooksPathKey, c.Core.HooksPath)
+	}
+}
+
+func (c *Config) marshalExtensions() {
+	if c.Extensions.ObjectFormat != "" {
+		s := c.Raw.Section(extensionsSection)
+		s.SetOption(objectFormatKey, c.Extensions.ObjectFormat)
+	}
+}
+
+func (c *Config) marshalInit() {
+	if c.Init.DefaultBranch != "" {
+		c.Raw.Section(initSection).SetOption(defaultBranchKey, c.Init.DefaultBranch)
+	}
+
+	if c.Init.TemplateDir != "" {
+		c.Raw.Section(initSection).SetOption(templateDirKey, c.Init.TemplateDir)
+	}
 }
 
 func (c *Config) marshalPack() {
@@ -11,9 +11,16 @@ var _ = Suite(&ConfigSuite{})
 
 func (s *ConfigSuite) TestUnmarshal(c *C) {
 	input := []byte(`[core]
+        repositoryformatversion = 1
         bare = true
 		worktree = foo
 		commentchar = bar
+		hooksPath = hooks
+[extensions]
+		objectFormat = sha256
+[init]
+		defaultBranch = main
+		templateDir = /usr/share/templates
 [pack]
 		window = 20
 [remote "origin"]
@@ -39,9 +46,14 @@ func (s *ConfigSuite) TestUnmarshal(c *C) {
 	err := cfg.Unmarshal(input)
 	c.Assert(err, IsNil)
 
+	c.Assert(cfg.Core.RepositoryFormatVersion, Equals, Version1)
 	c.Assert(cfg.Core.IsBare, Equals, true)
 	c.Assert(cfg.Core.Worktree, Equals, "foo")
 	c.Assert(cfg.Core.CommentChar, Equals, "bar")
+	c.Assert(cfg.Core.HooksPath, Equals, "hooks")
+	c.Assert(cfg.Extensions.ObjectFormat, Equals, "sha256")
+	c.Assert(cfg.Init.DefaultBranch, Equals, "main")
+	c.Assert(cfg.Init.TemplateDir, Equals, "/usr/share/templates")
 	c.Assert(cfg.Pack.Window, Equals, uint(20))
 	c.Assert(cfg.Remotes, HasLen, 3)
 	c.Assert(cfg.Remotes["origin"].Name, Equals, "origin")
@@ -62,8 +74,14 @@ func (s *ConfigSuite) TestUnmarshal(c *C) {
 
 func (s *ConfigSuite) TestMarshal(c *C) {
 	output := []byte(`[core]
+	repositoryformatversion = 1
 	bare = true
 	worktree = bar
+	hooksPath = hooks
+[extensions]
+	objectformat = sha256
+[init]
+	defaultBranch = main
 [pack]
 	window = 20
 [remote "alt"]
@@ -83,8 +101,12 @@ func (s *ConfigSuite) TestMarshal(c *C) {
 `)
 
 	cfg := NewConfig()
+	cfg.Core.RepositoryFormatVersion = Version1
 	cfg.Core.IsBare = true
 	cfg.Core.Worktree = "bar"
+	cfg.Core.HooksPath = "hooks"
+	cfg.Extensions.ObjectFormat = "sha256"
+	cfg.Init.DefaultBranch = "main"
 	cfg.Pack.Window = 20
 	cfg.Remotes["origin"] = &RemoteConfig{
 		Name: "origin",
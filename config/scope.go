@@ -0,0 +1,69 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Scope is the scope of a config file.
+type Scope int
+
+const (
+	// LocalScope is the config of a repository.
+	LocalScope Scope = iota
+	// GlobalScope is the config of the user, in ~/.gitconfig or
+	// $XDG_CONFIG_HOME/git/config.
+	GlobalScope
+	// SystemScope is the config of the system, in /etc/gitconfig.
+	SystemScope
+)
+
+// Paths returns the paths of the config files of the given scope, GlobalScope
+// or SystemScope, in the order git reads them.
+func Paths(scope Scope) []string {
+	var files []string
+	switch scope {
+	case GlobalScope:
+		home, _ := os.UserHomeDir()
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			files = append(files, filepath.Join(xdg, "git", "config"))
+		} else if home != "" {
+			files = append(files, filepath.Join(home, ".config", "git", "config"))
+		}
+
+		if home != "" {
+			files = append(files, filepath.Join(home, ".gitconfig"))
+		}
+	case SystemScope:
+		files = append(files, "/etc/gitconfig")
+	}
+
+	return files
+}
+
+// LoadConfig loads the config of the given scope, GlobalScope or SystemScope,
+// merging its files as git does, the options of the last file taking
+// precedence. An empty Config is returned if there is no file.
+func LoadConfig(scope Scope) (*Config, error) {
+	var content []byte
+	for _, file := range Paths(scope) {
+		b, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		content = append(append(content, b...), '\n')
+	}
+
+	cfg := NewConfig()
+	if err := cfg.Unmarshal(content); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ScopeSuite struct {
+	home, xdg string
+}
+
+var _ = Suite(&ScopeSuite{})
+
+func (s *ScopeSuite) SetUpTest(c *C) {
+	s.home, s.xdg = os.Getenv("HOME"), os.Getenv("XDG_CONFIG_HOME")
+}
+
+func (s *ScopeSuite) TearDownTest(c *C) {
+	os.Setenv("HOME", s.home)
+	os.Setenv("XDG_CONFIG_HOME", s.xdg)
+}
+
+func (s *ScopeSuite) TestPaths(c *C) {
+	os.Setenv("HOME", "/home/foo")
+	os.Setenv("XDG_CONFIG_HOME", "")
+	c.Assert(Paths(GlobalScope), DeepEquals, []string{
+		filepath.Join("/home/foo", ".config", "git", "config"),
+		filepath.Join("/home/foo", ".gitconfig"),
+	})
+
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	c.Assert(Paths(GlobalScope), DeepEquals, []string{
+		filepath.Join("/xdg", "git", "config"),
+		filepath.Join("/home/foo", ".gitconfig"),
+	})
+
+	c.Assert(Paths(SystemScope), DeepEquals, []string{"/etc/gitconfig"})
+	c.Assert(Paths(LocalScope), HasLen, 0)
+}
+
+func (s *ScopeSuite) TestLoadConfig(c *C) {
+	home := c.MkDir()
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	cfg, err := LoadConfig(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Init.DefaultBranch, Equals, "")
+
+	c.Assert(os.MkdirAll(filepath.Join(home, ".config", "git"), 0755), IsNil)
+	err = ioutil.WriteFile(filepath.Join(home, ".config", "git", "config"),
+		[]byte("[init]\n\tdefaultBranch = main\n\ttemplateDir = /templates\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = ioutil.WriteFile(filepath.Join(home, ".gitconfig"),
+		[]byte("[init]\n\tdefaultBranch = trunk\n"), 0644)
+	c.Assert(err, IsNil)
+
+	cfg, err = LoadConfig(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Init.DefaultBranch, Equals, "trunk")
+	c.Assert(cfg.Init.TemplateDir, Equals, "/templates")
+}
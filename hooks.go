@@ -0,0 +1,402 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// Hooks run by the porcelain operations, as described at
+// https://git-scm.com/docs/githooks.
+const (
+	// HookPreCommit is run by Worktree.Commit before creating the commit,
+	// without arguments. It aborts the commit if it fails.
+	HookPreCommit = "pre-commit"
+	// HookCommitMsg is run by Worktree.Commit with the path of a file
+	// holding the commit message, which can be edited by the hook. It aborts
+	// the commit if it fails.
+	HookCommitMsg = "commit-msg"
+	// HookPostCheckout is run by Worktree.Checkout with the previous HEAD,
+	// the new HEAD and a flag, 1 when switching branches and 0 when checking
+	// out files.
+	HookPostCheckout = "post-checkout"
+	// HookPostMerge is run by Worktree.Pull after updating the worktree, with
+	// a flag telling if the merge was a squash, always 0.
+	HookPostMerge = "post-merge"
+	// HookPrePush is run by Remote.Push with the name and the URL of the
+	// remote, and a line per reference to update on the standard input, as
+	// "<local ref> <local hash> <remote ref> <remote hash>". It aborts the
+	// push if it fails.
+	HookPrePush = "pre-push"
+	// HookReferenceTransaction is run when the references are updated, with
+	// the state of the transaction, "prepared", "committed" or "aborted", and
+	// a line per reference on the standard input, as "<old> <new> <name>".
+	// It aborts the update if it fails in the "prepared" state.
+	HookReferenceTransaction = "reference-transaction"
+	// HookPostRewrite is run by Worktree.Commit when amending a commit, with
+	// the argument "amend" and the line "<old hash> <new hash>" on the
+	// standard input.
+	HookPostRewrite = "post-rewrite"
+)
+
+// HookFunc is a hook implemented in Go, registered with
+// Repository.RegisterHook. Returning an error has the same effect as a hook
+// script exiting with a non-zero status.
+type HookFunc func(h *Hook) error
+
+// Hook is an execution of a hook.
+type Hook struct {
+	// Name of the hook, such as HookPreCommit.
+	Name string
+	// Args are the arguments given to the hook.
+	Args []string
+	// Stdin is the standard input of the hook.
+	Stdin io.Reader
+	// Env are the environment variables given to the hook, besides the ones
+	// of the process, as "key=value".
+	Env []string
+}
+
+// HookError is returned when a hook aborts an operation.
+type HookError struct {
+	// Name of the failed hook.
+	Name string
+	// Output is the combined standard output and error of the hook, when it
+	// is a script.
+	Output []byte
+	// Err is the error returned by the hook.
+	Err error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook failed: %s", e.Name, e.Err)
+}
+
+// RegisterHook registers fn to be run as the hook with the given name, after
+// the hook script, if any. The registered hooks are run by the operations of
+// the Worktree and the Remotes of the repository.
+func (r *Repository) RegisterHook(name string, fn HookFunc) {
+	r.hooks.funcs[name] = append(r.hooks.funcs[name], fn)
+}
+
+// enabledHooks returns the hooks to be run by an operation, nil when they are
+// disabled.
+func (r *Repository) enabledHooks(noHooks bool) *hooks {
+	if noHooks {
+		return nil
+	}
+
+	return r.hooks
+}
+
+// setReferences sets the given references, running the reference-transaction
+// hook before and after updating them, unless noHooks is true.
+func (r *Repository) setReferences(noHooks bool, refs ...*plumbing.Reference) error {
+	h := r.enabledHooks(noHooks)
+	ok, err := h.has(HookReferenceTransaction)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		for _, ref := range refs {
+			if err := r.Storer.SetReference(ref); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	stdin := bytes.NewBuffer(nil)
+	for _, ref := range refs {
+		old := plumbing.ZeroHash.String()
+		current, err := r.Storer.Reference(ref.Name())
+		if err == nil {
+			old = referenceValue(current)
+		} else if err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		fmt.Fprintf(stdin, "%s %s %s\n", old, referenceValue(ref), ref.Name())
+	}
+
+	if err := h.run(HookReferenceTransaction, stdin.Bytes(), nil, "prepared"); err != nil {
+		_ = h.run(HookReferenceTransaction, stdin.Bytes(), nil, "aborted")
+		return err
+	}
+
+	for _, ref := range refs {
+		if err := r.Storer.SetReference(ref); err != nil {
+			_ = h.run(HookReferenceTransaction, stdin.Bytes(), nil, "aborted")
+			return err
+		}
+	}
+
+	// as git does, the exit status of the hook is ignored once the
+	// transaction is committed
+	_ = h.run(HookReferenceTransaction, stdin.Bytes(), nil, "committed")
+	return nil
+}
+
+func referenceValue(ref *plumbing.Reference) string {
+	if ref.Type() == plumbing.SymbolicReference {
+		return "ref:" + ref.Target().String()
+	}
+
+	return ref.Hash().String()
+}
+
+// prePushInput returns the standard input of the pre-push hook for the given
+// commands, looking for the local references pushed by them.
+func prePushInput(refspecs []config.RefSpec, localRefs []*plumbing.Reference, cmds []*packp.Command) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, cmd := range cmds {
+		local := "(delete)"
+		if cmd.Action() != packp.Delete {
+			local = cmd.New.String()
+			for _, ref := range localRefs {
+				if ref.Type() == plumbing.HashReference && ref.Hash() == cmd.New &&
+					pushesReference(refspecs, ref.Name(), cmd.Name) {
+					local = ref.Name().String()
+					break
+				}
+			}
+		}
+
+		fmt.Fprintf(buf, "%s %s %s %s\n", local, cmd.New, cmd.Name, cmd.Old)
+	}
+
+	return buf.Bytes()
+}
+
+func pushesReference(refspecs []config.RefSpec, local, remote plumbing.ReferenceName) bool {
+	for _, rs := range refspecs {
+		if !rs.IsDelete() && rs.Match(local) && rs.Dst(local) == remote {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hooks runs the hook scripts of a repository and the registered hook
+// functions. A nil *hooks runs nothing.
+type hooks struct {
+	s storage.Storer
+	// gitDir and workDir are the absolute paths of the repository and the
+	// worktree in the OS filesystem, empty if they are not stored in it.
+	gitDir  string
+	workDir string
+	funcs   map[string][]HookFunc
+}
+
+func newHooks(s storage.Storer, worktree billy.Filesystem) *hooks {
+	h := &hooks{s: s, funcs: make(map[string][]HookFunc)}
+	if fs, ok := s.(*filesystem.Storage); ok {
+		h.gitDir = osPath(fs.Filesystem())
+	}
+
+	if worktree != nil {
+		h.workDir = osPath(worktree)
+	}
+
+	return h
+}
+
+// osPath returns the absolute path of the root of fs in the OS filesystem, or
+// an empty string if fs isn't backed by it.
+func osPath(fs billy.Filesystem) string {
+	var basic billy.Basic = fs
+	for {
+		if _, ok := basic.(*osfs.OS); ok {
+			break
+		}
+
+		u, ok := basic.(interface{ Underlying() billy.Basic })
+		if !ok {
+			return ""
+		}
+
+		basic = u.Underlying()
+	}
+
+	path, err := filepath.Abs(fs.Root())
+	if err != nil {
+		return ""
+	}
+
+	return path
+}
+
+// dir returns the directory where the hooks are run, the worktree or the
+// repository when it is bare.
+func (h *hooks) dir() string {
+	if h.workDir != "" {
+		return h.workDir
+	}
+
+	return h.gitDir
+}
+
+// script returns the path of the hook script with the given name, or an empty
+// string if there isn't an executable one.
+func (h *hooks) script(name string) (string, error) {
+	cfg, err := h.s.Config()
+	if err != nil {
+		return "", err
+	}
+
+	var dir string
+	switch {
+	case cfg.Core.HooksPath != "" && filepath.IsAbs(cfg.Core.HooksPath):
+		dir = cfg.Core.HooksPath
+	case cfg.Core.HooksPath != "" && h.dir() != "":
+		dir = filepath.Join(h.dir(), cfg.Core.HooksPath)
+	case cfg.Core.HooksPath == "" && h.gitDir != "":
+		dir = filepath.Join(h.gitDir, "hooks")
+	default:
+		return "", nil
+	}
+
+	path := filepath.Join(dir, name)
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if fi.IsDir() || (runtime.GOOS != "windows" && fi.Mode()&0111 == 0) {
+		return "", nil
+	}
+
+	return path, nil
+}
+
+// has returns true if there is a hook script or a hook function with the
+// given name.
+func (h *hooks) has(name string) (bool, error) {
+	if h == nil {
+		return false, nil
+	}
+
+	if len(h.funcs[name]) != 0 {
+		return true, nil
+	}
+
+	script, err := h.script(name)
+	return script != "", err
+}
+
+// run runs the hook script and the hook functions with the given name, in this
+// order, stopping at the first one failing.
+func (h *hooks) run(name string, stdin []byte, env []string, args ...string) error {
+	if h == nil {
+		return nil
+	}
+
+	if h.gitDir != "" {
+		env = append([]string{"GIT_DIR=" + h.gitDir}, env...)
+	}
+
+	script, err := h.script(name)
+	if err != nil {
+		return err
+	}
+
+	if script != "" {
+		cmd := exec.Command(script, args...)
+		cmd.Dir = h.dir()
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdin = bytes.NewReader(stdin)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return &HookError{Name: name, Output: output, Err: err}
+		}
+	}
+
+	for _, fn := range h.funcs[name] {
+		err := fn(&Hook{
+			Name:  name,
+			Args:  args,
+			Stdin: bytes.NewReader(stdin),
+			Env:   env,
+		})
+		if err != nil {
+			return &HookError{Name: name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// commitEnv returns the environment of the hooks run by a commit.
+func (h *hooks) commitEnv() []string {
+	env := []string{"GIT_EDITOR=:"}
+	if h != nil && h.gitDir != "" {
+		env = append(env, "GIT_INDEX_FILE="+filepath.Join(h.gitDir, "index"))
+	}
+
+	return env
+}
+
+// commitMsg runs the commit-msg hook with a file holding msg, COMMIT_EDITMSG
+// in the repository or a temporary one, returning the message as left by the
+// hook.
+func (h *hooks) commitMsg(msg string) (string, error) {
+	ok, err := h.has(HookCommitMsg)
+	if err != nil || !ok {
+		return msg, err
+	}
+
+	var path string
+	if h.gitDir != "" {
+		path = filepath.Join(h.gitDir, "COMMIT_EDITMSG")
+		if err := ioutil.WriteFile(path, []byte(msg), 0644); err != nil {
+			return "", err
+		}
+	} else {
+		f, err := ioutil.TempFile("", "COMMIT_EDITMSG")
+		if err != nil {
+			return "", err
+		}
+
+		path = f.Name()
+		defer os.Remove(path)
+
+		_, err = f.WriteString(msg)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.run(HookCommitMsg, nil, h.commitEnv(), path); err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
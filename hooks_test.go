@@ -0,0 +1,335 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+type HooksSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&HooksSuite{})
+
+// hookCall is a recorded execution of a hook function.
+type hookCall struct {
+	Args  []string
+	Stdin string
+}
+
+// record registers a hook function recording its executions, and failing
+// with err, if not nil.
+func record(c *C, r *Repository, name string, err error) *[]hookCall {
+	var calls []hookCall
+	r.RegisterHook(name, func(h *Hook) error {
+		stdin, rerr := ioutil.ReadAll(h.Stdin)
+		c.Assert(rerr, IsNil)
+
+		calls = append(calls, hookCall{Args: h.Args, Stdin: string(stdin)})
+		return err
+	})
+
+	return &calls
+}
+
+func (s *HooksSuite) newRepository(c *C) (*Repository, *Worktree) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	return r, w
+}
+
+func (s *HooksSuite) commit(c *C, w *Worktree, file string, opts *CommitOptions) (plumbing.Hash, error) {
+	c.Assert(util.WriteFile(w.Filesystem, file, []byte(file), 0644), IsNil)
+	_, err := w.Add(file)
+	c.Assert(err, IsNil)
+
+	if opts == nil {
+		opts = &CommitOptions{}
+	}
+
+	opts.Author = &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Now()}
+	return w.Commit(file+"\n", opts)
+}
+
+func (s *HooksSuite) TestCommit(c *C) {
+	r, w := s.newRepository(c)
+	preCommit := record(c, r, HookPreCommit, nil)
+	transaction := record(c, r, HookReferenceTransaction, nil)
+	r.RegisterHook(HookCommitMsg, func(h *Hook) error {
+		c.Assert(h.Env, DeepEquals, []string{"GIT_EDITOR=:"})
+
+		f, err := os.OpenFile(h.Args[0], os.O_APPEND|os.O_WRONLY, 0)
+		c.Assert(err, IsNil)
+		_, err = f.WriteString("\nSigned-off-by: foo <foo@foo.foo>\n")
+		c.Assert(err, IsNil)
+		return f.Close()
+	})
+
+	hash, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "foo\n\nSigned-off-by: foo <foo@foo.foo>\n")
+
+	c.Assert(*preCommit, HasLen, 1)
+	c.Assert((*preCommit)[0].Args, HasLen, 0)
+
+	stdin := fmt.Sprintf("%s %s refs/heads/master\n", plumbing.ZeroHash, hash)
+	c.Assert(*transaction, DeepEquals, []hookCall{
+		{Args: []string{"prepared"}, Stdin: stdin},
+		{Args: []string{"committed"}, Stdin: stdin},
+	})
+}
+
+func (s *HooksSuite) TestCommitAborted(c *C) {
+	r, w := s.newRepository(c)
+	failure := errors.New("foo")
+	record(c, r, HookPreCommit, failure)
+
+	_, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, DeepEquals, &HookError{Name: HookPreCommit, Err: failure})
+
+	_, err = r.Head()
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *HooksSuite) TestCommitNoHooks(c *C) {
+	r, w := s.newRepository(c)
+	record(c, r, HookPreCommit, errors.New("foo"))
+	record(c, r, HookCommitMsg, errors.New("foo"))
+	transaction := record(c, r, HookReferenceTransaction, nil)
+
+	_, err := s.commit(c, w, "foo", &CommitOptions{NoHooks: true})
+	c.Assert(err, IsNil)
+	c.Assert(*transaction, HasLen, 0)
+}
+
+func (s *HooksSuite) TestReferenceTransactionAborted(c *C) {
+	r, w := s.newRepository(c)
+	first, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+
+	failure := errors.New("foo")
+	r.RegisterHook(HookReferenceTransaction, func(h *Hook) error {
+		if h.Args[0] == "prepared" {
+			return failure
+		}
+
+		return nil
+	})
+	transaction := record(c, r, HookReferenceTransaction, nil)
+
+	_, err = s.commit(c, w, "bar", nil)
+	c.Assert(err, DeepEquals, &HookError{Name: HookReferenceTransaction, Err: failure})
+	c.Assert(*transaction, HasLen, 1)
+	c.Assert((*transaction)[0].Args, DeepEquals, []string{"aborted"})
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, first)
+}
+
+func (s *HooksSuite) TestAmend(c *C) {
+	r, w := s.newRepository(c)
+	first, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+	second, err := s.commit(c, w, "bar", nil)
+	c.Assert(err, IsNil)
+
+	postRewrite := record(c, r, HookPostRewrite, nil)
+	amended, err := s.commit(c, w, "qux", &CommitOptions{Amend: true})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(amended)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{first})
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, amended)
+
+	c.Assert(*postRewrite, DeepEquals, []hookCall{{
+		Args:  []string{"amend"},
+		Stdin: fmt.Sprintf("%s %s\n", second, amended),
+	}})
+}
+
+func (s *HooksSuite) TestCheckout(c *C) {
+	r, w := s.newRepository(c)
+	first, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+	second, err := s.commit(c, w, "bar", nil)
+	c.Assert(err, IsNil)
+
+	postCheckout := record(c, r, HookPostCheckout, nil)
+	transaction := record(c, r, HookReferenceTransaction, nil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Hash:   first,
+		Branch: "refs/heads/foo",
+		Create: true,
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(*postCheckout, DeepEquals, []hookCall{{
+		Args: []string{second.String(), first.String(), "1"},
+	}})
+
+	c.Assert(*transaction, HasLen, 4)
+	c.Assert((*transaction)[0].Stdin, Equals,
+		fmt.Sprintf("%s %s refs/heads/foo\n", plumbing.ZeroHash, first))
+	c.Assert((*transaction)[2].Stdin, Equals,
+		"ref:refs/heads/master ref:refs/heads/foo HEAD\n")
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master, NoHooks: true})
+	c.Assert(err, IsNil)
+	c.Assert(*postCheckout, HasLen, 1)
+	c.Assert(*transaction, HasLen, 4)
+}
+
+func (s *HooksSuite) TestPrePush(c *C) {
+	r, w := s.newRepository(c)
+	hash, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+
+	url := c.MkDir()
+	_, err = PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	c.Assert(err, IsNil)
+
+	failure := errors.New("foo")
+	prePush := record(c, r, HookPrePush, failure)
+
+	err = r.Push(&PushOptions{})
+	c.Assert(err, DeepEquals, &HookError{Name: HookPrePush, Err: failure})
+	c.Assert(*prePush, DeepEquals, []hookCall{{
+		Args:  []string{DefaultRemoteName, url},
+		Stdin: fmt.Sprintf("refs/heads/master %s refs/heads/master %s\n", hash, plumbing.ZeroHash),
+	}})
+
+	err = r.Push(&PushOptions{NoHooks: true})
+	c.Assert(err, IsNil)
+
+	err = r.Push(&PushOptions{RefSpecs: []config.RefSpec{":refs/heads/master"}})
+	c.Assert(err, NotNil)
+	c.Assert((*prePush)[1].Stdin, Equals,
+		fmt.Sprintf("(delete) %s refs/heads/master %s\n", plumbing.ZeroHash, hash))
+}
+
+func (s *HooksSuite) TestPrePushScriptNewRemote(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("hook scripts are run by sh")
+	}
+
+	dir := c.MkDir()
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+
+	url := c.MkDir()
+	_, err = PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	hooks := filepath.Join(dir, ".git", "hooks")
+	c.Assert(os.MkdirAll(hooks, 0755), IsNil)
+	writeHook(c, hooks, HookPrePush, `echo "$@"; exit 1`)
+
+	remote := NewRemote(r.Storer, &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	err = remote.Push(&PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"}})
+	c.Assert(err, FitsTypeOf, &HookError{})
+	c.Assert(string(err.(*HookError).Output), Equals, DefaultRemoteName+" "+url+"\n")
+}
+
+func (s *HooksSuite) TestScripts(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("hook scripts are run by sh")
+	}
+
+	dir := c.MkDir()
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hooks := filepath.Join(dir, ".git", "hooks")
+	c.Assert(os.MkdirAll(hooks, 0755), IsNil)
+	writeHook(c, hooks, HookCommitMsg, `echo "$GIT_DIR $GIT_INDEX_FILE" > "$1"`)
+	writeHook(c, hooks, HookPreCommit, `echo "pre-commit in $(pwd)"; exit 1`)
+
+	_, err = s.commit(c, w, "foo", nil)
+	c.Assert(err, FitsTypeOf, &HookError{})
+	hookErr := err.(*HookError)
+	c.Assert(hookErr.Name, Equals, HookPreCommit)
+
+	root, err := filepath.EvalSymlinks(dir)
+	c.Assert(err, IsNil)
+	c.Assert(strings.TrimSpace(string(hookErr.Output)), Equals, "pre-commit in "+root)
+
+	// a hook which is not executable is ignored
+	c.Assert(os.Chmod(filepath.Join(hooks, HookPreCommit), 0644), IsNil)
+
+	hash, err := s.commit(c, w, "foo", nil)
+	c.Assert(err, IsNil)
+
+	gitDir := filepath.Join(dir, ".git")
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, gitDir+" "+filepath.Join(gitDir, "index")+"\n")
+
+	// core.hooksPath is relative to the worktree
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.HooksPath = filepath.Join(".git", "custom")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	custom := filepath.Join(dir, ".git", "custom")
+	c.Assert(os.MkdirAll(custom, 0755), IsNil)
+	writeHook(c, custom, HookPostCheckout, `echo "$@" > post-checkout.out`)
+
+	err = w.Checkout(&CheckoutOptions{Hash: hash})
+	c.Assert(err, IsNil)
+
+	output, err := ioutil.ReadFile(filepath.Join(dir, "post-checkout.out"))
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, fmt.Sprintf("%s %s 1\n", hash, hash))
+
+	// the hooks of the repository are not run anymore
+	hash, err = s.commit(c, w, "bar", nil)
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "bar\n")
+}
+
+func writeHook(c *C, dir, name, script string) {
+	content := []byte("#!/bin/sh\n" + script + "\n")
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, name), content, 0755), IsNil)
+}
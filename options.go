@@ -59,6 +59,19 @@ type CloneOptions struct {
 	// Tags describe how the tags will be fetched from the remote repository,
 	// by default is AllTags.
 	Tags TagMode
+	// PackfileURIProtocols are the protocols of the packfile URIs accepted
+	// from the remote, see FetchOptions.PackfileURIProtocols.
+	PackfileURIProtocols []string
+	// BundleURI is the URI of a bundle, or a bundle list, to bootstrap the
+	// clone from, see FetchOptions.BundleURI.
+	BundleURI string
+	// RetryPolicy retries the operations failing with transient errors, see
+	// FetchOptions.RetryPolicy.
+	RetryPolicy *transport.RetryPolicy
+	// Limits caps the packfile transfer, see FetchOptions.Limits.
+	Limits *transport.Limits
+	// FsckObjects checks the fetched objects, see FetchOptions.FsckObjects.
+	FsckObjects bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -104,6 +117,15 @@ type PullOptions struct {
 	// Force allows the pull to update a local branch even when the remote
 	// branch does not descend from it.
 	Force bool
+	// NoHooks disables the hooks run by the pull.
+	NoHooks bool
+	// RetryPolicy retries the operations failing with transient errors, see
+	// FetchOptions.RetryPolicy.
+	RetryPolicy *transport.RetryPolicy
+	// Limits caps the packfile transfer, see FetchOptions.Limits.
+	Limits *transport.Limits
+	// FsckObjects checks the fetched objects, see FetchOptions.FsckObjects.
+	FsckObjects bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -154,6 +176,34 @@ type FetchOptions struct {
 	// Force allows the fetch to update a local branch even when the remote
 	// branch does not descend from it.
 	Force bool
+	// PackfileURIProtocols are the protocols, such as "https", of the
+	// packfile URIs the remote may send instead of inlining some objects
+	// in the packfile. If empty, packfile URIs aren't requested. They are
+	// only requested from the remotes advertising the packfile-uris
+	// capability, a go-git extension of the protocol v0, see
+	// capability.PackfileURIs.
+	PackfileURIProtocols []string
+	// BundleURI is the URI of a bundle, or a bundle list, downloaded and
+	// unbundled before fetching, so only the objects missing from it are
+	// fetched from the remote. Its branches are stored under refs/bundles/.
+	BundleURI string
+	// RetryPolicy, if not nil, retries the reference advertisement, the
+	// negotiation and the packfile transfer when they fail with a transient
+	// error, and resumes the interrupted downloads of packfile and bundle
+	// URIs. The packfile is then downloaded into a temporary file, and only
+	// stored once fully transferred, a partial packfile being discarded
+	// before retrying.
+	RetryPolicy *transport.RetryPolicy
+	// Limits, if not nil, caps the packfile transfer: the fetch fails when
+	// it stalls or exceeds the size and object limits, nothing being
+	// stored. The downloads of packfile and bundle URIs are subject to the
+	// same limits, the object limits only applying to the packfile URIs.
+	Limits *transport.Limits
+	// FsckObjects checks the fetched objects while parsing the packfile,
+	// failing the fetch on the malformed ones, as the fetch.fsckObjects
+	// config option does. The checks are configured by the fsck.* and
+	// fetch.fsck.* config options, see fsck.NewCheckerFromConfig.
+	FsckObjects bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -190,6 +240,8 @@ type PushOptions struct {
 	// Prune specify that remote refs that match given RefSpecs and that do
 	// not exist locally will be removed.
 	Prune bool
+	// NoHooks disables the pre-push hook.
+	NoHooks bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -251,6 +303,14 @@ type CheckoutOptions struct {
 	// target branch. Force and Keep are mutually exclusive, should not be both
 	// set to true.
 	Keep bool
+	// NoHooks disables the hooks run by the checkout.
+	NoHooks bool
+	// PathSpecs, if not empty, checks out the files matching the given
+	// pathspecs, as described in the pathspec package, instead of switching
+	// branches. The files are restored from the commit given by Hash or
+	// Branch, both in the index and the worktree, or from the index if none
+	// of them is set. HEAD isn't updated.
+	PathSpecs []string
 }
 
 // Validate validates the fields and sets the default values.
@@ -345,6 +405,12 @@ type LogOptions struct {
 	// It is equivalent to running `git log -- <file-name>`.
 	FileName *string
 
+	// Show only those commits changing any of the paths matching the given
+	// pathspecs, as described in the pathspec package. It is equivalent to
+	// running `git log -- <pathspec>...`. If FileName is also set, it's
+	// matched as one more pathspec.
+	PathSpecs []string
+
 	// Pretend as if all the refs in refs/, along with HEAD, are listed on the command line as <commit>.
 	// It is equivalent to running `git log --all`.
 	// If set on true, the From option will be ignored.
@@ -380,6 +446,11 @@ type CommitOptions struct {
 	// commit will not be signed. The private key must be present and already
 	// decrypted.
 	SignKey *openpgp.Entity
+	// Amend replaces the HEAD commit with the new commit, by default with the
+	// same parents as the HEAD commit.
+	Amend bool
+	// NoHooks disables the hooks run by the commit.
+	NoHooks bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -392,7 +463,19 @@ func (o *CommitOptions) Validate(r *Repository) error {
 		o.Committer = o.Author
 	}
 
-	if len(o.Parents) == 0 {
+	if o.Amend && len(o.Parents) == 0 {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		commit, err := r.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+
+		o.Parents = commit.ParentHashes
+	} else if len(o.Parents) == 0 {
 		head, err := r.Head()
 		if err != nil && err != plumbing.ErrReferenceNotFound {
 			return err
@@ -445,11 +528,121 @@ func (o *CreateTagOptions) Validate(r *Repository, hash plumbing.Hash) error {
 type ListOptions struct {
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// RetryPolicy, if not nil, retries the reference advertisement when it
+	// fails with a transient error.
+	RetryPolicy *transport.RetryPolicy
 }
 
+// ErrIgnoredAndOnlyIgnored is returned by Clean when both Ignored and
+// OnlyIgnored are set.
+var ErrIgnoredAndOnlyIgnored = errors.New("Ignored and OnlyIgnored are mutually exclusive")
+
 // CleanOptions describes how a clean should be performed.
 type CleanOptions struct {
+	// Dir removes the untracked directories too, as with `git clean -d`.
+	// Otherwise only the untracked files in the tracked directories are
+	// removed.
 	Dir bool
+	// Ignored removes the ignored files too, as with `git clean -x`. The
+	// patterns in Exclude are still honored.
+	Ignored bool
+	// OnlyIgnored removes only the ignored files, as with `git clean -X`.
+	OnlyIgnored bool
+	// DryRun doesn't remove anything, it only reports the paths that would
+	// be removed.
+	DryRun bool
+	// PathSpecs limits the clean to the paths matching them, as described in
+	// the pathspec package. If empty, the whole worktree is cleaned.
+	PathSpecs []string
+	// Exclude adds gitignore patterns to the ignore rules, as with
+	// `git clean -e`.
+	Exclude []string
+	// Nested removes the untracked directories containing another git
+	// repository too, as with `git clean -ff`. It requires Dir.
+	Nested bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CleanOptions) Validate() error {
+	if o.Ignored && o.OnlyIgnored {
+		return ErrIgnoredAndOnlyIgnored
+	}
+
+	return nil
+}
+
+// ErrMissingPathSpecs is returned by the operations requiring pathspecs when
+// none is given.
+var ErrMissingPathSpecs = errors.New("pathspecs are required")
+
+// AddOptions describes how an add operation should be performed.
+type AddOptions struct {
+	// PathSpecs selects the files to add, as described in the pathspec
+	// package. If empty, all the files are added, as with `git add --all`.
+	PathSpecs []string
+}
+
+// SnapshotOptions describes how the snapshot of a worktree should be taken.
+type SnapshotOptions struct {
+	// PathSpecs limits the snapshot to the files matching the given
+	// pathspecs, as described in the pathspec package. If empty, all the
+	// files are stored.
+	PathSpecs []string
+}
+
+// RemoveOptions describes how a remove operation should be performed.
+type RemoveOptions struct {
+	// PathSpecs selects the files to remove, as described in the pathspec
+	// package. It's required.
+	PathSpecs []string
+	// Cached only removes the files from the index, keeping them in the
+	// worktree.
+	Cached bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RemoveOptions) Validate() error {
+	if len(o.PathSpecs) == 0 {
+		return ErrMissingPathSpecs
+	}
+
+	return nil
+}
+
+// RestoreOptions describes how a restore operation should be performed.
+type RestoreOptions struct {
+	// Source is the commit the files are restored from. If it's zero, the
+	// worktree is restored from the index, and the index from HEAD.
+	Source plumbing.Hash
+	// Staged restores the files of the index.
+	Staged bool
+	// Worktree restores the files of the worktree, it's the default if
+	// Staged is false. If both are true and Source is zero, the files are
+	// restored from HEAD.
+	Worktree bool
+	// PathSpecs selects the files to restore, as described in the pathspec
+	// package. It's required.
+	PathSpecs []string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RestoreOptions) Validate() error {
+	if len(o.PathSpecs) == 0 {
+		return ErrMissingPathSpecs
+	}
+
+	if !o.Staged {
+		o.Worktree = true
+	}
+
+	return nil
+}
+
+// StatusOptions describes how a status should be performed.
+type StatusOptions struct {
+	// PathSpecs limits the status to the paths matching the given pathspecs,
+	// as described in the pathspec package, such as a path or a directory.
+	PathSpecs []string
 }
 
 // GrepOptions describes how a grep should be performed.
@@ -464,10 +657,51 @@ type GrepOptions struct {
 	ReferenceName plumbing.ReferenceName
 	// PathSpecs are compiled Regexp objects of pathspec to use in the matching.
 	PathSpecs []*regexp.Regexp
+	// Paths limits the search to the files matching the given pathspecs, as
+	// described in the pathspec package. If PathSpecs is also set, the files
+	// must match both.
+	Paths []string
+	// FixedStrings are strings matched literally, in addition to Patterns.
+	FixedStrings []string
+	// IgnoreCase ignores case differences between the patterns and the
+	// content.
+	IgnoreCase bool
+	// Revisions are additional revisions, resolving to commits, which
+	// trees are searched. They are resolved as Repository.ResolveRevision
+	// does, so the annotated tags are peeled to their commits, but they
+	// can't name trees. The TreeName of the results is the revision.
+	Revisions []plumbing.Revision
+	// Index searches the blobs registered in the index instead of the
+	// committed trees.
+	Index bool
+	// Worktree searches the tracked files in the worktree instead of the
+	// committed trees.
+	Worktree bool
+	// Untracked searches the untracked files in the worktree, the ignored
+	// files are skipped.
+	Untracked bool
+	// BeforeContext is the number of lines of context returned before each
+	// matching line.
+	BeforeContext int
+	// AfterContext is the number of lines of context returned after each
+	// matching line.
+	AfterContext int
+	// FilesWithMatches returns only one result per file with matches, with
+	// only the FileName and TreeName set.
+	FilesWithMatches bool
+	// Count returns only one result per file with matches, with the number of
+	// matching lines in Count instead of the content.
+	Count bool
+	// Text processes the binary files as if they were text, by default
+	// binary files, per the "diff" attribute or their content, are skipped.
+	Text bool
+	// Workers is the number of files matched concurrently, by default one.
+	Workers int
 }
 
 var (
 	ErrHashOrReference = errors.New("ambiguous options, only one of CommitHash or ReferenceName can be passed")
+	ErrGrepOutputMode  = errors.New("ambiguous options, only one of FilesWithMatches or Count can be passed")
 )
 
 // Validate validates the fields and sets the default values.
@@ -476,9 +710,17 @@ func (o *GrepOptions) Validate(w *Worktree) error {
 		return ErrHashOrReference
 	}
 
-	// If none of CommitHash and ReferenceName are provided, set commit hash of
-	// the repository's head.
-	if o.CommitHash.IsZero() && o.ReferenceName == "" {
+	if o.FilesWithMatches && o.Count {
+		return ErrGrepOutputMode
+	}
+
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+
+	// If no source is provided, set commit hash of the repository's head.
+	if o.CommitHash.IsZero() && o.ReferenceName == "" && len(o.Revisions) == 0 &&
+		!o.Index && !o.Worktree && !o.Untracked {
 		ref, err := w.r.Head()
 		if err != nil {
 			return err
@@ -489,13 +731,143 @@ func (o *GrepOptions) Validate(w *Worktree) error {
 	return nil
 }
 
+var (
+	ErrSeparateGitDirBare      = errors.New("separate git dir incompatible with bare repository")
+	ErrUnsupportedObjectFormat = errors.New("unsupported object format")
+)
+
+// InitOptions describes how a repository is created by InitWithOptions.
+type InitOptions struct {
+	// InitialBranch is the branch HEAD points to, as a full reference name
+	// or a branch name, by default master.
+	InitialBranch plumbing.ReferenceName
+}
+
+// Validate validates the fields and sets the default values.
+func (o *InitOptions) Validate() error {
+	if o.InitialBranch == "" {
+		o.InitialBranch = plumbing.Master
+	}
+
+	if !o.InitialBranch.IsBranch() {
+		o.InitialBranch = plumbing.NewBranchReferenceName(o.InitialBranch.String())
+	}
+
+	return nil
+}
+
+// PlainInitOptions describes how a plain repository is created by
+// PlainInitWithOptions.
+type PlainInitOptions struct {
+	InitOptions
+	// Bare creates a repository without worktree.
+	Bare bool
+	// TemplateDir is the directory whose files, such as the hooks, the
+	// info/exclude file and the description, are copied in the git directory.
+	TemplateDir string
+	// SeparateGitDir is the path of the git directory, placed out of the
+	// worktree, where a .git file points to it.
+	SeparateGitDir string
+	// Shared is the value of the core.sharedRepository option, making the
+	// repository shared by the users of a group: "group" to give the group
+	// read and write permissions, "all" to allow the others to read too, or
+	// the octal permissions of the files, such as "0640". The permissions
+	// are only given to the files created by the init, the ones written
+	// later, such as new objects and references, are created with the
+	// default ones.
+	Shared string
+	// ObjectFormat is the hash algorithm of the objects, only "sha1" is
+	// supported.
+	ObjectFormat string
+	// UseGlobalConfig defines whether the InitialBranch and the TemplateDir
+	// default to the init.defaultBranch and init.templateDir options of the
+	// global config, or else the system one, as git does.
+	UseGlobalConfig bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *PlainInitOptions) Validate() error {
+	if o.Bare && o.SeparateGitDir != "" {
+		return ErrSeparateGitDirBare
+	}
+
+	if o.ObjectFormat != "" && o.ObjectFormat != "sha1" {
+		return ErrUnsupportedObjectFormat
+	}
+
+	if _, err := parseSharedRepository(o.Shared); err != nil {
+		return err
+	}
+
+	return o.InitOptions.Validate()
+}
+
 // PlainOpenOptions describes how opening a plain repository should be
 // performed.
 type PlainOpenOptions struct {
 	// DetectDotGit defines whether parent directories should be
-	// walked until a .git directory or file is found.
+	// walked until a .git directory or file, or a bare repository, is found.
 	DetectDotGit bool
+	// UseEnv defines whether the GIT_DIR, GIT_WORK_TREE, GIT_COMMON_DIR,
+	// GIT_CEILING_DIRECTORIES and GIT_DISCOVERY_ACROSS_FILESYSTEM environment
+	// variables are honoured, as git does.
+	UseEnv bool
+	// CeilingDirectories are the directories where the walk of the parent
+	// directories stops, without looking at them or their parents.
+	CeilingDirectories []string
+	// StopAtFilesystemBoundary defines whether the walk of the parent
+	// directories stops at a filesystem boundary, as git does unless
+	// GIT_DISCOVERY_ACROSS_FILESYSTEM is set.
+	StopAtFilesystemBoundary bool
+	// CheckOwnership defines whether a repository owned by another user is
+	// refused, with ErrUnsafeRepository, unless it is listed by the
+	// safe.directory options of the global or system config.
+	CheckOwnership bool
 }
 
 // Validate validates the fields and sets the default values.
 func (o *PlainOpenOptions) Validate() error { return nil }
+
+// BisectOptions describes how a bisect session should be started.
+type BisectOptions struct {
+	// Bad is the commit known to have the bug, if any.
+	Bad plumbing.Hash
+	// Good are the commits known to not have the bug.
+	Good []plumbing.Hash
+	// PathSpecs limits the bisect to the commits changing the given paths.
+	PathSpecs []string
+	// TermBad and TermGood are the terms used instead of "bad" and "good",
+	// like "new" and "old" when looking for the commit introducing a change
+	// other than a bug. Default to "bad" and "good".
+	TermBad, TermGood string
+	// NoCheckout doesn't check out the commits to be tested, the BISECT_HEAD
+	// reference is updated instead. It's always the case on bare repositories.
+	NoCheckout bool
+}
+
+var (
+	ErrBisectTerms = errors.New("invalid bisect terms, they must be different valid reference names")
+)
+
+// Validate validates the fields and sets the default values.
+func (o *BisectOptions) Validate() error {
+	if o.TermBad == "" {
+		o.TermBad = "bad"
+	}
+
+	if o.TermGood == "" {
+		o.TermGood = "good"
+	}
+
+	for _, term := range []string{o.TermBad, o.TermGood} {
+		if term == "skip" || strings.ContainsAny(term, "/ ~^:?*[\\") {
+			return ErrBisectTerms
+		}
+	}
+
+	if o.TermBad == o.TermGood {
+		return ErrBisectTerms
+	}
+
+	return nil
+}
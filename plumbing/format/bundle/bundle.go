@@ -0,0 +1,272 @@
+// Package bundle implements encoding and decoding of git bundles, the files
+// created by git bundle holding a packfile along with the references it
+// completes and the objects it requires.
+//
+// See https://git-scm.com/docs/gitformat-bundle for the format and
+// https://git-scm.com/docs/bundle-uri for the bundle lists.
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+var (
+	// ErrBadSignature is returned when the data doesn't start with the
+	// signature of a supported bundle version.
+	ErrBadSignature = errors.New("bundle: bad signature")
+	// ErrUnsupportedObjectFormat is returned when a v3 bundle holds objects
+	// not hashed with SHA-1.
+	ErrUnsupportedObjectFormat = errors.New("bundle: unsupported object format")
+	// ErrMissingPrerequisite is returned when unbundling into a storer
+	// missing any of the prerequisites of the bundle.
+	ErrMissingPrerequisite = errors.New("bundle: missing prerequisite")
+)
+
+var (
+	signatureV2 = []byte("# v2 git bundle\n")
+	signatureV3 = []byte("# v3 git bundle\n")
+)
+
+const objectFormatCapability = "object-format"
+
+// Bundle is a git bundle.
+type Bundle struct {
+	// Version is the version of the bundle format, 2 or 3.
+	Version int
+	// Capabilities are the capabilities of a v3 bundle.
+	Capabilities map[string]string
+	// Prerequisites are the commits the receiver must have, as the packfile
+	// is not complete without them.
+	Prerequisites []plumbing.Hash
+	// References are the references the bundle completes.
+	References []*plumbing.Reference
+	// Packfile is the packfile following the header.
+	Packfile io.Reader
+}
+
+// IsBundle returns true if b starts with the signature of a bundle.
+func IsBundle(b []byte) bool {
+	return bytes.HasPrefix(b, signatureV2) || bytes.HasPrefix(b, signatureV3)
+}
+
+// Decoder reads and decodes bundles from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads the bundle header into b, and sets its Packfile to the
+// remaining of the input stream.
+func (d *Decoder) Decode(b *Bundle) error {
+	line, err := d.r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	switch {
+	case bytes.Equal(line, signatureV2):
+		b.Version = 2
+	case bytes.Equal(line, signatureV3):
+		b.Version = 3
+	default:
+		return ErrBadSignature
+	}
+
+	for {
+		line, err := d.r.ReadString('\n')
+		if err == io.EOF {
+			return fmt.Errorf("bundle: unexpected EOF in header")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+
+		if err := b.decodeLine(line); err != nil {
+			return err
+		}
+	}
+
+	b.Packfile = d.r
+	return nil
+}
+
+func (b *Bundle) decodeLine(line string) error {
+	switch line[0] {
+	case '@':
+		return b.decodeCapability(line[1:])
+	case '-':
+		h, _ := splitLine(line[1:])
+		if h.IsZero() {
+			return fmt.Errorf("bundle: malformed prerequisite %q", line)
+		}
+
+		b.Prerequisites = append(b.Prerequisites, h)
+	default:
+		h, name := splitLine(line)
+		if h.IsZero() || name == "" {
+			return fmt.Errorf("bundle: malformed reference %q", line)
+		}
+
+		b.References = append(b.References,
+			plumbing.NewHashReference(plumbing.ReferenceName(name), h))
+	}
+
+	return nil
+}
+
+func (b *Bundle) decodeCapability(line string) error {
+	if b.Version < 3 {
+		return fmt.Errorf("bundle: unexpected capability %q", line)
+	}
+
+	key, value := line, ""
+	if i := strings.IndexByte(line, '='); i != -1 {
+		key, value = line[:i], line[i+1:]
+	}
+
+	if key == objectFormatCapability && value != "sha1" {
+		return ErrUnsupportedObjectFormat
+	}
+
+	if b.Capabilities == nil {
+		b.Capabilities = make(map[string]string)
+	}
+
+	b.Capabilities[key] = value
+	return nil
+}
+
+// splitLine splits a header line into its leading hash and the rest of it.
+func splitLine(line string) (plumbing.Hash, string) {
+	i := strings.IndexByte(line, ' ')
+	if i == -1 {
+		i = len(line)
+	}
+
+	if i != 40 {
+		return plumbing.ZeroHash, ""
+	}
+
+	h := plumbing.NewHash(line[:i])
+	if i == len(line) {
+		return h, ""
+	}
+
+	return h, line[i+1:]
+}
+
+// Encode writes the bundle header followed by its packfile into w.
+func Encode(w io.Writer, b *Bundle) error {
+	var buf bytes.Buffer
+	if len(b.Capabilities) == 0 {
+		buf.Write(signatureV2)
+	} else {
+		buf.Write(signatureV3)
+		keys := make([]string, 0, len(b.Capabilities))
+		for k := range b.Capabilities {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "@%s=%s\n", k, b.Capabilities[k])
+		}
+	}
+
+	for _, h := range b.Prerequisites {
+		fmt.Fprintf(&buf, "-%s\n", h)
+	}
+
+	for _, ref := range b.References {
+		fmt.Fprintf(&buf, "%s %s\n", ref.Hash(), ref.Name())
+	}
+
+	buf.WriteByte('\n')
+	if _, err := buf.WriteTo(w); err != nil {
+		return err
+	}
+
+	if b.Packfile == nil {
+		return nil
+	}
+
+	_, err := io.Copy(w, b.Packfile)
+	return err
+}
+
+// Create writes into w a bundle holding the given references, and the
+// objects reachable from them but not from the prerequisites.
+func Create(w io.Writer, s storer.EncodedObjectStorer,
+	refs []*plumbing.Reference, prerequisites []plumbing.Hash) error {
+	var wants []plumbing.Hash
+	for _, ref := range refs {
+		wants = append(wants, ref.Hash())
+	}
+
+	haves, err := revlist.Objects(s, prerequisites, nil)
+	if err != nil {
+		return err
+	}
+
+	objs, err := revlist.Objects(s, wants, haves)
+	if err != nil {
+		return err
+	}
+
+	var pack bytes.Buffer
+	if _, err := packfile.NewEncoder(&pack, s, false).Encode(objs, 10); err != nil {
+		return err
+	}
+
+	return Encode(w, &Bundle{
+		Prerequisites: prerequisites,
+		References:    refs,
+		Packfile:      &pack,
+	})
+}
+
+// Unbundle decodes the bundle read from r, and stores its objects into s,
+// provided it has all the prerequisites. The references aren't updated.
+func Unbundle(s storer.Storer, r io.Reader) (*Bundle, error) {
+	b := &Bundle{}
+	if err := NewDecoder(r).Decode(b); err != nil {
+		return nil, err
+	}
+
+	for _, h := range b.Prerequisites {
+		if err := s.HasEncodedObject(h); err != nil {
+			if err == plumbing.ErrObjectNotFound {
+				return nil, ErrMissingPrerequisite
+			}
+
+			return nil, err
+		}
+	}
+
+	if err := packfile.UpdateObjectStorage(s, b.Packfile); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
@@ -0,0 +1,120 @@
+package bundle_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	fixtures "gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type BundleSuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&BundleSuite{})
+
+var (
+	master = plumbing.NewHashReference("refs/heads/master",
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	parent = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+func (s *BundleSuite) TestDecode(c *C) {
+	raw := "# v2 git bundle\n" +
+		"-918c48b83bd081e863dbe1b80f8998f058cd8294 vendor stuff\n" +
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n" +
+		"\nPACK"
+
+	var b bundle.Bundle
+	err := bundle.NewDecoder(strings.NewReader(raw)).Decode(&b)
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, 2)
+	c.Assert(b.Prerequisites, DeepEquals, []plumbing.Hash{parent})
+	c.Assert(b.References, DeepEquals, []*plumbing.Reference{master})
+
+	var pack bytes.Buffer
+	_, err = pack.ReadFrom(b.Packfile)
+	c.Assert(err, IsNil)
+	c.Assert(pack.String(), Equals, "PACK")
+}
+
+func (s *BundleSuite) TestDecodeV3(c *C) {
+	raw := "# v3 git bundle\n" +
+		"@object-format=sha1\n" +
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n" +
+		"\n"
+
+	var b bundle.Bundle
+	err := bundle.NewDecoder(strings.NewReader(raw)).Decode(&b)
+	c.Assert(err, IsNil)
+	c.Assert(b.Version, Equals, 3)
+	c.Assert(b.Capabilities, DeepEquals, map[string]string{"object-format": "sha1"})
+
+	raw = strings.Replace(raw, "sha1", "sha256", 1)
+	err = bundle.NewDecoder(strings.NewReader(raw)).Decode(&b)
+	c.Assert(err, Equals, bundle.ErrUnsupportedObjectFormat)
+}
+
+func (s *BundleSuite) TestDecodeErrors(c *C) {
+	for _, raw := range []string{
+		"PACK",
+		"# v2 git bundle\n6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n",
+		"# v2 git bundle\n6ecf0ef2 refs/heads/master\n\n",
+		"# v2 git bundle\n@object-format=sha1\n\n",
+	} {
+		var b bundle.Bundle
+		err := bundle.NewDecoder(strings.NewReader(raw)).Decode(&b)
+		c.Assert(err, NotNil, Commentf("%q", raw))
+	}
+}
+
+func (s *BundleSuite) TestEncode(c *C) {
+	var buf bytes.Buffer
+	err := bundle.Encode(&buf, &bundle.Bundle{
+		Prerequisites: []plumbing.Hash{parent},
+		References:    []*plumbing.Reference{master},
+		Packfile:      strings.NewReader("PACK"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "# v2 git bundle\n"+
+		"-918c48b83bd081e863dbe1b80f8998f058cd8294\n"+
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n"+
+		"\nPACK")
+	c.Assert(bundle.IsBundle(buf.Bytes()), Equals, true)
+}
+
+func (s *BundleSuite) TestCreateAndUnbundle(c *C) {
+	f := fixtures.Basic().One()
+	src := filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+
+	var full bytes.Buffer
+	err := bundle.Create(&full, src, []*plumbing.Reference{master}, nil)
+	c.Assert(err, IsNil)
+
+	var incremental bytes.Buffer
+	err = bundle.Create(&incremental, src, []*plumbing.Reference{master}, []plumbing.Hash{parent})
+	c.Assert(err, IsNil)
+	c.Assert(incremental.Len() < full.Len(), Equals, true)
+
+	dst := memory.NewStorage()
+	_, err = bundle.Unbundle(dst, bytes.NewReader(incremental.Bytes()))
+	c.Assert(err, Equals, bundle.ErrMissingPrerequisite)
+
+	b, err := bundle.Unbundle(dst, &full)
+	c.Assert(err, IsNil)
+	c.Assert(b.References, DeepEquals, []*plumbing.Reference{master})
+	c.Assert(dst.HasEncodedObject(master.Hash()), IsNil)
+	c.Assert(dst.HasEncodedObject(parent), IsNil)
+
+	_, err = bundle.Unbundle(dst, &incremental)
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,111 @@
+package bundle
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+const (
+	listSection          = "bundle"
+	versionKey           = "version"
+	modeKey              = "mode"
+	uriKey               = "uri"
+	creationTokenKey     = "creationToken"
+	supportedListVersion = 1
+)
+
+// ListMode tells which of the bundles of a list must be unbundled.
+type ListMode string
+
+const (
+	// AllMode requires all the bundles of the list.
+	AllMode ListMode = "all"
+	// AnyMode requires any one of the bundles of the list, which are
+	// alternatives of each other.
+	AnyMode ListMode = "any"
+)
+
+// List is a bundle list, as served from a bundle URI.
+type List struct {
+	// Version is the version of the list format.
+	Version int
+	// Mode tells which bundles are required.
+	Mode ListMode
+	// Bundles are the bundles of the list, sorted by creation token if they
+	// have one, so the prerequisites of a bundle are in the previous ones.
+	Bundles []*ListEntry
+}
+
+// ListEntry is a bundle of a List.
+type ListEntry struct {
+	// ID is the identifier of the bundle in the list.
+	ID string
+	// URI is where the bundle is downloaded from, it may be relative to the
+	// URI of the list.
+	URI string
+	// CreationToken orders the bundles of the list, being zero if unset.
+	CreationToken uint64
+}
+
+// Decode decodes the list, in git config format, read from r.
+func (l *List) Decode(r io.Reader) error {
+	raw := config.New()
+	if err := config.NewDecoder(r).Decode(raw); err != nil {
+		return err
+	}
+
+	s := raw.Section(listSection)
+	v, err := strconv.Atoi(s.Option(versionKey))
+	if err != nil || v != supportedListVersion {
+		return fmt.Errorf("bundle: unsupported list version %q", s.Option(versionKey))
+	}
+
+	l.Version = v
+	l.Mode = ListMode(s.Option(modeKey))
+	if l.Mode != AllMode && l.Mode != AnyMode {
+		return fmt.Errorf("bundle: unsupported list mode %q", l.Mode)
+	}
+
+	for _, ss := range s.Subsections {
+		e := &ListEntry{ID: ss.Name, URI: ss.Option(uriKey)}
+		if e.URI == "" {
+			return fmt.Errorf("bundle: missing uri for %q", ss.Name)
+		}
+
+		if t := ss.Option(creationTokenKey); t != "" {
+			if e.CreationToken, err = strconv.ParseUint(t, 10, 64); err != nil {
+				return fmt.Errorf("bundle: malformed creationToken for %q", ss.Name)
+			}
+		}
+
+		l.Bundles = append(l.Bundles, e)
+	}
+
+	sort.SliceStable(l.Bundles, func(i, j int) bool {
+		return l.Bundles[i].CreationToken < l.Bundles[j].CreationToken
+	})
+
+	return nil
+}
+
+// Encode encodes the list in git config format into w.
+func (l *List) Encode(w io.Writer) error {
+	raw := config.New()
+	s := raw.Section(listSection)
+	s.SetOption(versionKey, strconv.Itoa(l.Version))
+	s.SetOption(modeKey, string(l.Mode))
+
+	for _, e := range l.Bundles {
+		ss := s.Subsection(e.ID)
+		ss.SetOption(uriKey, e.URI)
+		if e.CreationToken != 0 {
+			ss.SetOption(creationTokenKey, strconv.FormatUint(e.CreationToken, 10))
+		}
+	}
+
+	return config.NewEncoder(w).Encode(raw)
+}
@@ -0,0 +1,65 @@
+package bundle_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+)
+
+type ListSuite struct{}
+
+var _ = Suite(&ListSuite{})
+
+func (s *ListSuite) TestDecode(c *C) {
+	raw := `[bundle]
+	version = 1
+	mode = all
+[bundle "daily"]
+	uri = daily.bundle
+	creationToken = 2
+[bundle "base"]
+	uri = https://example.com/base.bundle
+	creationToken = 1
+`
+
+	var l bundle.List
+	err := l.Decode(strings.NewReader(raw))
+	c.Assert(err, IsNil)
+	c.Assert(l.Version, Equals, 1)
+	c.Assert(l.Mode, Equals, bundle.AllMode)
+	c.Assert(l.Bundles, DeepEquals, []*bundle.ListEntry{
+		{ID: "base", URI: "https://example.com/base.bundle", CreationToken: 1},
+		{ID: "daily", URI: "daily.bundle", CreationToken: 2},
+	})
+}
+
+func (s *ListSuite) TestDecodeErrors(c *C) {
+	for _, raw := range []string{
+		"[bundle]\n\tversion = 2\n\tmode = all\n",
+		"[bundle]\n\tversion = 1\n\tmode = some\n",
+		"[bundle]\n\tversion = 1\n\tmode = all\n[bundle \"foo\"]\n\tcreationToken = 1\n",
+		"[bundle]\n\tversion = 1\n\tmode = all\n[bundle \"foo\"]\n\turi = foo\n\tcreationToken = bar\n",
+	} {
+		var l bundle.List
+		c.Assert(l.Decode(strings.NewReader(raw)), NotNil, Commentf("%q", raw))
+	}
+}
+
+func (s *ListSuite) TestEncode(c *C) {
+	l := &bundle.List{
+		Version: 1,
+		Mode:    bundle.AnyMode,
+		Bundles: []*bundle.ListEntry{
+			{ID: "base", URI: "base.bundle", CreationToken: 1},
+		},
+	}
+
+	var buf bytes.Buffer
+	c.Assert(l.Encode(&buf), IsNil)
+
+	var decoded bundle.List
+	c.Assert(decoded.Decode(&buf), IsNil)
+	c.Assert(&decoded, DeepEquals, l)
+}
@@ -0,0 +1,178 @@
+package commitgraph
+
+import (
+	"strings"
+)
+
+const (
+	// BloomFilterVersion is the version of the hash function used by the
+	// changed-path Bloom filters, version 1 is the one used by Git.
+	BloomFilterVersion = 1
+
+	bloomNumHashes       = 7
+	bloomBitsPerEntry    = 10
+	bloomMaxChangedPaths = 512
+	bloomHashSeed0       = 0x293ae76f
+	bloomHashSeed1       = 0x7e646e2c
+	bloomDataHeaderSize  = 12
+	bloomFilterTruncated = 0xff
+)
+
+// BloomFilter is a changed-path Bloom filter of a commit. It holds the paths
+// changed by a commit compared to its first parent, or to the empty tree for
+// root commits, along with all their leading directories.
+type BloomFilter struct {
+	data []byte
+}
+
+// NewBloomFilter returns a Bloom filter holding the given changed paths and
+// their leading directories. Commits changing too many paths get a filter
+// with all the bits set, which matches any path.
+func NewBloomFilter(paths []string) *BloomFilter {
+	keys := make(map[string]bool)
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		for p != "" {
+			keys[p] = true
+
+			i := strings.LastIndex(p, "/")
+			if i == -1 {
+				break
+			}
+
+			p = p[:i]
+		}
+	}
+
+	if len(keys) > bloomMaxChangedPaths {
+		return &BloomFilter{data: []byte{bloomFilterTruncated}}
+	}
+
+	size := (len(keys)*bloomBitsPerEntry + 7) / 8
+	if size == 0 {
+		size = 1
+	}
+
+	f := &BloomFilter{data: make([]byte, size)}
+	for k := range keys {
+		f.add(k)
+	}
+
+	return f
+}
+
+// Contains returns false if the path is definitely not in the filter. A
+// true result means that the path may have been changed.
+func (f *BloomFilter) Contains(path string) bool {
+	if f == nil || len(f.data) == 0 {
+		return true
+	}
+
+	path = strings.Trim(path, "/")
+	for path != "" {
+		if !f.contains(path) {
+			return false
+		}
+
+		i := strings.LastIndex(path, "/")
+		if i == -1 {
+			break
+		}
+
+		path = path[:i]
+	}
+
+	return true
+}
+
+// Data returns the raw bits of the filter, as stored in the BDAT chunk.
+func (f *BloomFilter) Data() []byte {
+	return f.data
+}
+
+func (f *BloomFilter) add(key string) {
+	bits := uint32(len(f.data) * 8)
+	h0, h1 := bloomKeyHashes(key)
+	for i := uint32(0); i < bloomNumHashes; i++ {
+		pos := (h0 + i*h1) % bits
+		f.data[pos/8] |= 1 << (pos & 7)
+	}
+}
+
+func (f *BloomFilter) contains(key string) bool {
+	bits := uint32(len(f.data) * 8)
+	h0, h1 := bloomKeyHashes(key)
+	for i := uint32(0); i < bloomNumHashes; i++ {
+		pos := (h0 + i*h1) % bits
+		if f.data[pos/8]&(1<<(pos&7)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func bloomKeyHashes(key string) (uint32, uint32) {
+	return murmur3(bloomHashSeed0, key), murmur3(bloomHashSeed1, key)
+}
+
+// murmur3 is the 32-bit MurmurHash3 as implemented by Git for the version 1
+// of the changed-path Bloom filters. Git reads the bytes as signed chars, so
+// the bytes above 0x7f are sign extended.
+func murmur3(seed uint32, data string) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+		r1 = 15
+		r2 = 13
+		m  = 5
+		n  = 0xe6546b64
+	)
+
+	h := seed
+	blocks := len(data) / 4
+	for i := 0; i < blocks; i++ {
+		k := signExtend(data[4*i]) |
+			signExtend(data[4*i+1])<<8 |
+			signExtend(data[4*i+2])<<16 |
+			signExtend(data[4*i+3])<<24
+
+		k *= c1
+		k = k<<r1 | k>>(32-r1)
+		k *= c2
+
+		h ^= k
+		h = h<<r2 | h>>(32-r2)
+		h = h*m + n
+	}
+
+	tail := data[blocks*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= signExtend(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= signExtend(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= signExtend(tail[0])
+		k *= c1
+		k = k<<r1 | k>>(32-r1)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+
+	return h
+}
+
+func signExtend(b byte) uint32 {
+	return uint32(int32(int8(b)))
+}
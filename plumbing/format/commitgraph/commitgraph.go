@@ -18,6 +18,10 @@ type CommitData struct {
 	// Generation number is the pre-computed generation in the commit graph
 	// or zero if not available
 	Generation int
+	// GenerationV2 is the corrected commit date of the commit, the maximum
+	// between its commit time and one more than the corrected commit dates
+	// of its parents, or zero if not available.
+	GenerationV2 uint64
 	// When is the timestamp of the commit.
 	When time.Time
 }
@@ -33,3 +37,11 @@ type Index interface {
 	// Hashes returns all the hashes that are available in the index
 	Hashes() []plumbing.Hash
 }
+
+// BloomFilterIndex is implemented by the indexes holding changed-path Bloom
+// filters for their commits.
+type BloomFilterIndex interface {
+	// GetBloomFilterByIndex gets the changed-path Bloom filter of the commit
+	// at the given index, it returns a nil filter if it's not available.
+	GetBloomFilterByIndex(i int) (*BloomFilter, error)
+}
@@ -1,10 +1,13 @@
 package commitgraph_test
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 	fixtures "gopkg.in/src-d/go-git-fixtures.v3"
@@ -130,3 +133,139 @@ func (s *CommitgraphSuite) TestReencodeInMemory(c *C) {
 		testDecodeHelper(c, tmpName)
 	})
 }
+
+func (s *CommitgraphSuite) TestBloomFilter(c *C) {
+	filter := commitgraph.NewBloomFilter([]string{"go/example.go", "README"})
+	c.Assert(filter.Contains("go/example.go"), Equals, true)
+	c.Assert(filter.Contains("go"), Equals, true)
+	c.Assert(filter.Contains("README"), Equals, true)
+	c.Assert(filter.Contains("vendor/foo.go"), Equals, false)
+	c.Assert(filter.Contains("go/other.go"), Equals, false)
+
+	empty := commitgraph.NewBloomFilter(nil)
+	c.Assert(empty.Data(), DeepEquals, []byte{0})
+	c.Assert(empty.Contains("README"), Equals, false)
+
+	var paths []string
+	for i := 0; i < 600; i++ {
+		paths = append(paths, fmt.Sprintf("file%d", i))
+	}
+
+	large := commitgraph.NewBloomFilter(paths)
+	c.Assert(large.Data(), DeepEquals, []byte{0xff})
+	c.Assert(large.Contains("README"), Equals, true)
+}
+
+func (s *CommitgraphSuite) TestReencodeBloomFilters(c *C) {
+	fixtures.ByTag("commit-graph").Test(c, func(f *fixtures.Fixture) {
+		dotgit := f.DotGit()
+
+		reader, err := os.Open(path.Join(dotgit.Root(), "objects", "info", "commit-graph"))
+		c.Assert(err, IsNil)
+		index, err := commitgraph.OpenFileIndex(reader)
+		c.Assert(err, IsNil)
+
+		filters := make(map[plumbing.Hash]*commitgraph.BloomFilter)
+		memoryIndex := commitgraph.NewMemoryIndex()
+		for i, hash := range index.Hashes() {
+			commitData, err := index.GetCommitDataByIndex(i)
+			c.Assert(err, IsNil)
+			memoryIndex.Add(hash, commitData)
+
+			// Leave one of the commits without a filter
+			if i == 0 {
+				continue
+			}
+
+			filters[hash] = commitgraph.NewBloomFilter([]string{hash.String()[:8] + "/file"})
+			c.Assert(memoryIndex.AddBloomFilter(hash, filters[hash]), IsNil)
+		}
+		reader.Close()
+
+		writer, err := ioutil.TempFile(dotgit.Root(), "commit-graph")
+		c.Assert(err, IsNil)
+		tmpName := writer.Name()
+		defer os.Remove(tmpName)
+		encoder := commitgraph.NewEncoder(writer)
+		err = encoder.Encode(memoryIndex)
+		c.Assert(err, IsNil)
+		writer.Close()
+
+		testDecodeHelper(c, tmpName)
+
+		reader, err = os.Open(tmpName)
+		c.Assert(err, IsNil)
+		defer reader.Close()
+		index, err = commitgraph.OpenFileIndex(reader)
+		c.Assert(err, IsNil)
+
+		bloomIndex, ok := index.(commitgraph.BloomFilterIndex)
+		c.Assert(ok, Equals, true)
+		for _, hash := range index.Hashes() {
+			i, err := index.GetIndexByHash(hash)
+			c.Assert(err, IsNil)
+			filter, err := bloomIndex.GetBloomFilterByIndex(i)
+			c.Assert(err, IsNil)
+
+			expected, ok := filters[hash]
+			if !ok {
+				c.Assert(filter.Data(), HasLen, 0)
+				c.Assert(filter.Contains("foo"), Equals, true)
+				continue
+			}
+
+			c.Assert(filter.Data(), DeepEquals, expected.Data())
+			c.Assert(filter.Contains(hash.String()[:8]+"/file"), Equals, true)
+		}
+	})
+}
+
+func (s *CommitgraphSuite) TestDecodeWithoutBloomFilters(c *C) {
+	fixtures.ByTag("commit-graph").Test(c, func(f *fixtures.Fixture) {
+		dotgit := f.DotGit()
+
+		reader, err := os.Open(path.Join(dotgit.Root(), "objects", "info", "commit-graph"))
+		c.Assert(err, IsNil)
+		defer reader.Close()
+		index, err := commitgraph.OpenFileIndex(reader)
+		c.Assert(err, IsNil)
+
+		filter, err := index.(commitgraph.BloomFilterIndex).GetBloomFilterByIndex(0)
+		c.Assert(err, IsNil)
+		c.Assert(filter, IsNil)
+	})
+}
+
+func (s *CommitgraphSuite) TestGenerationData(c *C) {
+	root := plumbing.NewHash("1111111111111111111111111111111111111111")
+	child := plumbing.NewHash("2222222222222222222222222222222222222222")
+	skewed := plumbing.NewHash("3333333333333333333333333333333333333333")
+
+	// The skewed commit is much older than its parent, so its corrected
+	// commit date offset doesn't fit in 31 bits.
+	index := commitgraph.NewMemoryIndex()
+	index.Add(root, &commitgraph.CommitData{When: time.Unix(1<<32, 0)})
+	index.Add(child, &commitgraph.CommitData{ParentHashes: []plumbing.Hash{root}, When: time.Unix(1<<32+10, 0)})
+	index.Add(skewed, &commitgraph.CommitData{ParentHashes: []plumbing.Hash{child}, When: time.Unix(100, 0)})
+	c.Assert(index.ComputeGenerations(), IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(commitgraph.NewEncoder(buf).Encode(index), IsNil)
+	decoded, err := commitgraph.OpenFileIndex(bytes.NewReader(buf.Bytes()))
+	c.Assert(err, IsNil)
+
+	expected := map[plumbing.Hash][]uint64{
+		root:   {1, 1 << 32},
+		child:  {2, 1<<32 + 10},
+		skewed: {3, 1<<32 + 11},
+	}
+
+	for h, e := range expected {
+		i, err := decoded.GetIndexByHash(h)
+		c.Assert(err, IsNil)
+		data, err := decoded.GetCommitDataByIndex(i)
+		c.Assert(err, IsNil)
+		c.Assert(uint64(data.Generation), Equals, e[0])
+		c.Assert(data.GenerationV2, Equals, e[1])
+	}
+}
@@ -94,6 +94,54 @@
 //       positions for the parents until reaching a value with the most-significant
 //       bit on. The other bits correspond to the position of the last parent.
 //
+//   Generation Data (ID: {'G', 'D', 'A', 'T' }) (N * 4 bytes) [Optional]
+//     * This list of 4-byte values store corrected commit date offsets for the
+//       commits, arranged in the same order as commit data chunk.
+//     * If the corrected commit date offset cannot be stored within 31 bits,
+//       the value has its most-significant bit on and the other bits store
+//       the position of corrected commit date into the Generation Data Overflow
+//       chunk.
+//     * The corrected commit date of a commit is the maximum between its
+//       commit time and one more than the corrected commit dates of its
+//       parents.
+//
+//   Generation Data Overflow (ID: {'G', 'D', 'O', 'V' }) [Optional]
+//     * This list of 8-byte values stores the corrected commit date offsets
+//       for commits with corrected commit date offsets that cannot be
+//       stored within 31 bits.
+//     * Generation Data Overflow chunk is present only when Generation Data
+//       chunk is present and at least one corrected commit date offset cannot
+//       be stored within 31 bits.
+//
+//   Bloom Filter Index (ID: {'B', 'I', 'D', 'X'}) (N * 4 bytes) [Optional]
+//     * The ith entry, BIDX[i], stores the number of bytes in all Bloom filters
+//       from commit 0 to commit i (inclusive) in lexicographic order. The Bloom
+//       filter for the i-th commit spans from BIDX[i-1] to BIDX[i] (plus header
+//       length), where BIDX[-1] is 0.
+//     * The BIDX chunk is ignored if the BDAT chunk is not present.
+//
+//   Bloom Filter Data (ID: {'B', 'D', 'A', 'T'}) [Optional]
+//     * It starts with header consisting of three unsigned 32-bit integers:
+//       - Version of the hash algorithm being used. We currently only support
+//         value 1 which corresponds to the 32-bit version of the murmur3 hash
+//         implemented exactly as described in
+//         https://en.wikipedia.org/wiki/MurmurHash#Algorithm and the double
+//         hashing technique using seed values 0x293ae76f and 0x7e646e2c as
+//         described in https://doi.org/10.1007/978-3-540-30494-4_26 "Bloom
+//         Filters in Probabilistic Verification"
+//       - The number of times a path is hashed and hence the number of bit
+//         positions that cumulatively determine whether a file is present in
+//         the commit.
+//       - The minimum number of bits 'b' per entry in the Bloom filter. If the
+//         filter contains 'n' entries, then the filter size is the minimum
+//         number of bytes that contain n*b bits.
+//     * The rest of the chunk is the concatenation of all the computed Bloom
+//       filters for the commits in lexicographic order.
+//     * Note: Commits with no changes or more than 512 changes have Bloom
+//       filters of length one, with either all bits set to zero or one
+//       respectively.
+//     * The BDAT chunk is present if and only if BIDX is present.
+//
 // TRAILER:
 //
 // 	H-byte HASH-checksum of all of the above.
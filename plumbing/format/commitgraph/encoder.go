@@ -37,6 +37,37 @@ func (e *Encoder) Encode(idx Index) error {
 		chunkSizes = append(chunkSizes, uint64(extraEdgesCount)*4)
 	}
 
+	generationData, generationOverflows, err := e.prepareGenerationData(idx, hashes)
+	if err != nil {
+		return err
+	}
+
+	if generationData != nil {
+		chunkSignatures = append(chunkSignatures, generationDataSignature)
+		chunkSizes = append(chunkSizes, uint64(len(generationData))*4)
+		if len(generationOverflows) > 0 {
+			chunkSignatures = append(chunkSignatures, generationDataOverflowSignature)
+			chunkSizes = append(chunkSizes, uint64(len(generationOverflows))*8)
+		}
+	}
+
+	bloomFilters, err := e.prepareBloomFilters(idx, hashes)
+	if err != nil {
+		return err
+	}
+
+	if bloomFilters != nil {
+		var bloomDataSize uint64
+		for _, f := range bloomFilters {
+			if f != nil {
+				bloomDataSize += uint64(len(f.data))
+			}
+		}
+
+		chunkSignatures = append(chunkSignatures, bloomIndexSignature, bloomDataSignature)
+		chunkSizes = append(chunkSizes, uint64(len(hashes))*4, bloomDataHeaderSize+bloomDataSize)
+	}
+
 	if err := e.encodeFileHeader(len(chunkSignatures)); err != nil {
 		return err
 	}
@@ -56,6 +87,16 @@ func (e *Encoder) Encode(idx Index) error {
 	} else {
 		return err
 	}
+	if generationData != nil {
+		if err := e.encodeGenerationData(generationData, generationOverflows); err != nil {
+			return err
+		}
+	}
+	if bloomFilters != nil {
+		if err := e.encodeBloomFilters(bloomFilters); err != nil {
+			return err
+		}
+	}
 
 	return e.encodeChecksum()
 }
@@ -87,6 +128,74 @@ func (e *Encoder) prepare(idx Index, hashes []plumbing.Hash) (hashToIndex map[pl
 	return
 }
 
+// prepareGenerationData returns the corrected commit date offsets of the given
+// hashes, in the same order, along with the offsets not fitting in 31 bits. It
+// returns nil if any of the commits doesn't have a corrected commit date.
+func (e *Encoder) prepareGenerationData(idx Index, hashes []plumbing.Hash) (data []uint32, overflows []uint64, err error) {
+	if len(hashes) == 0 {
+		return nil, nil, nil
+	}
+
+	data = make([]uint32, len(hashes))
+	for i, hash := range hashes {
+		origIndex, err := idx.GetIndexByHash(hash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		commitData, err := idx.GetCommitDataByIndex(origIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		commitTime := uint64(commitData.When.Unix())
+		if commitData.GenerationV2 == 0 || commitData.GenerationV2 < commitTime {
+			return nil, nil, nil
+		}
+
+		offset := commitData.GenerationV2 - commitTime
+		if offset > uint64(generationOverflowMask) {
+			data[i] = uint32(len(overflows)) | generationOverflow
+			overflows = append(overflows, offset)
+			continue
+		}
+
+		data[i] = uint32(offset)
+	}
+
+	return data, overflows, nil
+}
+
+// prepareBloomFilters returns the changed-path Bloom filters of the given
+// hashes, in the same order, or nil if the index doesn't hold any filter.
+func (e *Encoder) prepareBloomFilters(idx Index, hashes []plumbing.Hash) ([]*BloomFilter, error) {
+	bidx, ok := idx.(BloomFilterIndex)
+	if !ok {
+		return nil, nil
+	}
+
+	filters := make([]*BloomFilter, len(hashes))
+	found := false
+	for i, hash := range hashes {
+		origIndex, err := idx.GetIndexByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if filters[i], err = bidx.GetBloomFilterByIndex(origIndex); err != nil {
+			return nil, err
+		}
+
+		found = found || filters[i] != nil
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return filters, nil
+}
+
 func (e *Encoder) encodeFileHeader(chunkCount int) (err error) {
 	if _, err = e.Write(commitFileSignature); err == nil {
 		_, err = e.Write([]byte{1, 1, byte(chunkCount), 0})
@@ -182,6 +291,50 @@ func (e *Encoder) encodeExtraEdges(extraEdges []uint32) (err error) {
 	return
 }
 
+func (e *Encoder) encodeGenerationData(data []uint32, overflows []uint64) (err error) {
+	for _, offset := range data {
+		if err = binary.WriteUint32(e, offset); err != nil {
+			return
+		}
+	}
+	for _, offset := range overflows {
+		if err = binary.WriteUint64(e, offset); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// encodeBloomFilters writes the BIDX and BDAT chunks, the commits without a
+// filter are stored with an empty one, meaning that it's not computed.
+func (e *Encoder) encodeBloomFilters(filters []*BloomFilter) (err error) {
+	var offset uint32
+	for _, f := range filters {
+		if f != nil {
+			offset += uint32(len(f.data))
+		}
+		if err = binary.WriteUint32(e, offset); err != nil {
+			return
+		}
+	}
+
+	for _, v := range []uint32{BloomFilterVersion, bloomNumHashes, bloomBitsPerEntry} {
+		if err = binary.WriteUint32(e, v); err != nil {
+			return
+		}
+	}
+
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if _, err = e.Write(f.data); err != nil {
+			return
+		}
+	}
+	return
+}
+
 func (e *Encoder) encodeChecksum() error {
 	_, err := e.Write(e.hash.Sum(nil)[:20])
 	return err
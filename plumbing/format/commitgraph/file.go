@@ -23,17 +23,24 @@ var (
 	// graph file is corrupted.
 	ErrMalformedCommitGraphFile = errors.New("Malformed commit graph file")
 
-	commitFileSignature    = []byte{'C', 'G', 'P', 'H'}
-	oidFanoutSignature     = []byte{'O', 'I', 'D', 'F'}
-	oidLookupSignature     = []byte{'O', 'I', 'D', 'L'}
-	commitDataSignature    = []byte{'C', 'D', 'A', 'T'}
-	extraEdgeListSignature = []byte{'E', 'D', 'G', 'E'}
-	lastSignature          = []byte{0, 0, 0, 0}
+	commitFileSignature             = []byte{'C', 'G', 'P', 'H'}
+	oidFanoutSignature              = []byte{'O', 'I', 'D', 'F'}
+	oidLookupSignature              = []byte{'O', 'I', 'D', 'L'}
+	commitDataSignature             = []byte{'C', 'D', 'A', 'T'}
+	extraEdgeListSignature          = []byte{'E', 'D', 'G', 'E'}
+	generationDataSignature         = []byte{'G', 'D', 'A', 'T'}
+	generationDataOverflowSignature = []byte{'G', 'D', 'O', 'V'}
+	bloomIndexSignature             = []byte{'B', 'I', 'D', 'X'}
+	bloomDataSignature              = []byte{'B', 'D', 'A', 'T'}
+	lastSignature                   = []byte{0, 0, 0, 0}
 
 	parentNone        = uint32(0x70000000)
 	parentOctopusUsed = uint32(0x80000000)
 	parentOctopusMask = uint32(0x7fffffff)
 	parentLast        = uint32(0x80000000)
+
+	generationOverflow     = uint32(0x80000000)
+	generationOverflowMask = uint32(0x7fffffff)
 )
 
 type fileIndex struct {
@@ -43,6 +50,11 @@ type fileIndex struct {
 	oidLookupOffset     int64
 	commitDataOffset    int64
 	extraEdgeListOffset int64
+	bloomIndexOffset    int64
+	bloomDataOffset     int64
+
+	generationDataOffset         int64
+	generationDataOverflowOffset int64
 }
 
 // OpenFileIndex opens a serialized commit graph file in the format described at
@@ -59,6 +71,9 @@ func OpenFileIndex(reader io.ReaderAt) (Index, error) {
 	if err := fi.readFanout(); err != nil {
 		return nil, err
 	}
+	if err := fi.readBloomDataHeader(); err != nil {
+		return nil, err
+	}
 
 	return fi, nil
 }
@@ -108,6 +123,14 @@ func (fi *fileIndex) readChunkHeaders() error {
 			fi.commitDataOffset = int64(chunkOffset)
 		} else if bytes.Equal(chunkID, extraEdgeListSignature) {
 			fi.extraEdgeListOffset = int64(chunkOffset)
+		} else if bytes.Equal(chunkID, generationDataSignature) {
+			fi.generationDataOffset = int64(chunkOffset)
+		} else if bytes.Equal(chunkID, generationDataOverflowSignature) {
+			fi.generationDataOverflowOffset = int64(chunkOffset)
+		} else if bytes.Equal(chunkID, bloomIndexSignature) {
+			fi.bloomIndexOffset = int64(chunkOffset)
+		} else if bytes.Equal(chunkID, bloomDataSignature) {
+			fi.bloomDataOffset = int64(chunkOffset)
 		} else if bytes.Equal(chunkID, lastSignature) {
 			break
 		}
@@ -135,6 +158,35 @@ func (fi *fileIndex) readFanout() error {
 	return nil
 }
 
+// readBloomDataHeader checks the settings of the changed-path Bloom filters,
+// the filters are ignored if they were computed with unsupported settings.
+func (fi *fileIndex) readBloomDataHeader() error {
+	if fi.bloomIndexOffset <= 0 || fi.bloomDataOffset <= 0 {
+		fi.bloomIndexOffset, fi.bloomDataOffset = 0, 0
+		return nil
+	}
+
+	header := io.NewSectionReader(fi.reader, fi.bloomDataOffset, bloomDataHeaderSize)
+	version, err := binary.ReadUint32(header)
+	if err != nil {
+		return err
+	}
+	numHashes, err := binary.ReadUint32(header)
+	if err != nil {
+		return err
+	}
+	bitsPerEntry, err := binary.ReadUint32(header)
+	if err != nil {
+		return err
+	}
+
+	if version != BloomFilterVersion || numHashes != bloomNumHashes || bitsPerEntry != bloomBitsPerEntry {
+		fi.bloomIndexOffset, fi.bloomDataOffset = 0, 0
+	}
+
+	return nil
+}
+
 func (fi *fileIndex) GetIndexByHash(h plumbing.Hash) (int, error) {
 	var oid plumbing.Hash
 
@@ -220,15 +272,90 @@ func (fi *fileIndex) GetCommitDataByIndex(idx int) (*CommitData, error) {
 		return nil, err
 	}
 
+	commitTime := genAndTime & 0x3FFFFFFFF
+	generationV2, err := fi.getGenerationV2(idx, commitTime)
+	if err != nil {
+		return nil, err
+	}
+
 	return &CommitData{
 		TreeHash:      treeHash,
 		ParentIndexes: parentIndexes,
 		ParentHashes:  parentHashes,
 		Generation:    int(genAndTime >> 34),
-		When:          time.Unix(int64(genAndTime&0x3FFFFFFFF), 0),
+		GenerationV2:  generationV2,
+		When:          time.Unix(int64(commitTime), 0),
 	}, nil
 }
 
+// getGenerationV2 returns the corrected commit date of the commit at the
+// given index, or zero if the file doesn't contain the generation data chunk.
+func (fi *fileIndex) getGenerationV2(idx int, commitTime uint64) (uint64, error) {
+	if fi.generationDataOffset <= 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, 8)
+	if _, err := fi.reader.ReadAt(buf[:4], fi.generationDataOffset+int64(idx)*4); err != nil {
+		return 0, err
+	}
+
+	offset := encbin.BigEndian.Uint32(buf[:4])
+	if offset&generationOverflow == 0 {
+		return commitTime + uint64(offset), nil
+	}
+
+	if fi.generationDataOverflowOffset <= 0 {
+		return 0, ErrMalformedCommitGraphFile
+	}
+
+	pos := fi.generationDataOverflowOffset + int64(offset&generationOverflowMask)*8
+	if _, err := fi.reader.ReadAt(buf, pos); err != nil {
+		return 0, err
+	}
+
+	return commitTime + encbin.BigEndian.Uint64(buf), nil
+}
+
+// GetBloomFilterByIndex gets the changed-path Bloom filter of the commit at
+// the given index, it returns a nil filter if the file doesn't contain them.
+func (fi *fileIndex) GetBloomFilterByIndex(idx int) (*BloomFilter, error) {
+	if idx >= fi.fanout[0xff] {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	if fi.bloomIndexOffset == 0 {
+		return nil, nil
+	}
+
+	var start uint32
+	buf := make([]byte, 4)
+	if idx > 0 {
+		if _, err := fi.reader.ReadAt(buf, fi.bloomIndexOffset+int64(idx-1)*4); err != nil {
+			return nil, err
+		}
+
+		start = encbin.BigEndian.Uint32(buf)
+	}
+
+	if _, err := fi.reader.ReadAt(buf, fi.bloomIndexOffset+int64(idx)*4); err != nil {
+		return nil, err
+	}
+
+	end := encbin.BigEndian.Uint32(buf)
+	if end < start {
+		return nil, ErrMalformedCommitGraphFile
+	}
+
+	data := make([]byte, end-start)
+	offset := fi.bloomDataOffset + bloomDataHeaderSize + int64(start)
+	if _, err := fi.reader.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+
+	return &BloomFilter{data: data}, nil
+}
+
 func (fi *fileIndex) getHashesFromIndexes(indexes []int) ([]plumbing.Hash, error) {
 	hashes := make([]plumbing.Hash, len(indexes))
 
@@ -7,14 +7,16 @@ import (
 // MemoryIndex provides a way to build the commit-graph in memory
 // for later encoding to file.
 type MemoryIndex struct {
-	commitData []*CommitData
-	indexMap   map[plumbing.Hash]int
+	commitData   []*CommitData
+	indexMap     map[plumbing.Hash]int
+	bloomFilters map[int]*BloomFilter
 }
 
 // NewMemoryIndex creates in-memory commit graph representation
 func NewMemoryIndex() *MemoryIndex {
 	return &MemoryIndex{
-		indexMap: make(map[plumbing.Hash]int),
+		indexMap:     make(map[plumbing.Hash]int),
+		bloomFilters: make(map[int]*BloomFilter),
 	}
 }
 
@@ -70,3 +72,84 @@ func (mi *MemoryIndex) Add(hash plumbing.Hash, commitData *CommitData) {
 	mi.indexMap[hash] = len(mi.commitData)
 	mi.commitData = append(mi.commitData, commitData)
 }
+
+// AddBloomFilter sets the changed-path Bloom filter of a commit already added
+// to the memory index.
+func (mi *MemoryIndex) AddBloomFilter(hash plumbing.Hash, filter *BloomFilter) error {
+	i, err := mi.GetIndexByHash(hash)
+	if err != nil {
+		return err
+	}
+
+	mi.bloomFilters[i] = filter
+	return nil
+}
+
+// GetBloomFilterByIndex gets the changed-path Bloom filter of the commit at
+// the given index, if available
+func (mi *MemoryIndex) GetBloomFilterByIndex(i int) (*BloomFilter, error) {
+	if i >= len(mi.commitData) {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return mi.bloomFilters[i], nil
+}
+
+// ComputeGenerations sets the generation numbers of all the commits in the
+// index, both the topological levels and the corrected commit dates. All the
+// parents of the commits must be in the index.
+func (mi *MemoryIndex) ComputeGenerations() error {
+	done := make([]bool, len(mi.commitData))
+	for i := range mi.commitData {
+		if done[i] {
+			continue
+		}
+
+		// Iterative depth-first walk, a commit is computed once all its
+		// parents are.
+		stack := []int{i}
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			commitData, err := mi.GetCommitDataByIndex(current)
+			if err != nil {
+				return err
+			}
+
+			pending := false
+			for _, p := range commitData.ParentIndexes {
+				if !done[p] {
+					stack = append(stack, p)
+					pending = true
+				}
+			}
+
+			if pending {
+				continue
+			}
+
+			stack = stack[:len(stack)-1]
+			if done[current] {
+				continue
+			}
+
+			generation := 1
+			generationV2 := uint64(commitData.When.Unix())
+			for _, p := range commitData.ParentIndexes {
+				parent := mi.commitData[p]
+				if parent.Generation >= generation {
+					generation = parent.Generation + 1
+				}
+
+				if parent.GenerationV2 >= generationV2 {
+					generationV2 = parent.GenerationV2 + 1
+				}
+			}
+
+			commitData.Generation = generation
+			commitData.GenerationV2 = generationV2
+			done[current] = true
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package packfile
 import (
 	"bytes"
 	"compress/zlib"
+	"errors"
 	"io"
 	"sync"
 
@@ -27,15 +28,26 @@ const (
 // UpdateObjectStorage updates the storer with the objects in the given
 // packfile.
 func UpdateObjectStorage(s storer.Storer, packfile io.Reader) error {
+	return UpdateObjectStorageWithLimits(s, packfile, Limits{})
+}
+
+// UpdateObjectStorageWithLimits updates the storer with the objects in the
+// given packfile, failing if it exceeds the given limits or an observer
+// fails while it's parsed.
+func UpdateObjectStorageWithLimits(s storer.Storer, packfile io.Reader, l Limits, ob ...Observer) error {
 	if pw, ok := s.(storer.PackfileWriter); ok {
-		return WritePackfileToObjectStorage(pw, packfile)
+		err := writePackfileToObjectStorage(pw, packfile, l, ob...)
+		if err != errParserNotSupported {
+			return err
+		}
 	}
 
-	p, err := NewParserWithStorage(NewScanner(packfile), s)
+	p, err := NewParserWithStorage(NewScanner(packfile), s, ob...)
 	if err != nil {
 		return err
 	}
 
+	p.SetLimits(l)
 	_, err = p.Parse()
 	return err
 }
@@ -45,6 +57,28 @@ func UpdateObjectStorage(s storer.Storer, packfile io.Reader) error {
 func WritePackfileToObjectStorage(
 	sw storer.PackfileWriter,
 	packfile io.Reader,
+) (err error) {
+	return writePackfileToObjectStorage(sw, packfile, Limits{})
+}
+
+// parsingWriter is implemented by the packfile writers parsing the packfile
+// written to them, which can enforce limits and notify observers, set
+// before the first write.
+type parsingWriter interface {
+	SetLimits(Limits)
+	AddObserver(Observer)
+}
+
+// errParserNotSupported is returned by writePackfileToObjectStorage when the
+// packfile writer can't enforce the limits or notify the observers, before
+// anything is written.
+var errParserNotSupported = errors.New("packfile writer doesn't support limits nor observers")
+
+func writePackfileToObjectStorage(
+	sw storer.PackfileWriter,
+	packfile io.Reader,
+	l Limits,
+	ob ...Observer,
 ) (err error) {
 	w, err := sw.PackfileWriter()
 	if err != nil {
@@ -53,6 +87,18 @@ func WritePackfileToObjectStorage(
 
 	defer ioutil.CheckClose(w, &err)
 
+	if l != (Limits{}) || len(ob) != 0 {
+		pw, ok := w.(parsingWriter)
+		if !ok {
+			return errParserNotSupported
+		}
+
+		pw.SetLimits(l)
+		for _, o := range ob {
+			pw.AddObserver(o)
+		}
+	}
+
 	var n int64
 	n, err = io.Copy(w, packfile)
 	if err == nil && n == 0 {
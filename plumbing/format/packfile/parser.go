@@ -22,8 +22,26 @@ var (
 
 	// ErrDeltaNotCached is returned when the delta could not be found in cache.
 	ErrDeltaNotCached = errors.New("delta could not be found in cache")
+
+	// ErrMaxObjectsExceeded is returned when the packfile holds more objects
+	// than allowed by the parser limits.
+	ErrMaxObjectsExceeded = errors.New("packfile exceeds the maximum number of objects")
+
+	// ErrMaxObjectSizeExceeded is returned when an object of the packfile is
+	// bigger than allowed by the parser limits.
+	ErrMaxObjectSizeExceeded = errors.New("object exceeds the maximum size")
 )
 
+// Limits are the limits a Parser enforces on a packfile. The zero values
+// mean no limit.
+type Limits struct {
+	// MaxObjects is the maximum number of objects in the packfile.
+	MaxObjects uint32
+	// MaxObjectSize is the maximum size of an object, once inflated and,
+	// for the deltas, resolved.
+	MaxObjectSize int64
+}
+
 // Observer interface is implemented by index encoders.
 type Observer interface {
 	// OnHeader is called when a new packfile is opened.
@@ -52,7 +70,8 @@ type Parser struct {
 	// delta content by offset, only used if source is not seekable
 	deltas map[int64][]byte
 
-	ob []Observer
+	limits Limits
+	ob     []Observer
 }
 
 // NewParser creates a new Parser. The Scanner source must be seekable.
@@ -87,6 +106,12 @@ func NewParserWithStorage(
 	}, nil
 }
 
+// SetLimits sets the limits enforced while parsing, before any object is
+// inflated.
+func (p *Parser) SetLimits(l Limits) {
+	p.limits = l
+}
+
 func (p *Parser) forEachObserver(f func(o Observer) error) error {
 	for _, o := range p.ob {
 		if err := f(o); err != nil {
@@ -162,6 +187,10 @@ func (p *Parser) init() error {
 		return err
 	}
 
+	if p.limits.MaxObjects != 0 && c > p.limits.MaxObjects {
+		return ErrMaxObjectsExceeded
+	}
+
 	if err := p.onHeader(c); err != nil {
 		return err
 	}
@@ -216,6 +245,10 @@ func (p *Parser) indexObjects() error {
 			parent.Children = append(parent.Children, ota)
 
 		default:
+			if p.exceedsMaxObjectSize(oh.Length) {
+				return ErrMaxObjectSizeExceeded
+			}
+
 			ota = newBaseObject(oh.Offset, oh.Length, t)
 		}
 
@@ -224,6 +257,10 @@ func (p *Parser) indexObjects() error {
 			return err
 		}
 
+		if delta && p.exceedsMaxObjectSize(deltaTargetSize(buf.Bytes())) {
+			return ErrMaxObjectSizeExceeded
+		}
+
 		ota.Crc32 = crc
 		ota.Length = oh.Length
 
@@ -263,6 +300,26 @@ func (p *Parser) indexObjects() error {
 	return nil
 }
 
+func (p *Parser) exceedsMaxObjectSize(size int64) bool {
+	return p.limits.MaxObjectSize != 0 && size > p.limits.MaxObjectSize
+}
+
+// deltaTargetSize returns the size of the object resulting of applying the
+// given delta.
+func deltaTargetSize(delta []byte) int64 {
+	if len(delta) == 0 {
+		return 0
+	}
+
+	_, delta = decodeLEB128(delta)
+	if len(delta) == 0 {
+		return 0
+	}
+
+	sz, _ := decodeLEB128(delta)
+	return int64(sz)
+}
+
 func (p *Parser) resolveDeltas() error {
 	buf := &bytes.Buffer{}
 	for _, obj := range p.oi {
@@ -77,6 +77,46 @@ func (s *ParserSuite) TestParserHashes(c *C) {
 	c.Assert(obs.objects, DeepEquals, objs)
 }
 
+func (s *ParserSuite) TestParserLimits(c *C) {
+	f := fixtures.Basic().One()
+
+	for _, l := range []packfile.Limits{
+		{MaxObjects: 31},
+		{MaxObjectSize: 217848},
+	} {
+		parser, err := packfile.NewParser(packfile.NewScanner(f.Packfile()))
+		c.Assert(err, IsNil)
+
+		parser.SetLimits(l)
+		_, err = parser.Parse()
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *ParserSuite) TestParserMaxObjects(c *C) {
+	f := fixtures.Basic().One()
+	obs := new(testObserver)
+	parser, err := packfile.NewParser(packfile.NewScanner(f.Packfile()), obs)
+	c.Assert(err, IsNil)
+
+	parser.SetLimits(packfile.Limits{MaxObjects: 30})
+	_, err = parser.Parse()
+	c.Assert(err, Equals, packfile.ErrMaxObjectsExceeded)
+	c.Assert(obs.objects, HasLen, 0)
+}
+
+func (s *ParserSuite) TestParserMaxObjectSize(c *C) {
+	f := fixtures.Basic().One()
+	obs := new(testObserver)
+	parser, err := packfile.NewParser(packfile.NewScanner(f.Packfile()), obs)
+	c.Assert(err, IsNil)
+
+	parser.SetLimits(packfile.Limits{MaxObjectSize: 217847})
+	_, err = parser.Parse()
+	c.Assert(err, Equals, packfile.ErrMaxObjectSizeExceeded)
+	c.Assert(obs.objects, HasLen, 0)
+}
+
 func (s *ParserSuite) TestThinPack(c *C) {
 
 	// Initialize an empty repository
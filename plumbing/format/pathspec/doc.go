@@ -0,0 +1,28 @@
+// Package pathspec implements the pathspecs used by git to limit the paths an
+// operation applies to, as described in the pathspec entry of gitglossary.
+//
+// A pathspec is a path, relative to the current directory, or a pattern
+// matched against the paths. By default the wildcards of the pattern, "*", "?"
+// and "[...]", also match the slashes, and a path matches a pathspec without
+// wildcards when it's equal to it or it's inside of the directory named by it.
+//
+// A pathspec starting with a colon has magic words changing its meaning,
+// either in its long form, a comma separated list of words in parenthesis, as
+// in ":(glob,icase)*.go", or in its short form, a sequence of magic signatures
+// ended by a colon, as in ":!:vendor". The magic words are:
+//
+//   - top (":/"): the pathspec is relative to the root of the worktree,
+//     instead of the current directory.
+//   - literal: the wildcards are matched literally.
+//   - glob: the pattern is matched as a shell glob, with the wildcards not
+//     matching the slashes, except "**" in "**/", "/**" and "/**/", which
+//     matches any number of directories.
+//   - icase: the pattern is matched ignoring the case.
+//   - attr: followed by a space separated list of attribute requirements,
+//     "NAME" when it's set, "-NAME" when it's unset, "!NAME" when it's
+//     unspecified and "NAME=VALUE" when it has the given value, the path must
+//     also meet all of them, according to the gitattributes.
+//   - exclude (":!" or ":^"): the paths matching the pathspec are excluded
+//     from the ones matching the others, or from all the paths if all the
+//     pathspecs are excluding ones.
+package pathspec
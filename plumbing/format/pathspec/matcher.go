@@ -0,0 +1,119 @@
+package pathspec
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+// Matcher matches the paths against a list of pathspecs.
+type Matcher interface {
+	// Match returns true if the path, relative to the root of the worktree,
+	// matches the pathspecs. If isDir is true, it returns true if the
+	// directory may contain paths matching them, so it should be walked.
+	Match(path string, isDir bool) bool
+}
+
+// NewMatcher returns a Matcher of the given pathspecs. A path matches them if
+// it matches any of the pathspecs without the exclude magic, or there isn't
+// any, and it doesn't match any of the excluding ones. The attributes of the
+// paths, required by the pathspecs with the attr magic, are taken from attrs,
+// which can be nil if there isn't any.
+func NewMatcher(ps []*Pathspec, attrs gitattributes.Matcher) Matcher {
+	m := &matcher{attrs: attrs}
+	for _, p := range ps {
+		if p.Exclude {
+			m.exclude = append(m.exclude, p)
+		} else {
+			m.include = append(m.include, p)
+		}
+
+		m.hasAttrs = m.hasAttrs || len(p.Attributes) != 0
+	}
+
+	return m
+}
+
+// ParseMatcher parses the pathspecs, given in the directory prefix of the
+// worktree, and returns a Matcher of them.
+func ParseMatcher(specs []string, prefix string, attrs gitattributes.Matcher) (Matcher, error) {
+	ps := make([]*Pathspec, 0, len(specs))
+	for _, spec := range specs {
+		p, err := Parse(spec, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, p)
+	}
+
+	return NewMatcher(ps, attrs), nil
+}
+
+// HasAttributes returns true if any of the pathspecs has the attr magic, so
+// the matching depends on the attributes of the paths.
+func HasAttributes(ps []*Pathspec) bool {
+	for _, p := range ps {
+		if len(p.Attributes) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+type matcher struct {
+	include  []*Pathspec
+	exclude  []*Pathspec
+	attrs    gitattributes.Matcher
+	hasAttrs bool
+}
+
+func (m *matcher) Match(path string, isDir bool) bool {
+	if isDir {
+		return m.matchDir(path)
+	}
+
+	var attrs map[string]gitattributes.Attribute
+	if m.hasAttrs && m.attrs != nil {
+		attrs, _ = m.attrs.Match(strings.Split(path, "/"), nil)
+	}
+
+	for _, p := range m.exclude {
+		if p.Match(path, attrs) {
+			return false
+		}
+	}
+
+	if len(m.include) == 0 {
+		return true
+	}
+
+	for _, p := range m.include {
+		if p.Match(path, attrs) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *matcher) matchDir(dir string) bool {
+	for _, p := range m.exclude {
+		if p.covers(dir) {
+			return false
+		}
+	}
+
+	if len(m.include) == 0 {
+		return true
+	}
+
+	for _, p := range m.include {
+		if p.leadsTo(dir) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,57 @@
+package pathspec
+
+import (
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+type MatcherSuite struct{}
+
+var _ = Suite(&MatcherSuite{})
+
+func (s *MatcherSuite) TestMatch(c *C) {
+	m, err := ParseMatcher([]string{"src", "docs/*.md", ":!src/vendor", ":(exclude)*_test.go"}, "", nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(m.Match("src/main.go", false), Equals, true)
+	c.Assert(m.Match("src/main_test.go", false), Equals, false)
+	c.Assert(m.Match("src/vendor/lib.go", false), Equals, false)
+	c.Assert(m.Match("docs/api/index.md", false), Equals, true)
+	c.Assert(m.Match("README.md", false), Equals, false)
+
+	c.Assert(m.Match("src", true), Equals, true)
+	c.Assert(m.Match("src/foo", true), Equals, true)
+	c.Assert(m.Match("src/vendor", true), Equals, false)
+	c.Assert(m.Match("docs", true), Equals, true)
+	c.Assert(m.Match("docs/api", true), Equals, true)
+	c.Assert(m.Match("lib", true), Equals, false)
+}
+
+func (s *MatcherSuite) TestMatchOnlyExclude(c *C) {
+	m, err := ParseMatcher([]string{":!vendor"}, "", nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(m.Match("main.go", false), Equals, true)
+	c.Assert(m.Match("vendor/lib.go", false), Equals, false)
+	c.Assert(m.Match("vendor", true), Equals, false)
+}
+
+func (s *MatcherSuite) TestMatchEmpty(c *C) {
+	m, err := ParseMatcher(nil, "", nil)
+	c.Assert(err, IsNil)
+	c.Assert(m.Match("foo", false), Equals, true)
+	c.Assert(m.Match("foo", true), Equals, true)
+}
+
+func (s *MatcherSuite) TestMatchAttributes(c *C) {
+	line, err := gitattributes.ParseAttributesLine("*.png binary", nil, false)
+	c.Assert(err, IsNil)
+	attrs := gitattributes.NewMatcher([]gitattributes.MatchAttribute{line})
+
+	m, err := ParseMatcher([]string{":(exclude,attr:binary)"}, "", attrs)
+	c.Assert(err, IsNil)
+
+	c.Assert(m.Match("foo/logo.png", false), Equals, false)
+	c.Assert(m.Match("foo/main.go", false), Equals, true)
+	c.Assert(m.Match("foo", true), Equals, true)
+}
@@ -0,0 +1,266 @@
+package pathspec
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+const (
+	magicPrefix    = ":"
+	magicSeparator = ","
+	attrPrefix     = "attr:"
+	wildcards      = "*?[\\"
+)
+
+var (
+	// ErrOutsideRepository is returned when a pathspec names a path outside of
+	// the worktree.
+	ErrOutsideRepository = errors.New("pathspec is outside of the repository")
+	// ErrIncompatibleMagic is returned when a pathspec has both the literal
+	// and the glob magic words.
+	ErrIncompatibleMagic = errors.New("literal and glob pathspec magic are incompatible")
+)
+
+// Pathspec is a parsed pathspec.
+type Pathspec struct {
+	// Original is the pathspec as given to Parse.
+	Original string
+	// Pattern is the path or the pattern of the pathspec, relative to the root
+	// of the worktree, without the magic.
+	Pattern string
+
+	Top     bool
+	Literal bool
+	Glob    bool
+	ICase   bool
+	Exclude bool
+	// Attributes are the requirements of the attr magic, all of them must be
+	// met by the matching paths.
+	Attributes []gitattributes.Attribute
+
+	// prefix is the part of the pattern without wildcards.
+	prefix string
+}
+
+// Parse parses a pathspec given in the directory prefix of the worktree,
+// relative to its root, with an empty prefix being the root.
+func Parse(spec, prefix string) (*Pathspec, error) {
+	p := &Pathspec{Original: spec}
+	pattern, err := p.parseMagic(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Literal && p.Glob {
+		return nil, ErrIncompatibleMagic
+	}
+
+	if p.Top {
+		prefix = ""
+	}
+
+	if p.Pattern, err = join(prefix, pattern); err != nil {
+		return nil, err
+	}
+
+	p.prefix = p.Pattern
+	if !p.Literal {
+		if i := strings.IndexAny(p.Pattern, wildcards); i >= 0 {
+			p.prefix = p.Pattern[:i]
+		}
+	}
+
+	return p, nil
+}
+
+// parseMagic sets the magic words of the pathspec, returning the rest of it.
+func (p *Pathspec) parseMagic(spec string) (string, error) {
+	if !strings.HasPrefix(spec, magicPrefix) {
+		return spec, nil
+	}
+
+	if strings.HasPrefix(spec, magicPrefix+"(") {
+		end := strings.Index(spec, ")")
+		if end < 0 {
+			return "", fmt.Errorf("missing ')' at the end of pathspec magic in %q", spec)
+		}
+
+		for _, word := range strings.Split(spec[2:end], magicSeparator) {
+			if err := p.setMagic(strings.TrimSpace(word)); err != nil {
+				return "", err
+			}
+		}
+
+		return spec[end+1:], nil
+	}
+
+	for i := 1; i < len(spec); i++ {
+		switch spec[i] {
+		case '/':
+			p.Top = true
+		case '!', '^':
+			p.Exclude = true
+		case ':':
+			return spec[i+1:], nil
+		default:
+			return spec[i:], nil
+		}
+	}
+
+	return "", nil
+}
+
+func (p *Pathspec) setMagic(word string) error {
+	switch {
+	case word == "top":
+		p.Top = true
+	case word == "literal":
+		p.Literal = true
+	case word == "glob":
+		p.Glob = true
+	case word == "icase":
+		p.ICase = true
+	case word == "exclude":
+		p.Exclude = true
+	case strings.HasPrefix(word, attrPrefix):
+		m, err := gitattributes.ParseAttributesLine("* "+word[len(attrPrefix):], nil, false)
+		if err != nil {
+			return err
+		}
+
+		p.Attributes = append(p.Attributes, m.Attributes...)
+	case strings.HasPrefix(word, "prefix:"), word == "":
+	default:
+		return fmt.Errorf("invalid pathspec magic %q", word)
+	}
+
+	return nil
+}
+
+// join joins the prefix and the pattern, keeping a trailing slash and
+// resolving the "." and ".." elements.
+func join(prefix, pattern string) (string, error) {
+	joined := path.Join(prefix, pattern)
+	if joined == ".." || strings.HasPrefix(joined, "../") || path.IsAbs(joined) {
+		return "", ErrOutsideRepository
+	}
+
+	if joined == "." {
+		return "", nil
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		joined += "/"
+	}
+
+	return joined, nil
+}
+
+// HasWildcards returns true if the pattern of the pathspec is matched as a
+// pattern instead of as a path.
+func (p *Pathspec) HasWildcards() bool {
+	return p.prefix != p.Pattern
+}
+
+// Prefix returns the leading part of the pattern without wildcards, the
+// whole pattern if it has none.
+func (p *Pathspec) Prefix() string {
+	return p.prefix
+}
+
+// Match returns true if the path, relative to the root of the worktree,
+// matches the pathspec. The attributes of the path are only used if it has
+// the attr magic.
+func (p *Pathspec) Match(path string, attrs map[string]gitattributes.Attribute) bool {
+	if !p.matchPath(path) {
+		return false
+	}
+
+	for _, req := range p.Attributes {
+		if !meets(attrs[req.Name()], req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Pathspec) matchPath(path string) bool {
+	if matchLeadingPath(p.Pattern, path, p.ICase) {
+		return true
+	}
+
+	if !p.HasWildcards() {
+		return false
+	}
+
+	return wildmatch([]rune(p.Pattern), []rune(path), p.Glob, p.ICase)
+}
+
+// matchLeadingPath returns true if path is equal to pattern, or it's inside of
+// the directory named by it, ignoring the case if icase is true.
+func matchLeadingPath(pattern, path string, icase bool) bool {
+	if pattern == "" {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		return hasPrefix(path+"/", pattern, icase)
+	}
+
+	return equal(path, pattern, icase) || hasPrefix(path, pattern+"/", icase)
+}
+
+func equal(s, t string, icase bool) bool {
+	if icase {
+		return strings.EqualFold(s, t)
+	}
+
+	return s == t
+}
+
+func hasPrefix(s, prefix string, icase bool) bool {
+	return len(s) >= len(prefix) && equal(s[:len(prefix)], prefix, icase)
+}
+
+// leadsTo returns true if the directory may contain paths matching the
+// pathspec, because it's inside of it or it's a directory leading to the part
+// of the pattern without wildcards.
+func (p *Pathspec) leadsTo(dir string) bool {
+	if dir == "" || hasPrefix(p.prefix, dir+"/", p.ICase) || matchLeadingPath(p.Pattern, dir, p.ICase) {
+		return true
+	}
+
+	return p.HasWildcards() && hasPrefix(dir+"/", p.prefix, p.ICase)
+}
+
+// covers returns true if all the paths inside of the directory match the
+// pathspec, regardless of their attributes.
+func (p *Pathspec) covers(dir string) bool {
+	if p.HasWildcards() || len(p.Attributes) != 0 {
+		return false
+	}
+
+	return matchLeadingPath(p.Pattern, dir, p.ICase)
+}
+
+// meets returns true if the attribute, nil if it's unspecified, meets the
+// requirement.
+func meets(attr, req gitattributes.Attribute) bool {
+	switch {
+	case req.IsUnspecified():
+		return attr == nil || attr.IsUnspecified()
+	case attr == nil:
+		return false
+	case req.IsUnset():
+		return attr.IsUnset()
+	case req.IsValueSet():
+		return attr.IsValueSet() && attr.Value() == req.Value()
+	}
+
+	return attr.IsSet()
+}
@@ -0,0 +1,144 @@
+package pathspec
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type PathspecSuite struct{}
+
+var _ = Suite(&PathspecSuite{})
+
+func (s *PathspecSuite) TestParse(c *C) {
+	p, err := Parse("foo/*.go", "")
+	c.Assert(err, IsNil)
+	c.Assert(p.Pattern, Equals, "foo/*.go")
+	c.Assert(p.HasWildcards(), Equals, true)
+
+	p, err = Parse(":(glob,icase)Foo/**", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(p.Glob, Equals, true)
+	c.Assert(p.ICase, Equals, true)
+	c.Assert(p.Pattern, Equals, "bar/Foo/**")
+
+	p, err = Parse(":(top,literal)foo*", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(p.Pattern, Equals, "foo*")
+	c.Assert(p.HasWildcards(), Equals, false)
+
+	p, err = Parse(":(exclude,attr:text -binary !eol diff=go)", "")
+	c.Assert(err, IsNil)
+	c.Assert(p.Exclude, Equals, true)
+	c.Assert(p.Attributes, HasLen, 4)
+	c.Assert(p.Attributes[3].Value(), Equals, "go")
+}
+
+func (s *PathspecSuite) TestParseShortMagic(c *C) {
+	p, err := Parse(":/foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(p.Top, Equals, true)
+	c.Assert(p.Pattern, Equals, "foo")
+
+	p, err = Parse(":!/:foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(p.Top, Equals, true)
+	c.Assert(p.Exclude, Equals, true)
+	c.Assert(p.Pattern, Equals, "foo")
+
+	p, err = Parse(":^foo", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(p.Exclude, Equals, true)
+	c.Assert(p.Pattern, Equals, "bar/foo")
+
+	p, err = Parse(":/", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(p.Pattern, Equals, "")
+}
+
+func (s *PathspecSuite) TestParsePrefix(c *C) {
+	p, err := Parse("../foo/./bar/", "qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(p.Pattern, Equals, "qux/foo/bar/")
+
+	p, err = Parse(".", "qux")
+	c.Assert(err, IsNil)
+	c.Assert(p.Pattern, Equals, "qux")
+
+	_, err = Parse("../foo", "")
+	c.Assert(err, Equals, ErrOutsideRepository)
+}
+
+func (s *PathspecSuite) TestParseErrors(c *C) {
+	_, err := Parse(":(foo)bar", "")
+	c.Assert(err, ErrorMatches, `invalid pathspec magic "foo"`)
+
+	_, err = Parse(":(glob", "")
+	c.Assert(err, NotNil)
+
+	_, err = Parse(":(glob,literal)foo", "")
+	c.Assert(err, Equals, ErrIncompatibleMagic)
+}
+
+func (s *PathspecSuite) TestMatch(c *C) {
+	for _, t := range []struct {
+		spec    string
+		path    string
+		matches bool
+	}{
+		{"", "foo/bar", true},
+		{"foo", "foo", true},
+		{"foo", "foo/bar", true},
+		{"foo", "foobar", false},
+		{"foo/", "foo/bar", true},
+		{"foo/", "foo", true},
+		{"*.go", "foo/bar.go", true},
+		{"foo/*.go", "foo/bar/qux.go", true},
+		{"f?o", "foo", true},
+		{"f[a-z]o", "fOo", false},
+		{"f[!a-z]o", "fOo", true},
+		{":(glob)*.go", "foo/bar.go", false},
+		{":(glob)*.go", "bar.go", true},
+		{":(glob)**/*.go", "foo/bar/qux.go", true},
+		{":(glob)**/*.go", "qux.go", true},
+		{":(glob)foo/**", "foo/bar/qux", true},
+		{":(glob)foo/**/qux", "foo/qux", true},
+		{":(glob)foo/**/qux", "foo/bar/baz/qux", true},
+		{":(glob)foo**/qux", "foo/bar/qux", false},
+		{":(literal)*.go", "foo.go", false},
+		{":(literal)*.go", "*.go", true},
+		{"\\*.go", "*.go", true},
+		{"\\*.go", "a.go", false},
+		{":(icase)FOO", "foo/Bar", true},
+		{":(icase)*.GO", "Bar.go", true},
+		{":(icase)[A-Z]*.go", "bar.go", true},
+		{":(icase)[!a-z]*.go", "Bar.go", false},
+		{":(icase)foo/", "FOO/bar", true},
+	} {
+		p, err := Parse(t.spec, "")
+		c.Assert(err, IsNil)
+		c.Assert(p.Match(t.path, nil), Equals, t.matches, Commentf("%q %q", t.spec, t.path))
+	}
+}
+
+func (s *PathspecSuite) TestMatchAttributes(c *C) {
+	p, err := Parse(":(attr:text -binary !eol diff=go)", "")
+	c.Assert(err, IsNil)
+
+	m, err := gitattributes.ParseAttributesLine("* text -binary diff=go", nil, false)
+	c.Assert(err, IsNil)
+
+	attrs := make(map[string]gitattributes.Attribute)
+	for _, a := range m.Attributes {
+		attrs[a.Name()] = a
+	}
+
+	c.Assert(p.Match("foo", attrs), Equals, true)
+
+	delete(attrs, "text")
+	c.Assert(p.Match("foo", attrs), Equals, false)
+	c.Assert(p.Match("foo", nil), Equals, false)
+}
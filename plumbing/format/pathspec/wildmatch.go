@@ -0,0 +1,196 @@
+package pathspec
+
+import "unicode"
+
+// wildmatch returns true if the text matches the shell pattern. If pathname
+// is true, the wildcards don't match the slashes, except "**" when it's a whole
+// element of the path, which matches any number of directories. If icase is
+// true, the case of the letters is ignored, also in the bracket expressions.
+func wildmatch(p, t []rune, pathname, icase bool) bool {
+	w := &wildmatcher{pathname: pathname, icase: icase}
+	return w.match(p, t, true)
+}
+
+type wildmatcher struct {
+	pathname bool
+	icase    bool
+}
+
+// match matches the text against the pattern, with start telling if the
+// pattern starts at the beginning of an element of the path.
+func (w *wildmatcher) match(p, t []rune, start bool) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '?':
+			if len(t) == 0 || (w.pathname && t[0] == '/') {
+				return false
+			}
+
+			p, t, start = p[1:], t[1:], false
+		case '[':
+			end, ok := w.matchClass(p, t)
+			if end < 0 {
+				// an unterminated class is matched literally
+				if len(t) == 0 || t[0] != '[' {
+					return false
+				}
+
+				p, t, start = p[1:], t[1:], false
+				continue
+			}
+
+			if !ok {
+				return false
+			}
+
+			p, t, start = p[end:], t[1:], false
+		case '*':
+			return w.matchStar(p, t, start)
+		case '\\':
+			if len(p) == 1 {
+				return false
+			}
+
+			p = p[1:]
+			fallthrough
+		default:
+			if len(t) == 0 || !w.equal(t[0], p[0]) {
+				return false
+			}
+
+			p, t, start = p[1:], t[1:], p[0] == '/'
+		}
+	}
+
+	return len(t) == 0
+}
+
+// matchStar matches the text against a pattern starting with a star, with
+// start telling if the star is at the beginning of an element of the path.
+func (w *wildmatcher) matchStar(p, t []rune, start bool) bool {
+	n := 1
+	for n < len(p) && p[n] == '*' {
+		n++
+	}
+
+	rest := p[n:]
+	if w.pathname && start && n >= 2 && (len(rest) == 0 || rest[0] == '/') {
+		return w.matchDoubleStar(rest, t)
+	}
+
+	if len(rest) == 0 {
+		return !w.pathname || !containsSlash(t)
+	}
+
+	for i := 0; i <= len(t); i++ {
+		if w.match(rest, t[i:], false) {
+			return true
+		}
+
+		if i < len(t) && w.pathname && t[i] == '/' {
+			return false
+		}
+	}
+
+	return false
+}
+
+// matchDoubleStar matches the text against the rest of a pattern after a
+// "**" element, empty or starting with a slash.
+func (w *wildmatcher) matchDoubleStar(rest, t []rune) bool {
+	if len(rest) == 0 {
+		return true
+	}
+
+	// "**/" matches zero or more directories
+	rest = rest[1:]
+	for i := 0; i <= len(t); i++ {
+		if (i == 0 || t[i-1] == '/') && w.match(rest, t[i:], true) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchClass matches the first rune of the text against the bracket
+// expression at the start of the pattern, returning the length of the
+// expression, or -1 if it isn't terminated.
+func (w *wildmatcher) matchClass(p, t []rune) (int, bool) {
+	i := 1
+	negated := i < len(p) && (p[i] == '!' || p[i] == '^')
+	if negated {
+		i++
+	}
+
+	var c rune = -1
+	if len(t) != 0 && !(w.pathname && t[0] == '/') {
+		c = t[0]
+	}
+
+	var matched bool
+	for first := true; i < len(p); first = false {
+		if p[i] == ']' && !first {
+			return i + 1, c >= 0 && matched != negated
+		}
+
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+
+		i++
+		hi := lo
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			hi = p[i+1]
+			if hi == '\\' && i+2 < len(p) {
+				i++
+				hi = p[i+1]
+			}
+
+			i += 2
+		}
+
+		if w.inRange(c, lo, hi) {
+			matched = true
+		}
+	}
+
+	return -1, false
+}
+
+// equal returns true if the runes are the same, ignoring the case if icase is
+// set.
+func (w *wildmatcher) equal(a, b rune) bool {
+	if a == b {
+		return true
+	}
+
+	return w.icase && unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// inRange returns true if the rune is between lo and hi, or, if icase is set,
+// its lower or upper case is.
+func (w *wildmatcher) inRange(c, lo, hi rune) bool {
+	if lo <= c && c <= hi {
+		return true
+	}
+
+	if !w.icase || c < 0 {
+		return false
+	}
+
+	l, u := unicode.ToLower(c), unicode.ToUpper(c)
+	return (lo <= l && l <= hi) || (lo <= u && u <= hi)
+}
+
+func containsSlash(t []rune) bool {
+	for _, r := range t {
+		if r == '/' {
+			return true
+		}
+	}
+
+	return false
+}
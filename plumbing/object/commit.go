@@ -306,6 +306,13 @@ func (c *Commit) Stats() (FileStats, error) {
 // StatsContext returns the stats of a commit. Error will be return if context
 // expires. Provided context must be non-nil.
 func (c *Commit) StatsContext(ctx context.Context) (FileStats, error) {
+	return c.StatsWithOptions(ctx, nil)
+}
+
+// StatsWithOptions returns the stats of a commit, restricted to the paths
+// selected by the given options. Error will be return if context expires.
+// Provided context must be non-nil, opts can be nil.
+func (c *Commit) StatsWithOptions(ctx context.Context, opts *DiffTreeOptions) (FileStats, error) {
 	fromTree, err := c.Tree()
 	if err != nil {
 		return nil, err
@@ -324,7 +331,12 @@ func (c *Commit) StatsContext(ctx context.Context) (FileStats, error) {
 		}
 	}
 
-	patch, err := toTree.PatchContext(ctx, fromTree)
+	changes, err := DiffTreeWithOptions(ctx, toTree, fromTree, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := changes.PatchContext(ctx)
 	if err != nil {
 		return nil, err
 	}
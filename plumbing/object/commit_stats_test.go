@@ -93,3 +93,23 @@ func (s *CommitStatsSuite) writeHistory(c *C, files ...[]byte) (*git.Repository,
 
 	return r, hash
 }
+
+func (s *CommitStatsSuite) TestStatsWithOptions(c *C) {
+	r, hash := s.writeHistory(c, []byte("foo\n"))
+
+	aCommit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	fileStats, err := aCommit.StatsWithOptions(context.Background(), &object.DiffTreeOptions{
+		PathSpecs: []string{"foo"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fileStats, HasLen, 1)
+	c.Assert(fileStats[0].Name, Equals, "foo")
+
+	fileStats, err = aCommit.StatsWithOptions(context.Background(), &object.DiffTreeOptions{
+		PathSpecs: []string{"bar"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(fileStats, HasLen, 0)
+}
@@ -1,18 +1,36 @@
 package object
 
 import (
+	"context"
 	"io"
+	"strings"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pathspec"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
 
+// ChangedPathFilter tells if a commit may change a path compared to its first
+// parent, or to the empty tree for root commits. It's used by the file history
+// walks to skip the tree diffs of the commits not changing the file, i.e. by
+// means of the changed-path Bloom filters of a commit-graph file.
+type ChangedPathFilter interface {
+	// MaybeChanged returns false if the commit definitely doesn't change the
+	// path, true otherwise.
+	MaybeChanged(commit plumbing.Hash, path string) bool
+}
+
 type commitFileIter struct {
 	fileName      string
+	pathSpecs     []string
 	sourceIter    CommitIter
 	currentCommit *Commit
 	checkParent   bool
+	pathFilter    ChangedPathFilter
+	// filterPaths are the paths given to the pathFilter, one of them is
+	// changed by the commits changing a path matching the pathspecs.
+	filterPaths []string
 }
 
 // NewCommitFileIterFromIter returns a commit iterator which performs diffTree between
@@ -24,10 +42,75 @@ func NewCommitFileIterFromIter(fileName string, commitIter CommitIter, checkPare
 	iterator := new(commitFileIter)
 	iterator.sourceIter = commitIter
 	iterator.fileName = fileName
+	iterator.pathSpecs = []string{":(literal)" + fileName}
+	iterator.filterPaths = []string{fileName}
 	iterator.checkParent = checkParent
 	return iterator
 }
 
+// NewCommitPathSpecIterFromIter works like NewCommitFileIterFromIter, but it
+// finds the commits changing any of the paths matching the given pathspecs,
+// as described by DiffTreeOptions.
+func NewCommitPathSpecIterFromIter(pathSpecs []string, commitIter CommitIter, checkParent bool) CommitIter {
+	iterator := new(commitFileIter)
+	iterator.sourceIter = commitIter
+	iterator.pathSpecs = pathSpecs
+	iterator.filterPaths = leadingPaths(pathSpecs)
+	iterator.checkParent = checkParent
+	return iterator
+}
+
+// NewCommitPathSpecIterFromIterWithFilter works like
+// NewCommitPathSpecIterFromIter, but it skips the tree diff of the commits
+// that, according to the given filter, don't change any of the paths leading
+// to the pathspecs compared to their first parent.
+func NewCommitPathSpecIterFromIterWithFilter(pathSpecs []string, commitIter CommitIter, checkParent bool, filter ChangedPathFilter) CommitIter {
+	iterator := NewCommitPathSpecIterFromIter(pathSpecs, commitIter, checkParent).(*commitFileIter)
+	iterator.pathFilter = filter
+	return iterator
+}
+
+// leadingPaths returns the paths without wildcards, up to their last slash,
+// leading to the pathspecs, so a path matching them is equal to or inside of
+// one of these. It returns nil if there is none, if any pathspec ignores the
+// case, or if a pathspec can match any path.
+func leadingPaths(pathSpecs []string) []string {
+	var paths []string
+	for _, spec := range pathSpecs {
+		p, err := pathspec.Parse(spec, "")
+		if err != nil || p.ICase {
+			return nil
+		}
+
+		if p.Exclude {
+			continue
+		}
+
+		path := p.Prefix()
+		if p.HasWildcards() {
+			path = path[:strings.LastIndex("/"+path, "/")]
+		}
+
+		path = strings.Trim(path, "/")
+		if path == "" {
+			return nil
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// NewCommitFileIterFromIterWithFilter works like NewCommitFileIterFromIter,
+// but it skips the tree diff of the commits that, according to the given
+// filter, don't change the file compared to their first parent.
+func NewCommitFileIterFromIterWithFilter(fileName string, commitIter CommitIter, checkParent bool, filter ChangedPathFilter) CommitIter {
+	iterator := NewCommitFileIterFromIter(fileName, commitIter, checkParent).(*commitFileIter)
+	iterator.pathFilter = filter
+	return iterator
+}
+
 func (c *commitFileIter) Next() (*Commit, error) {
 	if c.currentCommit == nil {
 		var err error
@@ -57,6 +140,15 @@ func (c *commitFileIter) getNextFileCommit() (*Commit, error) {
 			parentCommit = nil
 		}
 
+		if c.canSkip(parentCommit) {
+			c.currentCommit = parentCommit
+			if parentCommit == nil {
+				return nil, io.EOF
+			}
+
+			continue
+		}
+
 		// Fetch the trees of the current and parent commits
 		currentTree, currTreeErr := c.currentCommit.Tree()
 		if currTreeErr != nil {
@@ -72,8 +164,11 @@ func (c *commitFileIter) getNextFileCommit() (*Commit, error) {
 			}
 		}
 
-		// Find diff between current and parent trees
-		changes, diffErr := DiffTree(currentTree, parentTree)
+		// Find diff between current and parent trees, only walking the
+		// subtrees leading to the paths
+		changes, diffErr := DiffTreeWithOptions(context.Background(), currentTree, parentTree, &DiffTreeOptions{
+			PathSpecs: c.pathSpecs,
+		})
 		if diffErr != nil {
 			return nil, diffErr
 		}
@@ -96,13 +191,39 @@ func (c *commitFileIter) getNextFileCommit() (*Commit, error) {
 	}
 }
 
+// canSkip returns true if the path filter tells that the current commit
+// doesn't change any of the filtered paths, which is only known when the next
+// commit is its first parent, or when both are root commits.
+func (c *commitFileIter) canSkip(parent *Commit) bool {
+	if c.pathFilter == nil || len(c.filterPaths) == 0 {
+		return false
+	}
+
+	parents := c.currentCommit.ParentHashes
+	if parent == nil {
+		if len(parents) != 0 {
+			return false
+		}
+	} else if len(parents) == 0 || parents[0] != parent.Hash {
+		return false
+	}
+
+	for _, path := range c.filterPaths {
+		if c.pathFilter.MaybeChanged(c.currentCommit.Hash, path) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (c *commitFileIter) hasFileChange(changes Changes, parent *Commit) bool {
 	for _, change := range changes {
-		if change.name() != c.fileName {
+		if c.fileName != "" && change.name() != c.fileName {
 			continue
 		}
 
-		// filename matches, now check if source iterator contains all commits (from all refs)
+		// path matches, now check if source iterator contains all commits (from all refs)
 		if c.checkParent {
 			if parent != nil && isParentHash(parent.Hash, c.currentCommit) {
 				return true
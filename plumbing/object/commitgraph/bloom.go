@@ -0,0 +1,77 @@
+package commitgraph
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// ComputeBloomFilter computes the changed-path Bloom filter of a commit, with
+// the paths changed compared to its first parent, or all the paths of its
+// tree for root commits.
+func ComputeBloomFilter(c *object.Commit) (*commitgraph.BloomFilter, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var parentTree *object.Tree
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		if ch.From.Name != "" {
+			paths = append(paths, ch.From.Name)
+		}
+
+		if ch.To.Name != "" && ch.To.Name != ch.From.Name {
+			paths = append(paths, ch.To.Name)
+		}
+	}
+
+	return commitgraph.NewBloomFilter(paths), nil
+}
+
+type bloomChangedPathFilter struct {
+	index commitgraph.Index
+}
+
+// NewChangedPathFilter returns an object.ChangedPathFilter using the
+// changed-path Bloom filters of the given commit-graph index. The commits not
+// in the index, or without a filter, are considered as changing any path.
+func NewChangedPathFilter(index commitgraph.Index) object.ChangedPathFilter {
+	return &bloomChangedPathFilter{index}
+}
+
+func (f *bloomChangedPathFilter) MaybeChanged(commit plumbing.Hash, path string) bool {
+	bidx, ok := f.index.(commitgraph.BloomFilterIndex)
+	if !ok {
+		return true
+	}
+
+	i, err := f.index.GetIndexByHash(commit)
+	if err != nil {
+		return true
+	}
+
+	filter, err := bidx.GetBloomFilterByIndex(i)
+	if err != nil || filter == nil {
+		return true
+	}
+
+	return filter.Contains(path)
+}
@@ -29,6 +29,12 @@ type CommitNode interface {
 	// Generation returns the generation of the commit for reachability analysis.
 	// Objects with newer generation are not reachable from objects of older generation.
 	Generation() uint64
+	// GenerationV2 returns the corrected commit date of the commit, it's zero
+	// if it's unknown, because the commit isn't in the commit-graph or the
+	// commit-graph doesn't provide it. As the topological level returned by
+	// Generation, commits with newer corrected commit date are not reachable
+	// from commits with an older one.
+	GenerationV2() uint64
 	// Commit returns the full commit object from the node
 	Commit() (*object.Commit, error)
 }
@@ -118,6 +118,10 @@ func (c *graphCommitNode) Generation() uint64 {
 	return uint64(c.commitData.Generation)
 }
 
+func (c *graphCommitNode) GenerationV2() uint64 {
+	return c.commitData.GenerationV2
+}
+
 func (c *graphCommitNode) Commit() (*object.Commit, error) {
 	return object.GetCommit(c.gci.s, c.hash)
 }
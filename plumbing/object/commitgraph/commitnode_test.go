@@ -10,6 +10,7 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 )
 
@@ -145,3 +146,59 @@ func (s *CommitNodeSuite) TestMixedGraph(c *C) {
 	testParents(c, nodeIndex)
 	testCommitAndTree(c, nodeIndex)
 }
+
+func (s *CommitNodeSuite) TestChangedPathFilter(c *C) {
+	f := fixtures.ByTag("commit-graph").One()
+	storer := unpackRepositry(f)
+
+	reader, err := storer.Filesystem().Open(path.Join("objects", "info", "commit-graph"))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	fileIndex, err := commitgraph.OpenFileIndex(reader)
+	c.Assert(err, IsNil)
+
+	memoryIndex := commitgraph.NewMemoryIndex()
+	for i, hash := range fileIndex.Hashes() {
+		node, err := fileIndex.GetCommitDataByIndex(i)
+		c.Assert(err, IsNil)
+		memoryIndex.Add(hash, node)
+
+		commit, err := object.GetCommit(storer, hash)
+		c.Assert(err, IsNil)
+		bloom, err := ComputeBloomFilter(commit)
+		c.Assert(err, IsNil)
+		c.Assert(memoryIndex.AddBloomFilter(hash, bloom), IsNil)
+	}
+
+	filter := NewChangedPathFilter(memoryIndex)
+	c.Assert(filter.MaybeChanged(plumbing.ZeroHash, "foo"), Equals, true)
+
+	head, err := object.GetCommit(storer, plumbing.NewHash("b9d69064b190e7aedccf84731ca1d917871f8a1c"))
+	c.Assert(err, IsNil)
+	tree, err := head.Tree()
+	c.Assert(err, IsNil)
+
+	err = tree.Files().ForEach(func(file *object.File) error {
+		expected := fileHistory(c, object.NewCommitFileIterFromIter(
+			file.Name, object.NewCommitPreorderIter(head, nil, nil), false,
+		))
+		obtained := fileHistory(c, object.NewCommitFileIterFromIterWithFilter(
+			file.Name, object.NewCommitPreorderIter(head, nil, nil), false, filter,
+		))
+
+		c.Assert(obtained, DeepEquals, expected, Commentf("file %s", file.Name))
+		return nil
+	})
+	c.Assert(err, IsNil)
+}
+
+func fileHistory(c *C, iter object.CommitIter) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	err := iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	return hashes
+}
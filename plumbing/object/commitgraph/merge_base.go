@@ -0,0 +1,357 @@
+package commitgraph
+
+import (
+	"io"
+	"math"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	paintOne = 1 << iota
+	paintTwo
+	paintStale
+	paintResult
+
+	paintBoth = paintOne | paintTwo
+)
+
+// generation returns the generation used to sort and prune the walks: the
+// corrected commit date when available, the topological level otherwise. The
+// commits with an unknown generation, the ones not covered by the commit-graph
+// or from commit-graph files written without generations, get the highest
+// possible value, so they are never pruned.
+func generation(c CommitNode) uint64 {
+	if g := c.GenerationV2(); g != 0 {
+		return g
+	}
+
+	if g := c.Generation(); g != 0 {
+		return g
+	}
+
+	return math.MaxUint64
+}
+
+// painter walks the history from several commits, propagating to every
+// ancestor the flags of the commits it was reached from. The commits are
+// visited by descending generation and commit time, and they are visited
+// again when they get new flags, so the flags are always right even for
+// commits without a known generation.
+type painter struct {
+	heap  *binaryheap.Heap
+	flags map[plumbing.Hash]int
+
+	popped map[plumbing.Hash]bool
+	order  []CommitNode
+}
+
+func newPainter() *painter {
+	return &painter{
+		heap: binaryheap.NewWith(func(a, b interface{}) int {
+			ca, cb := a.(CommitNode), b.(CommitNode)
+			ga, gb := generation(ca), generation(cb)
+			switch {
+			case ga > gb:
+				return -1
+			case ga < gb:
+				return 1
+			case ca.CommitTime().After(cb.CommitTime()):
+				return -1
+			case ca.CommitTime().Before(cb.CommitTime()):
+				return 1
+			}
+
+			return 0
+		}),
+		flags:  make(map[plumbing.Hash]int),
+		popped: make(map[plumbing.Hash]bool),
+	}
+}
+
+// push adds the flags to the commit, queueing it only if they are new.
+func (p *painter) push(c CommitNode, flags int) {
+	old := p.flags[c.ID()]
+	if old|flags == old {
+		return
+	}
+
+	p.flags[c.ID()] = old | flags
+	p.heap.Push(c)
+}
+
+func (p *painter) pop() (CommitNode, bool) {
+	c, ok := p.heap.Pop()
+	if !ok {
+		return nil, false
+	}
+
+	node := c.(CommitNode)
+	if !p.popped[node.ID()] {
+		p.popped[node.ID()] = true
+		p.order = append(p.order, node)
+	}
+
+	return node, true
+}
+
+func (p *painter) pushParents(c CommitNode, flags int) error {
+	return c.ParentNodes().ForEach(func(parent CommitNode) error {
+		p.push(parent, flags)
+		return nil
+	})
+}
+
+// visited returns the visited commits having exactly the given flags, in the
+// order they were first visited.
+func (p *painter) visited(flags int) []CommitNode {
+	var res []CommitNode
+	for _, c := range p.order {
+		if p.flags[c.ID()] == flags {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+// done returns true if all the queued commits have the given flags, and
+// have a known generation, so the flags of the visited commits can't change.
+func (p *painter) done(flags int, knownGeneration bool) bool {
+	for _, v := range p.heap.Values() {
+		c := v.(CommitNode)
+		if p.flags[c.ID()]&flags != flags {
+			return false
+		}
+
+		if knownGeneration && generation(c) == math.MaxUint64 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// paintDownToCommon returns the common ancestors of one and twos not reachable
+// from other common ancestors found during the walk. The result may contain
+// redundant commits when the history isn't covered by the commit-graph.
+func paintDownToCommon(one CommitNode, twos []CommitNode) ([]CommitNode, error) {
+	p := newPainter()
+	p.push(one, paintOne)
+	for _, two := range twos {
+		p.push(two, paintTwo)
+	}
+
+	var result []CommitNode
+	for !p.done(paintStale, false) {
+		c, _ := p.pop()
+
+		flags := p.flags[c.ID()] & (paintBoth | paintStale)
+		if flags == paintBoth {
+			if p.flags[c.ID()]&paintResult == 0 {
+				p.flags[c.ID()] |= paintResult
+				result = append(result, c)
+			}
+
+			flags |= paintStale
+		}
+
+		if err := p.pushParents(c, flags); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// MergeBase mimics the behavior of `git merge-base actual other`, returning
+// the best common ancestors of the given commits, the ones not reachable from
+// other common ancestors. The walk stops as soon as the remaining commits can
+// only lead to redundant common ancestors.
+func MergeBase(c, other CommitNode) ([]CommitNode, error) {
+	if c.ID() == other.ID() {
+		return []CommitNode{c}, nil
+	}
+
+	result, err := paintDownToCommon(c, []CommitNode{other})
+	if err != nil {
+		return nil, err
+	}
+
+	return Independents(result)
+}
+
+// IsAncestor returns true if the commit c is an ancestor of other, it mimics
+// the behavior of `git merge-base --is-ancestor c other`. The commits with
+// a generation lower than the one of c aren't walked, since they can't reach
+// it.
+func IsAncestor(c, other CommitNode) (bool, error) {
+	if c.ID() == other.ID() {
+		return true, nil
+	}
+
+	min := generation(c)
+	if min > generation(other) {
+		return false, nil
+	}
+
+	seen := map[plumbing.Hash]bool{other.ID(): true}
+	stack := []CommitNode{other}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		iter := current.ParentNodes()
+		for {
+			parent, err := iter.Next()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return false, err
+			}
+
+			if parent.ID() == c.ID() {
+				return true, nil
+			}
+
+			if seen[parent.ID()] || generation(parent) < min {
+				continue
+			}
+
+			seen[parent.ID()] = true
+			stack = append(stack, parent)
+		}
+	}
+
+	return false, nil
+}
+
+// Independents returns the subset of the given commits not reachable from the
+// others, it mimics the behavior of `git merge-base --independent commit...`.
+// The commits with a generation lower than the minimum of the given ones
+// aren't walked, since they can't reach any of them.
+func Independents(commits []CommitNode) ([]CommitNode, error) {
+	var candidates []CommitNode
+	isCandidate := make(map[plumbing.Hash]bool)
+	min := uint64(math.MaxUint64)
+	for _, c := range commits {
+		if isCandidate[c.ID()] {
+			continue
+		}
+
+		isCandidate[c.ID()] = true
+		candidates = append(candidates, c)
+		if g := generation(c); g < min {
+			min = g
+		}
+	}
+
+	if len(candidates) < 2 {
+		return candidates, nil
+	}
+
+	redundant := make(map[plumbing.Hash]bool)
+	seen := make(map[plumbing.Hash]bool)
+	stack := make([]CommitNode, len(candidates))
+	copy(stack, candidates)
+
+	for len(stack) > 0 && len(redundant) < len(candidates)-1 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		iter := current.ParentNodes()
+		for {
+			parent, err := iter.Next()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			if seen[parent.ID()] {
+				continue
+			}
+
+			seen[parent.ID()] = true
+			if isCandidate[parent.ID()] {
+				redundant[parent.ID()] = true
+			}
+
+			if generation(parent) >= min {
+				stack = append(stack, parent)
+			}
+		}
+	}
+
+	var result []CommitNode
+	for _, c := range candidates {
+		if !redundant[c.ID()] {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// AheadBehind returns the number of commits reachable from c and not from
+// other, ahead, and the number of commits reachable from other and not from c,
+// behind. It mimics `git rev-list --left-right --count c...other`. The walk
+// stops once all the queued commits are reachable from both.
+func AheadBehind(c, other CommitNode) (ahead, behind int, err error) {
+	p, err := paintSides([]CommitNode{c}, []CommitNode{other})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, flags := range p.flags {
+		switch flags {
+		case paintOne:
+			ahead++
+		case paintTwo:
+			behind++
+		}
+	}
+
+	return ahead, behind, nil
+}
+
+// ExclusiveAncestors returns the commits reachable from c, including itself,
+// and not reachable from any of the excluded ones, as `git rev-list c
+// ^excluded...` does. The commits are returned sorted by descending generation
+// and commit time.
+func ExclusiveAncestors(c CommitNode, excluded []CommitNode) ([]CommitNode, error) {
+	p, err := paintSides([]CommitNode{c}, excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.visited(paintOne), nil
+}
+
+// paintSides walks the history from ones and twos, until all the commits
+// reachable from only one of the sides are visited.
+func paintSides(ones, twos []CommitNode) (*painter, error) {
+	p := newPainter()
+	for _, c := range ones {
+		p.push(c, paintOne)
+	}
+
+	for _, c := range twos {
+		p.push(c, paintTwo)
+	}
+
+	for !p.done(paintBoth, true) {
+		current, _ := p.pop()
+		if err := p.pushParents(current, p.flags[current.ID()]); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
@@ -0,0 +1,240 @@
+package commitgraph
+
+import (
+	"bytes"
+	"path"
+	"sort"
+
+	. "gopkg.in/check.v1"
+	fixtures "gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+type MergeBaseSuite struct {
+	fixtures.Suite
+	storer  *filesystem.Storage
+	hashes  []plumbing.Hash
+	indexes map[string]CommitNodeIndex
+}
+
+var _ = Suite(&MergeBaseSuite{})
+
+func (s *MergeBaseSuite) SetUpSuite(c *C) {
+	s.Suite.SetUpSuite(c)
+
+	f := fixtures.ByTag("commit-graph").One()
+	s.storer = unpackRepositry(f)
+
+	reader, err := s.storer.Filesystem().Open(path.Join("objects", "info", "commit-graph"))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	fileIndex, err := commitgraph.OpenFileIndex(reader)
+	c.Assert(err, IsNil)
+
+	s.hashes = fileIndex.Hashes()
+
+	// Memory index with corrected commit dates, and a copy without the head
+	// commit, so it has to be loaded from the object storage.
+	memoryIndex := commitgraph.NewMemoryIndex()
+	mixedIndex := commitgraph.NewMemoryIndex()
+	for i, hash := range s.hashes {
+		data, err := fileIndex.GetCommitDataByIndex(i)
+		c.Assert(err, IsNil)
+
+		copied := *data
+		memoryIndex.Add(hash, &copied)
+		if hash.String() != "b9d69064b190e7aedccf84731ca1d917871f8a1c" {
+			mixed := *data
+			mixedIndex.Add(hash, &mixed)
+		}
+	}
+
+	c.Assert(memoryIndex.ComputeGenerations(), IsNil)
+	c.Assert(mixedIndex.ComputeGenerations(), IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(commitgraph.NewEncoder(buf).Encode(memoryIndex), IsNil)
+	encodedIndex, err := commitgraph.OpenFileIndex(bytes.NewReader(buf.Bytes()))
+	c.Assert(err, IsNil)
+
+	s.indexes = map[string]CommitNodeIndex{
+		"object":  NewObjectCommitNodeIndex(s.storer),
+		"v1":      NewGraphCommitNodeIndex(fileIndex, s.storer),
+		"v2":      NewGraphCommitNodeIndex(memoryIndex, s.storer),
+		"encoded": NewGraphCommitNodeIndex(encodedIndex, s.storer),
+		"mixed":   NewGraphCommitNodeIndex(mixedIndex, s.storer),
+	}
+}
+
+func (s *MergeBaseSuite) commit(c *C, h plumbing.Hash) *object.Commit {
+	commit, err := object.GetCommit(s.storer, h)
+	c.Assert(err, IsNil)
+	return commit
+}
+
+func (s *MergeBaseSuite) node(c *C, index CommitNodeIndex, h plumbing.Hash) CommitNode {
+	node, err := index.Get(h)
+	c.Assert(err, IsNil)
+	return node
+}
+
+func (s *MergeBaseSuite) ancestors(c *C, h plumbing.Hash) map[plumbing.Hash]bool {
+	res := make(map[plumbing.Hash]bool)
+	err := object.NewCommitPreorderIter(s.commit(c, h), nil, nil).ForEach(func(commit *object.Commit) error {
+		res[commit.Hash] = true
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	return res
+}
+
+func nodeHashes(nodes []CommitNode) []string {
+	var res []string
+	for _, n := range nodes {
+		res = append(res, n.ID().String())
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+func commitHashes(commits []*object.Commit) []string {
+	var res []string
+	for _, commit := range commits {
+		res = append(res, commit.Hash.String())
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+func (s *MergeBaseSuite) TestGenerationV2(c *C) {
+	for name, index := range s.indexes {
+		for _, h := range s.hashes {
+			node := s.node(c, index, h)
+			err := node.ParentNodes().ForEach(func(parent CommitNode) error {
+				c.Assert(generation(parent) <= generation(node), Equals, true, Commentf("index %s", name))
+
+				if name == "v2" || name == "encoded" {
+					c.Assert(parent.GenerationV2() < node.GenerationV2(), Equals, true)
+					c.Assert(node.GenerationV2() >= uint64(node.CommitTime().Unix()), Equals, true)
+				}
+
+				if name == "object" || name == "v1" {
+					c.Assert(node.GenerationV2(), Equals, uint64(0), Commentf("index %s", name))
+				}
+
+				return nil
+			})
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *MergeBaseSuite) TestMergeBase(c *C) {
+	for name, index := range s.indexes {
+		for _, a := range s.hashes {
+			for _, b := range s.hashes {
+				expected, err := s.commit(c, a).MergeBase(s.commit(c, b))
+				c.Assert(err, IsNil)
+
+				obtained, err := MergeBase(s.node(c, index, a), s.node(c, index, b))
+				c.Assert(err, IsNil)
+				c.Assert(nodeHashes(obtained), DeepEquals, commitHashes(expected),
+					Commentf("index %s, merge-base %s %s", name, a, b))
+			}
+		}
+	}
+}
+
+func (s *MergeBaseSuite) TestIsAncestor(c *C) {
+	for name, index := range s.indexes {
+		for _, a := range s.hashes {
+			for _, b := range s.hashes {
+				expected := s.ancestors(c, b)[a]
+
+				obtained, err := IsAncestor(s.node(c, index, a), s.node(c, index, b))
+				c.Assert(err, IsNil)
+				c.Assert(obtained, Equals, expected,
+					Commentf("index %s, is-ancestor %s %s", name, a, b))
+			}
+		}
+	}
+}
+
+func (s *MergeBaseSuite) TestIndependents(c *C) {
+	for name, index := range s.indexes {
+		var (
+			nodes   []CommitNode
+			commits []*object.Commit
+		)
+
+		for _, h := range s.hashes {
+			nodes = append(nodes, s.node(c, index, h))
+			commits = append(commits, s.commit(c, h))
+
+			expected, err := object.Independents(commits)
+			c.Assert(err, IsNil)
+
+			obtained, err := Independents(nodes)
+			c.Assert(err, IsNil)
+			c.Assert(nodeHashes(obtained), DeepEquals, commitHashes(expected), Commentf("index %s", name))
+		}
+	}
+}
+
+func (s *MergeBaseSuite) TestAheadBehind(c *C) {
+	for name, index := range s.indexes {
+		for _, a := range s.hashes {
+			for _, b := range s.hashes {
+				left, right := s.ancestors(c, a), s.ancestors(c, b)
+
+				var ahead, behind int
+				for h := range left {
+					if !right[h] {
+						ahead++
+					}
+				}
+
+				for h := range right {
+					if !left[h] {
+						behind++
+					}
+				}
+
+				obtainedAhead, obtainedBehind, err := AheadBehind(s.node(c, index, a), s.node(c, index, b))
+				c.Assert(err, IsNil)
+				c.Assert([]int{obtainedAhead, obtainedBehind}, DeepEquals, []int{ahead, behind},
+					Commentf("index %s, ahead-behind %s %s", name, a, b))
+			}
+		}
+	}
+}
+
+func (s *MergeBaseSuite) TestExclusiveAncestors(c *C) {
+	for name, index := range s.indexes {
+		for _, a := range s.hashes {
+			for _, b := range s.hashes {
+				left, right := s.ancestors(c, a), s.ancestors(c, b)
+
+				var expected []string
+				for h := range left {
+					if !right[h] {
+						expected = append(expected, h.String())
+					}
+				}
+
+				sort.Strings(expected)
+
+				obtained, err := ExclusiveAncestors(s.node(c, index, a), []CommitNode{s.node(c, index, b)})
+				c.Assert(err, IsNil)
+				c.Assert(nodeHashes(obtained), DeepEquals, expected,
+					Commentf("index %s, rev-list %s ^%s", name, a, b))
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pathspec"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
 )
@@ -18,6 +19,25 @@ func DiffTree(a, b *Tree) (Changes, error) {
 // tree objects. Provided context must be non-nil.
 // An error will be return if context expires
 func DiffTreeContext(ctx context.Context, a, b *Tree) (Changes, error) {
+	return DiffTreeWithOptions(ctx, a, b, nil)
+}
+
+// DiffTreeOptions contains the options used by DiffTreeWithOptions.
+type DiffTreeOptions struct {
+	// PathSpecs restricts the changes to the paths matching the given
+	// pathspecs, relative to the root of the trees, as described in the
+	// pathspec package. A path matches a pathspec without magic and wildcards
+	// if it's equal to it or if it's inside of it, when it's a directory. The
+	// directories not leading to any pathspec are not walked. If empty, all
+	// the paths match.
+	PathSpecs []string
+}
+
+// DiffTreeWithOptions compares the content and mode of the blobs found via
+// two tree objects, as DiffTreeContext does, restricting the comparison to
+// the paths selected by the given options. Provided context must be non-nil,
+// opts can be nil.
+func DiffTreeWithOptions(ctx context.Context, a, b *Tree, opts *DiffTreeOptions) (Changes, error) {
 	from := NewTreeRootNode(a)
 	to := NewTreeRootNode(b)
 
@@ -25,7 +45,17 @@ func DiffTreeContext(ctx context.Context, a, b *Tree) (Changes, error) {
 		return bytes.Equal(a.Hash(), b.Hash())
 	}
 
-	merkletrieChanges, err := merkletrie.DiffTreeContext(ctx, from, to, hashEqual)
+	mopts := &merkletrie.DiffTreeOptions{}
+	if opts != nil && len(opts.PathSpecs) != 0 {
+		m, err := pathspec.ParseMatcher(opts.PathSpecs, "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		mopts.Filter = newPathSpecFilter(m)
+	}
+
+	merkletrieChanges, err := merkletrie.DiffTreeWithOptions(ctx, from, to, hashEqual, mopts)
 	if err != nil {
 		if err == merkletrie.ErrCanceled {
 			return nil, ErrCanceled
@@ -35,3 +65,11 @@ func DiffTreeContext(ctx context.Context, a, b *Tree) (Changes, error) {
 
 	return newChanges(merkletrieChanges)
 }
+
+// newPathSpecFilter returns a merkletrie.Filter accepting the paths matching
+// the pathspecs and the directories which may contain them.
+func newPathSpecFilter(m pathspec.Matcher) merkletrie.Filter {
+	return func(p noder.Path) bool {
+		return m.Match(p.String(), p.IsDir())
+	}
+}
@@ -1,12 +1,14 @@
 package object
 
 import (
+	"context"
 	"sort"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pathspec"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
@@ -374,3 +376,60 @@ func (s *DiffTreeSuite) TestIssue279(c *C) {
 	}
 	c.Assert(b.Hash(), Not(DeepEquals), bb.Hash())
 }
+
+func (s *DiffTreeSuite) TestDiffTreeWithOptions(c *C) {
+	from := s.commitFromStorer(c, s.Storer,
+		plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"))
+	to := s.commitFromStorer(c, s.Storer,
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	fromTree, err := from.Tree()
+	c.Assert(err, IsNil)
+	toTree, err := to.Tree()
+	c.Assert(err, IsNil)
+
+	all, err := DiffTree(fromTree, toTree)
+	c.Assert(err, IsNil)
+
+	for _, pathSpecs := range [][]string{
+		{"go"},
+		{"go/", "vendor/foo.go"},
+		{"json/short.json"},
+		{"unknown"},
+		{""},
+	} {
+		changes, err := DiffTreeWithOptions(context.Background(), fromTree, toTree, &DiffTreeOptions{
+			PathSpecs: pathSpecs,
+		})
+		c.Assert(err, IsNil)
+
+		m, err := pathspec.ParseMatcher(pathSpecs, "", nil)
+		c.Assert(err, IsNil)
+
+		var expected []string
+		for _, ch := range all {
+			if m.Match(ch.name(), false) {
+				expected = append(expected, ch.name())
+			}
+		}
+
+		var obtained []string
+		for _, ch := range changes {
+			obtained = append(obtained, ch.name())
+		}
+
+		c.Assert(obtained, DeepEquals, expected, Commentf("pathspecs: %v", pathSpecs))
+	}
+
+	changes, err := DiffTreeWithOptions(context.Background(), fromTree, toTree, &DiffTreeOptions{
+		PathSpecs: []string{"*.go", ":(exclude)vendor"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(changes, HasLen, 1)
+	c.Assert(changes[0].name(), Equals, "go/example.go")
+
+	_, err = DiffTreeWithOptions(context.Background(), fromTree, toTree, &DiffTreeOptions{
+		PathSpecs: []string{":(glob,literal)foo"},
+	})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,295 @@
+package fsck
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+)
+
+// checkTree checks the entries of a tree: "<mode> <name>\0<hash>".
+func checkTree(r *report, content []byte) {
+	seen := make(map[string]bool)
+	var prev string
+	var prevDir bool
+
+	for i := 0; len(content) > 0 && r.err == nil; i++ {
+		sp := bytes.IndexByte(content, ' ')
+		if sp <= 0 {
+			r.fail(BadTree, "malformed entry mode")
+			return
+		}
+
+		mode := string(content[:sp])
+		content = content[sp+1:]
+
+		nul := bytes.IndexByte(content, 0)
+		if nul < 0 || len(content) < nul+1+20 {
+			r.fail(BadTree, "truncated entry")
+			return
+		}
+
+		name := string(content[:nul])
+		var h plumbing.Hash
+		copy(h[:], content[nul+1:])
+		content = content[nul+1+20:]
+
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			r.fail(BadTree, "malformed mode %q", mode)
+			return
+		}
+
+		if mode[0] == '0' {
+			r.fail(ZeroPaddedFilemode, "contains zero-padded file modes")
+		}
+
+		fm := filemode.FileMode(m)
+		switch fm {
+		case filemode.Regular, filemode.Executable, filemode.Dir,
+			filemode.Symlink, filemode.Submodule:
+		default:
+			r.fail(BadFilemode, "contains bad file modes")
+		}
+
+		checkEntryName(r, name)
+		if fm == filemode.Symlink && IsDotGitmodules(name, true, true) {
+			r.fail(GitmodulesSymlink, ".gitmodules is a symbolic link")
+		}
+
+		if h.IsZero() {
+			r.fail(NullSha1, "contains entries pointing to null sha1")
+		}
+
+		if seen[name] {
+			r.fail(DuplicateEntries, "contains duplicate file entries")
+		}
+
+		isDir := fm == filemode.Dir
+		if i > 0 && compareEntryNames(prev, prevDir, name, isDir) > 0 {
+			r.fail(TreeNotSorted, "not properly sorted")
+		}
+
+		seen[name] = true
+		prev, prevDir = name, isDir
+	}
+}
+
+func checkEntryName(r *report, name string) {
+	switch {
+	case name == "":
+		r.fail(EmptyName, "contains empty pathname")
+	case strings.IndexByte(name, '/') >= 0:
+		r.fail(FullPathname, "contains full pathnames")
+	case name == ".":
+		r.fail(HasDot, "contains '.'")
+	case name == "..":
+		r.fail(HasDotdot, "contains '..'")
+	case IsDotGit(name, true, true):
+		r.fail(HasDotgit, "contains '.git'")
+	}
+}
+
+// compareEntryNames compares the names of two tree entries in the order of
+// the trees, where the directories sort as if their name ended with a slash.
+func compareEntryNames(a string, aDir bool, b string, bDir bool) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+
+	if c := strings.Compare(a[:n], b[:n]); c != 0 {
+		return c
+	}
+
+	ca, cb := entryNameEnd(a, n, aDir), entryNameEnd(b, n, bDir)
+	switch {
+	case ca < cb:
+		return -1
+	case ca > cb:
+		return 1
+	}
+
+	return 0
+}
+
+func entryNameEnd(name string, n int, dir bool) byte {
+	if n < len(name) {
+		return name[n]
+	}
+
+	if dir {
+		return '/'
+	}
+
+	return 0
+}
+
+// checkCommit checks the headers of a commit.
+func checkCommit(r *report, content []byte) {
+	line, content := nextLine(content)
+	if !strings.HasPrefix(line, "tree ") {
+		r.fail(MissingTree, "invalid format - expected 'tree' line")
+		return
+	}
+
+	if !isHash(line[len("tree "):]) {
+		r.fail(BadTreeSha1, "invalid 'tree' line format - bad sha1")
+		return
+	}
+
+	line, content = nextLine(content)
+	for strings.HasPrefix(line, "parent ") {
+		if !isHash(line[len("parent "):]) {
+			r.fail(BadParentSha1, "invalid 'parent' line format - bad sha1")
+			return
+		}
+
+		line, content = nextLine(content)
+	}
+
+	if !strings.HasPrefix(line, "author ") {
+		r.fail(MissingAuthor, "invalid format - expected 'author' line")
+		return
+	}
+
+	checkIdent(r, line[len("author "):])
+
+	line, content = nextLine(content)
+	for strings.HasPrefix(line, "author ") {
+		r.fail(MultipleAuthors, "invalid format - multiple 'author' lines")
+		line, content = nextLine(content)
+	}
+
+	if !strings.HasPrefix(line, "committer ") {
+		r.fail(MissingCommitter, "invalid format - expected 'committer' line")
+		return
+	}
+
+	checkIdent(r, line[len("committer "):])
+}
+
+// checkTag checks the headers of a tag.
+func checkTag(r *report, content []byte) {
+	line, content := nextLine(content)
+	if !strings.HasPrefix(line, "object ") {
+		r.fail(MissingObject, "invalid format - expected 'object' line")
+		return
+	}
+
+	if !isHash(line[len("object "):]) {
+		r.fail(BadObjectSha1, "invalid 'object' line format - bad sha1")
+		return
+	}
+
+	line, content = nextLine(content)
+	if !strings.HasPrefix(line, "type ") {
+		r.fail(MissingTypeEntry, "invalid format - expected 'type' line")
+		return
+	}
+
+	t, err := plumbing.ParseObjectType(line[len("type "):])
+	if err != nil || !t.Valid() || t.IsDelta() {
+		r.fail(BadType, "invalid 'type' value")
+		return
+	}
+
+	line, content = nextLine(content)
+	if !strings.HasPrefix(line, "tag ") {
+		r.fail(MissingTagEntry, "invalid format - expected 'tag' line")
+		return
+	}
+
+	line, _ = nextLine(content)
+	if !strings.HasPrefix(line, "tagger ") {
+		r.fail(MissingTaggerEntry, "invalid format - expected 'tagger' line")
+		return
+	}
+
+	checkIdent(r, line[len("tagger "):])
+}
+
+// checkIdent checks an identity: "Name <email> <timestamp> <timezone>".
+func checkIdent(r *report, ident string) {
+	lt := strings.IndexByte(ident, '<')
+	if lt < 0 {
+		r.fail(MissingEmail, "invalid author/committer line - missing email")
+		return
+	}
+
+	if lt == 0 {
+		r.fail(MissingNameBeforeEmail, "invalid author/committer line - missing name before email")
+		return
+	}
+
+	if ident[lt-1] != ' ' {
+		r.fail(MissingSpaceBeforeEmail, "invalid author/committer line - missing space before email")
+		return
+	}
+
+	gt := strings.IndexAny(ident[lt+1:], "<>")
+	if gt < 0 || ident[lt+1+gt] != '>' {
+		r.fail(BadEmail, "invalid author/committer line - bad email")
+		return
+	}
+
+	rest := ident[lt+1+gt+1:]
+	if !strings.HasPrefix(rest, " ") {
+		r.fail(MissingSpaceBeforeDate, "invalid author/committer line - missing space before date")
+		return
+	}
+
+	rest = rest[1:]
+	sp := strings.IndexByte(rest, ' ')
+	if sp <= 0 || !isDigits(rest[:sp]) || (sp > 1 && rest[0] == '0') {
+		r.fail(BadDate, "invalid author/committer line - bad date")
+		return
+	}
+
+	tz := rest[sp+1:]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') || !isDigits(tz[1:]) {
+		r.fail(BadTimezone, "invalid author/committer line - bad time zone")
+	}
+}
+
+// nextLine returns the first line of b, without the line feed, and what
+// follows it.
+func nextLine(b []byte) (string, []byte) {
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return string(b), nil
+	}
+
+	return string(b[:i]), b[i+1:]
+}
+
+// isHash returns true if s is a full lowercase hexadecimal hash.
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,128 @@
+package fsck
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+const (
+	// FetchSection is the config section of the fetch options.
+	FetchSection = "fetch"
+	// ReceivePackSection is the config section of the receive-pack options.
+	ReceivePackSection = "receive"
+)
+
+const (
+	fsckSection     = "fsck"
+	transferSection = "transfer"
+	fsckObjectsKey  = "fsckObjects"
+	skipListKey     = "skipList"
+)
+
+// Enabled returns true if the objects transferred by the given operation,
+// FetchSection or ReceivePackSection, must be checked according to the
+// <section>.fsckObjects config option, or transfer.fsckObjects if not set.
+func Enabled(cfg *config.Config, section string) bool {
+	v := cfg.Raw.Section(section).Options.Get(fsckObjectsKey)
+	if v == "" {
+		v = cfg.Raw.Section(transferSection).Options.Get(fsckObjectsKey)
+	}
+
+	return v == "true"
+}
+
+// NewCheckerFromConfig returns the Checker of the given operation,
+// FetchSection or ReceivePackSection, configured by the fsck.<msg-id> and
+// fsck.skipList config options, which the <section>.fsck.<msg-id> and
+// <section>.fsck.skipList ones override. The skip lists are files holding
+// an object name per line, and comments starting with "#".
+func NewCheckerFromConfig(cfg *config.Config, section string) (*Checker, error) {
+	c := NewChecker()
+
+	var skipList string
+	for _, opts := range []format.Options{
+		cfg.Raw.Section(fsckSection).Options,
+		cfg.Raw.Section(section).Subsection(fsckSection).Options,
+	} {
+		for _, o := range opts {
+			if o.IsKey(skipListKey) {
+				skipList = o.Value
+				continue
+			}
+
+			sev, err := ParseSeverity(o.Value)
+			if err != nil {
+				return nil, fmt.Errorf("malformed fsck option %s: %s", o.Key, err)
+			}
+
+			c.Severities[messageID(o.Key)] = sev
+		}
+	}
+
+	if skipList == "" {
+		return c, nil
+	}
+
+	if err := c.loadSkipList(skipList); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// messageID returns the MessageID of a config key, compared without case.
+func messageID(key string) MessageID {
+	for _, id := range messageIDs {
+		if strings.EqualFold(string(id), key) {
+			return id
+		}
+	}
+
+	return MessageID(key)
+}
+
+var messageIDs = []MessageID{
+	BadTree, BadFilemode, ZeroPaddedFilemode, EmptyName, FullPathname,
+	HasDot, HasDotdot, HasDotgit, GitmodulesSymlink, DuplicateEntries,
+	TreeNotSorted, NullSha1, MissingTree, BadTreeSha1, BadParentSha1, MissingAuthor, MultipleAuthors,
+	MissingCommitter, MissingObject, BadObjectSha1, MissingTypeEntry, BadType,
+	MissingTagEntry, MissingTaggerEntry, MissingNameBeforeEmail,
+	MissingSpaceBeforeEmail, MissingEmail, BadEmail, MissingSpaceBeforeDate,
+	BadDate, BadTimezone,
+}
+
+func (c *Checker) loadSkipList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !isHash(line) {
+			return fmt.Errorf("invalid object name in skip list %s: %q", path, line)
+		}
+
+		c.Skip[plumbing.NewHash(line)] = true
+	}
+
+	return s.Err()
+}
@@ -0,0 +1,92 @@
+package fsck_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConfigSuite struct{}
+
+var _ = Suite(&ConfigSuite{})
+
+func (s *ConfigSuite) TestEnabled(c *C) {
+	cfg := config.NewConfig()
+	c.Assert(fsck.Enabled(cfg, fsck.FetchSection), Equals, false)
+
+	cfg.Raw.Section("transfer").SetOption("fsckObjects", "true")
+	c.Assert(fsck.Enabled(cfg, fsck.FetchSection), Equals, true)
+	c.Assert(fsck.Enabled(cfg, fsck.ReceivePackSection), Equals, true)
+
+	cfg.Raw.Section("receive").SetOption("fsckObjects", "false")
+	c.Assert(fsck.Enabled(cfg, fsck.FetchSection), Equals, true)
+	c.Assert(fsck.Enabled(cfg, fsck.ReceivePackSection), Equals, false)
+}
+
+func (s *ConfigSuite) TestNewCheckerFromConfig(c *C) {
+	cfg := config.NewConfig()
+	cfg.Raw.Section("fsck").SetOption("hasDotgit", "warn")
+	cfg.Raw.Section("fsck").SetOption("badDate", "ignore")
+	cfg.Raw.Section("fetch").Subsection("fsck").SetOption("HASDOTGIT", "ignore")
+
+	ch, err := fsck.NewCheckerFromConfig(cfg, fsck.FetchSection)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Severity(fsck.HasDotgit), Equals, fsck.Ignore)
+	c.Assert(ch.Severity(fsck.BadDate), Equals, fsck.Ignore)
+	c.Assert(ch.Severity(fsck.HasDotdot), Equals, fsck.Error)
+	c.Assert(ch.Severity(fsck.ZeroPaddedFilemode), Equals, fsck.Warn)
+
+	ch, err = fsck.NewCheckerFromConfig(cfg, fsck.ReceivePackSection)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Severity(fsck.HasDotgit), Equals, fsck.Warn)
+}
+
+func (s *ConfigSuite) TestNewCheckerFromConfigBadSeverity(c *C) {
+	cfg := config.NewConfig()
+	cfg.Raw.Section("receive").Subsection("fsck").SetOption("hasDotgit", "fatal")
+
+	_, err := fsck.NewCheckerFromConfig(cfg, fsck.ReceivePackSection)
+	c.Assert(err, ErrorMatches, `malformed fsck option hasDotgit: .*`)
+}
+
+func (s *ConfigSuite) TestSkipList(c *C) {
+	f, err := ioutil.TempFile("", "skiplist")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("# skipped objects\n" + someHash.String() + " # bad tree\n\n")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	cfg := config.NewConfig()
+	cfg.Raw.Section("fsck").SetOption("skipList", "/non-existent")
+	cfg.Raw.Section("fetch").Subsection("fsck").SetOption("skipList", f.Name())
+
+	ch, err := fsck.NewCheckerFromConfig(cfg, fsck.FetchSection)
+	c.Assert(err, IsNil)
+	c.Assert(ch.Skip, DeepEquals, map[plumbing.Hash]bool{someHash: true})
+
+	_, err = fsck.NewCheckerFromConfig(cfg, fsck.ReceivePackSection)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *ConfigSuite) TestSkipListMalformed(c *C) {
+	f, err := ioutil.TempFile("", "skiplist")
+	c.Assert(err, IsNil)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("foo\n")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	cfg := config.NewConfig()
+	cfg.Raw.Section("fsck").SetOption("skipList", f.Name())
+
+	_, err = fsck.NewCheckerFromConfig(cfg, fsck.FetchSection)
+	c.Assert(err, ErrorMatches, `invalid object name in skip list .*: "foo"`)
+}
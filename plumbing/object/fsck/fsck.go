@@ -0,0 +1,210 @@
+// Package fsck validates the format of the git objects, as git fsck does,
+// mainly to reject the malformed objects received by fetch and receive-pack.
+package fsck
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// Severity is how a failed check is reported.
+type Severity int
+
+const (
+	// Error makes the object invalid.
+	Error Severity = iota
+	// Warn reports the failed check to the Checker.Warn function, without
+	// making the object invalid.
+	Warn
+	// Ignore ignores the failed check.
+	Ignore
+)
+
+// ParseSeverity parses a severity as the values of the fsck.<msg-id> config
+// options: "error", "warn" or "ignore".
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "error":
+		return Error, nil
+	case "warn":
+		return Warn, nil
+	case "ignore":
+		return Ignore, nil
+	}
+
+	return Error, fmt.Errorf("unknown fsck severity %q", s)
+}
+
+// MessageID identifies a check, with the names used by git.
+type MessageID string
+
+const (
+	// BadTree is reported for the trees that can't be parsed.
+	BadTree MessageID = "badTree"
+	// BadFilemode is reported for the tree entries with an unknown mode.
+	BadFilemode MessageID = "badFilemode"
+	// ZeroPaddedFilemode is reported for the tree entries with a mode
+	// starting with a zero.
+	ZeroPaddedFilemode MessageID = "zeroPaddedFilemode"
+	// EmptyName is reported for the tree entries with an empty name.
+	EmptyName MessageID = "emptyName"
+	// FullPathname is reported for the tree entries with a slash.
+	FullPathname MessageID = "fullPathname"
+	// HasDot is reported for the tree entries named ".".
+	HasDot MessageID = "hasDot"
+	// HasDotdot is reported for the tree entries named "..".
+	HasDotdot MessageID = "hasDotdot"
+	// HasDotgit is reported for the tree entries named ".git", in any case,
+	// or any of its NTFS and HFS+ aliases.
+	HasDotgit MessageID = "hasDotgit"
+	// DuplicateEntries is reported for the trees with many entries with the
+	// same name.
+	DuplicateEntries MessageID = "duplicateEntries"
+	// TreeNotSorted is reported for the trees with unsorted entries.
+	TreeNotSorted MessageID = "treeNotSorted"
+	// GitmodulesSymlink is reported for the symbolic links named
+	// ".gitmodules", or any of its aliases.
+	GitmodulesSymlink MessageID = "gitmodulesSymlink"
+	// NullSha1 is reported for the tree entries pointing to the zero hash.
+	NullSha1 MessageID = "nullSha1"
+
+	// MissingTree is reported for the commits without tree header.
+	MissingTree MessageID = "missingTree"
+	// BadTreeSha1 is reported for the commits with a malformed tree hash.
+	BadTreeSha1 MessageID = "badTreeSha1"
+	// BadParentSha1 is reported for the commits with a malformed parent
+	// hash.
+	BadParentSha1 MessageID = "badParentSha1"
+	// MissingAuthor is reported for the commits without author.
+	MissingAuthor MessageID = "missingAuthor"
+	// MultipleAuthors is reported for the commits with many authors.
+	MultipleAuthors MessageID = "multipleAuthors"
+	// MissingCommitter is reported for the commits without committer.
+	MissingCommitter MessageID = "missingCommitter"
+
+	// MissingObject is reported for the tags without object header.
+	MissingObject MessageID = "missingObject"
+	// BadObjectSha1 is reported for the tags with a malformed object hash.
+	BadObjectSha1 MessageID = "badObjectSha1"
+	// MissingTypeEntry is reported for the tags without type header.
+	MissingTypeEntry MessageID = "missingTypeEntry"
+	// BadType is reported for the tags with an unknown object type.
+	BadType MessageID = "badType"
+	// MissingTagEntry is reported for the tags without tag header.
+	MissingTagEntry MessageID = "missingTagEntry"
+	// MissingTaggerEntry is reported for the tags without tagger.
+	MissingTaggerEntry MessageID = "missingTaggerEntry"
+
+	// MissingNameBeforeEmail is reported for the identities without name.
+	MissingNameBeforeEmail MessageID = "missingNameBeforeEmail"
+	// MissingSpaceBeforeEmail is reported for the identities without space
+	// between the name and the email.
+	MissingSpaceBeforeEmail MessageID = "missingSpaceBeforeEmail"
+	// MissingEmail is reported for the identities without email.
+	MissingEmail MessageID = "missingEmail"
+	// BadEmail is reported for the identities with a malformed email.
+	BadEmail MessageID = "badEmail"
+	// MissingSpaceBeforeDate is reported for the identities without space
+	// between the email and the date.
+	MissingSpaceBeforeDate MessageID = "missingSpaceBeforeDate"
+	// BadDate is reported for the identities with a malformed date.
+	BadDate MessageID = "badDate"
+	// BadTimezone is reported for the identities with a malformed timezone.
+	BadTimezone MessageID = "badTimezone"
+)
+
+// DefaultSeverities are the severities of the checks not set to Error.
+var DefaultSeverities = map[MessageID]Severity{
+	ZeroPaddedFilemode: Warn,
+	MissingTaggerEntry: Ignore,
+}
+
+// ObjectError is a failed check of an object.
+type ObjectError struct {
+	ID      MessageID
+	Hash    plumbing.Hash
+	Message string
+}
+
+func (e *ObjectError) Error() string {
+	return fmt.Sprintf("object %s: %s: %s", e.Hash, e.ID, e.Message)
+}
+
+// Checker checks the objects, with a severity per check.
+type Checker struct {
+	// Severities overrides the severities of the checks, and the default
+	// ones.
+	Severities map[MessageID]Severity
+	// Skip are the objects not checked.
+	Skip map[plumbing.Hash]bool
+	// Warn, if not nil, is called for the failed checks with Warn severity.
+	Warn func(*ObjectError)
+}
+
+// NewChecker returns a Checker with the default severities.
+func NewChecker() *Checker {
+	return &Checker{
+		Severities: make(map[MessageID]Severity),
+		Skip:       make(map[plumbing.Hash]bool),
+	}
+}
+
+// Severity returns the severity of the given check.
+func (c *Checker) Severity(id MessageID) Severity {
+	if s, ok := c.Severities[id]; ok {
+		return s
+	}
+
+	return DefaultSeverities[id]
+}
+
+// Check checks the object of the given type, hash and content, returning
+// the first failed check with Error severity.
+func (c *Checker) Check(t plumbing.ObjectType, h plumbing.Hash, content []byte) error {
+	if c.Skip[h] {
+		return nil
+	}
+
+	r := &report{c: c, h: h}
+	switch t {
+	case plumbing.TreeObject:
+		checkTree(r, content)
+	case plumbing.CommitObject:
+		checkCommit(r, content)
+	case plumbing.TagObject:
+		checkTag(r, content)
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return nil
+}
+
+// report collects the failed checks of an object.
+type report struct {
+	c   *Checker
+	h   plumbing.Hash
+	err *ObjectError
+}
+
+// fail reports a failed check, the first one with Error severity making the
+// object invalid.
+func (r *report) fail(id MessageID, format string, args ...interface{}) {
+	if r.err != nil {
+		return
+	}
+
+	err := &ObjectError{ID: id, Hash: r.h, Message: fmt.Sprintf(format, args...)}
+	switch r.c.Severity(id) {
+	case Error:
+		r.err = err
+	case Warn:
+		if r.c.Warn != nil {
+			r.c.Warn(err)
+		}
+	}
+}
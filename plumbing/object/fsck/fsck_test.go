@@ -0,0 +1,212 @@
+package fsck_test
+
+import (
+	"bytes"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FsckSuite struct{}
+
+var _ = Suite(&FsckSuite{})
+
+var someHash = plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")
+
+type entry struct {
+	mode, name string
+	hash       plumbing.Hash
+}
+
+func encodeTree(entries ...entry) []byte {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.WriteString(e.mode + " " + e.name + "\x00")
+		buf.Write(e.hash[:])
+	}
+
+	return buf.Bytes()
+}
+
+func (s *FsckSuite) checkTree(c *C, entries ...entry) error {
+	return fsck.NewChecker().Check(plumbing.TreeObject, someHash, encodeTree(entries...))
+}
+
+func (s *FsckSuite) assertFails(c *C, err error, id fsck.MessageID) {
+	c.Assert(err, NotNil)
+	oerr, ok := err.(*fsck.ObjectError)
+	c.Assert(ok, Equals, true, Commentf("%v", err))
+	c.Assert(oerr.ID, Equals, id)
+	c.Assert(oerr.Hash, Equals, someHash)
+}
+
+func (s *FsckSuite) TestTree(c *C) {
+	err := s.checkTree(c,
+		entry{"100644", ".gitignore", someHash},
+		entry{"100755", "a", someHash},
+		entry{"120000", "a-link", someHash},
+		entry{"40000", "b", someHash},
+		entry{"160000", "c", someHash},
+	)
+	c.Assert(err, IsNil)
+}
+
+func (s *FsckSuite) TestTreeBadEntries(c *C) {
+	for name, id := range map[string]fsck.MessageID{
+		"":           fsck.EmptyName,
+		"a/b":        fsck.FullPathname,
+		".":          fsck.HasDot,
+		"..":         fsck.HasDotdot,
+		".git":       fsck.HasDotgit,
+		".GiT":       fsck.HasDotgit,
+		"GIT~1":      fsck.HasDotgit,
+		".git. ":     fsck.HasDotgit,
+		".g\u200cit": fsck.HasDotgit,
+	} {
+		s.assertFails(c, s.checkTree(c, entry{"100644", name, someHash}), id)
+	}
+
+	s.assertFails(c, s.checkTree(c, entry{"100664", "a", someHash}), fsck.BadFilemode)
+	s.assertFails(c, s.checkTree(c, entry{"100", "a", someHash}), fsck.BadFilemode)
+	s.assertFails(c, s.checkTree(c, entry{"1x0644", "a", someHash}), fsck.BadTree)
+	s.assertFails(c, s.checkTree(c, entry{"100644", "a", plumbing.ZeroHash}), fsck.NullSha1)
+	s.assertFails(c, s.checkTree(c, entry{"120000", ".gitmodules", someHash}), fsck.GitmodulesSymlink)
+}
+
+func (s *FsckSuite) TestTreeOrder(c *C) {
+	s.assertFails(c, s.checkTree(c,
+		entry{"100644", "b", someHash},
+		entry{"100644", "a", someHash},
+	), fsck.TreeNotSorted)
+
+	s.assertFails(c, s.checkTree(c,
+		entry{"40000", "a", someHash},
+		entry{"100644", "a-b", someHash},
+	), fsck.TreeNotSorted)
+
+	s.assertFails(c, s.checkTree(c,
+		entry{"100644", "a", someHash},
+		entry{"40000", "a", someHash},
+	), fsck.DuplicateEntries)
+}
+
+func (s *FsckSuite) TestTreeTruncated(c *C) {
+	content := encodeTree(entry{"100644", "a", someHash})
+	err := fsck.NewChecker().Check(plumbing.TreeObject, someHash, content[:len(content)-1])
+	s.assertFails(c, err, fsck.BadTree)
+}
+
+func (s *FsckSuite) TestSeverities(c *C) {
+	var warnings []*fsck.ObjectError
+	ch := fsck.NewChecker()
+	ch.Warn = func(err *fsck.ObjectError) { warnings = append(warnings, err) }
+
+	content := encodeTree(entry{"040000", "a", someHash})
+	c.Assert(ch.Check(plumbing.TreeObject, someHash, content), IsNil)
+	c.Assert(warnings, HasLen, 1)
+	c.Assert(warnings[0].ID, Equals, fsck.ZeroPaddedFilemode)
+
+	ch.Severities[fsck.ZeroPaddedFilemode] = fsck.Error
+	s.assertFails(c, ch.Check(plumbing.TreeObject, someHash, content), fsck.ZeroPaddedFilemode)
+
+	ch.Severities[fsck.ZeroPaddedFilemode] = fsck.Ignore
+	content = encodeTree(entry{"040000", ".git", someHash})
+	ch.Severities[fsck.HasDotgit] = fsck.Warn
+	warnings = nil
+	c.Assert(ch.Check(plumbing.TreeObject, someHash, content), IsNil)
+	c.Assert(warnings, HasLen, 1)
+	c.Assert(warnings[0].ID, Equals, fsck.HasDotgit)
+}
+
+func (s *FsckSuite) TestSkip(c *C) {
+	ch := fsck.NewChecker()
+	ch.Skip[someHash] = true
+
+	content := encodeTree(entry{"100644", "..", someHash})
+	c.Assert(ch.Check(plumbing.TreeObject, someHash, content), IsNil)
+}
+
+const (
+	treeLine      = "tree a8d315b2b1c615d43042c3a62402b8a54288cf5c\n"
+	parentLine    = "parent 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n"
+	authorLine    = "author John Doe <john@example.com> 1257894000 +0100\n"
+	committerLine = "committer John Doe <john@example.com> 1257894000 -0700\n"
+)
+
+func (s *FsckSuite) TestCommit(c *C) {
+	content := treeLine + parentLine + parentLine + authorLine + committerLine + "\nmessage\n"
+	c.Assert(fsck.NewChecker().Check(plumbing.CommitObject, someHash, []byte(content)), IsNil)
+}
+
+func (s *FsckSuite) TestCommitBadHeaders(c *C) {
+	for content, id := range map[string]fsck.MessageID{
+		parentLine + authorLine + committerLine:                                 fsck.MissingTree,
+		"tree a8d315b2\n" + authorLine + committerLine:                          fsck.BadTreeSha1,
+		treeLine + "parent foo\n" + authorLine + committerLine:                  fsck.BadParentSha1,
+		treeLine + committerLine:                                                fsck.MissingAuthor,
+		treeLine + authorLine + authorLine + committerLine:                      fsck.MultipleAuthors,
+		treeLine + authorLine + "\nmessage\n":                                   fsck.MissingCommitter,
+		treeLine + "author John Doe 1257894000 +0100\n" + committerLine:         fsck.MissingEmail,
+		treeLine + "author <john@example.com> 1 +0100\n" + committerLine:        fsck.MissingNameBeforeEmail,
+		treeLine + "author John<john@example.com> 1 +0100\n" + committerLine:    fsck.MissingSpaceBeforeEmail,
+		treeLine + "author John <john@<example.com> 1 +0100\n" + committerLine:  fsck.BadEmail,
+		treeLine + "author John <john@example.com>1 +0100\n" + committerLine:    fsck.MissingSpaceBeforeDate,
+		treeLine + "author John <john@example.com> 01 +0100\n" + committerLine:  fsck.BadDate,
+		treeLine + "author John <john@example.com> foo +0100\n" + committerLine: fsck.BadDate,
+		treeLine + "author John <john@example.com> 1 0100\n" + committerLine:    fsck.BadTimezone,
+	} {
+		err := fsck.NewChecker().Check(plumbing.CommitObject, someHash, []byte(content))
+		s.assertFails(c, err, id)
+	}
+}
+
+const (
+	objectLine = "object 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n"
+	typeLine   = "type commit\n"
+	tagLine    = "tag v1.0.0\n"
+	taggerLine = "tagger John Doe <john@example.com> 1257894000 +0100\n"
+)
+
+func (s *FsckSuite) TestTag(c *C) {
+	content := objectLine + typeLine + tagLine + taggerLine + "\nmessage\n"
+	c.Assert(fsck.NewChecker().Check(plumbing.TagObject, someHash, []byte(content)), IsNil)
+
+	content = objectLine + typeLine + tagLine + "\nmessage\n"
+	c.Assert(fsck.NewChecker().Check(plumbing.TagObject, someHash, []byte(content)), IsNil)
+}
+
+func (s *FsckSuite) TestTagBadHeaders(c *C) {
+	for content, id := range map[string]fsck.MessageID{
+		typeLine + tagLine + taggerLine:                        fsck.MissingObject,
+		"object foo\n" + typeLine + tagLine + taggerLine:       fsck.BadObjectSha1,
+		objectLine + tagLine + taggerLine:                      fsck.MissingTypeEntry,
+		objectLine + "type ofs-delta\n" + tagLine + taggerLine: fsck.BadType,
+		objectLine + "type foo\n" + tagLine + taggerLine:       fsck.BadType,
+		objectLine + typeLine + taggerLine:                     fsck.MissingTagEntry,
+		objectLine + typeLine + tagLine + "tagger John Doe\n":  fsck.MissingEmail,
+	} {
+		err := fsck.NewChecker().Check(plumbing.TagObject, someHash, []byte(content))
+		s.assertFails(c, err, id)
+	}
+
+	ch := fsck.NewChecker()
+	ch.Severities[fsck.MissingTaggerEntry] = fsck.Error
+	err := ch.Check(plumbing.TagObject, someHash, []byte(objectLine+typeLine+tagLine))
+	s.assertFails(c, err, fsck.MissingTaggerEntry)
+}
+
+func (s *FsckSuite) TestParseSeverity(c *C) {
+	for v, expected := range map[string]fsck.Severity{"error": fsck.Error, "WARN": fsck.Warn, "ignore": fsck.Ignore} {
+		sev, err := fsck.ParseSeverity(v)
+		c.Assert(err, IsNil)
+		c.Assert(sev, Equals, expected)
+	}
+
+	_, err := fsck.ParseSeverity("foo")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,34 @@
+package fsck
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+)
+
+type observer struct {
+	c *Checker
+	t plumbing.ObjectType
+}
+
+// NewObserver returns a packfile.Observer checking the objects of the
+// packfile while it's parsed, failing with the first invalid object.
+func NewObserver(c *Checker) packfile.Observer {
+	return &observer{c: c}
+}
+
+func (o *observer) OnHeader(count uint32) error {
+	return nil
+}
+
+func (o *observer) OnInflatedObjectHeader(t plumbing.ObjectType, objSize int64, pos int64) error {
+	o.t = t
+	return nil
+}
+
+func (o *observer) OnInflatedObjectContent(h plumbing.Hash, pos int64, crc uint32, content []byte) error {
+	return o.c.Check(o.t, h, content)
+}
+
+func (o *observer) OnFooter(h plumbing.Hash) error {
+	return nil
+}
@@ -0,0 +1,80 @@
+package fsck
+
+import (
+	"strings"
+)
+
+// IsDotGit returns true if name, the name of a tree entry, is ".git" in any
+// case. If ntfs is true, its NTFS aliases, such as "git~1" or ".git.", match
+// too. If hfs is true, the names matching once the Unicode code points
+// ignored by HFS+ are removed, such as a zero width joiner, match too.
+func IsDotGit(name string, ntfs, hfs bool) bool {
+	if strings.EqualFold(name, ".git") {
+		return true
+	}
+
+	if ntfs && isNTFSDotFile(name, "git", "git~1") {
+		return true
+	}
+
+	return hfs && strings.EqualFold(stripHFSIgnorable(name), ".git")
+}
+
+// IsDotGitmodules returns true if name, the name of a tree entry, is
+// ".gitmodules" in any case, with the NTFS and HFS+ aliases matched as
+// IsDotGit does.
+func IsDotGitmodules(name string, ntfs, hfs bool) bool {
+	if strings.EqualFold(name, ".gitmodules") {
+		return true
+	}
+
+	if ntfs && isNTFSDotFile(name, "gitmodules", "gitmod~1") {
+		return true
+	}
+
+	return hfs && strings.EqualFold(stripHFSIgnorable(name), ".gitmodules")
+}
+
+// isNTFSDotFile returns true if name is the given dot file, or its short
+// name, followed by any number of dots and spaces, which NTFS ignores, or
+// by an alternate data stream.
+func isNTFSDotFile(name, file, short string) bool {
+	var rest string
+	switch {
+	case len(name) > len(file) && name[0] == '.' &&
+		strings.EqualFold(name[1:len(file)+1], file):
+		rest = name[len(file)+1:]
+	case len(name) >= len(short) && strings.EqualFold(name[:len(short)], short):
+		rest = name[len(short):]
+	default:
+		return false
+	}
+
+	for _, c := range rest {
+		switch c {
+		case ':':
+			return true
+		case '.', ' ':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// stripHFSIgnorable removes from name the code points ignored by HFS+ when
+// comparing names.
+func stripHFSIgnorable(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u200c', '\u200d', '\u200e', '\u200f',
+			'\u202a', '\u202b', '\u202c', '\u202d', '\u202e',
+			'\u206a', '\u206b', '\u206c', '\u206d', '\u206e', '\u206f',
+			'\ufeff':
+			return -1
+		}
+
+		return r
+	}, name)
+}
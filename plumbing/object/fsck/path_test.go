@@ -0,0 +1,41 @@
+package fsck_test
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
+
+	. "gopkg.in/check.v1"
+)
+
+type PathSuite struct{}
+
+var _ = Suite(&PathSuite{})
+
+func (s *PathSuite) TestIsDotGit(c *C) {
+	for _, name := range []string{".git", ".GIT", ".gIt"} {
+		c.Assert(fsck.IsDotGit(name, false, false), Equals, true, Commentf(name))
+	}
+
+	for _, name := range []string{"git~1", "GIT~1", ".git.", ".git ..", ".git::$INDEX_ALLOCATION", "git~1:stream"} {
+		c.Assert(fsck.IsDotGit(name, false, false), Equals, false, Commentf(name))
+		c.Assert(fsck.IsDotGit(name, true, false), Equals, true, Commentf(name))
+	}
+
+	for _, name := range []string{".g\u200cit", "\ufeff.GIT", ".git\u206f"} {
+		c.Assert(fsck.IsDotGit(name, false, false), Equals, false, Commentf(name))
+		c.Assert(fsck.IsDotGit(name, false, true), Equals, true, Commentf(name))
+	}
+
+	for _, name := range []string{"git", ".gitx", "git~2", ".git.x", ".gi", "", ".gitmodules"} {
+		c.Assert(fsck.IsDotGit(name, true, true), Equals, false, Commentf(name))
+	}
+}
+
+func (s *PathSuite) TestIsDotGitmodules(c *C) {
+	c.Assert(fsck.IsDotGitmodules(".GitModules", false, false), Equals, true)
+	c.Assert(fsck.IsDotGitmodules("gitmod~1", false, false), Equals, false)
+	c.Assert(fsck.IsDotGitmodules("gitmod~1", true, false), Equals, true)
+	c.Assert(fsck.IsDotGitmodules(".gitmodules . ", true, false), Equals, true)
+	c.Assert(fsck.IsDotGitmodules(".gitmodules\u200d", false, true), Equals, true)
+	c.Assert(fsck.IsDotGitmodules(".git", true, true), Equals, false)
+	c.Assert(fsck.IsDotGitmodules(".gitmodulesx", true, true), Equals, false)
+}
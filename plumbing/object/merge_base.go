@@ -14,6 +14,10 @@ var errIsReachable = fmt.Errorf("first is reachable from second")
 // MergeBase mimics the behavior of `git merge-base actual other`, returning the
 // best common ancestor between the actual and the passed one.
 // The best common ancestors can not be reached from other common ancestors.
+//
+// The whole history of both commits is walked, since a Commit has no access
+// to the commit-graph of its repository. commitgraph.MergeBase uses the
+// generations of a commitgraph.CommitNodeIndex to stop the walk early.
 func (c *Commit) MergeBase(other *Commit) ([]*Commit, error) {
 	// use sortedByCommitDateDesc strategy
 	sorted := sortByCommitDateDesc(c, other)
@@ -43,6 +47,8 @@ func (c *Commit) MergeBase(other *Commit) ([]*Commit, error) {
 // IsAncestor returns true if the actual commit is ancestor of the passed one.
 // It returns an error if the history is not transversable
 // It mimics the behavior of `git merge --is-ancestor actual other`
+//
+// As MergeBase, it doesn't use the commit-graph, see commitgraph.IsAncestor.
 func (c *Commit) IsAncestor(other *Commit) (bool, error) {
 	found := false
 	iter := NewCommitPreorderIter(other, nil, nil)
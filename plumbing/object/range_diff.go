@@ -0,0 +1,425 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	fdiff "gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// DefaultCreationFactor is the percentage of the size of a patch used as the
+// cost of considering it created or removed, instead of paired with a patch
+// of the other series, same as the default of `git range-diff`.
+const DefaultCreationFactor = 60
+
+// CommitRange is the range of commits reachable from Tip and not reachable
+// from Base, as `Base..Tip`.
+type CommitRange struct {
+	Base *Commit
+	Tip  *Commit
+}
+
+// RangeDiffOptions describes how a range diff should be performed.
+type RangeDiffOptions struct {
+	// CreationFactor is the percentage of the size of a patch used as the
+	// cost of not pairing it, by default DefaultCreationFactor. The higher
+	// it is, the more different the paired patches can be.
+	CreationFactor int
+}
+
+// RangeDiffPair is a commit of the old series paired with a commit of the new
+// series. Old is nil for the commits only in the new series, and New is nil
+// for the commits removed from the old series.
+type RangeDiffPair struct {
+	Old, New *Commit
+	// OldIndex and NewIndex are the 1-based positions of the commits in
+	// their series, 0 when the commit is nil.
+	OldIndex, NewIndex int
+	// Interdiff is the diff between the patches of the commits, nil if both
+	// patches are equal or one of the commits is nil.
+	Interdiff *Patch
+}
+
+// String returns the header of the pair, as printed by `git range-diff`.
+func (p *RangeDiffPair) String() string {
+	status := "!"
+	switch {
+	case p.Old == nil:
+		status = ">"
+	case p.New == nil:
+		status = "<"
+	case p.Interdiff == nil:
+		status = "="
+	}
+
+	return fmt.Sprintf("%s %s %s %s",
+		rangeDiffCommit(p.OldIndex, p.Old), status,
+		rangeDiffCommit(p.NewIndex, p.New), rangeDiffSubject(p.Old, p.New))
+}
+
+func rangeDiffCommit(index int, c *Commit) string {
+	if c == nil {
+		return "-:  -------"
+	}
+
+	return fmt.Sprintf("%d:  %s", index, c.Hash.String()[:7])
+}
+
+func rangeDiffSubject(old, new *Commit) string {
+	c := new
+	if c == nil {
+		c = old
+	}
+
+	return strings.SplitN(c.Message, "\n", 2)[0]
+}
+
+// RangeDiff mimics the behavior of `git range-diff old.Base..old.Tip
+// new.Base..new.Tip`, pairing the commits of both series by the minimal cost
+// of the differences between their patches, and returning the pairs in the
+// order of the new series.
+func RangeDiff(old, new *CommitRange) ([]*RangeDiffPair, error) {
+	return RangeDiffContext(context.Background(), old, new, nil)
+}
+
+// RangeDiffContext is the same as RangeDiff, but it returns an error if the
+// context expires. Provided context must be non-nil, opts can be nil.
+func RangeDiffContext(ctx context.Context, old, new *CommitRange, opts *RangeDiffOptions) ([]*RangeDiffPair, error) {
+	factor := DefaultCreationFactor
+	if opts != nil && opts.CreationFactor > 0 {
+		factor = opts.CreationFactor
+	}
+
+	a, err := rangePatches(ctx, old)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := rangePatches(ctx, new)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(a) + len(b)
+	cost := make([][]int, n)
+	for i := range cost {
+		cost[i] = make([]int, n)
+	}
+
+	for i := range a {
+		for j := range b {
+			select {
+			case <-ctx.Done():
+				return nil, ErrCanceled
+			default:
+			}
+
+			if a[i].text == b[j].text {
+				continue
+			}
+
+			cost[i][j] = diffSize(diff.Do(a[i].text, b[j].text))
+		}
+
+		for j := len(b); j < n; j++ {
+			cost[i][j] = a[i].size * factor / 100
+		}
+	}
+
+	for i := len(a); i < n; i++ {
+		for j := range b {
+			cost[i][j] = b[j].size * factor / 100
+		}
+	}
+
+	assignment := minimalAssignment(cost)
+
+	aMatch := make([]int, len(a))
+	bMatch := make([]int, len(b))
+	for j := range bMatch {
+		bMatch[j] = -1
+	}
+
+	for i := range a {
+		aMatch[i] = -1
+		if j := assignment[i]; j < len(b) {
+			aMatch[i] = j
+			bMatch[j] = i
+		}
+	}
+
+	return rangeDiffPairs(a, b, aMatch, bMatch), nil
+}
+
+// rangeDiffPairs sorts the pairs as git does, following the new series, and
+// showing the removed commits once the preceding ones are shown.
+func rangeDiffPairs(a, b []*rangePatch, aMatch, bMatch []int) []*RangeDiffPair {
+	var pairs []*RangeDiffPair
+	shown := make([]bool, len(a))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && shown[i] {
+			i++
+		}
+
+		if i < len(a) && aMatch[i] < 0 {
+			pairs = append(pairs, &RangeDiffPair{Old: a[i].commit, OldIndex: i + 1})
+			shown[i] = true
+			i++
+			continue
+		}
+
+		for j < len(b) && bMatch[j] < 0 {
+			pairs = append(pairs, &RangeDiffPair{New: b[j].commit, NewIndex: j + 1})
+			j++
+		}
+
+		if j < len(b) {
+			k := bMatch[j]
+			pair := &RangeDiffPair{
+				Old: a[k].commit, OldIndex: k + 1,
+				New: b[j].commit, NewIndex: j + 1,
+			}
+
+			if a[k].text != b[j].text {
+				pair.Interdiff = interdiff(a[k].text, b[j].text)
+				pair.Interdiff.message = pair.String()
+			}
+
+			pairs = append(pairs, pair)
+			shown[k] = true
+			j++
+		}
+	}
+
+	return pairs
+}
+
+// interdiff returns the diff between two patch texts as a Patch, without file
+// headers, so it's encoded as a sequence of hunks.
+func interdiff(from, to string) *Patch {
+	var chunks []fdiff.Chunk
+	for _, d := range diff.Do(from, to) {
+		var op fdiff.Operation
+		switch d.Type {
+		case dmp.DiffEqual:
+			op = fdiff.Equal
+		case dmp.DiffDelete:
+			op = fdiff.Delete
+		case dmp.DiffInsert:
+			op = fdiff.Add
+		}
+
+		chunks = append(chunks, &textChunk{d.Text, op})
+	}
+
+	return &Patch{filePatches: []fdiff.FilePatch{&textFilePatch{chunks: chunks}}}
+}
+
+func diffSize(diffs []dmp.Diff) int {
+	var size int
+	for _, d := range diffs {
+		if d.Type != dmp.DiffEqual {
+			size += strings.Count(d.Text, "\n")
+		}
+	}
+
+	return size
+}
+
+// patchSize returns the number of added and deleted lines of a patch text.
+func patchSize(text string) int {
+	var size int
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			size++
+		}
+	}
+
+	return size
+}
+
+type rangePatch struct {
+	commit *Commit
+	text   string
+	size   int
+}
+
+// rangePatches returns the patches of the non-merge commits of the range, from
+// the oldest to the newest.
+func rangePatches(ctx context.Context, r *CommitRange) ([]*rangePatch, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if r.Base != nil {
+		err := NewCommitPreorderIter(r.Base, nil, nil).ForEach(func(c *Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*Commit
+	err := NewCommitPreorderIter(r.Tip, excluded, nil).ForEach(func(c *Commit) error {
+		if c.NumParents() < 2 {
+			commits = append(commits, c)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	patches := make([]*rangePatch, len(commits))
+	for i, c := range commits {
+		text, err := rangePatchText(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+
+		patches[len(commits)-1-i] = &rangePatch{
+			commit: c,
+			text:   text,
+			size:   patchSize(text),
+		}
+	}
+
+	return patches, nil
+}
+
+var hunkHeaderRegExp = regexp.MustCompile(`^@@ -[0-9,]+ \+[0-9,]+ @@`)
+
+// rangePatchText returns the text compared between the commits: the message
+// and the patch against the first parent, without the index lines and the line
+// numbers of the hunks, since they change with unrelated commits.
+func rangePatchText(ctx context.Context, c *Commit) (string, error) {
+	to, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	var from *Tree
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return "", err
+		}
+
+		if from, err = parent.Tree(); err != nil {
+			return "", err
+		}
+	}
+
+	changes, err := DiffTreeContext(ctx, from, to)
+	if err != nil {
+		return "", err
+	}
+
+	patch, err := changes.PatchContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, line := range strings.SplitAfter(strings.TrimRight(c.Message, "\n"), "\n") {
+		buf.WriteString("    " + strings.TrimRight(line, "\n") + "\n")
+	}
+
+	buf.WriteString("\n")
+
+	encoded := bytes.NewBuffer(nil)
+	if err := patch.Encode(encoded); err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.SplitAfter(encoded.String(), "\n") {
+		if strings.HasPrefix(line, "index ") {
+			continue
+		}
+
+		buf.WriteString(hunkHeaderRegExp.ReplaceAllString(line, "@@"))
+	}
+
+	return buf.String(), nil
+}
+
+// minimalAssignment solves the assignment problem for the given square cost
+// matrix with the Hungarian algorithm, returning the column assigned to each
+// row.
+func minimalAssignment(cost [][]int) []int {
+	n := len(cost)
+	// u and v are the potentials of the rows and the columns, p the row
+	// assigned to each column, all of them 1-based, with 0 as a sentinel.
+	u := make([]int, n+1)
+	v := make([]int, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]int, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.MaxInt32
+		}
+
+		for p[j0] != 0 {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.MaxInt32, 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+
+				cur := cost[i0-1][j-1] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+
+			j0 = j1
+		}
+
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, n)
+	for j := 1; j <= n; j++ {
+		if p[j] != 0 {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+
+	return assignment
+}
@@ -0,0 +1,142 @@
+package object_test
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type RangeDiffSuite struct {
+	r *git.Repository
+}
+
+var _ = Suite(&RangeDiffSuite{})
+
+func (s *RangeDiffSuite) SetUpTest(c *C) {
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	s.r = r
+}
+
+// commit writes the given files on top of the parent commit, or on top of
+// the current worktree if the parent is the zero hash.
+func (s *RangeDiffSuite) commit(c *C, parent plumbing.Hash, msg string, files ...string) *object.Commit {
+	w, err := s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	if !parent.IsZero() {
+		c.Assert(w.Checkout(&git.CheckoutOptions{Hash: parent, Force: true}), IsNil)
+	}
+
+	for i := 0; i < len(files); i += 2 {
+		c.Assert(util.WriteFile(w.Filesystem, files[i], []byte(files[i+1]), 0644), IsNil)
+		_, err = w.Add(files[i])
+		c.Assert(err, IsNil)
+	}
+
+	hash, err := w.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{Name: "Foo", Email: "foo@example.local", When: time.Now()},
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := s.r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	return commit
+}
+
+func (s *RangeDiffSuite) TestRangeDiff(c *C) {
+	base := s.commit(c, plumbing.ZeroHash, "base\n", "a", "1\n2\n3\n4\n5\n6\n")
+	o1 := s.commit(c, base.Hash, "add b\n", "b", "b\n")
+	o2 := s.commit(c, o1.Hash, "change a\n", "a", "1\n2\nthree\nfour\n5\n6\n")
+	o3 := s.commit(c, o2.Hash, "add c\n", "c", "c\nc\nc\n")
+
+	newBase := s.commit(c, base.Hash, "add z\n", "z", "z\n")
+	n1 := s.commit(c, newBase.Hash, "add b\n", "b", "b\n")
+	n2 := s.commit(c, n1.Hash, "change a\n", "a", "1\n2\nTHREE\nfour\n5\n6\n")
+	n3 := s.commit(c, n2.Hash, "add d\n", "d", "d\nd\nd\nd\n")
+
+	pairs, err := object.RangeDiff(
+		&object.CommitRange{Base: base, Tip: o3},
+		&object.CommitRange{Base: newBase, Tip: n3},
+	)
+	c.Assert(err, IsNil)
+	c.Assert(pairs, HasLen, 4)
+
+	c.Assert(pairs[0].Old.Hash, Equals, o1.Hash)
+	c.Assert(pairs[0].New.Hash, Equals, n1.Hash)
+	c.Assert(pairs[0].Interdiff, IsNil)
+	c.Assert(pairs[0].String(), Equals,
+		"1:  "+o1.Hash.String()[:7]+" = 1:  "+n1.Hash.String()[:7]+" add b")
+
+	c.Assert(pairs[1].Old.Hash, Equals, o2.Hash)
+	c.Assert(pairs[1].New.Hash, Equals, n2.Hash)
+	c.Assert(pairs[1].Interdiff, NotNil)
+	c.Assert(pairs[1].Interdiff.String(), Equals, ""+
+		pairs[1].String()+"\n"+
+		"@@ -8,7 +8,7 @@  1\n"+
+		"  2\n"+
+		" -3\n"+
+		" -4\n"+
+		"-+three\n"+
+		"++THREE\n"+
+		" +four\n"+
+		"  5\n"+
+		"  6\n")
+
+	c.Assert(pairs[2].Old.Hash, Equals, o3.Hash)
+	c.Assert(pairs[2].New, IsNil)
+	c.Assert(pairs[2].String(), Equals, "3:  "+o3.Hash.String()[:7]+" < -:  ------- add c")
+
+	c.Assert(pairs[3].Old, IsNil)
+	c.Assert(pairs[3].New.Hash, Equals, n3.Hash)
+	c.Assert(pairs[3].String(), Equals, "-:  ------- > 3:  "+n3.Hash.String()[:7]+" add d")
+}
+
+func (s *RangeDiffSuite) TestRangeDiffCreationFactor(c *C) {
+	base := s.commit(c, plumbing.ZeroHash, "base\n", "a", "a\n")
+	old := s.commit(c, base.Hash, "add b\n", "b", "1\n2\n3\n")
+	new := s.commit(c, base.Hash, "add b\n", "b", "4\n5\n6\n")
+
+	pairs, err := object.RangeDiff(
+		&object.CommitRange{Base: base, Tip: old},
+		&object.CommitRange{Base: base, Tip: new},
+	)
+	c.Assert(err, IsNil)
+	c.Assert(pairs, HasLen, 2)
+	c.Assert(pairs[0].New, IsNil)
+	c.Assert(pairs[1].Old, IsNil)
+
+	pairs, err = object.RangeDiffContext(context.Background(),
+		&object.CommitRange{Base: base, Tip: old},
+		&object.CommitRange{Base: base, Tip: new},
+		&object.RangeDiffOptions{CreationFactor: 200},
+	)
+	c.Assert(err, IsNil)
+	c.Assert(pairs, HasLen, 1)
+	c.Assert(pairs[0].Old.Hash, Equals, old.Hash)
+	c.Assert(pairs[0].New.Hash, Equals, new.Hash)
+	c.Assert(pairs[0].Interdiff, NotNil)
+}
+
+func (s *RangeDiffSuite) TestRangeDiffSameSeries(c *C) {
+	base := s.commit(c, plumbing.ZeroHash, "base\n", "a", "a\n")
+	tip := s.commit(c, base.Hash, "add b\n", "b", "b\n")
+
+	pairs, err := object.RangeDiff(
+		&object.CommitRange{Base: base, Tip: tip},
+		&object.CommitRange{Base: base, Tip: tip},
+	)
+	c.Assert(err, IsNil)
+	c.Assert(pairs, HasLen, 1)
+	c.Assert(pairs[0].Interdiff, IsNil)
+	c.Assert(pairs[0].OldIndex, Equals, 1)
+	c.Assert(pairs[0].NewIndex, Equals, 1)
+}
@@ -230,6 +230,17 @@ const (
 	PushCert Capability = "push-cert"
 	// SymRef symbolic reference support for better negotiation.
 	SymRef Capability = "symref"
+	// PackfileURIs if the upload-pack server advertises this capability, the
+	// client may request it with the comma-separated list of protocols it
+	// can download packfiles from (e.g. "packfile-uris=http,https"). The
+	// server may then leave some objects out of the response packfile, and
+	// send instead the URIs and checksums of pregenerated packfiles holding
+	// them, before the packfile. This is a go-git extension to the protocol v0,
+	// modelled after the protocol v2 packfile-uris fetch argument, which
+	// isn't supported: git neither advertises nor requests it, so the
+	// response is only changed for the go-git clients requesting it from a
+	// go-git server configured with static packs.
+	PackfileURIs Capability = "packfile-uris"
 )
 
 const DefaultAgent = "go-git/4.x"
@@ -241,12 +252,17 @@ var known = map[Capability]bool{
 	NoProgress: true, IncludeTag: true, ReportStatus: true, DeleteRefs: true,
 	Quiet: true, Atomic: true, PushOptions: true, AllowTipSHA1InWant: true,
 	AllowReachableSHA1InWant: true, PushCert: true, SymRef: true,
+	PackfileURIs: true,
 }
 
 var requiresArgument = map[Capability]bool{
 	Agent: true, PushCert: true, SymRef: true,
 }
 
+var optionalArgument = map[Capability]bool{
+	PackfileURIs: true,
+}
+
 var multipleArgument = map[Capability]bool{
 	SymRef: true,
 }
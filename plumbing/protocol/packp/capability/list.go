@@ -86,10 +86,7 @@ func (l *List) Get(capability Capability) []string {
 
 // Set sets a capability removing the previous values
 func (l *List) Set(capability Capability, values ...string) error {
-	if _, ok := l.m[capability]; ok {
-		delete(l.m, capability)
-	}
-
+	l.Delete(capability)
 	return l.Add(capability, values...)
 }
 
@@ -133,7 +130,7 @@ func (l *List) validate(c Capability, values []string) error {
 		return ErrArgumentsRequired
 	}
 
-	if !requiresArgument[c] && len(values) != 0 {
+	if !requiresArgument[c] && !optionalArgument[c] && len(values) != 0 {
 		return ErrArguments
 	}
 
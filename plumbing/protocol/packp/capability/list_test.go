@@ -173,6 +173,17 @@ func (s *SuiteCapabilities) TestAddErrArgumentsNotAllowed(c *check.C) {
 	c.Assert(err, check.Equals, ErrArguments)
 }
 
+func (s *SuiteCapabilities) TestAddOptionalArgument(c *check.C) {
+	cap := NewList()
+	err := cap.Add(PackfileURIs)
+	c.Assert(err, check.IsNil)
+	c.Assert(cap.String(), check.Equals, "packfile-uris")
+
+	err = cap.Set(PackfileURIs, "http,https")
+	c.Assert(err, check.IsNil)
+	c.Assert(cap.String(), check.Equals, "packfile-uris=http,https")
+}
+
 func (s *SuiteCapabilities) TestAddErrArguments(c *check.C) {
 	cap := NewList()
 	err := cap.Add(SymRef, "")
@@ -8,7 +8,8 @@ type stateFn func() stateFn
 
 const (
 	// common
-	hashSize = 40
+	hashSize   = 40
+	pktLenSize = 4
 
 	// advrefs
 	head   = "HEAD"
@@ -37,6 +38,9 @@ var (
 	// shallow-update
 	unshallow = []byte("unshallow ")
 
+	// packfile-uris
+	packfileURIsHeader = []byte("packfile-uris")
+
 	// server-response
 	ack = []byte("ACK")
 	nak = []byte("NAK")
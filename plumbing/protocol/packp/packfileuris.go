@@ -0,0 +1,96 @@
+package packp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+)
+
+// PackfileURI is a pregenerated packfile the client must download to get
+// the objects left out of an upload-pack response packfile.
+type PackfileURI struct {
+	// Hash is the checksum of the packfile.
+	Hash plumbing.Hash
+	// URI is where the packfile is downloaded from.
+	URI string
+}
+
+// PackfileURIs is the packfile-uris section of an upload-pack response.
+type PackfileURIs []*PackfileURI
+
+// Decode decodes the section from the reader, if any. The reader is left
+// untouched if the next line isn't the section header.
+func (u *PackfileURIs) Decode(reader *bufio.Reader) error {
+	ahead, err := reader.Peek(pktLenSize + len(packfileURIsHeader))
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(ahead[pktLenSize:], packfileURIsHeader) {
+		return nil
+	}
+
+	s := pktline.NewScanner(reader)
+	if !s.Scan() {
+		return s.Err()
+	}
+
+	for s.Scan() {
+		line := s.Bytes()
+		if len(line) == 0 {
+			return nil
+		}
+
+		if err := u.decodeLine(bytes.TrimSuffix(line, eol)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("unexpected EOF in packfile-uris section")
+}
+
+func (u *PackfileURIs) decodeLine(line []byte) error {
+	if len(line) < hashSize+2 || line[hashSize] != ' ' {
+		return fmt.Errorf("malformed packfile-uri %q", line)
+	}
+
+	*u = append(*u, &PackfileURI{
+		Hash: plumbing.NewHash(string(line[:hashSize])),
+		URI:  string(line[hashSize+1:]),
+	})
+
+	return nil
+}
+
+// Encode encodes the section into the writer, nothing is written if there
+// are no URIs.
+func (u PackfileURIs) Encode(w io.Writer) error {
+	if len(u) == 0 {
+		return nil
+	}
+
+	e := pktline.NewEncoder(w)
+	if err := e.Encodef("%s\n", packfileURIsHeader); err != nil {
+		return err
+	}
+
+	for _, p := range u {
+		if err := e.Encodef("%s %s\n", p.Hash, p.URI); err != nil {
+			return err
+		}
+	}
+
+	return e.Flush()
+}
@@ -18,10 +18,10 @@ const chLen = 1
 // NewMuxer returns a new Muxer for the given t that writes on w.
 //
 // If t is equal to `Sideband` the max pack size is set to MaxPackedSize, in any
-// other value is given, max pack is set to MaxPackedSize64k, that is the
-// maximum length of a line in pktline format.
+// other value is given, max pack is set to pktline.MaxPayloadSize, that is the
+// maximum length of the payload of a line in pktline format.
 func NewMuxer(t Type, w io.Writer) *Muxer {
-	max := MaxPackedSize64k
+	max := pktline.MaxPayloadSize
 	if t == Sideband {
 		max = MaxPackedSize
 	}
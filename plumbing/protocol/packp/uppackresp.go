@@ -20,11 +20,15 @@ var ErrUploadPackResponseNotDecoded = errors.New("upload-pack-response should be
 type UploadPackResponse struct {
 	ShallowUpdate
 	ServerResponse
-
-	r          io.ReadCloser
-	isShallow  bool
-	isMultiACK bool
-	isOk       bool
+	// PackfileURIs are the packfiles to download besides the response
+	// packfile, if the request was done with the packfile-uris capability.
+	PackfileURIs PackfileURIs
+
+	r              io.ReadCloser
+	isShallow      bool
+	isMultiACK     bool
+	isPackfileURIs bool
+	isOk           bool
 }
 
 // NewUploadPackResponse create a new UploadPackResponse instance, the request
@@ -35,8 +39,9 @@ func NewUploadPackResponse(req *UploadPackRequest) *UploadPackResponse {
 		req.Capabilities.Supports(capability.MultiACKDetailed)
 
 	return &UploadPackResponse{
-		isShallow:  isShallow,
-		isMultiACK: isMultiACK,
+		isShallow:      isShallow,
+		isMultiACK:     isMultiACK,
+		isPackfileURIs: req.Capabilities.Supports(capability.PackfileURIs),
 	}
 }
 
@@ -65,6 +70,12 @@ func (r *UploadPackResponse) Decode(reader io.ReadCloser) error {
 		return err
 	}
 
+	if r.isPackfileURIs {
+		if err := r.PackfileURIs.Decode(buf); err != nil {
+			return err
+		}
+	}
+
 	// now the reader is ready to read the packfile content
 	r.r = ioutil.NewReadCloser(buf, reader)
 
@@ -83,6 +94,10 @@ func (r *UploadPackResponse) Encode(w io.Writer) (err error) {
 		return err
 	}
 
+	if err := r.PackfileURIs.Encode(w); err != nil {
+		return err
+	}
+
 	defer ioutil.CheckClose(r.r, &err)
 	_, err = io.Copy(w, r.r)
 	return err
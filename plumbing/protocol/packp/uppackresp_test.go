@@ -124,3 +124,66 @@ func (s *UploadPackResponseSuite) TestEncodeMultiACK(c *C) {
 	b := bytes.NewBuffer(nil)
 	c.Assert(res.Encode(b), NotNil)
 }
+
+func (s *UploadPackResponseSuite) TestEncodePackfileURIs(c *C) {
+	pf := ioutil.NopCloser(bytes.NewBuffer([]byte("PACK")))
+	req := NewUploadPackRequest()
+	req.Capabilities.Set(capability.PackfileURIs, "https")
+
+	res := NewUploadPackResponseWithPackfile(req, pf)
+	defer func() { c.Assert(res.Close(), IsNil) }()
+	res.PackfileURIs = PackfileURIs{{
+		Hash: plumbing.NewHash("5dc01c595e6c6ec9ccda4f6f69c131c0dd945f81"),
+		URI:  "https://example.com/foo.pack",
+	}}
+
+	b := bytes.NewBuffer(nil)
+	c.Assert(res.Encode(b), IsNil)
+
+	expected := "0008NAK\n" +
+		"0012packfile-uris\n" +
+		"004a5dc01c595e6c6ec9ccda4f6f69c131c0dd945f81 https://example.com/foo.pack\n" +
+		"0000PACK"
+	c.Assert(b.String(), Equals, expected)
+}
+
+func (s *UploadPackResponseSuite) TestDecodePackfileURIs(c *C) {
+	raw := "0008NAK\n" +
+		"0012packfile-uris\n" +
+		"004a5dc01c595e6c6ec9ccda4f6f69c131c0dd945f81 https://example.com/foo.pack\n" +
+		"0000PACK"
+
+	req := NewUploadPackRequest()
+	req.Capabilities.Set(capability.PackfileURIs, "https")
+
+	res := NewUploadPackResponse(req)
+	defer res.Close()
+
+	err := res.Decode(ioutil.NopCloser(bytes.NewBufferString(raw)))
+	c.Assert(err, IsNil)
+	c.Assert(res.PackfileURIs, HasLen, 1)
+	c.Assert(res.PackfileURIs[0].Hash.String(), Equals, "5dc01c595e6c6ec9ccda4f6f69c131c0dd945f81")
+	c.Assert(res.PackfileURIs[0].URI, Equals, "https://example.com/foo.pack")
+
+	pack, err := ioutil.ReadAll(res)
+	c.Assert(err, IsNil)
+	c.Assert(pack, DeepEquals, []byte("PACK"))
+}
+
+func (s *UploadPackResponseSuite) TestDecodePackfileURIsNone(c *C) {
+	raw := "0008NAK\nPACK"
+
+	req := NewUploadPackRequest()
+	req.Capabilities.Set(capability.PackfileURIs, "https")
+
+	res := NewUploadPackResponse(req)
+	defer res.Close()
+
+	err := res.Decode(ioutil.NopCloser(bytes.NewBufferString(raw)))
+	c.Assert(err, IsNil)
+	c.Assert(res.PackfileURIs, HasLen, 0)
+
+	pack, err := ioutil.ReadAll(res)
+	c.Assert(err, IsNil)
+	c.Assert(pack, DeepEquals, []byte("PACK"))
+}
@@ -100,6 +100,7 @@ func (r *runner) Command(cmd string, ep *transport.Endpoint, auth transport.Auth
 
 type command struct {
 	cmd          *exec.Cmd
+	stdoutCloser io.Closer
 	stderrCloser io.Closer
 	closed       bool
 }
@@ -122,7 +123,9 @@ func (c *command) StdinPipe() (io.WriteCloser, error) {
 }
 
 func (c *command) StdoutPipe() (io.Reader, error) {
-	return c.cmd.StdoutPipe()
+	r, err := c.cmd.StdoutPipe()
+	c.stdoutCloser = r
+	return r, err
 }
 
 func (c *command) Kill() error {
@@ -130,7 +133,8 @@ func (c *command) Kill() error {
 	return c.Close()
 }
 
-// Close waits for the command to exit.
+// Close waits for the command to exit. Its output is closed first, so it
+// doesn't block writing what was left unread, e.g. when a fetch is aborted.
 func (c *command) Close() error {
 	if c.closed {
 		return nil
@@ -142,6 +146,10 @@ func (c *command) Close() error {
 
 	}()
 
+	if c.stdoutCloser != nil {
+		_ = c.stdoutCloser.Close()
+	}
+
 	err := c.cmd.Wait()
 	if _, ok := err.(*os.PathError); ok {
 		return nil
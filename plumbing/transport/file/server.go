@@ -1,8 +1,13 @@
 package file
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/internal/common"
@@ -10,11 +15,42 @@ import (
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
+// ErrTimeout is returned by the server commands when the transfer is
+// interrupted after ServeOptions.Timeout of inactivity.
+var ErrTimeout = errors.New("timeout, no activity in the transfer")
+
+// ServeOptions describes how a git-upload-pack or git-receive-pack request
+// should be served.
+type ServeOptions struct {
+	// Strict only serves the repository if path is a git directory. Otherwise
+	// path/.git, path.git/.git and path.git are tried too, as git does.
+	Strict bool
+	// Timeout, if not zero, interrupts the transfer after this time of
+	// inactivity, returning ErrTimeout. The input and the output are closed,
+	// when they are io.Closers, to stop the transfer.
+	Timeout time.Duration
+	// StatelessRPC serves a single request without advertising the
+	// references first, as it's done over smart HTTP.
+	StatelessRPC bool
+	// AdvertiseRefs only advertises the references, and returns.
+	AdvertiseRefs bool
+	// Stdin and Stdout, if not nil, are used instead of the standard input
+	// and output.
+	Stdin  io.Reader
+	Stdout io.Writer
+}
+
 // ServeUploadPack serves a git-upload-pack request using standard output, input
 // and error. This is meant to be used when implementing a git-upload-pack
 // command.
 func ServeUploadPack(path string) error {
-	ep, err := transport.NewEndpoint(path)
+	return ServeUploadPackWithOptions(path, &ServeOptions{Strict: true})
+}
+
+// ServeUploadPackWithOptions serves a git-upload-pack request as
+// ServeUploadPack, with the given options.
+func ServeUploadPackWithOptions(path string, o *ServeOptions) error {
+	ep, err := newServeEndpoint(path, o.Strict)
 	if err != nil {
 		return err
 	}
@@ -25,14 +61,22 @@ func ServeUploadPack(path string) error {
 		return fmt.Errorf("error creating session: %s", err)
 	}
 
-	return common.ServeUploadPack(srvCmd, s)
+	return serve(o, func(cmd common.ServerCommand) error {
+		return common.ServeUploadPack(cmd, s)
+	})
 }
 
 // ServeReceivePack serves a git-receive-pack request using standard output,
 // input and error. This is meant to be used when implementing a
 // git-receive-pack command.
 func ServeReceivePack(path string) error {
-	ep, err := transport.NewEndpoint(path)
+	return ServeReceivePackWithOptions(path, &ServeOptions{Strict: true})
+}
+
+// ServeReceivePackWithOptions serves a git-receive-pack request as
+// ServeReceivePack, with the given options.
+func ServeReceivePackWithOptions(path string, o *ServeOptions) error {
+	ep, err := newServeEndpoint(path, o.Strict)
 	if err != nil {
 		return err
 	}
@@ -43,7 +87,148 @@ func ServeReceivePack(path string) error {
 		return fmt.Errorf("error creating session: %s", err)
 	}
 
-	return common.ServeReceivePack(srvCmd, s)
+	return serve(o, func(cmd common.ServerCommand) error {
+		return common.ServeReceivePack(cmd, s)
+	})
+}
+
+// gitDirSuffixes are the suffixes tried by git to find the git directory when
+// not in strict mode.
+var gitDirSuffixes = []string{"/.git", "", ".git/.git", ".git"}
+
+func newServeEndpoint(path string, strict bool) (*transport.Endpoint, error) {
+	if !strict {
+		for _, suffix := range gitDirSuffixes {
+			dir := filepath.FromSlash(path + suffix)
+			if _, err := os.Stat(filepath.Join(dir, "config")); err == nil {
+				path = dir
+				break
+			}
+		}
+	}
+
+	return transport.NewEndpoint(path)
+}
+
+func serve(o *ServeOptions, f func(common.ServerCommand) error) error {
+	var in io.Reader = os.Stdin
+	var out io.Writer = os.Stdout
+	if o.Stdin != nil {
+		in = o.Stdin
+	}
+
+	if o.Stdout != nil {
+		out = o.Stdout
+	}
+
+	cmd := common.ServerCommand{
+		Stdin:         in,
+		Stdout:        ioutil.WriteNopCloser(out),
+		Stderr:        srvCmd.Stderr,
+		AdvertiseRefs: o.AdvertiseRefs,
+		StatelessRPC:  o.StatelessRPC,
+	}
+
+	if o.Timeout == 0 {
+		return f(cmd)
+	}
+
+	t := newIdleTimer(o.Timeout)
+	defer t.Stop()
+
+	cmd.Stdin = &idleReader{t, cmd.Stdin}
+	cmd.Stdout = &idleWriter{t, cmd.Stdout}
+
+	done := make(chan error, 1)
+	go func() { done <- f(cmd) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-t.expired:
+		// f may be blocked reading or writing, closing the pipes unblocks
+		// it, and then it fails as the idle reader and writer do once
+		// expired
+		closePipe(in)
+		closePipe(out)
+		return ErrTimeout
+	}
+}
+
+// closePipe closes the given reader or writer, if it's an io.Closer.
+func closePipe(v interface{}) {
+	if c, ok := v.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// idleTimer is a timer expiring after a period without calling Reset.
+type idleTimer struct {
+	d       time.Duration
+	t       *time.Timer
+	expired chan struct{}
+	once    sync.Once
+}
+
+func newIdleTimer(d time.Duration) *idleTimer {
+	t := &idleTimer{d: d, expired: make(chan struct{})}
+	t.t = time.AfterFunc(d, func() {
+		t.once.Do(func() { close(t.expired) })
+	})
+
+	return t
+}
+
+func (t *idleTimer) Reset() {
+	t.t.Reset(t.d)
+}
+
+func (t *idleTimer) Stop() {
+	t.t.Stop()
+}
+
+// isExpired returns true if the timer has expired.
+func (t *idleTimer) isExpired() bool {
+	select {
+	case <-t.expired:
+		return true
+	default:
+		return false
+	}
+}
+
+type idleReader struct {
+	t *idleTimer
+	r io.Reader
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	if r.t.isExpired() {
+		return 0, ErrTimeout
+	}
+
+	n, err := r.r.Read(p)
+	r.t.Reset()
+	return n, err
+}
+
+type idleWriter struct {
+	t *idleTimer
+	w io.WriteCloser
+}
+
+func (w *idleWriter) Write(p []byte) (int, error) {
+	if w.t.isExpired() {
+		return 0, ErrTimeout
+	}
+
+	n, err := w.w.Write(p)
+	w.t.Reset()
+	return n, err
+}
+
+func (w *idleWriter) Close() error {
+	return w.w.Close()
 }
 
 var srvCmd = common.ServerCommand{
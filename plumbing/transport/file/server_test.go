@@ -1,8 +1,14 @@
 package file
 
 import (
+	"io"
+	"io/ioutil"
+	"net/http/cgi"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"time"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-git-fixtures.v3"
@@ -67,9 +73,56 @@ func (s *ServerSuite) TestClone(c *C) {
 	c.Assert(err, IsNil, Commentf("combined stdout and stderr:\n%s\n", out))
 }
 
+func (s *ServerSuite) TestHTTPBackend(c *C) {
+	if !s.checkExecPerm(c) {
+		c.Skip("go-git binary has not execution permissions")
+	}
+
+	srv := httptest.NewServer(&cgi.Handler{
+		Path: filepath.Join(s.tmpDir, "go-git"),
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=/",
+			"GIT_HTTP_EXPORT_ALL=1",
+			"REMOTE_USER=foo",
+		},
+	})
+	defer srv.Close()
+
+	pathToClone := c.MkDir()
+	cmd := exec.Command("git", "clone", srv.URL+filepath.ToSlash(s.SrcPath), pathToClone)
+	out, err := cmd.CombinedOutput()
+	c.Assert(err, IsNil, Commentf("combined stdout and stderr:\n%s\n", out))
+
+	pathToPush := c.MkDir()
+	c.Assert(exec.Command("git", "init", "--bare", pathToPush).Run(), IsNil)
+
+	cmd = exec.Command("git", "push", srv.URL+filepath.ToSlash(pathToPush),
+		"refs/heads/*:refs/heads/*",
+	)
+	cmd.Dir = pathToClone
+	out, err = cmd.CombinedOutput()
+	c.Assert(err, IsNil, Commentf("combined stdout and stderr:\n%s\n", out))
+}
+
 func (s *ServerSuite) checkExecPerm(c *C) bool {
 	const userExecPermMask = 0100
 	info, err := os.Stat(s.ReceivePackBin)
 	c.Assert(err, IsNil)
 	return (info.Mode().Perm() & userExecPermMask) == userExecPermMask
 }
+
+func (s *ServerSuite) TestServeTimeout(c *C) {
+	r, w := io.Pipe()
+	err := ServeUploadPackWithOptions(s.SrcPath, &ServeOptions{
+		Timeout:      50 * time.Millisecond,
+		StatelessRPC: true,
+		Stdin:        r,
+		Stdout:       ioutil.Discard,
+	})
+	c.Assert(err, Equals, ErrTimeout)
+
+	// the input is closed, so the upload-pack reading it stops
+	_, err = w.Write([]byte("0000"))
+	c.Assert(err, Equals, io.ErrClosedPipe)
+}
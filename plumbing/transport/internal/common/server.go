@@ -15,18 +15,29 @@ type ServerCommand struct {
 	Stderr io.Writer
 	Stdout io.WriteCloser
 	Stdin  io.Reader
+	// AdvertiseRefs only advertises the references, and returns.
+	AdvertiseRefs bool
+	// StatelessRPC serves a single request without advertising the
+	// references first, as it's done over smart HTTP.
+	StatelessRPC bool
 }
 
 func ServeUploadPack(cmd ServerCommand, s transport.UploadPackSession) (err error) {
 	ioutil.CheckClose(cmd.Stdout, &err)
 
-	ar, err := s.AdvertisedReferences()
-	if err != nil {
-		return err
+	if cmd.AdvertiseRefs || !cmd.StatelessRPC {
+		ar, err := s.AdvertisedReferences()
+		if err != nil {
+			return err
+		}
+
+		if err := ar.Encode(cmd.Stdout); err != nil {
+			return err
+		}
 	}
 
-	if err := ar.Encode(cmd.Stdout); err != nil {
-		return err
+	if cmd.AdvertiseRefs {
+		return nil
 	}
 
 	req := packp.NewUploadPackRequest()
@@ -44,13 +55,19 @@ func ServeUploadPack(cmd ServerCommand, s transport.UploadPackSession) (err erro
 }
 
 func ServeReceivePack(cmd ServerCommand, s transport.ReceivePackSession) error {
-	ar, err := s.AdvertisedReferences()
-	if err != nil {
-		return fmt.Errorf("internal error in advertised references: %s", err)
+	if cmd.AdvertiseRefs || !cmd.StatelessRPC {
+		ar, err := s.AdvertisedReferences()
+		if err != nil {
+			return fmt.Errorf("internal error in advertised references: %s", err)
+		}
+
+		if err := ar.Encode(cmd.Stdout); err != nil {
+			return fmt.Errorf("error in advertised references encoding: %s", err)
+		}
 	}
 
-	if err := ar.Encode(cmd.Stdout); err != nil {
-		return fmt.Errorf("error in advertised references encoding: %s", err)
+	if cmd.AdvertiseRefs {
+		return nil
 	}
 
 	req := packp.NewReferenceUpdateRequest()
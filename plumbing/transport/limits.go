@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"io"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// Limits caps the resources a single operation can use transferring a
+// packfile. The zero values mean no limit.
+type Limits struct {
+	// IdleTimeout is the maximum time a read waits for data.
+	IdleTimeout time.Duration
+	// MaxPackSize is the maximum size of the packfile, in bytes.
+	MaxPackSize int64
+	// MaxObjects is the maximum number of objects in the packfile.
+	MaxObjects uint32
+	// MaxObjectSize is the maximum size of an object, in bytes, once
+	// inflated.
+	MaxObjectSize int64
+	// RateLimit is the maximum transfer rate, in bytes per second.
+	RateLimit int64
+}
+
+// NewReader returns a reader reading the packfile from r, enforcing the idle
+// timeout, the maximum pack size and the rate limit. A nil Limits returns r.
+func (l *Limits) NewReader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+
+	if l.IdleTimeout > 0 {
+		r = ioutil.NewIdleTimeoutReader(r, l.IdleTimeout)
+	}
+
+	if l.MaxPackSize > 0 {
+		r = ioutil.NewMaxSizeReader(r, l.MaxPackSize)
+	}
+
+	if l.RateLimit > 0 {
+		r = ioutil.NewRateLimitedReader(r, l.RateLimit)
+	}
+
+	return r
+}
+
+// PackfileLimits returns the limits enforced while parsing the packfile.
+func (l *Limits) PackfileLimits() packfile.Limits {
+	if l == nil {
+		return packfile.Limits{}
+	}
+
+	return packfile.Limits{
+		MaxObjects:    l.MaxObjects,
+		MaxObjectSize: l.MaxObjectSize,
+	}
+}
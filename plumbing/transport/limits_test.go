@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	gitioutil "gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	. "gopkg.in/check.v1"
+)
+
+type LimitsSuite struct{}
+
+var _ = Suite(&LimitsSuite{})
+
+func (s *LimitsSuite) TestNewReaderNil(c *C) {
+	r := strings.NewReader("foo")
+
+	var l *Limits
+	c.Assert(l.NewReader(r), Equals, r)
+	c.Assert(l.PackfileLimits(), Equals, packfile.Limits{})
+}
+
+func (s *LimitsSuite) TestNewReaderMaxPackSize(c *C) {
+	l := &Limits{MaxPackSize: 3}
+	b, err := ioutil.ReadAll(l.NewReader(bytes.NewBufferString("foobar")))
+	c.Assert(err, Equals, gitioutil.ErrReadLimitExceeded)
+	c.Assert(string(b), Equals, "foo")
+}
+
+func (s *LimitsSuite) TestPackfileLimits(c *C) {
+	l := &Limits{MaxPackSize: 3, MaxObjects: 2, MaxObjectSize: 1}
+	c.Assert(l.PackfileLimits(), Equals, packfile.Limits{MaxObjects: 2, MaxObjectSize: 1})
+}
@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// RetryPolicy tells how many times, and how often, an operation failing with
+// a transient error is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one. If lower than two, the operations aren't retried.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled before
+	// each of the following ones.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts, if not zero.
+	MaxBackoff time.Duration
+	// IsRetryable tells whether a failed operation can be retried. If nil,
+	// IsTransientError is used.
+	IsRetryable func(error) bool
+}
+
+// Do calls f until it succeeds, fails with an error that can't be retried,
+// the attempts are exhausted or the context is done, returning the last
+// error. A nil policy calls f once.
+func (p *RetryPolicy) Do(ctx context.Context, f func() error) error {
+	err := f()
+	if p == nil {
+		return err
+	}
+
+	backoff := p.InitialBackoff
+	for attempt := 1; err != nil && attempt < p.MaxAttempts && p.isRetryable(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if p.MaxBackoff != 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+
+		err = f()
+	}
+
+	return err
+}
+
+func (p *RetryPolicy) isRetryable(err error) bool {
+	if p.IsRetryable != nil {
+		return p.IsRetryable(err)
+	}
+
+	return IsTransientError(err)
+}
+
+// statusCoder is implemented by the errors of the HTTP responses.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// IsTransientError returns true if err may not happen again if the operation
+// is retried: server errors, timeouts, stalled transfers and broken
+// connections. The errors wrapped by plumbing.UnexpectedError, url.Error,
+// net.OpError and os.SyscallError are checked too.
+func IsTransientError(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+
+	switch e := err.(type) {
+	case nil, *plumbing.PermanentError:
+		return false
+	case *plumbing.UnexpectedError:
+		return IsTransientError(e.Err)
+	case *url.Error:
+		return e.Timeout() || IsTransientError(e.Err)
+	case *net.OpError:
+		return e.Timeout() || IsTransientError(e.Err)
+	case *os.SyscallError:
+		return IsTransientError(e.Err)
+	case statusCoder:
+		code := e.StatusCode()
+		return code >= 500 || code == 408 || code == 429
+	case net.Error:
+		if e.Timeout() {
+			return true
+		}
+	}
+
+	switch err {
+	case io.ErrUnexpectedEOF, ioutil.ErrIdleTimeout, syscall.ECONNRESET,
+		syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE:
+		return true
+	default:
+		return false
+	}
+}
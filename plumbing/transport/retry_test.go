@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	. "gopkg.in/check.v1"
+)
+
+type RetrySuite struct{}
+
+var _ = Suite(&RetrySuite{})
+
+type statusError int
+
+func (e statusError) StatusCode() int { return int(e) }
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+
+func (s *RetrySuite) TestDo(c *C) {
+	p := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	var calls int
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return syscall.ECONNRESET
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 3)
+
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return plumbing.NewUnexpectedError(statusError(503))
+	})
+	c.Assert(err, NotNil)
+	c.Assert(calls, Equals, 3)
+}
+
+func (s *RetrySuite) TestDoNotRetryable(c *C) {
+	p := &RetryPolicy{MaxAttempts: 3}
+
+	var calls int
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return ErrRepositoryNotFound
+	})
+	c.Assert(err, Equals, ErrRepositoryNotFound)
+	c.Assert(calls, Equals, 1)
+
+	calls = 0
+	p.IsRetryable = func(err error) bool { return err == ErrRepositoryNotFound }
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return ErrRepositoryNotFound
+	})
+	c.Assert(err, Equals, ErrRepositoryNotFound)
+	c.Assert(calls, Equals, 3)
+}
+
+func (s *RetrySuite) TestDoNilPolicy(c *C) {
+	var p *RetryPolicy
+
+	var calls int
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return syscall.ECONNRESET
+	})
+	c.Assert(err, Equals, syscall.ECONNRESET)
+	c.Assert(calls, Equals, 1)
+}
+
+func (s *RetrySuite) TestDoContextDone(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}
+
+	var calls int
+	err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return syscall.ECONNRESET
+	})
+	c.Assert(err, Equals, syscall.ECONNRESET)
+	c.Assert(calls, Equals, 1)
+}
+
+func (s *RetrySuite) TestIsTransientError(c *C) {
+	for _, err := range []error{
+		syscall.ECONNRESET,
+		io.ErrUnexpectedEOF,
+		&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+		&url.Error{Op: "Get", URL: "http://foo", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+		plumbing.NewUnexpectedError(statusError(502)),
+		statusError(429),
+		ioutil.ErrIdleTimeout,
+	} {
+		c.Assert(IsTransientError(err), Equals, true, Commentf("%v", err))
+	}
+
+	for _, err := range []error{
+		nil,
+		errors.New("foo"),
+		context.Canceled,
+		context.DeadlineExceeded,
+		&net.OpError{Op: "read", Err: errors.New("foo")},
+		statusError(404),
+		plumbing.NewPermanentError(syscall.ECONNRESET),
+	} {
+		c.Assert(IsTransientError(err), Equals, false, Commentf("%v", err))
+	}
+}
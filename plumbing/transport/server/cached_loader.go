@@ -0,0 +1,197 @@
+package server
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// DefaultLoaderCacheSize is the default number of repositories kept by the
+// loader returned by NewCachedFilesystemLoader.
+const DefaultLoaderCacheSize = 64
+
+// ErrAccessDenied is returned by the AccessControl functions to deny the
+// access to a repository.
+var ErrAccessDenied = errors.New("access denied")
+
+// ServiceLoader is a Loader aware of the service requested and of the
+// authentication method of the session. The server uses LoadService instead
+// of Load if its loader implements it.
+type ServiceLoader interface {
+	Loader
+	// LoadService loads the storer.Storer of the endpoint to serve the given
+	// service, transport.UploadPackServiceName or
+	// transport.ReceivePackServiceName.
+	LoadService(ep *transport.Endpoint, auth transport.AuthMethod, service string) (storer.Storer, error)
+}
+
+// AccessControl decides whether the service can be served on the repository
+// of the endpoint for the given authentication method, returning an error,
+// such as ErrAccessDenied, if it can't.
+type AccessControl func(ep *transport.Endpoint, auth transport.AuthMethod, service string) error
+
+// LoaderOptions describes the behaviour of the loader returned by
+// NewCachedFilesystemLoader.
+type LoaderOptions struct {
+	// CacheSize is the maximum number of repositories kept, the least
+	// recently used ones are evicted first. By default DefaultLoaderCacheSize.
+	CacheSize int
+	// AccessControl, if not nil, is called before loading any repository.
+	AccessControl AccessControl
+	// AutoInit creates a bare repository in the path of the endpoint when
+	// receive-pack is requested and it doesn't exist, as it's done by
+	// receive.autoInit in some git hosting services.
+	AutoInit bool
+}
+
+type cachedLoader struct {
+	base billy.Filesystem
+	opts LoaderOptions
+
+	m     sync.Mutex
+	ll    *list.List
+	cache map[string]*list.Element
+}
+
+type cachedStorage struct {
+	path    string
+	fs      billy.Filesystem
+	objects cache.Object
+	packs   time.Time
+}
+
+// NewCachedFilesystemLoader creates a ServiceLoader that resolves paths with
+// a given base filesystem, as NewFilesystemLoader, keeping the resolved
+// repositories and their object caches between the requests. The cache of a
+// repository is dropped when its packfiles change. As a filesystem.Storage
+// isn't safe for concurrent use, every session gets its own storage, sharing
+// the object cache of the repository.
+func NewCachedFilesystemLoader(base billy.Filesystem, opts *LoaderOptions) ServiceLoader {
+	l := &cachedLoader{
+		base:  base,
+		ll:    list.New(),
+		cache: make(map[string]*list.Element),
+	}
+
+	if opts != nil {
+		l.opts = *opts
+	}
+
+	if l.opts.CacheSize <= 0 {
+		l.opts.CacheSize = DefaultLoaderCacheSize
+	}
+
+	return l
+}
+
+// Load loads the storer.Storer of the endpoint to serve upload-pack.
+func (l *cachedLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	return l.LoadService(ep, nil, transport.UploadPackServiceName)
+}
+
+// LoadService loads the storer.Storer of the endpoint after checking the
+// access control, creating the repository if it doesn't exist, receive-pack
+// is requested and AutoInit is set. Returns transport.ErrRepositoryNotFound
+// if the repository doesn't exist.
+func (l *cachedLoader) LoadService(ep *transport.Endpoint, auth transport.AuthMethod, service string) (storer.Storer, error) {
+	if !isSandboxed(l.base, ep.Path) {
+		return nil, transport.ErrRepositoryNotFound
+	}
+
+	if l.opts.AccessControl != nil {
+		if err := l.opts.AccessControl(ep, auth, service); err != nil {
+			return nil, err
+		}
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	fs, err := l.base.Chroot(ep.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.Stat("config"); err != nil {
+		l.evict(ep.Path)
+		if !l.opts.AutoInit || service != transport.ReceivePackServiceName {
+			return nil, transport.ErrRepositoryNotFound
+		}
+
+		if err := initBareRepository(fs); err != nil {
+			return nil, err
+		}
+	}
+
+	packs := packsModTime(fs)
+	if e, ok := l.cache[ep.Path]; ok {
+		cs := e.Value.(*cachedStorage)
+		if cs.packs.Equal(packs) {
+			l.ll.MoveToFront(e)
+			return filesystem.NewStorage(cs.fs, cs.objects), nil
+		}
+
+		l.evict(ep.Path)
+	}
+
+	cs := &cachedStorage{
+		path:    ep.Path,
+		fs:      fs,
+		objects: cache.NewObjectLRUDefault(),
+		packs:   packs,
+	}
+
+	l.cache[ep.Path] = l.ll.PushFront(cs)
+	for l.ll.Len() > l.opts.CacheSize {
+		l.evict(l.ll.Back().Value.(*cachedStorage).path)
+	}
+
+	return filesystem.NewStorage(cs.fs, cs.objects), nil
+}
+
+func (l *cachedLoader) evict(path string) {
+	e, ok := l.cache[path]
+	if !ok {
+		return
+	}
+
+	l.ll.Remove(e)
+	delete(l.cache, path)
+}
+
+// packsModTime returns the modification time of the packfiles directory, which
+// changes when packfiles are added or removed.
+func packsModTime(fs billy.Filesystem) time.Time {
+	fi, err := fs.Stat(fs.Join("objects", "pack"))
+	if err != nil {
+		return time.Time{}
+	}
+
+	return fi.ModTime()
+}
+
+func initBareRepository(fs billy.Filesystem) error {
+	s := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	if err := s.Init(); err != nil {
+		return err
+	}
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+	if err := s.SetReference(head); err != nil {
+		return err
+	}
+
+	cfg := config.NewConfig()
+	cfg.Core.IsBare = true
+	return s.SetConfig(cfg)
+}
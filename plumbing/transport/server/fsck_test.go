@@ -0,0 +1,135 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type FsckSuite struct {
+	loader   server.MapLoader
+	storer   *memory.Storage
+	endpoint *transport.Endpoint
+}
+
+var _ = Suite(&FsckSuite{})
+
+func (s *FsckSuite) SetUpTest(c *C) {
+	var err error
+	s.endpoint, err = transport.NewEndpoint("/repo.git")
+	c.Assert(err, IsNil)
+
+	s.storer = memory.NewStorage()
+	s.loader = server.MapLoader{s.endpoint.String(): s.storer}
+}
+
+func encodeObject(c *C, s storer.EncodedObjectStorer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	c.Assert(o.Encode(obj), IsNil)
+
+	h, err := s.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+// commitWithEntry returns a packfile holding a commit with a tree holding a
+// single entry with the given name, and the hash of the commit.
+func commitWithEntry(c *C, name string) ([]byte, plumbing.Hash) {
+	sto := memory.NewStorage()
+
+	blob := sto.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	blobHash, err := sto.SetEncodedObject(blob)
+	c.Assert(err, IsNil)
+
+	treeHash := encodeObject(c, sto, &object.Tree{Entries: []object.TreeEntry{
+		{Name: name, Mode: filemode.Regular, Hash: blobHash},
+	}})
+
+	sig := object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1257894000, 0).UTC()}
+	commitHash := encodeObject(c, sto, &object.Commit{
+		Author: sig, Committer: sig, Message: "foo\n", TreeHash: treeHash,
+	})
+
+	var buf bytes.Buffer
+	_, err = packfile.NewEncoder(&buf, sto, false).Encode(
+		[]plumbing.Hash{commitHash, treeHash, blobHash}, 10)
+	c.Assert(err, IsNil)
+
+	return buf.Bytes(), commitHash
+}
+
+func (s *FsckSuite) receivePack(c *C, opts *server.Options, name string) (*packp.ReportStatus, error) {
+	sess, err := server.NewServerWithOptions(s.loader, opts).NewReceivePackSession(s.endpoint, nil)
+	c.Assert(err, IsNil)
+
+	pack, h := commitWithEntry(c, name)
+
+	req := packp.NewReferenceUpdateRequest()
+	c.Assert(req.Capabilities.Set(capability.ReportStatus), IsNil)
+	req.Commands = []*packp.Command{{Name: "refs/heads/master", New: h}}
+	req.Packfile = ioutil.NopCloser(bytes.NewReader(pack))
+
+	return sess.ReceivePack(context.Background(), req)
+}
+
+func (s *FsckSuite) TestReceivePackWithoutFsck(c *C) {
+	rs, err := s.receivePack(c, &server.Options{}, ".git")
+	c.Assert(err, IsNil)
+	c.Assert(rs.UnpackStatus, Equals, "ok")
+}
+
+func (s *FsckSuite) TestReceivePackFsckObjects(c *C) {
+	rs, err := s.receivePack(c, &server.Options{FsckObjects: true}, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(rs.UnpackStatus, Equals, "ok")
+
+	for _, name := range []string{".git", ".."} {
+		rs, err := s.receivePack(c, &server.Options{FsckObjects: true}, name)
+		c.Assert(err, FitsTypeOf, &fsck.ObjectError{})
+		c.Assert(rs.UnpackStatus, Equals, err.Error())
+	}
+}
+
+func (s *FsckSuite) TestReceivePackFsckObjectsConfig(c *C) {
+	cfg, err := s.storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("receive").SetOption("fsckObjects", "true")
+	c.Assert(s.storer.SetConfig(cfg), IsNil)
+
+	_, err = s.receivePack(c, &server.Options{}, ".GIT")
+	c.Assert(err, FitsTypeOf, &fsck.ObjectError{})
+	c.Assert(err.(*fsck.ObjectError).ID, Equals, fsck.HasDotgit)
+
+	_, err = s.storer.Reference("refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	cfg.Raw.Section("receive").Subsection("fsck").SetOption("hasDotgit", "ignore")
+	c.Assert(s.storer.SetConfig(cfg), IsNil)
+
+	rs, err := s.receivePack(c, &server.Options{}, ".GIT")
+	c.Assert(err, IsNil)
+	c.Assert(rs.UnpackStatus, Equals, "ok")
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+const maxInputSizeKey = "maxInputSize"
+
+// receivePackLimits returns the limits of the options with the maximum pack
+// size overridden by the receive.maxInputSize config option, if set.
+func receivePackLimits(opts *Options, cfg *config.Config) (*transport.Limits, error) {
+	v := cfg.Raw.Section(receivePackSection).Options.Get(maxInputSizeKey)
+	if v == "" {
+		return opts.ReceivePackLimits, nil
+	}
+
+	size, err := parseSize(v)
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s.%s %q", receivePackSection, maxInputSizeKey, v)
+	}
+
+	l := &transport.Limits{}
+	if opts.ReceivePackLimits != nil {
+		*l = *opts.ReceivePackLimits
+	}
+
+	l.MaxPackSize = size
+	return l, nil
+}
+
+// parseSize parses a size with an optional k, m or g unit suffix, as the
+// integer config values.
+func parseSize(v string) (int64, error) {
+	var unit int64 = 1
+	switch strings.ToLower(v[len(v)-1:]) {
+	case "k":
+		unit = 1 << 10
+	case "m":
+		unit = 1 << 20
+	case "g":
+		unit = 1 << 30
+	}
+
+	if unit != 1 {
+		v = v[:len(v)-1]
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+
+	return n * unit, nil
+}
@@ -0,0 +1,100 @@
+package server_test
+
+import (
+	"context"
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	gitioutil "gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type LimitsSuite struct {
+	fixtures.Suite
+	loader   server.MapLoader
+	storer   *memory.Storage
+	endpoint *transport.Endpoint
+}
+
+var _ = Suite(&LimitsSuite{})
+
+func (s *LimitsSuite) SetUpTest(c *C) {
+	var err error
+	s.endpoint, err = transport.NewEndpoint("/repo.git")
+	c.Assert(err, IsNil)
+
+	s.storer = memory.NewStorage()
+	s.loader = server.MapLoader{s.endpoint.String(): s.storer}
+}
+
+func (s *LimitsSuite) receivePack(c *C, opts *server.Options) (*packp.ReportStatus, error) {
+	sess, err := server.NewServerWithOptions(s.loader, opts).NewReceivePackSession(s.endpoint, nil)
+	c.Assert(err, IsNil)
+
+	req := packp.NewReferenceUpdateRequest()
+	c.Assert(req.Capabilities.Set(capability.ReportStatus), IsNil)
+	req.Commands = []*packp.Command{{Name: "refs/heads/master", New: masterHash}}
+	req.Packfile = ioutil.NopCloser(fixtures.Basic().One().Packfile())
+
+	return sess.ReceivePack(context.Background(), req)
+}
+
+func (s *LimitsSuite) TestReceivePackLimits(c *C) {
+	rs, err := s.receivePack(c, &server.Options{
+		ReceivePackLimits: &transport.Limits{MaxObjects: 31, MaxObjectSize: 217848},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(rs.UnpackStatus, Equals, "ok")
+
+	ref, err := s.storer.Reference("refs/heads/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, masterHash)
+}
+
+func (s *LimitsSuite) TestReceivePackMaxObjects(c *C) {
+	rs, err := s.receivePack(c, &server.Options{
+		ReceivePackLimits: &transport.Limits{MaxObjects: 30},
+	})
+	c.Assert(err, Equals, packfile.ErrMaxObjectsExceeded)
+	c.Assert(rs.UnpackStatus, Equals, packfile.ErrMaxObjectsExceeded.Error())
+
+	_, err = s.storer.Reference("refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *LimitsSuite) TestReceivePackMaxObjectSize(c *C) {
+	_, err := s.receivePack(c, &server.Options{
+		ReceivePackLimits: &transport.Limits{MaxObjectSize: 1024},
+	})
+	c.Assert(err, Equals, packfile.ErrMaxObjectSizeExceeded)
+}
+
+func (s *LimitsSuite) TestReceivePackMaxInputSize(c *C) {
+	cfg, err := s.storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("receive").AddOption("maxInputSize", "1k")
+	c.Assert(s.storer.SetConfig(cfg), IsNil)
+
+	_, err = s.receivePack(c, &server.Options{
+		ReceivePackLimits: &transport.Limits{MaxPackSize: 1 << 30},
+	})
+	c.Assert(err, Equals, gitioutil.ErrReadLimitExceeded)
+}
+
+func (s *LimitsSuite) TestReceivePackMalformedMaxInputSize(c *C) {
+	cfg, err := s.storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("receive").AddOption("maxInputSize", "1x")
+	c.Assert(s.storer.SetConfig(cfg), IsNil)
+
+	_, err = server.NewServer(s.loader).NewReceivePackSession(s.endpoint, nil)
+	c.Assert(err, ErrorMatches, `malformed receive.maxInputSize "1x"`)
+}
@@ -1,6 +1,10 @@
 package server
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
@@ -35,6 +39,10 @@ func NewFilesystemLoader(base billy.Filesystem) Loader {
 // storer for it. Returns transport.ErrRepositoryNotFound if a repository does
 // not exist in the given path.
 func (l *fsLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	if !isSandboxed(l.base, ep.Path) {
+		return nil, transport.ErrRepositoryNotFound
+	}
+
 	fs, err := l.base.Chroot(ep.Path)
 	if err != nil {
 		return nil, err
@@ -47,6 +55,65 @@ func (l *fsLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
 	return filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil
 }
 
+// isSandboxed returns true if the path can't get out of the base filesystem of
+// a loader, because it doesn't have any ".." element and, once the symbolic
+// links are resolved, it's still under the root of the base filesystem.
+func isSandboxed(base billy.Filesystem, path string) bool {
+	for _, e := range strings.FieldsFunc(path, isPathSeparator) {
+		if e == ".." {
+			return false
+		}
+	}
+
+	if base.Root() == "" {
+		return true
+	}
+
+	root, err := filepath.EvalSymlinks(base.Root())
+	if err != nil {
+		// the base filesystem isn't backed by the os filesystem
+		return true
+	}
+
+	resolved, err := evalExistingSymlinks(filepath.Join(base.Root(), path))
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// evalExistingSymlinks resolves the symbolic links of the longest existing
+// prefix of the path, so the repositories created later by the loader are
+// resolved too.
+func evalExistingSymlinks(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil || !os.IsNotExist(err) {
+		return resolved, err
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", err
+	}
+
+	resolved, err = evalExistingSymlinks(parent)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(resolved, filepath.Base(path)), nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
 // MapLoader is a Loader that uses a lookup map of storer.Storer by
 // transport.Endpoint.
 type MapLoader map[string]storer.Storer
@@ -1,16 +1,25 @@
 package server
 
 import (
+	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
 type LoaderSuite struct {
+	fixtures.Suite
 	RepoPath string
 }
 
@@ -21,6 +30,8 @@ func (s *LoaderSuite) SetUpSuite(c *C) {
 		c.Skip("git command not found")
 	}
 
+	s.Suite.SetUpSuite(c)
+
 	dir := c.MkDir()
 	s.RepoPath = filepath.Join(dir, "repo.git")
 	c.Assert(exec.Command("git", "init", "--bare", s.RepoPath).Run(), IsNil)
@@ -70,3 +81,169 @@ func (s *LoaderSuite) TestMapLoader(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(sto, Equals, loaderSto)
 }
+
+func (s *LoaderSuite) TestLoadOutsideBase(c *C) {
+	loader := NewFilesystemLoader(osfs.New(filepath.Join(s.RepoPath, "objects")))
+	sto, err := loader.Load(s.endpoint(c, "/../"))
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(sto, IsNil)
+
+	loader = NewCachedFilesystemLoader(osfs.New(filepath.Join(s.RepoPath, "objects")), nil)
+	sto, err = loader.Load(s.endpoint(c, "/../"))
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(sto, IsNil)
+}
+
+func (s *LoaderSuite) TestLoadSymlinkOutsideBase(c *C) {
+	base := c.MkDir()
+	c.Assert(exec.Command("git", "init", "--bare", filepath.Join(base, "repo.git")).Run(), IsNil)
+	c.Assert(os.Symlink(filepath.Join(base, "repo.git"), filepath.Join(base, "link.git")), IsNil)
+	c.Assert(os.Symlink(s.RepoPath, filepath.Join(base, "escape.git")), IsNil)
+	c.Assert(os.Symlink(filepath.Dir(s.RepoPath), filepath.Join(base, "escape")), IsNil)
+
+	cached := NewCachedFilesystemLoader(osfs.New(base), &LoaderOptions{AutoInit: true})
+	for _, loader := range []Loader{NewFilesystemLoader(osfs.New(base)), cached} {
+		sto, err := loader.Load(s.endpoint(c, "/link.git"))
+		c.Assert(err, IsNil)
+		c.Assert(sto, NotNil)
+
+		sto, err = loader.Load(s.endpoint(c, "/escape.git"))
+		c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+		c.Assert(sto, IsNil)
+	}
+
+	ep := s.endpoint(c, "/escape/new.git")
+	_, err := cached.LoadService(ep, nil, transport.ReceivePackServiceName)
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+
+	_, err = os.Stat(filepath.Join(filepath.Dir(s.RepoPath), "new.git"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+// cachedObjects returns the object cache kept by the loader for the path.
+func cachedObjects(l ServiceLoader, path string) cache.Object {
+	e, ok := l.(*cachedLoader).cache[path]
+	if !ok {
+		return nil
+	}
+
+	return e.Value.(*cachedStorage).objects
+}
+
+func (s *LoaderSuite) TestCachedLoader(c *C) {
+	other := filepath.Join(c.MkDir(), "other.git")
+	c.Assert(exec.Command("git", "init", "--bare", other).Run(), IsNil)
+
+	loader := NewCachedFilesystemLoader(osfs.New(""), &LoaderOptions{CacheSize: 1})
+
+	sto, err := loader.Load(s.endpoint(c, s.RepoPath))
+	c.Assert(err, IsNil)
+	objects := cachedObjects(loader, s.RepoPath)
+	c.Assert(objects, NotNil)
+
+	// every session has its own storage, sharing the object cache
+	cached, err := loader.Load(s.endpoint(c, s.RepoPath))
+	c.Assert(err, IsNil)
+	c.Assert(cached, Not(Equals), sto)
+	c.Assert(cachedObjects(loader, s.RepoPath), Equals, objects)
+
+	// the packfiles changed
+	future := time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(filepath.Join(s.RepoPath, "objects", "pack"), future, future), IsNil)
+	_, err = loader.Load(s.endpoint(c, s.RepoPath))
+	c.Assert(err, IsNil)
+	c.Assert(cachedObjects(loader, s.RepoPath), Not(Equals), objects)
+	objects = cachedObjects(loader, s.RepoPath)
+
+	// evicted by the other repository
+	_, err = loader.Load(s.endpoint(c, other))
+	c.Assert(err, IsNil)
+	c.Assert(cachedObjects(loader, s.RepoPath), IsNil)
+	_, err = loader.Load(s.endpoint(c, s.RepoPath))
+	c.Assert(err, IsNil)
+	c.Assert(cachedObjects(loader, s.RepoPath), Not(Equals), objects)
+
+	_, err = loader.Load(s.endpoint(c, "does-not-exist"))
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+}
+
+func (s *LoaderSuite) TestCachedLoaderAccessControl(c *C) {
+	var calls []string
+	loader := NewCachedFilesystemLoader(osfs.New(""), &LoaderOptions{
+		AccessControl: func(ep *transport.Endpoint, auth transport.AuthMethod, service string) error {
+			calls = append(calls, ep.Path+" "+service)
+			if service == transport.ReceivePackServiceName {
+				return ErrAccessDenied
+			}
+
+			return nil
+		},
+	})
+
+	ep := s.endpoint(c, s.RepoPath)
+	_, err := loader.LoadService(ep, nil, transport.UploadPackServiceName)
+	c.Assert(err, IsNil)
+
+	srv := NewServer(loader)
+	_, err = srv.NewReceivePackSession(ep, nil)
+	c.Assert(err, Equals, ErrAccessDenied)
+
+	c.Assert(calls, DeepEquals, []string{
+		s.RepoPath + " " + transport.UploadPackServiceName,
+		s.RepoPath + " " + transport.ReceivePackServiceName,
+	})
+}
+
+func (s *LoaderSuite) TestCachedLoaderAutoInit(c *C) {
+	path := filepath.Join(c.MkDir(), "new.git")
+	loader := NewCachedFilesystemLoader(osfs.New(""), &LoaderOptions{AutoInit: true})
+
+	ep := s.endpoint(c, path)
+	_, err := loader.LoadService(ep, nil, transport.UploadPackServiceName)
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+
+	sto, err := loader.LoadService(ep, nil, transport.ReceivePackServiceName)
+	c.Assert(err, IsNil)
+
+	cfg, err := sto.(*filesystem.Storage).Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.IsBare, Equals, true)
+
+	head, err := sto.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	objects := cachedObjects(loader, path)
+	_, err = loader.LoadService(ep, nil, transport.UploadPackServiceName)
+	c.Assert(err, IsNil)
+	c.Assert(cachedObjects(loader, path), Equals, objects)
+}
+
+func (s *LoaderSuite) TestCachedLoaderConcurrentSessions(c *C) {
+	fs := fixtures.Basic().One().DotGit()
+	loader := NewCachedFilesystemLoader(osfs.New(filepath.Dir(fs.Root())), nil)
+	ep := s.endpoint(c, "/"+filepath.Base(fs.Root()))
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			sto, err := loader.Load(ep)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			_, errs[i] = sto.EncodedObject(plumbing.CommitObject, head)
+		}(i)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		c.Assert(err, IsNil)
+	}
+}
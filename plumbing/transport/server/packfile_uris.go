@@ -0,0 +1,163 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+)
+
+const blobPackfileURIKey = "blobPackfileUri"
+
+// StaticPack is a pregenerated packfile, served from an URI to the clients
+// supporting the packfile-uris capability instead of inlining its objects in
+// the upload-pack responses.
+type StaticPack struct {
+	// Hash is the checksum of the packfile.
+	Hash plumbing.Hash
+	// URI is where the packfile is downloaded from.
+	URI string
+	// Objects are the objects in the packfile. When any of them would be
+	// sent to a client, all of them are left out of the response packfile,
+	// and the URI is sent instead. The packfile must hold their deltas bases.
+	Objects []plumbing.Hash
+}
+
+// NewStaticPack returns the StaticPack for the packfile read from r, served
+// from the given URI.
+func NewStaticPack(uri string, r io.ReadSeeker) (*StaticPack, error) {
+	w := new(idxfile.Writer)
+	p, err := packfile.NewParser(packfile.NewScanner(r), w)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := w.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	sp := &StaticPack{Hash: checksum, URI: uri}
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return sp, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		sp.Objects = append(sp.Objects, e.Hash)
+	}
+}
+
+// staticPacks returns the static packs of the options along with the ones
+// configured with uploadpack.blobPackfileUri, whose values are
+// "<object-hash> <pack-hash> <uri>".
+func staticPacks(opts *Options, cfg *config.Config) ([]*StaticPack, error) {
+	packs := append([]*StaticPack(nil), opts.StaticPacks...)
+
+	byHash := make(map[plumbing.Hash]*StaticPack)
+	for _, v := range cfg.Raw.Section(uploadPackSection).Options.GetAll(blobPackfileURIKey) {
+		fields := strings.Fields(v)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed %s.%s %q", uploadPackSection, blobPackfileURIKey, v)
+		}
+
+		obj, pack := plumbing.NewHash(fields[0]), plumbing.NewHash(fields[1])
+		if obj.IsZero() || pack.IsZero() {
+			return nil, fmt.Errorf("malformed %s.%s %q", uploadPackSection, blobPackfileURIKey, v)
+		}
+
+		sp, ok := byHash[pack]
+		if !ok {
+			sp = &StaticPack{Hash: pack, URI: fields[2]}
+			byHash[pack] = sp
+			packs = append(packs, sp)
+		}
+
+		sp.Objects = append(sp.Objects, obj)
+	}
+
+	return packs, nil
+}
+
+// offloadObjects leaves out of objs the objects in the static packs the
+// client can download, returning the remaining objects and the packfile URIs
+// to send to the client.
+func (s *upSession) offloadObjects(req *packp.UploadPackRequest, objs []plumbing.Hash) (
+	[]plumbing.Hash, packp.PackfileURIs) {
+	if len(s.staticPacks) == 0 || !req.Capabilities.Supports(capability.PackfileURIs) {
+		return objs, nil
+	}
+
+	protocols := make(map[string]bool)
+	for _, v := range req.Capabilities.Get(capability.PackfileURIs) {
+		for _, p := range strings.Split(v, ",") {
+			protocols[p] = true
+		}
+	}
+
+	sent := make(map[plumbing.Hash]bool, len(objs))
+	for _, h := range objs {
+		sent[h] = true
+	}
+
+	offloaded := make(map[plumbing.Hash]bool)
+	var uris packp.PackfileURIs
+	for _, sp := range s.staticPacks {
+		u, err := url.Parse(sp.URI)
+		if err != nil || !protocols[u.Scheme] || !anySent(sp.Objects, sent) {
+			continue
+		}
+
+		for _, h := range sp.Objects {
+			offloaded[h] = true
+		}
+
+		uris = append(uris, &packp.PackfileURI{Hash: sp.Hash, URI: sp.URI})
+	}
+
+	if len(uris) == 0 {
+		return objs, nil
+	}
+
+	var remaining []plumbing.Hash
+	for _, h := range objs {
+		if !offloaded[h] {
+			remaining = append(remaining, h)
+		}
+	}
+
+	return remaining, uris
+}
+
+func anySent(objs []plumbing.Hash, sent map[plumbing.Hash]bool) bool {
+	for _, h := range objs {
+		if sent[h] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// ErrHiddenReference is reported by receive-pack for the commands updating a
+// hidden reference.
+var ErrHiddenReference = errors.New("deny updating a hidden ref")
+
+// Options holds the settings of a server, mostly restricting the references
+// it advertises and accepts.
+type Options struct {
+	// HideRefs are the patterns of the references hidden by both services,
+	// with the syntax of the transfer.hideRefs config option. A pattern
+	// hides the references named as it or under it, "!" negates it and "^"
+	// matches it against the name including the namespace. The later
+	// patterns take precedence over the earlier ones.
+	HideRefs []string
+	// UploadPackHideRefs are the patterns of the references hidden by
+	// upload-pack only, as the uploadpack.hideRefs config option.
+	UploadPackHideRefs []string
+	// ReceivePackHideRefs are the patterns of the references hidden by
+	// receive-pack only, as the receive.hideRefs config option.
+	ReceivePackHideRefs []string
+	// Namespace returns the namespace of the repository at the endpoint, as
+	// GIT_NAMESPACE does. Only the references under it are visible, without
+	// its prefix, so many repositories can share a single storer. If nil or
+	// it returns an empty string, all the references are visible.
+	Namespace func(ep *transport.Endpoint) string
+	// RefFilter, if not nil, hides the references it returns false for.
+	RefFilter RefFilter
+	// AllowTipSHA1InWant allows upload-pack to serve the tips of the hidden
+	// references, as the uploadpack.allowTipSHA1InWant config option.
+	AllowTipSHA1InWant bool
+	// AllowReachableSHA1InWant allows upload-pack to serve any object
+	// reachable from a reference, as the uploadpack.allowReachableSHA1InWant
+	// config option.
+	AllowReachableSHA1InWant bool
+	// StaticPacks are the pregenerated packfiles upload-pack advertises
+	// with the packfile-uris capability, besides the ones configured with
+	// the uploadpack.blobPackfileUri config option. The capability is only
+	// advertised when there are static packs, and the packfile URIs are
+	// only sent to the clients requesting it, see capability.PackfileURIs.
+	StaticPacks []*StaticPack
+	// ReceivePackLimits, if not nil, caps the packfiles received by
+	// receive-pack, which are rejected when they stall or exceed the size
+	// and object limits. Its maximum pack size is overridden by the
+	// receive.maxInputSize config option.
+	ReceivePackLimits *transport.Limits
+	// FsckObjects makes receive-pack check the received objects, rejecting
+	// the packfiles with malformed ones, as the receive.fsckObjects config
+	// option. The checks are configured by the fsck.* and receive.fsck.*
+	// config options, see fsck.NewCheckerFromConfig.
+	FsckObjects bool
+}
+
+// RefFilter decides whether a reference is visible for a session, given the
+// endpoint and the authentication method it was opened with. The reference
+// name doesn't include the namespace.
+type RefFilter func(ep *transport.Endpoint, auth transport.AuthMethod, ref *plumbing.Reference) bool
+
+// NamespacePrefix returns the prefix of the reference names in the given
+// namespace, e.g. refs/namespaces/foo/refs/namespaces/bar/ for foo/bar.
+func NamespacePrefix(namespace string) string {
+	var prefix string
+	for _, c := range strings.Split(namespace, "/") {
+		if c != "" {
+			prefix += "refs/namespaces/" + c + "/"
+		}
+	}
+
+	return prefix
+}
+
+const (
+	uploadPackSection           = "uploadpack"
+	receivePackSection          = "receive"
+	transferSection             = "transfer"
+	hideRefsKey                 = "hideRefs"
+	allowTipSHA1InWantKey       = "allowTipSHA1InWant"
+	allowReachableSHA1InWantKey = "allowReachableSHA1InWant"
+)
+
+// loadConfig returns the configuration of the storer, if it has any.
+func loadConfig(s storer.Storer) (*config.Config, error) {
+	cs, ok := s.(config.ConfigStorer)
+	if !ok {
+		return config.NewConfig(), nil
+	}
+
+	return cs.Config()
+}
+
+// refView is the view of the references of a storer given to a session.
+type refView struct {
+	storer    storer.Storer
+	ep        *transport.Endpoint
+	auth      transport.AuthMethod
+	prefix    string
+	hideRefs  []string
+	refFilter RefFilter
+}
+
+func newRefView(s storer.Storer, ep *transport.Endpoint, auth transport.AuthMethod,
+	opts *Options, cfg *config.Config, section string) *refView {
+	v := &refView{storer: s, ep: ep, auth: auth}
+	if opts.Namespace != nil && ep != nil {
+		v.prefix = NamespacePrefix(opts.Namespace(ep))
+	}
+
+	v.refFilter = opts.RefFilter
+
+	v.hideRefs = append(v.hideRefs, opts.HideRefs...)
+	if section == uploadPackSection {
+		v.hideRefs = append(v.hideRefs, opts.UploadPackHideRefs...)
+	} else {
+		v.hideRefs = append(v.hideRefs, opts.ReceivePackHideRefs...)
+	}
+
+	for _, name := range []string{transferSection, section} {
+		v.hideRefs = append(v.hideRefs, cfg.Raw.Section(name).Options.GetAll(hideRefsKey)...)
+	}
+
+	return v
+}
+
+// isRestricted returns true if some reference may not be visible.
+func (v *refView) isRestricted() bool {
+	return v.prefix != "" || len(v.hideRefs) != 0 || v.refFilter != nil
+}
+
+// fullName returns the name of the reference in the storer.
+func (v *refView) fullName(n plumbing.ReferenceName) plumbing.ReferenceName {
+	return plumbing.ReferenceName(v.prefix + n.String())
+}
+
+// visible returns the reference, named as the session sees it, and whether it
+// is visible. The given reference is named as in the storer.
+func (v *refView) visible(ref *plumbing.Reference) (*plumbing.Reference, bool) {
+	full := ref.Name().String()
+	if !strings.HasPrefix(full, v.prefix) {
+		return nil, false
+	}
+
+	name := plumbing.ReferenceName(full[len(v.prefix):])
+	if v.isHidden(name.String(), full) {
+		return nil, false
+	}
+
+	if v.prefix != "" {
+		if ref.Type() == plumbing.SymbolicReference {
+			target := ref.Target().String()
+			if !strings.HasPrefix(target, v.prefix) {
+				return nil, false
+			}
+
+			ref = plumbing.NewSymbolicReference(name,
+				plumbing.ReferenceName(target[len(v.prefix):]))
+		} else {
+			ref = plumbing.NewHashReference(name, ref.Hash())
+		}
+	}
+
+	if v.refFilter != nil && !v.refFilter(v.ep, v.auth, ref) {
+		return nil, false
+	}
+
+	return ref, true
+}
+
+// isHidden returns true if the reference is hidden by the hideRefs patterns,
+// following git: the last matching pattern wins.
+func (v *refView) isHidden(name, full string) bool {
+	for i := len(v.hideRefs) - 1; i >= 0; i-- {
+		pattern := v.hideRefs[i]
+		negated := strings.HasPrefix(pattern, "!")
+		if negated {
+			pattern = pattern[1:]
+		}
+
+		subject := name
+		if strings.HasPrefix(pattern, "^") {
+			pattern = pattern[1:]
+			subject = full
+		}
+
+		pattern = strings.TrimRight(pattern, "/")
+		if pattern == "" || !strings.HasPrefix(subject, pattern) {
+			continue
+		}
+
+		if len(subject) == len(pattern) || subject[len(pattern)] == '/' {
+			return !negated
+		}
+	}
+
+	return false
+}
+
+// references returns the visible hash references.
+func (v *refView) references() ([]*plumbing.Reference, error) {
+	iter, err := v.storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		if ref, ok := v.visible(ref); ok {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+
+	return refs, err
+}
+
+// head returns the HEAD of the namespace, if visible.
+func (v *refView) head() (*plumbing.Reference, error) {
+	ref, err := v.storer.Reference(v.fullName(plumbing.HEAD))
+	if err != nil {
+		return nil, err
+	}
+
+	ref, ok := v.visible(ref)
+	if !ok {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	return ref, nil
+}
+
+// resolve resolves a symbolic reference of the session, returning
+// plumbing.ErrReferenceNotFound if its target isn't visible.
+func (v *refView) resolve(ref *plumbing.Reference) (*plumbing.Reference, error) {
+	target, err := storer.ResolveReference(v.storer, v.fullName(ref.Target()))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := v.visible(target); !ok {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	return target, nil
+}
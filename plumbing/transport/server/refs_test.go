@@ -0,0 +1,187 @@
+package server_test
+
+import (
+	"context"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type RefsSuite struct {
+	loader   server.MapLoader
+	storer   *memory.Storage
+	endpoint *transport.Endpoint
+}
+
+var _ = Suite(&RefsSuite{})
+
+var (
+	masterHash = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	secretHash = plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	fooHash    = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+func (s *RefsSuite) SetUpTest(c *C) {
+	var err error
+	s.endpoint, err = transport.NewEndpoint("/repo.git")
+	c.Assert(err, IsNil)
+
+	s.storer = memory.NewStorage()
+	s.loader = server.MapLoader{s.endpoint.String(): s.storer}
+
+	for _, ref := range []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/master"),
+		plumbing.NewHashReference("refs/heads/master", masterHash),
+		plumbing.NewHashReference("refs/heads/secret", secretHash),
+		plumbing.NewSymbolicReference("refs/namespaces/foo/HEAD", "refs/namespaces/foo/refs/heads/master"),
+		plumbing.NewHashReference("refs/namespaces/foo/refs/heads/master", fooHash),
+	} {
+		c.Assert(s.storer.SetReference(ref), IsNil)
+	}
+}
+
+func (s *RefsSuite) advertisedReferences(c *C, opts *server.Options) *packp.AdvRefs {
+	sess, err := server.NewServerWithOptions(s.loader, opts).NewUploadPackSession(s.endpoint, nil)
+	c.Assert(err, IsNil)
+
+	ar, err := sess.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	return ar
+}
+
+func (s *RefsSuite) receivePack(c *C, opts *server.Options, cmds ...*packp.Command) map[plumbing.ReferenceName]string {
+	sess, err := server.NewServerWithOptions(s.loader, opts).NewReceivePackSession(s.endpoint, nil)
+	c.Assert(err, IsNil)
+
+	req := packp.NewReferenceUpdateRequest()
+	c.Assert(req.Capabilities.Set(capability.ReportStatus), IsNil)
+	req.Commands = cmds
+
+	rs, _ := sess.ReceivePack(context.Background(), req)
+	c.Assert(rs, NotNil)
+
+	status := make(map[plumbing.ReferenceName]string)
+	for _, cs := range rs.CommandStatuses {
+		status[cs.ReferenceName] = cs.Status
+	}
+
+	return status
+}
+
+func (s *RefsSuite) TestHideRefs(c *C) {
+	ar := s.advertisedReferences(c, &server.Options{
+		HideRefs: []string{"refs/heads/", "!refs/heads/master", "refs/namespaces"},
+	})
+
+	c.Assert(ar.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/master": masterHash,
+	})
+	c.Assert(*ar.Head, Equals, masterHash)
+
+	ar = s.advertisedReferences(c, &server.Options{
+		HideRefs:            []string{"refs/heads/master"},
+		ReceivePackHideRefs: []string{"refs/heads/secret"},
+	})
+
+	c.Assert(ar.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/secret":                     secretHash,
+		"refs/namespaces/foo/refs/heads/master": fooHash,
+	})
+	c.Assert(ar.Head, IsNil)
+	c.Assert(ar.Capabilities.Supports(capability.SymRef), Equals, false)
+}
+
+func (s *RefsSuite) TestHideRefsFromConfig(c *C) {
+	cfg, err := s.storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("transfer").AddOption("hideRefs", "refs/namespaces")
+	cfg.Raw.Section("receive").AddOption("hideRefs", "refs/heads/secret")
+	c.Assert(s.storer.SetConfig(cfg), IsNil)
+
+	ar := s.advertisedReferences(c, nil)
+	c.Assert(ar.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/master": masterHash,
+		"refs/heads/secret": secretHash,
+	})
+
+	status := s.receivePack(c, nil,
+		&packp.Command{Name: "refs/heads/secret", Old: secretHash, New: masterHash},
+		&packp.Command{Name: "refs/heads/master", Old: masterHash, New: secretHash},
+	)
+
+	c.Assert(status, DeepEquals, map[plumbing.ReferenceName]string{
+		"refs/heads/secret": server.ErrHiddenReference.Error(),
+		"refs/heads/master": "ok",
+	})
+
+	ref, err := s.storer.Reference("refs/heads/secret")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, secretHash)
+}
+
+func (s *RefsSuite) TestNamespace(c *C) {
+	opts := &server.Options{
+		Namespace: func(ep *transport.Endpoint) string { return "foo" },
+	}
+
+	ar := s.advertisedReferences(c, opts)
+	c.Assert(ar.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/master": fooHash,
+	})
+	c.Assert(*ar.Head, Equals, fooHash)
+	c.Assert(ar.Capabilities.Get(capability.SymRef), DeepEquals, []string{"HEAD:refs/heads/master"})
+
+	status := s.receivePack(c, opts,
+		&packp.Command{Name: "refs/heads/new", New: masterHash},
+	)
+	c.Assert(status, DeepEquals, map[plumbing.ReferenceName]string{
+		"refs/heads/new": "ok",
+	})
+
+	ref, err := s.storer.Reference("refs/namespaces/foo/refs/heads/new")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, masterHash)
+
+	_, err = s.storer.Reference("refs/heads/new")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RefsSuite) TestNamespacePrefix(c *C) {
+	c.Assert(server.NamespacePrefix(""), Equals, "")
+	c.Assert(server.NamespacePrefix("foo/bar"), Equals, "refs/namespaces/foo/refs/namespaces/bar/")
+}
+
+func (s *RefsSuite) TestRefFilter(c *C) {
+	var endpoints []*transport.Endpoint
+	ar := s.advertisedReferences(c, &server.Options{
+		RefFilter: func(ep *transport.Endpoint, auth transport.AuthMethod, ref *plumbing.Reference) bool {
+			endpoints = append(endpoints, ep)
+			return ref.Name() == "refs/heads/master" || ref.Name() == plumbing.HEAD
+		},
+	})
+
+	c.Assert(ar.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/master": masterHash,
+	})
+	c.Assert(*ar.Head, Equals, masterHash)
+	c.Assert(len(endpoints) > 0, Equals, true)
+	c.Assert(endpoints[0], Equals, s.endpoint)
+}
+
+func (s *RefsSuite) TestUploadPackHiddenWant(c *C) {
+	opts := &server.Options{UploadPackHideRefs: []string{"refs/heads/secret"}}
+	sess, err := server.NewServerWithOptions(s.loader, opts).NewUploadPackSession(s.endpoint, nil)
+	c.Assert(err, IsNil)
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, secretHash)
+
+	_, err = sess.UploadPack(context.Background(), req)
+	c.Assert(err, ErrorMatches, "not our ref "+secretHash.String())
+}
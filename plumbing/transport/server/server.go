@@ -10,8 +10,12 @@ import (
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
@@ -28,58 +32,136 @@ type server struct {
 // NewServer returns a transport.Transport implementing a git server,
 // independent of transport. Each transport must wrap this.
 func NewServer(loader Loader) transport.Transport {
+	return NewServerWithOptions(loader, nil)
+}
+
+// NewServerWithOptions returns a transport.Transport implementing a git
+// server as NewServer, with the given options.
+func NewServerWithOptions(loader Loader, opts *Options) transport.Transport {
 	return &server{
 		loader,
-		&handler{asClient: false},
+		&handler{asClient: false, opts: opts},
 	}
 }
 
 // NewClient returns a transport.Transport implementing a client with an
 // embedded server.
 func NewClient(loader Loader) transport.Transport {
+	return NewClientWithOptions(loader, nil)
+}
+
+// NewClientWithOptions returns a transport.Transport implementing a client
+// with an embedded server as NewClient, with the given options.
+func NewClientWithOptions(loader Loader, opts *Options) transport.Transport {
 	return &server{
 		loader,
-		&handler{asClient: true},
+		&handler{asClient: true, opts: opts},
 	}
 }
 
 func (s *server) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
-	sto, err := s.loader.Load(ep)
+	sto, err := s.load(ep, auth, transport.UploadPackServiceName)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.handler.NewUploadPackSession(sto)
+	return s.handler.NewUploadPackSession(sto, ep, auth)
 }
 
 func (s *server) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
-	sto, err := s.loader.Load(ep)
+	sto, err := s.load(ep, auth, transport.ReceivePackServiceName)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.handler.NewReceivePackSession(sto)
+	return s.handler.NewReceivePackSession(sto, ep, auth)
+}
+
+func (s *server) load(ep *transport.Endpoint, auth transport.AuthMethod, service string) (storer.Storer, error) {
+	if l, ok := s.loader.(ServiceLoader); ok {
+		return l.LoadService(ep, auth, service)
+	}
+
+	return s.loader.Load(ep)
 }
 
 type handler struct {
 	asClient bool
+	opts     *Options
+}
+
+func (h *handler) options() *Options {
+	if h.opts == nil {
+		return &Options{}
+	}
+
+	return h.opts
 }
 
-func (h *handler) NewUploadPackSession(s storer.Storer) (transport.UploadPackSession, error) {
+func (h *handler) NewUploadPackSession(s storer.Storer, ep *transport.Endpoint,
+	auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	cfg, err := loadConfig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := h.options()
+	packs, err := staticPacks(opts, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	options := cfg.Raw.Section(uploadPackSection).Options
 	return &upSession{
-		session: session{storer: s, asClient: h.asClient},
+		session: session{
+			storer:   s,
+			refs:     newRefView(s, ep, auth, opts, cfg, uploadPackSection),
+			asClient: h.asClient,
+		},
+		allowTip: opts.AllowTipSHA1InWant ||
+			options.Get(allowTipSHA1InWantKey) == "true",
+		allowReachable: opts.AllowReachableSHA1InWant ||
+			options.Get(allowReachableSHA1InWantKey) == "true",
+		staticPacks: packs,
 	}, nil
 }
 
-func (h *handler) NewReceivePackSession(s storer.Storer) (transport.ReceivePackSession, error) {
+func (h *handler) NewReceivePackSession(s storer.Storer, ep *transport.Endpoint,
+	auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	cfg, err := loadConfig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := h.options()
+	limits, err := receivePackLimits(opts, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var checker *fsck.Checker
+	if opts.FsckObjects || fsck.Enabled(cfg, fsck.ReceivePackSection) {
+		checker, err = fsck.NewCheckerFromConfig(cfg, fsck.ReceivePackSection)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &rpSession{
-		session:   session{storer: s, asClient: h.asClient},
+		session: session{
+			storer:   s,
+			refs:     newRefView(s, ep, auth, opts, cfg, receivePackSection),
+			asClient: h.asClient,
+		},
 		cmdStatus: map[plumbing.ReferenceName]error{},
+		limits:    limits,
+		checker:   checker,
 	}, nil
 }
 
 type session struct {
 	storer   storer.Storer
+	refs     *refView
 	caps     *capability.List
 	asClient bool
 }
@@ -105,6 +187,9 @@ func (s *session) checkSupportedCapabilities(cl *capability.List) error {
 
 type upSession struct {
 	session
+	allowTip       bool
+	allowReachable bool
+	staticPacks    []*StaticPack
 }
 
 func (s *upSession) AdvertisedReferences() (*packp.AdvRefs, error) {
@@ -116,11 +201,11 @@ func (s *upSession) AdvertisedReferences() (*packp.AdvRefs, error) {
 
 	s.caps = ar.Capabilities
 
-	if err := setReferences(s.storer, ar); err != nil {
+	if err := s.setReferences(ar); err != nil {
 		return nil, err
 	}
 
-	if err := setHEAD(s.storer, ar); err != nil {
+	if err := s.setHEAD(ar); err != nil {
 		return nil, err
 	}
 
@@ -157,22 +242,146 @@ func (s *upSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest
 		return nil, fmt.Errorf("shallow not supported")
 	}
 
+	if err := s.checkWants(req.Wants); err != nil {
+		return nil, err
+	}
+
 	objs, err := s.objectsToUpload(req)
 	if err != nil {
 		return nil, err
 	}
 
+	objs, uris := s.offloadObjects(req, objs)
+
 	pr, pw := io.Pipe()
-	e := packfile.NewEncoder(pw, s.storer, false)
 	go func() {
-		// TODO: plumb through a pack window.
-		_, err := e.Encode(objs, 10)
-		pw.CloseWithError(err)
+		pw.CloseWithError(s.writePackfile(pw, req, objs))
 	}()
 
-	return packp.NewUploadPackResponseWithPackfile(req,
+	resp := packp.NewUploadPackResponseWithPackfile(req,
 		ioutil.NewContextReadCloser(ctx, pr),
-	), nil
+	)
+	resp.PackfileURIs = uris
+
+	return resp, nil
+}
+
+// writePackfile encodes the packfile with the given objects, multiplexed with
+// the progress messages if the client requested a sideband.
+func (s *upSession) writePackfile(w io.Writer, req *packp.UploadPackRequest, objs []plumbing.Hash) error {
+	var m *sideband.Muxer
+	switch {
+	case req.Capabilities.Supports(capability.Sideband64k):
+		m = sideband.NewMuxer(sideband.Sideband64k, w)
+	case req.Capabilities.Supports(capability.Sideband):
+		m = sideband.NewMuxer(sideband.Sideband, w)
+	}
+
+	progress := func(format string, a ...interface{}) {
+		if m != nil && !req.Capabilities.Supports(capability.NoProgress) {
+			_, _ = m.WriteChannel(sideband.ProgressMessage, []byte(fmt.Sprintf(format, a...)))
+		}
+	}
+
+	pw := w
+	if m != nil {
+		pw = m
+	}
+
+	progress("Enumerating objects: %d, done.\n", len(objs))
+
+	// TODO: plumb through a pack window.
+	useRefDeltas := !req.Capabilities.Supports(capability.OFSDelta)
+	if _, err := packfile.NewEncoder(pw, s.storer, useRefDeltas).Encode(objs, 10); err != nil {
+		if m != nil {
+			_, _ = m.WriteChannel(sideband.ErrorMessage, []byte(err.Error()))
+		}
+
+		return err
+	}
+
+	progress("Total %d, done.\n", len(objs))
+
+	if m == nil {
+		return nil
+	}
+
+	return pktline.NewEncoder(w).Flush()
+}
+
+// checkWants checks that the wanted objects are the tips of visible
+// references, when some of them are hidden, unless allowed by
+// uploadpack.allowTipSHA1InWant or uploadpack.allowReachableSHA1InWant.
+func (s *upSession) checkWants(wants []plumbing.Hash) error {
+	if !s.refs.isRestricted() {
+		return nil
+	}
+
+	refs, err := s.refs.references()
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[plumbing.Hash]bool, len(refs))
+	for _, ref := range refs {
+		allowed[ref.Hash()] = true
+	}
+
+	var pending []plumbing.Hash
+	for _, h := range wants {
+		if !allowed[h] {
+			pending = append(pending, h)
+		}
+	}
+
+	if len(pending) != 0 && (s.allowTip || s.allowReachable) {
+		if allowed, err = s.allowedHiddenObjects(); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range pending {
+		if !allowed[h] {
+			return fmt.Errorf("not our ref %s", h)
+		}
+	}
+
+	return nil
+}
+
+// allowedHiddenObjects returns the objects that can be wanted besides the
+// tips of the visible references: the tips of all of them, or every object
+// reachable from them if allowReachable is set.
+func (s *upSession) allowedHiddenObjects() (map[plumbing.Hash]bool, error) {
+	iter, err := s.storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []plumbing.Hash
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			tips = append(tips, ref.Hash())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.allowReachable {
+		if tips, err = revlist.Objects(s.storer, tips, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	allowed := make(map[plumbing.Hash]bool, len(tips))
+	for _, h := range tips {
+		allowed[h] = true
+	}
+
+	return allowed, nil
 }
 
 func (s *upSession) objectsToUpload(req *packp.UploadPackRequest) ([]plumbing.Hash, error) {
@@ -181,16 +390,123 @@ func (s *upSession) objectsToUpload(req *packp.UploadPackRequest) ([]plumbing.Ha
 		return nil, err
 	}
 
-	return revlist.Objects(s.storer, req.Wants, haves)
+	objs, err := revlist.Objects(s.storer, req.Wants, haves)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Capabilities.Supports(capability.IncludeTag) {
+		return objs, nil
+	}
+
+	tags, err := s.tagsToInclude(objs, haves)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(objs, tags...), nil
+}
+
+// tagsToInclude returns the annotated tags of the visible references pointing
+// to the objects being sent, as requested by the include-tag capability.
+func (s *upSession) tagsToInclude(objs, haves []plumbing.Hash) ([]plumbing.Hash, error) {
+	sent := make(map[plumbing.Hash]bool, len(objs)+len(haves))
+	for _, h := range objs {
+		sent[h] = true
+	}
+
+	for _, h := range haves {
+		sent[h] = true
+	}
+
+	refs, err := s.refs.references()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []plumbing.Hash
+	for _, ref := range refs {
+		if !ref.Name().IsTag() || sent[ref.Hash()] {
+			continue
+		}
+
+		chain, target, err := s.peelTag(ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(chain) == 0 || !sent[target] {
+			continue
+		}
+
+		for _, h := range chain {
+			if !sent[h] {
+				sent[h] = true
+				tags = append(tags, h)
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+// peelTag returns the chain of annotated tags starting at h, and the object
+// it points to in the end.
+func (s *upSession) peelTag(h plumbing.Hash) ([]plumbing.Hash, plumbing.Hash, error) {
+	var chain []plumbing.Hash
+	for {
+		o, err := s.storer.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+
+		if o.Type() != plumbing.TagObject {
+			return chain, h, nil
+		}
+
+		tag, err := object.DecodeTag(s.storer, o)
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+
+		chain = append(chain, h)
+		h = tag.Target
+	}
 }
 
-func (*upSession) setSupportedCapabilities(c *capability.List) error {
+func (s *upSession) setSupportedCapabilities(c *capability.List) error {
 	if err := c.Set(capability.Agent, capability.DefaultAgent); err != nil {
 		return err
 	}
 
-	if err := c.Set(capability.OFSDelta); err != nil {
-		return err
+	for _, cap := range []capability.Capability{
+		capability.OFSDelta,
+		capability.Sideband,
+		capability.Sideband64k,
+		capability.IncludeTag,
+		capability.NoProgress,
+	} {
+		if err := c.Set(cap); err != nil {
+			return err
+		}
+	}
+
+	if s.allowTip {
+		if err := c.Set(capability.AllowTipSHA1InWant); err != nil {
+			return err
+		}
+	}
+
+	if s.allowReachable {
+		if err := c.Set(capability.AllowReachableSHA1InWant); err != nil {
+			return err
+		}
+	}
+
+	if len(s.staticPacks) != 0 {
+		if err := c.Set(capability.PackfileURIs); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -201,6 +517,8 @@ type rpSession struct {
 	cmdStatus map[plumbing.ReferenceName]error
 	firstErr  error
 	unpackErr error
+	limits    *transport.Limits
+	checker   *fsck.Checker
 }
 
 func (s *rpSession) AdvertisedReferences() (*packp.AdvRefs, error) {
@@ -212,11 +530,11 @@ func (s *rpSession) AdvertisedReferences() (*packp.AdvRefs, error) {
 
 	s.caps = ar.Capabilities
 
-	if err := setReferences(s.storer, ar); err != nil {
+	if err := s.setReferences(ar); err != nil {
 		return nil, err
 	}
 
-	if err := setHEAD(s.storer, ar); err != nil {
+	if err := s.setHEAD(ar); err != nil {
 		return nil, err
 	}
 
@@ -243,7 +561,11 @@ func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateR
 
 	//TODO: Implement 'atomic' update of references.
 
-	r := ioutil.NewContextReadCloser(ctx, req.Packfile)
+	var r io.ReadCloser
+	if req.Packfile != nil {
+		r = ioutil.NewContextReadCloser(ctx, req.Packfile)
+	}
+
 	if err := s.writePackfile(r); err != nil {
 		s.unpackErr = err
 		s.firstErr = err
@@ -256,7 +578,13 @@ func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateR
 
 func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
 	for _, cmd := range req.Commands {
-		exists, err := referenceExists(s.storer, cmd.Name)
+		if s.isHidden(cmd) {
+			s.setStatus(cmd.Name, ErrHiddenReference)
+			continue
+		}
+
+		name := s.refs.fullName(cmd.Name)
+		exists, err := referenceExists(s.storer, name)
 		if err != nil {
 			s.setStatus(cmd.Name, err)
 			continue
@@ -269,7 +597,7 @@ func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
 				continue
 			}
 
-			ref := plumbing.NewHashReference(cmd.Name, cmd.New)
+			ref := plumbing.NewHashReference(name, cmd.New)
 			err := s.storer.SetReference(ref)
 			s.setStatus(cmd.Name, err)
 		case packp.Delete:
@@ -278,7 +606,7 @@ func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
 				continue
 			}
 
-			err := s.storer.RemoveReference(cmd.Name)
+			err := s.storer.RemoveReference(name)
 			s.setStatus(cmd.Name, err)
 		case packp.Update:
 			if !exists {
@@ -286,19 +614,37 @@ func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
 				continue
 			}
 
-			ref := plumbing.NewHashReference(cmd.Name, cmd.New)
+			ref := plumbing.NewHashReference(name, cmd.New)
 			err := s.storer.SetReference(ref)
 			s.setStatus(cmd.Name, err)
 		}
 	}
 }
 
+// isHidden returns true if the reference updated by the command is hidden,
+// so it can't be updated.
+func (s *rpSession) isHidden(cmd *packp.Command) bool {
+	hash := cmd.New
+	if cmd.Action() == packp.Delete {
+		hash = cmd.Old
+	}
+
+	_, ok := s.refs.visible(plumbing.NewHashReference(s.refs.fullName(cmd.Name), hash))
+	return !ok
+}
+
 func (s *rpSession) writePackfile(r io.ReadCloser) error {
 	if r == nil {
 		return nil
 	}
 
-	if err := packfile.UpdateObjectStorage(s.storer, r); err != nil {
+	var ob []packfile.Observer
+	if s.checker != nil {
+		ob = append(ob, fsck.NewObserver(s.checker))
+	}
+
+	if err := packfile.UpdateObjectStorageWithLimits(s.storer,
+		s.limits.NewReader(r), s.limits.PackfileLimits(), ob...); err != nil {
 		_ = r.Close()
 		return err
 	}
@@ -360,8 +706,8 @@ func (*rpSession) setSupportedCapabilities(c *capability.List) error {
 	return c.Set(capability.ReportStatus)
 }
 
-func setHEAD(s storer.Storer, ar *packp.AdvRefs) error {
-	ref, err := s.Reference(plumbing.HEAD)
+func (s *session) setHEAD(ar *packp.AdvRefs) error {
+	ref, err := s.refs.head()
 	if err == plumbing.ErrReferenceNotFound {
 		return nil
 	}
@@ -371,11 +717,7 @@ func setHEAD(s storer.Storer, ar *packp.AdvRefs) error {
 	}
 
 	if ref.Type() == plumbing.SymbolicReference {
-		if err := ar.AddReference(ref); err != nil {
-			return nil
-		}
-
-		ref, err = storer.ResolveReference(s, ref.Target())
+		target, err := s.refs.resolve(ref)
 		if err == plumbing.ErrReferenceNotFound {
 			return nil
 		}
@@ -383,6 +725,12 @@ func setHEAD(s storer.Storer, ar *packp.AdvRefs) error {
 		if err != nil {
 			return err
 		}
+
+		if err := ar.AddReference(ref); err != nil {
+			return nil
+		}
+
+		ref = target
 	}
 
 	if ref.Type() != plumbing.HashReference {
@@ -395,21 +743,18 @@ func setHEAD(s storer.Storer, ar *packp.AdvRefs) error {
 	return nil
 }
 
-func setReferences(s storer.Storer, ar *packp.AdvRefs) error {
+func (s *session) setReferences(ar *packp.AdvRefs) error {
 	//TODO: add peeled references.
-	iter, err := s.IterReferences()
+	refs, err := s.refs.references()
 	if err != nil {
 		return err
 	}
 
-	return iter.ForEach(func(ref *plumbing.Reference) error {
-		if ref.Type() != plumbing.HashReference {
-			return nil
-		}
-
+	for _, ref := range refs {
 		ar.References[ref.Name().String()] = ref.Hash()
-		return nil
-	})
+	}
+
+	return nil
 }
 
 func referenceExists(s storer.ReferenceStorer, n plumbing.ReferenceName) (bool, error) {
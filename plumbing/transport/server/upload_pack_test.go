@@ -1,9 +1,24 @@
 package server_test
 
 import (
+	"bytes"
+	"context"
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
 type UploadPackSuite struct {
@@ -32,6 +47,118 @@ func (s *UploadPackSuite) TestUploadPackWithContext(c *C) {
 	c.Skip("UploadPack cannot be canceled on server")
 }
 
+func (s *UploadPackSuite) uploadPack(c *C, ep *transport.Endpoint, req *packp.UploadPackRequest) *packp.UploadPackResponse {
+	r, err := s.Client.NewUploadPackSession(ep, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	info, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+
+	for _, cap := range req.Capabilities.All() {
+		c.Assert(info.Capabilities.Supports(cap), Equals, true)
+	}
+
+	resp, err := r.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+	return resp
+}
+
+func (s *UploadPackSuite) TestUploadPackSideband(c *C) {
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(req.Capabilities.Set(capability.Sideband64k), IsNil)
+
+	resp := s.uploadPack(c, s.Endpoint, req)
+	progress := bytes.NewBuffer(nil)
+	d := sideband.NewDemuxer(sideband.Sideband64k, resp)
+	d.Progress = progress
+
+	pack, err := ioutil.ReadAll(d)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Close(), IsNil)
+
+	c.Assert(string(pack[:4]), Equals, "PACK")
+	c.Assert(progress.String(), Equals, "Enumerating objects: 28, done.\nTotal 28, done.\n")
+
+	c.Assert(req.Capabilities.Set(capability.NoProgress), IsNil)
+	resp = s.uploadPack(c, s.Endpoint, req)
+	progress.Reset()
+	d = sideband.NewDemuxer(sideband.Sideband64k, resp)
+	d.Progress = progress
+
+	_, err = ioutil.ReadAll(d)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Close(), IsNil)
+	c.Assert(progress.Len(), Equals, 0)
+}
+
+func (s *UploadPackSuite) TestUploadPackIncludeTag(c *C) {
+	fs := fixtures.ByTag("tags").One().DotGit()
+	ep, err := transport.NewEndpoint(fs.Root())
+	c.Assert(err, IsNil)
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	s.loader[ep.String()] = sto
+
+	tag, err := sto.Reference("refs/tags/annotated-tag")
+	c.Assert(err, IsNil)
+	o, err := sto.EncodedObject(plumbing.TagObject, tag.Hash())
+	c.Assert(err, IsNil)
+	target, err := sto.Reference("refs/tags/lightweight-tag")
+	c.Assert(err, IsNil)
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, target.Hash())
+
+	objects := func() *memory.Storage {
+		resp := s.uploadPack(c, ep, req)
+		m := memory.NewStorage()
+		c.Assert(packfile.UpdateObjectStorage(m, resp), IsNil)
+		c.Assert(resp.Close(), IsNil)
+		return m
+	}
+
+	_, err = objects().EncodedObject(plumbing.TagObject, o.Hash())
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	c.Assert(req.Capabilities.Set(capability.IncludeTag), IsNil)
+	_, err = objects().EncodedObject(plumbing.TagObject, o.Hash())
+	c.Assert(err, IsNil)
+}
+
+func (s *UploadPackSuite) TestUploadPackAllowTipSHA1InWant(c *C) {
+	hidden := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, hidden)
+
+	opts := &server.Options{UploadPackHideRefs: []string{"refs/remotes", "refs/heads/branch"}}
+	r, err := server.NewServerWithOptions(s.loader, opts).NewUploadPackSession(s.Endpoint, nil)
+	c.Assert(err, IsNil)
+	_, err = r.UploadPack(context.Background(), req)
+	c.Assert(err, ErrorMatches, "not our ref .*")
+
+	opts.AllowTipSHA1InWant = true
+	r, err = server.NewServerWithOptions(s.loader, opts).NewUploadPackSession(s.Endpoint, nil)
+	c.Assert(err, IsNil)
+	ar, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	c.Assert(ar.Capabilities.Supports(capability.AllowTipSHA1InWant), Equals, true)
+	resp, err := r.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Close(), IsNil)
+
+	req.Wants = []plumbing.Hash{plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")}
+	_, err = r.UploadPack(context.Background(), req)
+	c.Assert(err, ErrorMatches, "not our ref .*")
+
+	opts.AllowReachableSHA1InWant = true
+	r, err = server.NewServerWithOptions(s.loader, opts).NewUploadPackSession(s.Endpoint, nil)
+	c.Assert(err, IsNil)
+	resp, err = r.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Close(), IsNil)
+}
+
 // Tests server with `asClient = true`. This is recommended when using a server
 // registered directly with `client.InstallProtocol`.
 type ClientLikeUploadPackSuite struct {
@@ -48,3 +175,65 @@ func (s *ClientLikeUploadPackSuite) SetUpSuite(c *C) {
 func (s *ClientLikeUploadPackSuite) TestAdvertisedReferencesEmpty(c *C) {
 	s.UploadPackSuite.TestAdvertisedReferencesEmpty(c)
 }
+
+func (s *UploadPackSuite) TestUploadPackPackfileURIs(c *C) {
+	sto := s.loader[s.Endpoint.String()]
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	objs, err := revlist.Objects(sto, []plumbing.Hash{parent}, nil)
+	c.Assert(err, IsNil)
+
+	var pack bytes.Buffer
+	_, err = packfile.NewEncoder(&pack, sto, false).Encode(objs, 10)
+	c.Assert(err, IsNil)
+
+	sp, err := server.NewStaticPack("https://example.com/static.pack", bytes.NewReader(pack.Bytes()))
+	c.Assert(err, IsNil)
+	c.Assert(sp.Objects, HasLen, len(objs))
+
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, nil)
+	c.Assert(err, IsNil)
+	ar, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	c.Assert(ar.Capabilities.Supports(capability.PackfileURIs), Equals, false)
+
+	opts := &server.Options{StaticPacks: []*server.StaticPack{sp}}
+	r, err = server.NewServerWithOptions(s.loader, opts).NewUploadPackSession(s.Endpoint, nil)
+	c.Assert(err, IsNil)
+	ar, err = r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	c.Assert(ar.Capabilities.Supports(capability.PackfileURIs), Equals, true)
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	objects := func() (*memory.Storage, packp.PackfileURIs) {
+		resp, err := r.UploadPack(context.Background(), req)
+		c.Assert(err, IsNil)
+		m := memory.NewStorage()
+		c.Assert(packfile.UpdateObjectStorage(m, resp), IsNil)
+		c.Assert(resp.Close(), IsNil)
+		return m, resp.PackfileURIs
+	}
+
+	c.Assert(req.Capabilities.Set(capability.PackfileURIs, "http"), IsNil)
+	m, uris := objects()
+	c.Assert(uris, HasLen, 0)
+	c.Assert(m.Objects, HasLen, 28)
+
+	c.Assert(req.Capabilities.Set(capability.PackfileURIs, "http,https"), IsNil)
+	m, uris = objects()
+	c.Assert(uris, DeepEquals, packp.PackfileURIs{{Hash: sp.Hash, URI: sp.URI}})
+	c.Assert(m.Objects, HasLen, 28-len(objs))
+}
+
+func (s *UploadPackSuite) TestUploadPackBlobPackfileURIConfig(c *C) {
+	sto := memory.NewStorage()
+	cfg, err := sto.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("uploadpack").AddOption("blobPackfileUri", "foo")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	loader := server.MapLoader{s.Endpoint.String(): sto}
+	_, err = server.NewServer(loader).NewUploadPackSession(s.Endpoint, nil)
+	c.Assert(err, ErrorMatches, "malformed uploadpack.blobPackfileUri .*")
+}
@@ -15,7 +15,9 @@ import (
 // the commit provided that contains the file from the provided path. The last
 // commit into the returned slice is the commit where the file was created.
 // If the provided commit does not contains the specified path, a nil slice is
-// returned. The commits are sorted in commit order, newer to older.
+// returned. The commits are sorted in commit order, newer to older. The path
+// is looked up in the tree of each commit instead of diffing the trees, so only
+// the subtrees leading to it are read.
 //
 // Caveats:
 //
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"strings"
 
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-git.v4/config"
@@ -12,6 +15,7 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
@@ -41,15 +45,19 @@ const (
 
 // Remote represents a connection to a remote repository.
 type Remote struct {
-	c *config.RemoteConfig
-	s storage.Storer
+	c     *config.RemoteConfig
+	s     storage.Storer
+	hooks *hooks
 }
 
 // NewRemote creates a new Remote.
 // The intended purpose is to use the Remote for tasks such as listing remote references (like using git ls-remote).
 // Otherwise Remotes should be created via the use of a Repository.
+// A push runs the pre-push hook script of the repository stored in s, but not
+// the hook functions, which only run for the Remotes of the Repository where
+// they are registered.
 func NewRemote(s storage.Storer, c *config.RemoteConfig) *Remote {
-	return &Remote{s: s, c: c}
+	return &Remote{s: s, c: c, hooks: newHooks(s, nil)}
 }
 
 // Config returns the RemoteConfig object used to instantiate this Remote.
@@ -136,6 +144,13 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return NoErrAlreadyUpToDate
 	}
 
+	if !o.NoHooks {
+		stdin := prePushInput(o.RefSpecs, localRefs, req.Commands)
+		if err := r.hooks.run(HookPrePush, stdin, nil, r.c.Name, r.c.URLs[0]); err != nil {
+			return err
+		}
+	}
+
 	objects := objectsToPush(req.Commands)
 
 	haves, err := referencesToHashes(remoteRefs)
@@ -286,18 +301,19 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		o.RefSpecs = r.c.Fetch
 	}
 
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
-	if err != nil {
-		return nil, err
+	if o.BundleURI != "" {
+		if err = r.fetchBundleURI(ctx, o); err != nil {
+			return nil, err
+		}
 	}
 
-	defer ioutil.CheckClose(s, &err)
-
-	ar, err := s.AdvertisedReferences()
+	s, ar, err := r.openUploadPackSession(ctx, o.Auth, o.RetryPolicy)
 	if err != nil {
 		return nil, err
 	}
 
+	defer ioutil.CheckClose(s, &err)
+
 	req, err := r.newUploadPackRequest(o, ar)
 	if err != nil {
 		return nil, err
@@ -342,6 +358,34 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	return remoteRefs, nil
 }
 
+// openUploadPackSession starts an upload-pack session and retrieves the
+// advertised references, retrying on the errors allowed by the policy.
+func (r *Remote) openUploadPackSession(ctx context.Context, auth transport.AuthMethod,
+	policy *transport.RetryPolicy) (s transport.UploadPackSession, ar *packp.AdvRefs, err error) {
+	err = policy.Do(ctx, func() error {
+		s, ar, err = newAdvertisedUploadPackSession(r.c.URLs[0], auth)
+		return err
+	})
+
+	return s, ar, err
+}
+
+func newAdvertisedUploadPackSession(url string, auth transport.AuthMethod) (
+	transport.UploadPackSession, *packp.AdvRefs, error) {
+	s, err := newUploadPackSession(url, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		_ = s.Close()
+		return nil, nil, err
+	}
+
+	return s, ar, nil
+}
+
 func newUploadPackSession(url string, auth transport.AuthMethod) (transport.UploadPackSession, error) {
 	c, ep, err := newClient(url)
 	if err != nil {
@@ -377,24 +421,132 @@ func newClient(url string) (transport.Transport, *transport.Endpoint, error) {
 func (r *Remote) fetchPack(ctx context.Context, o *FetchOptions, s transport.UploadPackSession,
 	req *packp.UploadPackRequest) (err error) {
 
-	reader, err := s.UploadPack(ctx, req)
-	if err != nil {
-		return err
-	}
+	var reader *packp.UploadPackResponse
+	var pack io.Reader
+	if o.RetryPolicy == nil {
+		reader, err = s.UploadPack(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		defer ioutil.CheckClose(reader, &err)
+		pack = o.Limits.NewReader(buildSidebandIfSupported(req.Capabilities, reader, o.Progress))
+	} else {
+		var f *os.File
+		f, reader, err = r.downloadPack(ctx, o, s, req)
+		if err != nil {
+			return err
+		}
 
-	defer ioutil.CheckClose(reader, &err)
+		defer removeDownload(f, &err)
+		pack = f
+	}
 
 	if err = r.updateShallow(o, reader); err != nil {
 		return err
 	}
 
-	if err = packfile.UpdateObjectStorage(r.s,
-		buildSidebandIfSupported(req.Capabilities, reader, o.Progress),
+	ob, err := r.fsckObservers(o)
+	if err != nil {
+		return err
+	}
+
+	if err = packfile.UpdateObjectStorageWithLimits(r.s, pack,
+		o.Limits.PackfileLimits(), ob...,
 	); err != nil {
 		return err
 	}
 
-	return err
+	return r.fetchPackfileURIs(ctx, o, reader.PackfileURIs)
+}
+
+// fsckObservers returns the observers checking the fetched objects, if
+// enabled by the options or the fetch.fsckObjects config option. The
+// warnings are written to the progress.
+func (r *Remote) fsckObservers(o *FetchOptions) ([]packfile.Observer, error) {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.FsckObjects && !fsck.Enabled(cfg, fsck.FetchSection) {
+		return nil, nil
+	}
+
+	c, err := fsck.NewCheckerFromConfig(cfg, fsck.FetchSection)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Progress != nil {
+		c.Warn = func(err *fsck.ObjectError) {
+			fmt.Fprintf(o.Progress, "warning: %s\n", err)
+		}
+	}
+
+	return []packfile.Observer{fsck.NewObserver(c)}, nil
+}
+
+// downloadPack sends the upload-pack request and downloads the packfile of
+// the response into a temporary file, rewound to its beginning, so it's only
+// stored once fully transferred. The request is retried on the errors allowed
+// by the retry policy, during the negotiation as well as during the transfer,
+// with a new session, as the failed one may be unusable. The transfer of an
+// upload-pack response can't be resumed, so the partial packfile of a failed
+// attempt is discarded.
+func (r *Remote) downloadPack(ctx context.Context, o *FetchOptions, s transport.UploadPackSession,
+	req *packp.UploadPackRequest) (f *os.File, reader *packp.UploadPackResponse, err error) {
+	f, err = stdioutil.TempFile("", "go-git-pack-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := s
+	attempt := 0
+	err = o.RetryPolicy.Do(ctx, func() (err error) {
+		if attempt++; attempt > 1 {
+			if current != s {
+				_ = current.Close()
+			}
+
+			current, _, err = newAdvertisedUploadPackSession(r.c.URLs[0], o.Auth)
+			if err != nil {
+				current = s
+				return err
+			}
+		}
+
+		if err := truncateDownload(f); err != nil {
+			return plumbing.NewPermanentError(err)
+		}
+
+		reader, err = current.UploadPack(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		defer ioutil.CheckClose(reader, &err)
+		_, err = io.Copy(f, o.Limits.NewReader(
+			buildSidebandIfSupported(req.Capabilities, reader, o.Progress),
+		))
+
+		return err
+	})
+
+	if current != s {
+		_ = current.Close()
+	}
+
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		removeDownload(f, &err)
+		return nil, nil, err
+	}
+
+	return f, reader, nil
 }
 
 func (r *Remote) addReferencesToUpdate(
@@ -847,6 +999,13 @@ func (r *Remote) newUploadPackRequest(o *FetchOptions,
 		}
 	}
 
+	if len(o.PackfileURIProtocols) != 0 && ar.Capabilities.Supports(capability.PackfileURIs) {
+		protocols := strings.Join(o.PackfileURIProtocols, ",")
+		if err := req.Capabilities.Set(capability.PackfileURIs, protocols); err != nil {
+			return nil, err
+		}
+	}
+
 	return req, nil
 }
 
@@ -974,18 +1133,13 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 
 // List the references on the remote repository.
 func (r *Remote) List(o *ListOptions) (rfs []*plumbing.Reference, err error) {
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+	s, ar, err := r.openUploadPackSession(context.Background(), o.Auth, o.RetryPolicy)
 	if err != nil {
 		return nil, err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
-	if err != nil {
-		return nil, err
-	}
-
 	allRefs, err := ar.AllReferences()
 	if err != nil {
 		return nil, err
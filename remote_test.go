@@ -7,17 +7,26 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"syscall"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
+	gitioutil "gopkg.in/src-d/go-git.v4/utils/ioutil"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-billy.v4/osfs"
@@ -26,6 +35,7 @@ import (
 
 type RemoteSuite struct {
 	BaseSuite
+	restoreProtocol func()
 }
 
 var _ = Suite(&RemoteSuite{})
@@ -887,3 +897,303 @@ func (s *RemoteSuite) TestUseRefDeltas(c *C) {
 	ar.Capabilities.Delete(capability.OFSDelta)
 	c.Assert(r.useRefDeltas(ar), Equals, true)
 }
+
+// flakyTransport fails the first sessions and upload-pack requests with
+// transient errors, and resets the connection in the middle of the first
+// packfile transfers.
+type flakyTransport struct {
+	transport.Transport
+	sessionFailures    int
+	uploadPackFailures int
+	packResets         int
+	sessions           int
+}
+
+func (t *flakyTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
+	transport.UploadPackSession, error) {
+	if t.sessionFailures > 0 {
+		t.sessionFailures--
+		return nil, syscall.ECONNRESET
+	}
+
+	s, err := t.Transport.NewUploadPackSession(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	t.sessions++
+	return &flakySession{s, t}, nil
+}
+
+type flakySession struct {
+	transport.UploadPackSession
+	t *flakyTransport
+}
+
+func (s *flakySession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (
+	*packp.UploadPackResponse, error) {
+	if s.t.uploadPackFailures > 0 {
+		s.t.uploadPackFailures--
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	resp, err := s.UploadPackSession.UploadPack(ctx, req)
+	if err != nil || s.t.packResets == 0 {
+		return resp, err
+	}
+
+	s.t.packResets--
+	return packp.NewUploadPackResponseWithPackfile(req, &resetReader{resp, 1024}), nil
+}
+
+// resetReader fails with syscall.ECONNRESET once n bytes are read.
+type resetReader struct {
+	io.ReadCloser
+	n int
+}
+
+func (r *resetReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, syscall.ECONNRESET
+	}
+
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	r.n -= n
+	return n, err
+}
+
+func (s *RemoteSuite) installFlakyTransport(c *C, t *flakyTransport) string {
+	fs := fixtures.Basic().One().DotGit()
+	ep, err := transport.NewEndpoint(fs.Root())
+	c.Assert(err, IsNil)
+
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	t.Transport = server.NewClient(server.MapLoader{ep.String(): sto})
+
+	backup := client.Protocols["file"]
+	client.InstallProtocol("file", t)
+	s.restoreProtocol = func() { client.InstallProtocol("file", backup) }
+	return fs.Root()
+}
+
+func (s *RemoteSuite) TearDownTest(c *C) {
+	if s.restoreProtocol != nil {
+		s.restoreProtocol()
+		s.restoreProtocol = nil
+	}
+}
+
+func (s *RemoteSuite) TestFetchRetry(c *C) {
+	t := &flakyTransport{sessionFailures: 1, uploadPackFailures: 1}
+	url := s.installFlakyTransport(c, t)
+
+	r := NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	err := r.Fetch(&FetchOptions{
+		RefSpecs:    []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+		RetryPolicy: &transport.RetryPolicy{MaxAttempts: 2},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(t.sessions, Equals, 2)
+
+	ref, err := r.s.Reference("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *RemoteSuite) TestFetchRetryPackReset(c *C) {
+	t := &flakyTransport{packResets: 1}
+	url := s.installFlakyTransport(c, t)
+
+	sto := memory.NewStorage()
+	r := NewRemote(sto, &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	o := &FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+	}
+
+	c.Assert(r.Fetch(o), Equals, syscall.ECONNRESET)
+	c.Assert(sto.Objects, HasLen, 0)
+
+	t.packResets = 1
+	o.RetryPolicy = &transport.RetryPolicy{MaxAttempts: 2}
+	c.Assert(r.Fetch(o), IsNil)
+	c.Assert(t.sessions, Equals, 3)
+	c.Assert(sto.Objects, HasLen, 28)
+
+	ref, err := r.s.Reference("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *RemoteSuite) TestFetchRetryExhausted(c *C) {
+	t := &flakyTransport{sessionFailures: 2}
+	url := s.installFlakyTransport(c, t)
+
+	r := NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	err := r.Fetch(&FetchOptions{RetryPolicy: &transport.RetryPolicy{MaxAttempts: 2}})
+	c.Assert(err, Equals, syscall.ECONNRESET)
+
+	t.sessionFailures = 1
+	err = r.Fetch(&FetchOptions{})
+	c.Assert(err, Equals, syscall.ECONNRESET)
+}
+
+func (s *RemoteSuite) TestListRetry(c *C) {
+	t := &flakyTransport{sessionFailures: 1}
+	url := s.installFlakyTransport(c, t)
+
+	r := NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	refs, err := r.List(&ListOptions{RetryPolicy: &transport.RetryPolicy{MaxAttempts: 2}})
+	c.Assert(err, IsNil)
+	c.Assert(refs, Not(HasLen), 0)
+}
+
+func (s *RemoteSuite) TestFetchLimits(c *C) {
+	r := NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName, URLs: []string{s.GetBasicLocalRepositoryURL()},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	})
+
+	err := r.Fetch(&FetchOptions{
+		Limits: &transport.Limits{MaxObjects: 31, MaxObjectSize: 217848, MaxPackSize: 1 << 20},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteSuite) TestFetchMaxObjects(c *C) {
+	sto := memory.NewStorage()
+	r := NewRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName, URLs: []string{s.GetBasicLocalRepositoryURL()},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	})
+
+	err := r.Fetch(&FetchOptions{Limits: &transport.Limits{MaxObjects: 30}})
+	c.Assert(err, Equals, packfile.ErrMaxObjectsExceeded)
+	c.Assert(sto.Objects, HasLen, 0)
+}
+
+func (s *RemoteSuite) TestFetchMaxObjectSizeWithPackfileWriter(c *C) {
+	dir, err := ioutil.TempDir("", "fetch")
+	c.Assert(err, IsNil)
+
+	defer os.RemoveAll(dir)
+
+	sto := filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+	r := NewRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName, URLs: []string{s.GetBasicLocalRepositoryURL()},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	})
+
+	err = r.Fetch(&FetchOptions{Limits: &transport.Limits{MaxObjectSize: 1024}})
+	c.Assert(err, Equals, packfile.ErrMaxObjectSizeExceeded)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 0)
+}
+
+func (s *RemoteSuite) TestFetchMaxPackSize(c *C) {
+	r := NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName, URLs: []string{s.GetBasicLocalRepositoryURL()},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	})
+
+	err := r.Fetch(&FetchOptions{Limits: &transport.Limits{MaxPackSize: 1024}})
+	c.Assert(err, Equals, gitioutil.ErrReadLimitExceeded)
+}
+
+// installRepositoryWithEntry serves with the file protocol a repository
+// whose master holds a single file with the given name, returning its URL.
+func (s *RemoteSuite) installRepositoryWithEntry(c *C, name string) string {
+	sto := memory.NewStorage()
+
+	blob := sto.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	blobHash, err := sto.SetEncodedObject(blob)
+	c.Assert(err, IsNil)
+
+	tree := sto.NewEncodedObject()
+	c.Assert((&object.Tree{Entries: []object.TreeEntry{
+		{Name: name, Mode: filemode.Regular, Hash: blobHash},
+	}}).Encode(tree), IsNil)
+	treeHash, err := sto.SetEncodedObject(tree)
+	c.Assert(err, IsNil)
+
+	sig := object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1257894000, 0).UTC()}
+	commit := sto.NewEncodedObject()
+	c.Assert((&object.Commit{
+		Author: sig, Committer: sig, Message: "foo\n", TreeHash: treeHash,
+	}).Encode(commit), IsNil)
+	commitHash, err := sto.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+
+	c.Assert(sto.SetReference(plumbing.NewHashReference("refs/heads/master", commitHash)), IsNil)
+
+	ep, err := transport.NewEndpoint("/bad.git")
+	c.Assert(err, IsNil)
+
+	backup := client.Protocols["file"]
+	client.InstallProtocol("file", server.NewClient(server.MapLoader{ep.String(): sto}))
+	s.restoreProtocol = func() { client.InstallProtocol("file", backup) }
+	return ep.String()
+}
+
+func (s *RemoteSuite) TestFetchFsckObjects(c *C) {
+	url := s.installRepositoryWithEntry(c, "..")
+
+	r := NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	err := r.Fetch(&FetchOptions{
+		RefSpecs:    []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+		FsckObjects: true,
+	})
+	c.Assert(err, FitsTypeOf, &fsck.ObjectError{})
+	c.Assert(err.(*fsck.ObjectError).ID, Equals, fsck.HasDotdot)
+
+	_, err = r.s.Reference("refs/remotes/origin/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RemoteSuite) TestFetchFsckObjectsWithPackfileWriter(c *C) {
+	url := s.installRepositoryWithEntry(c, ".git")
+
+	dir, err := ioutil.TempDir("", "fetch")
+	c.Assert(err, IsNil)
+
+	defer os.RemoveAll(dir)
+
+	sto := filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+	cfg, err := sto.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("transfer").SetOption("fsckObjects", "true")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	r := NewRemote(sto, &config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	err = r.Fetch(&FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+	})
+	c.Assert(err, FitsTypeOf, &fsck.ObjectError{})
+	c.Assert(err.(*fsck.ObjectError).ID, Equals, fsck.HasDotgit)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 0)
+
+	cfg.Raw.Section("fetch").Subsection("fsck").SetOption("hasDotgit", "warn")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	var progress bytes.Buffer
+	err = r.Fetch(&FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+		Progress: &progress,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(progress.String(), Matches, "(?s).*warning: object [0-9a-f]{40}: hasDotgit: contains '.git'\n.*")
+}
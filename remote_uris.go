@@ -0,0 +1,319 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	stdioutil "io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrPackfileURIChecksum is returned when a packfile downloaded from a
+	// packfile URI doesn't match the checksum advertised by the server.
+	ErrPackfileURIChecksum = errors.New("packfile URI checksum mismatch")
+)
+
+const bundleRefPrefix = "refs/bundles/"
+
+// fetchPackfileURIs downloads the packfiles the server left out of the
+// upload-pack response, and stores them once verified.
+func (r *Remote) fetchPackfileURIs(ctx context.Context, o *FetchOptions, uris packp.PackfileURIs) error {
+	for _, u := range uris {
+		if !isPackfileURIProtocol(o.PackfileURIProtocols, u.URI) {
+			return fmt.Errorf("packfile URI with unrequested protocol: %s", u.URI)
+		}
+
+		if err := r.fetchPackfileURI(ctx, o, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Remote) fetchPackfileURI(ctx context.Context, o *FetchOptions, u *packp.PackfileURI) (err error) {
+	f, err := download(ctx, u.URI, o.RetryPolicy, o.Limits)
+	if err != nil {
+		return err
+	}
+
+	defer removeDownload(f, &err)
+
+	if err := verifyPackfile(f, u.Hash); err != nil {
+		return err
+	}
+
+	ob, err := r.fsckObservers(o)
+	if err != nil {
+		return err
+	}
+
+	return packfile.UpdateObjectStorageWithLimits(r.s, f, o.Limits.PackfileLimits(), ob...)
+}
+
+// fetchBundleURI downloads the bundle, or the bundles of the bundle list, at
+// the given URI, and unbundles them. Their branches are stored under
+// refs/bundles/, so the objects they hold aren't fetched again.
+func (r *Remote) fetchBundleURI(ctx context.Context, o *FetchOptions) (err error) {
+	f, err := download(ctx, o.BundleURI, o.RetryPolicy, o.Limits)
+	if err != nil {
+		return err
+	}
+
+	defer removeDownload(f, &err)
+
+	br := bufio.NewReader(f)
+	if ahead, _ := br.Peek(len("# vN git bundle\n")); bundle.IsBundle(ahead) {
+		return r.unbundle(br)
+	}
+
+	var l bundle.List
+	if err := l.Decode(br); err != nil {
+		return err
+	}
+
+	base, err := url.Parse(o.BundleURI)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range l.Bundles {
+		ref, err := url.Parse(e.URI)
+		if err != nil {
+			return err
+		}
+
+		err = r.fetchBundle(ctx, base.ResolveReference(ref).String(), o)
+		if l.Mode == bundle.AnyMode && err == nil {
+			return nil
+		}
+
+		if l.Mode == bundle.AllMode && err != nil {
+			return err
+		}
+	}
+
+	if l.Mode == bundle.AnyMode && len(l.Bundles) != 0 {
+		return fmt.Errorf("none of the bundles of %s could be unbundled", o.BundleURI)
+	}
+
+	return nil
+}
+
+func (r *Remote) fetchBundle(ctx context.Context, uri string, o *FetchOptions) (err error) {
+	f, err := download(ctx, uri, o.RetryPolicy, o.Limits)
+	if err != nil {
+		return err
+	}
+
+	defer removeDownload(f, &err)
+
+	return r.unbundle(f)
+}
+
+func (r *Remote) unbundle(rd io.Reader) error {
+	b, err := bundle.Unbundle(r.s, rd)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range b.References {
+		if !ref.Name().IsBranch() {
+			continue
+		}
+
+		name := plumbing.ReferenceName(bundleRefPrefix + ref.Name().Short())
+		if err := r.s.SetReference(plumbing.NewHashReference(name, ref.Hash())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// download downloads the content at uri into a temporary file, rewound to
+// its beginning. When the transfer is interrupted by an error the policy
+// retries, it's resumed with a range request if the server supports them.
+// The transfer is subject to the idle timeout, rate and pack size limits.
+func download(ctx context.Context, uri string, policy *transport.RetryPolicy,
+	limits *transport.Limits) (f *os.File, err error) {
+	f, err = stdioutil.TempFile("", "go-git-download-")
+	if err != nil {
+		return nil, err
+	}
+
+	err = policy.Do(ctx, func() error {
+		return resumeDownload(ctx, uri, f, limits)
+	})
+
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+
+	if err != nil {
+		removeDownload(f, &err)
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// resumeDownload appends to f the content at uri following what f holds.
+func resumeDownload(ctx context.Context, uri string, f *os.File, limits *transport.Limits) (err error) {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return plumbing.NewPermanentError(err)
+	}
+
+	if offset != 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(res.Body, &err)
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent && offset != 0:
+		if !strings.HasPrefix(res.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return fmt.Errorf("unexpected Content-Range %q downloading %s",
+				res.Header.Get("Content-Range"), uri)
+		}
+	case res.StatusCode == http.StatusOK:
+		offset = 0
+		if err := truncateDownload(f); err != nil {
+			return err
+		}
+	default:
+		return &downloadError{uri: uri, status: res.Status, code: res.StatusCode}
+	}
+
+	_, err = io.Copy(f, limitDownload(res.Body, offset, limits))
+	return err
+}
+
+// limitDownload applies the limits to the body of a download resumed at the
+// given offset, which counts towards the maximum pack size.
+func limitDownload(body io.Reader, offset int64, limits *transport.Limits) io.Reader {
+	if limits == nil {
+		return body
+	}
+
+	l := *limits
+	l.MaxPackSize = 0
+	r := l.NewReader(body)
+	if limits.MaxPackSize > 0 {
+		r = ioutil.NewMaxSizeReader(r, limits.MaxPackSize-offset)
+	}
+
+	return r
+}
+
+// removeDownload closes and removes a file created by download.
+func removeDownload(f *os.File, err *error) {
+	ioutil.CheckClose(f, err)
+	if rerr := os.Remove(f.Name()); rerr != nil && *err == nil {
+		*err = rerr
+	}
+}
+
+// truncateDownload empties a downloaded file, discarding a partial
+// download.
+func truncateDownload(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// downloadError is returned when a download gets an unexpected response.
+type downloadError struct {
+	uri    string
+	status string
+	code   int
+}
+
+// StatusCode returns the status code of the response.
+func (e *downloadError) StatusCode() int {
+	return e.code
+}
+
+func (e *downloadError) Error() string {
+	return fmt.Sprintf("unexpected status %q downloading %s", e.status, e.uri)
+}
+
+// verifyPackfile checks that the packfile read from r has the expected
+// checksum, and that its content matches it. r is rewound afterwards.
+func verifyPackfile(r io.ReadSeeker, expected plumbing.Hash) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if size < int64(len(expected)) {
+		return ErrPackfileURIChecksum
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	h := sha1.New()
+	if _, err := io.CopyN(h, r, size-int64(len(expected))); err != nil {
+		return err
+	}
+
+	var trailer plumbing.Hash
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return err
+	}
+
+	if trailer != expected || !bytes.Equal(h.Sum(nil), trailer[:]) {
+		return ErrPackfileURIChecksum
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	return err
+}
+
+// isPackfileURIProtocol returns true if the scheme of the given URI is in
+// protocols.
+func isPackfileURIProtocol(protocols []string, uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range protocols {
+		if strings.EqualFold(p, u.Scheme) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,333 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/bundle"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	fixtures "gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type RemoteURIsSuite struct {
+	BaseSuite
+	backup transport.Transport
+}
+
+var _ = Suite(&RemoteURIsSuite{})
+
+var (
+	uriMaster = plumbing.NewHashReference("refs/heads/master",
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	uriParent = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+// installServer serves the basic fixture with the given options through the
+// file protocol, returning its URL and storage.
+func (s *RemoteURIsSuite) installServer(c *C, opts *server.Options) (string, *filesystem.Storage) {
+	fs := fixtures.Basic().One().DotGit()
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+
+	ep, err := transport.NewEndpoint(fs.Root())
+	c.Assert(err, IsNil)
+
+	client.InstallProtocol("file", server.NewClientWithOptions(server.MapLoader{ep.String(): sto}, opts))
+	return fs.Root(), sto
+}
+
+func (s *RemoteURIsSuite) SetUpTest(c *C) {
+	s.backup = client.Protocols["file"]
+}
+
+func (s *RemoteURIsSuite) TearDownTest(c *C) {
+	client.InstallProtocol("file", s.backup)
+}
+
+// encodePack returns a packfile with the objects reachable from the parent of
+// master.
+func encodePack(c *C, sto *filesystem.Storage) []byte {
+	objs, err := revlist.Objects(sto, []plumbing.Hash{uriParent}, nil)
+	c.Assert(err, IsNil)
+
+	var buf bytes.Buffer
+	_, err = packfile.NewEncoder(&buf, sto, false).Encode(objs, 10)
+	c.Assert(err, IsNil)
+	return buf.Bytes()
+}
+
+func (s *RemoteURIsSuite) TestClonePackfileURIs(c *C) {
+	var pack []byte
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(pack)
+	}))
+	defer ts.Close()
+
+	opts := &server.Options{}
+	url, sto := s.installServer(c, opts)
+	pack = encodePack(c, sto)
+
+	sp, err := server.NewStaticPack(ts.URL+"/static.pack", bytes.NewReader(pack))
+	c.Assert(err, IsNil)
+	opts.StaticPacks = []*server.StaticPack{sp}
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:                  url,
+		PackfileURIProtocols: []string{"http"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(requests, Equals, 1)
+
+	_, err = r.CommitObject(uriParent)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, uriMaster.Hash())
+
+	objs, err := revlist.Objects(r.Storer, []plumbing.Hash{head.Hash()}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(objs, HasLen, 28)
+
+	_, err = Clone(memory.NewStorage(), nil, &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+	c.Assert(requests, Equals, 1)
+}
+
+func (s *RemoteURIsSuite) TestClonePackfileURIsChecksumMismatch(c *C) {
+	var pack []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pack)
+	}))
+	defer ts.Close()
+
+	opts := &server.Options{}
+	url, sto := s.installServer(c, opts)
+	pack = encodePack(c, sto)
+
+	sp, err := server.NewStaticPack(ts.URL+"/static.pack", bytes.NewReader(pack))
+	c.Assert(err, IsNil)
+	sp.Hash = plumbing.NewHash("0000000000000000000000000000000000000001")
+	opts.StaticPacks = []*server.StaticPack{sp}
+
+	_, err = Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:                  url,
+		PackfileURIProtocols: []string{"http"},
+	})
+	c.Assert(err, Equals, ErrPackfileURIChecksum)
+}
+
+func (s *RemoteURIsSuite) TestCloneBundleURI(c *C) {
+	url, sto := s.installServer(c, &server.Options{})
+
+	var base, incremental bytes.Buffer
+	parentRef := plumbing.NewHashReference(uriMaster.Name(), uriParent)
+	c.Assert(bundle.Create(&base, sto, []*plumbing.Reference{parentRef}, nil), IsNil)
+	c.Assert(bundle.Create(&incremental, sto,
+		[]*plumbing.Reference{uriMaster}, []plumbing.Hash{uriParent}), IsNil)
+
+	list := &bundle.List{Version: 1, Mode: bundle.AllMode, Bundles: []*bundle.ListEntry{
+		{ID: "incremental", URI: "incremental.bundle", CreationToken: 2},
+		{ID: "base", URI: "/bundles/base.bundle", CreationToken: 1},
+	}}
+
+	var requested []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bundles/list", func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		c.Assert(list.Encode(w), IsNil)
+	})
+	for name, b := range map[string]*bytes.Buffer{"base": &base, "incremental": &incremental} {
+		data := b.Bytes()
+		mux.HandleFunc(fmt.Sprintf("/bundles/%s.bundle", name), func(w http.ResponseWriter, r *http.Request) {
+			requested = append(requested, r.URL.Path)
+			w.Write(data)
+		})
+	}
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:       url,
+		BundleURI: ts.URL + "/bundles/list",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(requested, DeepEquals, []string{
+		"/bundles/list", "/bundles/base.bundle", "/bundles/incremental.bundle",
+	})
+
+	ref, err := r.Reference("refs/bundles/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, uriMaster.Hash())
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, uriMaster.Hash())
+}
+
+func (s *RemoteURIsSuite) TestCloneBundleURISingleBundle(c *C) {
+	url, sto := s.installServer(c, &server.Options{})
+
+	var b bytes.Buffer
+	parentRef := plumbing.NewHashReference(uriMaster.Name(), uriParent)
+	c.Assert(bundle.Create(&b, sto, []*plumbing.Reference{parentRef}, nil), IsNil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(b.Bytes())
+	}))
+	defer ts.Close()
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:       url,
+		BundleURI: ts.URL + "/base.bundle",
+	})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference("refs/bundles/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, uriParent)
+
+	_, err = r.CommitObject(uriMaster.Hash())
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteURIsSuite) TestCloneBundleURINotFound(c *C) {
+	url, _ := s.installServer(c, &server.Options{})
+
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	_, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:       url,
+		BundleURI: ts.URL + "/missing.bundle",
+	})
+	c.Assert(err, ErrorMatches, "unexpected status .*")
+}
+
+func (s *RemoteURIsSuite) TestClonePackfileURIsResume(c *C) {
+	var pack []byte
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// interrupt the first transfer halfway
+			w.Header().Set("Content-Length", fmt.Sprint(len(pack)))
+			w.Write(pack[:len(pack)/2])
+			conn, _, err := w.(http.Hijacker).Hijack()
+			c.Assert(err, IsNil)
+			conn.Close()
+			return
+		}
+
+		http.ServeContent(w, r, "static.pack", time.Time{}, bytes.NewReader(pack))
+	}))
+	defer ts.Close()
+
+	opts := &server.Options{}
+	url, sto := s.installServer(c, opts)
+	pack = encodePack(c, sto)
+
+	sp, err := server.NewStaticPack(ts.URL+"/static.pack", bytes.NewReader(pack))
+	c.Assert(err, IsNil)
+	opts.StaticPacks = []*server.StaticPack{sp}
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:                  url,
+		PackfileURIProtocols: []string{"http"},
+		RetryPolicy:          &transport.RetryPolicy{MaxAttempts: 2},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(ranges, DeepEquals, []string{"", fmt.Sprintf("bytes=%d-", len(pack)/2)})
+
+	_, err = r.CommitObject(uriParent)
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteURIsSuite) TestCloneBundleURIResume(c *C) {
+	url, sto := s.installServer(c, &server.Options{})
+
+	var b bytes.Buffer
+	c.Assert(bundle.Create(&b, sto, []*plumbing.Reference{uriMaster}, nil), IsNil)
+	data := b.Bytes()
+
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			// interrupt the first transfer halfway
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			w.Write(data[:len(data)/2])
+			conn, _, err := w.(http.Hijacker).Hijack()
+			c.Assert(err, IsNil)
+			conn.Close()
+			return
+		}
+
+		http.ServeContent(w, r, "master.bundle", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:         url,
+		BundleURI:   ts.URL + "/master.bundle",
+		RetryPolicy: &transport.RetryPolicy{MaxAttempts: 2},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(ranges, DeepEquals, []string{"", fmt.Sprintf("bytes=%d-", len(data)/2)})
+
+	ref, err := r.Reference("refs/bundles/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, uriMaster.Hash())
+}
+
+func (s *RemoteURIsSuite) TestCloneBundleURIRetry(c *C) {
+	url, sto := s.installServer(c, &server.Options{})
+
+	var b bytes.Buffer
+	c.Assert(bundle.Create(&b, sto, []*plumbing.Reference{uriMaster}, nil), IsNil)
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write(b.Bytes())
+	}))
+	defer ts.Close()
+
+	_, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:       url,
+		BundleURI: ts.URL + "/master.bundle",
+	})
+	c.Assert(err, ErrorMatches, "unexpected status .*")
+
+	requests = 0
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL:         url,
+		BundleURI:   ts.URL + "/master.bundle",
+		RetryPolicy: &transport.RetryPolicy{MaxAttempts: 2},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(requests, Equals, 2)
+
+	ref, err := r.Reference("refs/bundles/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, uriMaster.Hash())
+}
@@ -18,11 +18,14 @@ import (
 	"gopkg.in/src-d/go-git.v4/internal/revision"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	cgobject "gopkg.in/src-d/go-git.v4/plumbing/object/commitgraph"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/transactional"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 
 	"gopkg.in/src-d/go-billy.v4"
@@ -60,14 +63,25 @@ var (
 type Repository struct {
 	Storer storage.Storer
 
-	r  map[string]*Remote
-	wt billy.Filesystem
+	r     map[string]*Remote
+	wt    billy.Filesystem
+	hooks *hooks
 }
 
 // Init creates an empty git repository, based on the given Storer and worktree.
 // The worktree Filesystem is optional, if nil a bare repository is created. If
 // the given storer is not empty ErrRepositoryAlreadyExists is returned
 func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
+	return InitWithOptions(s, worktree, &InitOptions{})
+}
+
+// InitWithOptions creates an empty git repository, as Init does, with the
+// given options.
+func InitWithOptions(s storage.Storer, worktree billy.Filesystem, o *InitOptions) (*Repository, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
 	if err := initStorer(s); err != nil {
 		return nil, err
 	}
@@ -82,7 +96,7 @@ func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 		return nil, err
 	}
 
-	h := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+	h := plumbing.NewSymbolicReference(plumbing.HEAD, o.InitialBranch)
 	if err := s.SetReference(h); err != nil {
 		return nil, err
 	}
@@ -215,116 +229,91 @@ func CloneContext(
 // if the repository will have worktree (non-bare) or not (bare), if the path
 // is not empty ErrRepositoryAlreadyExists is returned.
 func PlainInit(path string, isBare bool) (*Repository, error) {
-	var wt, dot billy.Filesystem
-
-	if isBare {
-		dot = osfs.New(path)
-	} else {
-		wt = osfs.New(path)
-		dot, _ = wt.Chroot(GitDirName)
-	}
-
-	s := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
-
-	return Init(s, wt)
-}
-
-// PlainOpen opens a git repository from the given path. It detects if the
-// repository is bare or a normal one. If the path doesn't contain a valid
-// repository ErrRepositoryNotExists is returned
-func PlainOpen(path string) (*Repository, error) {
-	return PlainOpenWithOptions(path, &PlainOpenOptions{})
+	return PlainInitWithOptions(path, &PlainInitOptions{Bare: isBare})
 }
 
-// PlainOpenWithOptions opens a git repository from the given path with specific
-// options. See PlainOpen for more info.
-func PlainOpenWithOptions(path string, o *PlainOpenOptions) (*Repository, error) {
-	dot, wt, err := dotGitToOSFilesystems(path, o.DetectDotGit)
-	if err != nil {
-		return nil, err
-	}
-
-	if _, err := dot.Stat(""); err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrRepositoryNotExists
+// PlainInitWithOptions creates an empty git repository at the given path with
+// the given options, as `git init` does. If the path is not empty
+// ErrRepositoryAlreadyExists is returned.
+func PlainInitWithOptions(path string, opts *PlainInitOptions) (*Repository, error) {
+	o := *opts
+	if o.UseGlobalConfig {
+		if err := setInitDefaults(&o); err != nil {
+			return nil, err
 		}
+	}
 
+	if err := o.Validate(); err != nil {
 		return nil, err
 	}
 
-	s := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
-
-	return Open(s, wt)
-}
-
-func dotGitToOSFilesystems(path string, detect bool) (dot, wt billy.Filesystem, err error) {
-	if path, err = filepath.Abs(path); err != nil {
-		return nil, nil, err
+	var wt, dot billy.Filesystem
+	gitDir := path
+	switch {
+	case o.Bare:
+		dot = osfs.New(path)
+	case o.SeparateGitDir != "":
+		wt = osfs.New(path)
+		gitDir = o.SeparateGitDir
+		dot = osfs.New(gitDir)
+	default:
+		wt = osfs.New(path)
+		gitDir = filepath.Join(path, GitDirName)
+		dot, _ = wt.Chroot(GitDirName)
 	}
-	var fs billy.Filesystem
-	var fi os.FileInfo
-	for {
-		fs = osfs.New(path)
-		fi, err = fs.Stat(GitDirName)
-		if err == nil {
-			// no error; stop
-			break
-		}
-		if !os.IsNotExist(err) {
-			// unknown error; stop
-			return nil, nil, err
-		}
-		if detect {
-			// try its parent as long as we haven't reached
-			// the root dir
-			if dir := filepath.Dir(path); dir != path {
-				path = dir
-				continue
-			}
-		}
-		// not detecting via parent dirs and the dir does not exist;
-		// stop
-		return fs, nil, nil
+
+	// the templates are copied before HEAD is created, unless the repository
+	// already exists
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err == nil {
+		return nil, ErrRepositoryAlreadyExists
 	}
 
-	if fi.IsDir() {
-		dot, err = fs.Chroot(GitDirName)
-		return dot, fs, err
+	if o.TemplateDir != "" {
+		if err := copyTemplates(o.TemplateDir, gitDir); err != nil {
+			return nil, err
+		}
 	}
 
-	dot, err = dotGitFileToOSFilesystem(path, fs)
+	s := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+	r, err := InitWithOptions(s, wt, &o.InitOptions)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return dot, fs, nil
-}
-
-func dotGitFileToOSFilesystem(path string, fs billy.Filesystem) (bfs billy.Filesystem, err error) {
-	f, err := fs.Open(GitDirName)
+	shared, err := parseSharedRepository(o.Shared)
 	if err != nil {
 		return nil, err
 	}
-	defer ioutil.CheckClose(f, &err)
 
-	b, err := stdioutil.ReadAll(f)
-	if err != nil {
+	if err := setInitConfig(r, &o, shared); err != nil {
 		return nil, err
 	}
 
-	line := string(b)
-	const prefix = "gitdir: "
-	if !strings.HasPrefix(line, prefix) {
-		return nil, fmt.Errorf(".git file has no %s prefix", prefix)
+	if shared != nil {
+		if err := shared.apply(gitDir); err != nil {
+			return nil, err
+		}
 	}
 
-	gitdir := strings.Split(line[len(prefix):], "\n")[0]
-	gitdir = strings.TrimSpace(gitdir)
-	if filepath.IsAbs(gitdir) {
-		return osfs.New(gitdir), nil
+	return r, nil
+}
+
+// PlainOpen opens a git repository from the given path. It detects if the
+// repository is bare or a normal one. If the path doesn't contain a valid
+// repository ErrRepositoryNotExists is returned
+func PlainOpen(path string) (*Repository, error) {
+	return PlainOpenWithOptions(path, &PlainOpenOptions{})
+}
+
+// PlainOpenWithOptions opens a git repository from the given path with specific
+// options. See PlainOpen and PlainDiscover for more info.
+func PlainOpenWithOptions(path string, o *PlainOpenOptions) (*Repository, error) {
+	d, err := PlainDiscover(path, o)
+	if err != nil {
+		return nil, err
 	}
 
-	return osfs.New(fs.Join(path, gitdir)), nil
+	return openDiscovered(d)
 }
 
 // PlainClone a repository into the path with the given options, isBare defines
@@ -372,6 +361,7 @@ func newRepository(s storage.Storer, worktree billy.Filesystem) *Repository {
 		Storer: s,
 		wt:     worktree,
 		r:      make(map[string]*Remote),
+		hooks:  newHooks(s, worktree),
 	}
 }
 
@@ -451,7 +441,7 @@ func (r *Repository) Remote(name string) (*Remote, error) {
 		return nil, ErrRemoteNotFound
 	}
 
-	return NewRemote(r.Storer, c), nil
+	return r.newRemote(c), nil
 }
 
 // Remotes returns a list with all the remotes
@@ -465,7 +455,7 @@ func (r *Repository) Remotes() ([]*Remote, error) {
 
 	var i int
 	for _, c := range cfg.Remotes {
-		remotes[i] = NewRemote(r.Storer, c)
+		remotes[i] = r.newRemote(c)
 		i++
 	}
 
@@ -478,7 +468,7 @@ func (r *Repository) CreateRemote(c *config.RemoteConfig) (*Remote, error) {
 		return nil, err
 	}
 
-	remote := NewRemote(r.Storer, c)
+	remote := r.newRemote(c)
 
 	cfg, err := r.Storer.Config()
 	if err != nil {
@@ -504,11 +494,17 @@ func (r *Repository) CreateRemoteAnonymous(c *config.RemoteConfig) (*Remote, err
 		return nil, ErrAnonymousRemoteName
 	}
 
-	remote := NewRemote(r.Storer, c)
+	remote := r.newRemote(c)
 
 	return remote, nil
 }
 
+func (r *Repository) newRemote(c *config.RemoteConfig) *Remote {
+	remote := NewRemote(r.Storer, c)
+	remote.hooks = r.hooks
+	return remote
+}
+
 // DeleteRemote delete a remote from the repository and delete the config
 func (r *Repository) DeleteRemote(name string) error {
 	cfg, err := r.Storer.Config()
@@ -748,6 +744,12 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 		Progress:   o.Progress,
 		Tags:       o.Tags,
 		RemoteName: o.RemoteName,
+
+		PackfileURIProtocols: o.PackfileURIProtocols,
+		BundleURI:            o.BundleURI,
+		RetryPolicy:          o.RetryPolicy,
+		Limits:               o.Limits,
+		FsckObjects:          o.FsckObjects,
 	}, o.ReferenceName)
 	if err != nil {
 		return err
@@ -1063,7 +1065,15 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		return nil, err
 	}
 
-	if o.FileName != nil {
+	switch {
+	case len(o.PathSpecs) != 0:
+		pathSpecs := o.PathSpecs
+		if o.FileName != nil {
+			pathSpecs = append([]string{":(literal)" + *o.FileName}, pathSpecs...)
+		}
+
+		it = r.logWithPathSpecs(pathSpecs, it, o.All)
+	case o.FileName != nil:
 		// for `git log --all` also check parent (if the next commit comes from the real parent)
 		it = r.logWithFile(*o.FileName, it, o.All)
 	}
@@ -1098,8 +1108,74 @@ func (r *Repository) logAll(commitIterFunc func(*object.Commit) object.CommitIte
 	return object.NewCommitAllIter(r.Storer, commitIterFunc)
 }
 
-func (*Repository) logWithFile(fileName string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
-	return object.NewCommitFileIterFromIter(fileName, commitIter, checkParent)
+func (r *Repository) logWithFile(fileName string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
+	filter := r.changedPathFilter()
+	if filter == nil {
+		return object.NewCommitFileIterFromIter(fileName, commitIter, checkParent)
+	}
+
+	return object.NewCommitFileIterFromIterWithFilter(fileName, commitIter, checkParent, filter)
+}
+
+func (r *Repository) logWithPathSpecs(pathSpecs []string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
+	filter := r.changedPathFilter()
+	if filter == nil {
+		return object.NewCommitPathSpecIterFromIter(pathSpecs, commitIter, checkParent)
+	}
+
+	return object.NewCommitPathSpecIterFromIterWithFilter(pathSpecs, commitIter, checkParent, filter)
+}
+
+// changedPathFilter returns the filter of the changed-path Bloom filters of
+// the commit-graph file, or nil if there is none. The commit-graph is just an
+// optimization, a missing or unreadable file only means that all the commits
+// have to be diffed.
+func (r *Repository) changedPathFilter() object.ChangedPathFilter {
+	index, err := r.commitGraph()
+	if err != nil || index == nil {
+		return nil
+	}
+
+	return cgobject.NewChangedPathFilter(index)
+}
+
+// commitNodeIndex returns a commitgraph.CommitNodeIndex using the commit-graph
+// file of the repository when available, or the object storage otherwise.
+func (r *Repository) commitNodeIndex() cgobject.CommitNodeIndex {
+	index, err := r.commitGraph()
+	if err != nil || index == nil {
+		return cgobject.NewObjectCommitNodeIndex(r.Storer)
+	}
+
+	return cgobject.NewGraphCommitNodeIndex(index, r.Storer)
+}
+
+// commitGraph returns the index of the commit-graph file of the repository,
+// or nil if the storage isn't a filesystem one or the file doesn't exist.
+func (r *Repository) commitGraph() (index commitgraph.Index, err error) {
+	s, ok := r.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, nil
+	}
+
+	fs := s.Filesystem()
+	f, err := fs.Open(fs.Join("objects", "info", "commit-graph"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	content, err := stdioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return commitgraph.OpenFileIndex(bytes.NewReader(content))
 }
 
 func (*Repository) logWithLimit(commitIter object.CommitIter, limitOptions object.LogLimitOptions) object.CommitIter {
@@ -1552,3 +1628,22 @@ func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, er
 
 	return h, err
 }
+
+// Transaction runs fn with a Repository sharing the worktree of r, whose
+// Storer is a transaction over the Storer of r. The objects, references,
+// index, config and shallow information written by fn are kept in memory and
+// copied into the Storer of r only if fn returns nil, otherwise they are
+// discarded. Changes made to the worktree filesystem are not part of the
+// transaction.
+//
+// If a reference, the index or the config written by fn was changed
+// concurrently in the Storer of r, storage.ErrReferenceHasChanged,
+// transactional.ErrIndexHasChanged or transactional.ErrConfigHasChanged is
+// returned and the Storer of r is not modified.
+func (r *Repository) Transaction(fn func(*Repository) error) error {
+	return transactional.Do(r.Storer, func(s transactional.Storage) error {
+		tr := newRepository(s, r.wt)
+		tr.hooks = r.hooks
+		return fn(tr)
+	})
+}
@@ -0,0 +1,443 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	stdioutil "io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+const (
+	gitDirEnv               = "GIT_DIR"
+	gitWorkTreeEnv          = "GIT_WORK_TREE"
+	gitCommonDirEnv         = "GIT_COMMON_DIR"
+	gitCeilingDirsEnv       = "GIT_CEILING_DIRECTORIES"
+	gitDiscoveryAcrossFsEnv = "GIT_DISCOVERY_ACROSS_FILESYSTEM"
+
+	commonDirFile = "commondir"
+	safeSection   = "safe"
+	directoryKey  = "directory"
+	worktreeKey   = "worktree"
+	bareKey       = "bare"
+)
+
+// ErrUnsafeRepository is returned when opening, with
+// PlainOpenOptions.CheckOwnership, a repository owned by another user and not
+// listed by the safe.directory options.
+var ErrUnsafeRepository = errors.New("repository owned by another user, not listed in safe.directory")
+
+// statOwnerAndDevice returns the user owning a file and the device holding
+// it, ok being false if they are unknown in this platform.
+var statOwnerAndDevice func(fi os.FileInfo) (uid uint32, dev uint64, ok bool)
+
+// PlainDiscovery describes a repository found by PlainDiscover.
+type PlainDiscovery struct {
+	// GitDir is the path of the git directory.
+	GitDir string
+	// CommonDir is the path of the directory holding the objects, the refs
+	// and the config shared with GitDir, as the linked worktrees do. It is
+	// empty if there is none.
+	CommonDir string
+	// WorkTree is the path of the worktree, empty for a bare repository.
+	WorkTree string
+}
+
+// IsBare returns whether the discovered repository is bare.
+func (d *PlainDiscovery) IsBare() bool {
+	return d.WorkTree == ""
+}
+
+// path returns the path identifying the repository, its worktree or the git
+// directory of a bare repository.
+func (d *PlainDiscovery) path() string {
+	if d.IsBare() {
+		return d.GitDir
+	}
+
+	return d.WorkTree
+}
+
+// PlainDiscover finds the repository at the given path, as PlainOpenWithOptions
+// does, returning where its git directory and worktree are without opening it.
+func PlainDiscover(path string, o *PlainOpenOptions) (d *PlainDiscovery, err error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	gitDir := ""
+	if o.UseEnv {
+		gitDir = os.Getenv(gitDirEnv)
+	}
+
+	if gitDir != "" {
+		d, err = explicitGitDir(path, gitDir)
+	} else {
+		d, err = discoverGitDir(path, o)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setDiscoveryEnv(d, o); err != nil {
+		return nil, err
+	}
+
+	if d.CommonDir == "" {
+		if d.CommonDir, err = readCommonDir(d.GitDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.CheckOwnership {
+		if err := checkOwnership(d); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// explicitGitDir returns the repository of the git directory given by GIT_DIR.
+// As git does, its worktree is core.worktree, or else path, unless core.bare
+// is set.
+func explicitGitDir(path, gitDir string) (*PlainDiscovery, error) {
+	gitDir, err := filepath.Abs(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi, err := os.Stat(gitDir); err == nil && !fi.IsDir() {
+		if gitDir, err = readGitFile(gitDir); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &PlainDiscovery{GitDir: gitDir, WorkTree: path}
+
+	b, err := stdioutil.ReadFile(filepath.Join(gitDir, "config"))
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.NewConfig()
+	if err := cfg.Unmarshal(b); err != nil {
+		return nil, err
+	}
+
+	core := cfg.Raw.Section(coreSection)
+	switch {
+	case core.Option(worktreeKey) != "":
+		d.WorkTree = resolvePath(gitDir, core.Option(worktreeKey))
+	case configBool(core.Option(bareKey), false):
+		d.WorkTree = ""
+	}
+
+	return d, nil
+}
+
+// discoverGitDir finds the repository at path, walking its parent
+// directories when DetectDotGit is set, until a ceiling directory or, if
+// requested, a filesystem boundary is reached.
+func discoverGitDir(path string, o *PlainOpenOptions) (*PlainDiscovery, error) {
+	ceilings, stopAtFs := discoveryLimits(o)
+	dev, hasDev := deviceOf(path)
+	for dir := path; ; {
+		d, err := gitDirAt(dir)
+		if err != nil || d != nil {
+			return d, err
+		}
+
+		if !o.DetectDotGit {
+			// not a repository, it may still be a bare one being opened
+			return &PlainDiscovery{GitDir: path}, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir || ceilings[parent] {
+			return nil, ErrRepositoryNotExists
+		}
+
+		if parentDev, ok := deviceOf(parent); hasDev && ok && stopAtFs && parentDev != dev {
+			return nil, ErrRepositoryNotExists
+		}
+
+		dir = parent
+	}
+}
+
+// discoveryLimits returns the ceiling directories and whether the discovery
+// stops at the filesystem boundaries, from the options and, with UseEnv, the
+// environment.
+func discoveryLimits(o *PlainOpenOptions) (map[string]bool, bool) {
+	dirs := o.CeilingDirectories
+	stopAtFs := o.StopAtFilesystemBoundary
+	if o.UseEnv {
+		if v := os.Getenv(gitCeilingDirsEnv); v != "" {
+			dirs = append(dirs, filepath.SplitList(v)...)
+		}
+
+		stopAtFs = !configBool(os.Getenv(gitDiscoveryAcrossFsEnv), !stopAtFs)
+	}
+
+	ceilings := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		// as git does, the relative directories are ignored
+		if filepath.IsAbs(dir) {
+			ceilings[filepath.Clean(dir)] = true
+		}
+	}
+
+	return ceilings, stopAtFs
+}
+
+// gitDirAt returns the repository at dir, with a .git directory or file, or
+// bare, and nil if there is none.
+func gitDirAt(dir string) (*PlainDiscovery, error) {
+	dot := filepath.Join(dir, GitDirName)
+	fi, err := os.Stat(dot)
+	if err == nil {
+		if fi.IsDir() {
+			return &PlainDiscovery{GitDir: dot, WorkTree: dir}, nil
+		}
+
+		gitDir, err := readGitFile(dot)
+		if err != nil {
+			return nil, err
+		}
+
+		return &PlainDiscovery{GitDir: gitDir, WorkTree: dir}, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if isGitDir(dir) {
+		return &PlainDiscovery{GitDir: dir}, nil
+	}
+
+	return nil, nil
+}
+
+// isGitDir returns whether dir looks like a git directory, holding HEAD, the
+// objects and the refs.
+func isGitDir(dir string) bool {
+	for name, isDir := range map[string]bool{
+		"HEAD":    false,
+		"objects": true,
+		"refs":    true,
+	} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || fi.IsDir() != isDir {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readGitFile returns the git directory pointed by a .git file.
+func readGitFile(file string) (string, error) {
+	b, err := stdioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	line := string(b)
+	const prefix = "gitdir: "
+	if !strings.HasPrefix(line, prefix) {
+		return "", fmt.Errorf(".git file has no %s prefix", prefix)
+	}
+
+	gitdir := strings.Split(line[len(prefix):], "\n")[0]
+	gitdir = strings.TrimSpace(gitdir)
+	return resolvePath(filepath.Dir(file), gitdir), nil
+}
+
+// readCommonDir returns the common directory given by the commondir file of
+// a git directory, or an empty string if there is none.
+func readCommonDir(gitDir string) (string, error) {
+	b, err := stdioutil.ReadFile(filepath.Join(gitDir, commonDirFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return resolvePath(gitDir, strings.TrimSpace(string(b))), nil
+}
+
+// setDiscoveryEnv overrides the worktree and the common directory of a
+// discovered repository with GIT_WORK_TREE and GIT_COMMON_DIR, with UseEnv.
+func setDiscoveryEnv(d *PlainDiscovery, o *PlainOpenOptions) (err error) {
+	if !o.UseEnv {
+		return nil
+	}
+
+	if v := os.Getenv(gitWorkTreeEnv); v != "" {
+		if d.WorkTree, err = filepath.Abs(v); err != nil {
+			return err
+		}
+	}
+
+	if v := os.Getenv(gitCommonDirEnv); v != "" {
+		if d.CommonDir, err = filepath.Abs(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolvePath returns path, relative to dir if not absolute.
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// deviceOf returns the device holding the given path, ok being false if it
+// is unknown.
+func deviceOf(path string) (dev uint64, ok bool) {
+	if statOwnerAndDevice == nil {
+		return 0, false
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+
+	_, dev, ok = statOwnerAndDevice(fi)
+	return dev, ok
+}
+
+// checkOwnership returns ErrUnsafeRepository if the git directory or the
+// worktree of a repository are owned by another user, and the repository is
+// not listed by the safe.directory options, as git does.
+func checkOwnership(d *PlainDiscovery) error {
+	if statOwnerAndDevice == nil {
+		return nil
+	}
+
+	owned := true
+	for _, dir := range []string{d.GitDir, d.WorkTree} {
+		if dir == "" {
+			continue
+		}
+
+		fi, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if uid, _, ok := statOwnerAndDevice(fi); ok && int(uid) != os.Geteuid() {
+			owned = false
+		}
+	}
+
+	if owned {
+		return nil
+	}
+
+	safe, err := isSafeDirectory(d.path())
+	if err != nil {
+		return err
+	}
+
+	if !safe {
+		return ErrUnsafeRepository
+	}
+
+	return nil
+}
+
+// isSafeDirectory returns whether the given path is listed by the
+// safe.directory options of the system and global config. As git does, "*"
+// lists all the paths, a trailing "/*" all the paths under a directory, and an
+// empty value resets the list.
+func isSafeDirectory(path string) (bool, error) {
+	var dirs []string
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope} {
+		cfg, err := config.LoadConfig(scope)
+		if err != nil {
+			return false, err
+		}
+
+		for _, dir := range cfg.Raw.Section(safeSection).Options.GetAll(directoryKey) {
+			if dir == "" {
+				dirs = nil
+				continue
+			}
+
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, dir := range dirs {
+		switch {
+		case dir == "*":
+			return true, nil
+		case strings.HasSuffix(dir, "/*"):
+			if strings.HasPrefix(path, strings.TrimSuffix(dir, "*")) {
+				return true, nil
+			}
+		case filepath.Clean(dir) == path:
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// openDiscovered opens the storage and the worktree of a discovered
+// repository.
+func openDiscovered(d *PlainDiscovery) (*Repository, error) {
+	if _, err := os.Stat(d.GitDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrRepositoryNotExists
+		}
+
+		return nil, err
+	}
+
+	var dot billy.Filesystem = osfs.New(d.GitDir)
+	if d.CommonDir != "" {
+		dot = dotgit.NewRepositoryFilesystem(dot, osfs.New(d.CommonDir))
+	}
+
+	var wt billy.Filesystem
+	if !d.IsBare() {
+		wt = osfs.New(d.WorkTree)
+	}
+
+	s := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+	return Open(s, wt)
+}
@@ -0,0 +1,205 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	sharedRepositoryKey    = "sharedRepository"
+	receiveSection         = "receive"
+	denyNonFastforwardsKey = "denyNonFastforwards"
+)
+
+// setInitDefaults sets the initial branch and the template directory missing
+// in the options from the init.* options of the global config, or else the
+// system one.
+func setInitDefaults(o *PlainInitOptions) error {
+	for _, scope := range []config.Scope{config.GlobalScope, config.SystemScope} {
+		if o.InitialBranch != "" && o.TemplateDir != "" {
+			return nil
+		}
+
+		cfg, err := config.LoadConfig(scope)
+		if err != nil {
+			return err
+		}
+
+		if o.InitialBranch == "" {
+			o.InitialBranch = plumbing.ReferenceName(cfg.Init.DefaultBranch)
+		}
+
+		if o.TemplateDir == "" {
+			o.TemplateDir = cfg.Init.TemplateDir
+		}
+	}
+
+	return nil
+}
+
+// setInitConfig writes the options of a new repository in its config.
+func setInitConfig(r *Repository, o *PlainInitOptions, shared *sharedRepository) error {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.RepositoryFormatVersion = config.Version0
+	if shared != nil {
+		cfg.Raw.Section(coreSection).SetOption(sharedRepositoryKey, shared.String())
+		cfg.Raw.Section(receiveSection).SetOption(denyNonFastforwardsKey, "true")
+	}
+
+	return r.Storer.SetConfig(cfg)
+}
+
+// copyTemplates copies the files of the template directory to the git
+// directory, keeping the existing ones.
+func copyTemplates(src, dst string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(target, os.ModeDir|os.ModePerm)
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			if err := os.Symlink(link, target); err != nil && !os.IsExist(err) {
+				return err
+			}
+
+			return nil
+		}
+
+		return copyTemplateFile(path, target, fi.Mode().Perm())
+	})
+}
+
+func copyTemplateFile(src, dst string, perm os.FileMode) (err error) {
+	to, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if os.IsExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(to, &err)
+
+	from, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(from, &err)
+
+	_, err = io.Copy(to, from)
+	return err
+}
+
+// sharedRepository are the permissions given to the files of a repository
+// shared by the users of a group, as set by core.sharedRepository.
+type sharedRepository struct {
+	// perm are the permissions added to the ones of the files, or replacing
+	// them if exact.
+	perm  os.FileMode
+	exact bool
+}
+
+// parseSharedRepository parses a core.sharedRepository value, returning nil
+// if the repository is not shared.
+func parseSharedRepository(v string) (*sharedRepository, error) {
+	switch strings.ToLower(v) {
+	case "", "umask", "false", "no", "off", "0":
+		return nil, nil
+	case "group", "true", "yes", "on", "1":
+		return &sharedRepository{perm: 0660}, nil
+	case "all", "world", "everybody", "2":
+		return &sharedRepository{perm: 0664}, nil
+	}
+
+	perm, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || perm > 0777 {
+		return nil, fmt.Errorf("invalid core.sharedRepository value %q", v)
+	}
+
+	if perm&0600 != 0600 {
+		return nil, fmt.Errorf("invalid core.sharedRepository value %q: the owner "+
+			"must have read and write permissions", v)
+	}
+
+	return &sharedRepository{perm: os.FileMode(perm), exact: true}, nil
+}
+
+// String returns the core.sharedRepository value, as written by git.
+func (s *sharedRepository) String() string {
+	switch {
+	case s.exact:
+		return fmt.Sprintf("0%o", s.perm)
+	case s.perm == 0660:
+		return "1"
+	}
+
+	return "2"
+}
+
+// mode returns the mode of a file of the repository with the given mode, as
+// git does. The directories are executable by those who can read them, and
+// have the setgid bit, so their files belong to the group.
+func (s *sharedRepository) mode(mode os.FileMode) os.FileMode {
+	tweak := s.perm
+	if mode&0200 == 0 {
+		tweak &^= 0222
+	}
+
+	if mode&0100 != 0 {
+		tweak |= (tweak & 0444) >> 2
+	}
+
+	perm := mode.Perm() | tweak
+	if s.exact {
+		perm = tweak
+	}
+
+	if mode.IsDir() {
+		perm |= (perm&0444)>>2 | os.ModeSetgid
+	}
+
+	return perm
+}
+
+// apply sets the mode of all the files of the git directory.
+func (s *sharedRepository) apply(gitDir string) error {
+	return filepath.Walk(gitDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		return os.Chmod(path, s.mode(fi.Mode()))
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -23,12 +24,15 @@ import (
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	cgobject "gopkg.in/src-d/go-git.v4/plumbing/object/commitgraph"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/storage/readonly"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-billy.v4/memfs"
@@ -424,6 +428,187 @@ func (s *RepositorySuite) TestPlainInitAlreadyExists(c *C) {
 	c.Assert(r, IsNil)
 }
 
+func (s *RepositorySuite) TestPlainInitWithOptions(c *C) {
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := PlainInitWithOptions(dir, &PlainInitOptions{
+		InitOptions: InitOptions{InitialBranch: "main"},
+	})
+	c.Assert(err, IsNil)
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/main"))
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.IsBare, Equals, false)
+	c.Assert(cfg.Core.RepositoryFormatVersion, Equals, config.Version0)
+}
+
+func (s *RepositorySuite) TestPlainInitDefaultBranch(c *C) {
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("HOME", dir)
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	err = ioutil.WriteFile(filepath.Join(dir, ".gitconfig"),
+		[]byte("[init]\n\tdefaultBranch = trunk\n"), 0644)
+	c.Assert(err, IsNil)
+
+	r, err := PlainInit(filepath.Join(dir, "repo"), false)
+	c.Assert(err, IsNil)
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	o := &PlainInitOptions{UseGlobalConfig: true}
+	r, err = PlainInitWithOptions(filepath.Join(dir, "other"), o)
+	c.Assert(err, IsNil)
+	c.Assert(o.InitialBranch, Equals, plumbing.ReferenceName(""))
+
+	head, err = r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/trunk"))
+}
+
+func (s *RepositorySuite) TestPlainInitWithOptionsTemplateDir(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("windows doesn't have executable files")
+	}
+
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	templates := filepath.Join(dir, "templates")
+	c.Assert(os.MkdirAll(filepath.Join(templates, "hooks"), 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(templates, "info"), 0755), IsNil)
+	for name, perm := range map[string]os.FileMode{
+		"description":      0644,
+		"info/exclude":     0644,
+		"hooks/pre-commit": 0755,
+		"config":           0644,
+	} {
+		content := name + "\n"
+		if name == "config" {
+			content = "[user]\n\tname = foo\n"
+		}
+
+		err := ioutil.WriteFile(filepath.Join(templates, name), []byte(content), perm)
+		c.Assert(err, IsNil)
+	}
+
+	r, err := PlainInitWithOptions(filepath.Join(dir, "repo"), &PlainInitOptions{
+		TemplateDir: templates,
+	})
+	c.Assert(err, IsNil)
+
+	gitDir := filepath.Join(dir, "repo", GitDirName)
+	b, err := ioutil.ReadFile(filepath.Join(gitDir, "info", "exclude"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "info/exclude\n")
+
+	fi, err := os.Stat(filepath.Join(gitDir, "hooks", "pre-commit"))
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&0100, Equals, os.FileMode(0100))
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section("user").Options.Get("name"), Equals, "foo")
+	c.Assert(cfg.Core.RepositoryFormatVersion, Equals, config.Version0)
+}
+
+func (s *RepositorySuite) TestPlainInitWithOptionsSeparateGitDir(c *C) {
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	wt := filepath.Join(dir, "worktree")
+	gitDir := filepath.Join(dir, "storage")
+	_, err = PlainInitWithOptions(wt, &PlainInitOptions{SeparateGitDir: gitDir})
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(wt, GitDirName))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "gitdir: ../storage\n")
+
+	_, err = os.Stat(filepath.Join(gitDir, "HEAD"))
+	c.Assert(err, IsNil)
+
+	r, err := PlainOpen(wt)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Filesystem.Root(), Equals, wt)
+
+	_, err = PlainInitWithOptions(wt, &PlainInitOptions{SeparateGitDir: gitDir})
+	c.Assert(err, Equals, ErrRepositoryAlreadyExists)
+
+	_, err = PlainInitWithOptions(wt, &PlainInitOptions{Bare: true, SeparateGitDir: gitDir})
+	c.Assert(err, Equals, ErrSeparateGitDirBare)
+}
+
+func (s *RepositorySuite) TestPlainInitWithOptionsShared(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("windows doesn't have unix permissions")
+	}
+
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	for shared, perms := range map[string][2]os.FileMode{
+		"group": {0660, 0770},
+		"all":   {0664, 0775},
+		"0640":  {0640, 0750},
+	} {
+		path := filepath.Join(dir, shared)
+		r, err := PlainInitWithOptions(path, &PlainInitOptions{Bare: true, Shared: shared})
+		c.Assert(err, IsNil)
+
+		fi, err := os.Stat(filepath.Join(path, "HEAD"))
+		c.Assert(err, IsNil)
+		c.Assert(fi.Mode().Perm()&perms[0], Equals, perms[0], Commentf(shared))
+		if shared == "0640" {
+			c.Assert(fi.Mode().Perm(), Equals, perms[0])
+		}
+
+		fi, err = os.Stat(filepath.Join(path, "refs", "heads"))
+		c.Assert(err, IsNil)
+		c.Assert(fi.Mode().Perm()&perms[1], Equals, perms[1], Commentf(shared))
+		c.Assert(fi.Mode()&os.ModeSetgid, Equals, os.ModeSetgid)
+
+		cfg, err := r.Config()
+		c.Assert(err, IsNil)
+		c.Assert(cfg.Raw.Section("core").Options.Get("sharedRepository"), Not(Equals), "")
+		c.Assert(cfg.Raw.Section("receive").Options.Get("denyNonFastforwards"), Equals, "true")
+	}
+
+	_, err = PlainInitWithOptions(filepath.Join(dir, "invalid"), &PlainInitOptions{Shared: "0440"})
+	c.Assert(err, ErrorMatches, ".*owner must have read and write permissions")
+}
+
+func (s *RepositorySuite) TestPlainInitWithOptionsObjectFormat(c *C) {
+	dir, err := ioutil.TempDir("", "plain-init")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	_, err = PlainInitWithOptions(dir, &PlainInitOptions{ObjectFormat: "sha256"})
+	c.Assert(err, Equals, ErrUnsupportedObjectFormat)
+
+	_, err = PlainInitWithOptions(dir, &PlainInitOptions{ObjectFormat: "sha1"})
+	c.Assert(err, IsNil)
+}
+
 func (s *RepositorySuite) TestPlainOpen(c *C) {
 	dir, err := ioutil.TempDir("", "plain-open")
 	c.Assert(err, IsNil)
@@ -589,6 +774,200 @@ func (s *RepositorySuite) TestPlainOpenNotExistsDetectDotGit(c *C) {
 	c.Assert(r, IsNil)
 }
 
+func (s *RepositorySuite) TestPlainOpenDetectBare(c *C) {
+	dir, err := ioutil.TempDir("", "plain-open")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	_, err = PlainInit(dir, true)
+	c.Assert(err, IsNil)
+
+	subdir := filepath.Join(dir, "refs", "heads")
+	d, err := PlainDiscover(subdir, &PlainOpenOptions{DetectDotGit: true})
+	c.Assert(err, IsNil)
+	c.Assert(d.GitDir, Equals, dir)
+	c.Assert(d.IsBare(), Equals, true)
+
+	r, err := PlainOpenWithOptions(subdir, &PlainOpenOptions{DetectDotGit: true})
+	c.Assert(err, IsNil)
+
+	_, err = r.Worktree()
+	c.Assert(err, Equals, ErrIsBareRepository)
+}
+
+func (s *RepositorySuite) TestPlainOpenCeilingDirectories(c *C) {
+	dir, err := ioutil.TempDir("", "plain-open")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	subdir := filepath.Join(dir, "a", "b")
+	c.Assert(os.MkdirAll(subdir, 0755), IsNil)
+
+	_, err = PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	opt := &PlainOpenOptions{
+		DetectDotGit:       true,
+		CeilingDirectories: []string{filepath.Join(dir, "a")},
+	}
+
+	_, err = PlainOpenWithOptions(subdir, opt)
+	c.Assert(err, Equals, ErrRepositoryNotExists)
+
+	d, err := PlainDiscover(filepath.Join(dir, "a"), opt)
+	c.Assert(err, IsNil)
+	c.Assert(d.WorkTree, Equals, dir)
+	c.Assert(d.GitDir, Equals, filepath.Join(dir, GitDirName))
+
+	defer setTestEnv(gitCeilingDirsEnv, filepath.Join(dir, "a"))()
+
+	_, err = PlainOpenWithOptions(subdir, &PlainOpenOptions{DetectDotGit: true})
+	c.Assert(err, IsNil)
+
+	_, err = PlainOpenWithOptions(subdir, &PlainOpenOptions{DetectDotGit: true, UseEnv: true})
+	c.Assert(err, Equals, ErrRepositoryNotExists)
+}
+
+func (s *RepositorySuite) TestPlainOpenStopAtFilesystemBoundary(c *C) {
+	dir, err := ioutil.TempDir("", "plain-open")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	subdir := filepath.Join(dir, "mount", "a")
+	c.Assert(os.MkdirAll(subdir, 0755), IsNil)
+
+	_, err = PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	// the mount directory and its children are in another filesystem
+	defer func(f func(os.FileInfo) (uint32, uint64, bool)) {
+		statOwnerAndDevice = f
+	}(statOwnerAndDevice)
+	statOwnerAndDevice = func(fi os.FileInfo) (uint32, uint64, bool) {
+		if fi.Name() == "mount" || fi.Name() == "a" {
+			return 0, 1, true
+		}
+
+		return 0, 0, true
+	}
+
+	_, err = PlainOpenWithOptions(subdir, &PlainOpenOptions{DetectDotGit: true})
+	c.Assert(err, IsNil)
+
+	opt := &PlainOpenOptions{DetectDotGit: true, StopAtFilesystemBoundary: true}
+	_, err = PlainOpenWithOptions(subdir, opt)
+	c.Assert(err, Equals, ErrRepositoryNotExists)
+
+	defer setTestEnv(gitDiscoveryAcrossFsEnv, "true")()
+
+	opt.UseEnv = true
+	_, err = PlainOpenWithOptions(subdir, opt)
+	c.Assert(err, IsNil)
+
+	os.Setenv(gitDiscoveryAcrossFsEnv, "false")
+	_, err = PlainOpenWithOptions(subdir, &PlainOpenOptions{DetectDotGit: true, UseEnv: true})
+	c.Assert(err, Equals, ErrRepositoryNotExists)
+}
+
+func (s *RepositorySuite) TestPlainOpenWithEnv(c *C) {
+	dir, err := ioutil.TempDir("", "plain-open")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	_, err = PlainInit(filepath.Join(dir, "repo"), false)
+	c.Assert(err, IsNil)
+
+	_, err = PlainInit(filepath.Join(dir, "bare"), true)
+	c.Assert(err, IsNil)
+
+	other := filepath.Join(dir, "other")
+	c.Assert(os.Mkdir(other, 0755), IsNil)
+
+	defer setTestEnv(gitDirEnv, filepath.Join(dir, "repo", GitDirName))()
+
+	_, err = PlainOpen(other)
+	c.Assert(err, Equals, ErrRepositoryNotExists)
+
+	d, err := PlainDiscover(other, &PlainOpenOptions{UseEnv: true})
+	c.Assert(err, IsNil)
+	c.Assert(d.GitDir, Equals, filepath.Join(dir, "repo", GitDirName))
+	c.Assert(d.WorkTree, Equals, other)
+
+	defer setTestEnv(gitWorkTreeEnv, filepath.Join(dir, "repo"))()
+
+	r, err := PlainOpenWithOptions(other, &PlainOpenOptions{UseEnv: true})
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Filesystem.Root(), Equals, filepath.Join(dir, "repo"))
+
+	os.Unsetenv(gitWorkTreeEnv)
+	os.Setenv(gitDirEnv, filepath.Join(dir, "bare"))
+
+	d, err = PlainDiscover(other, &PlainOpenOptions{UseEnv: true})
+	c.Assert(err, IsNil)
+	c.Assert(d.GitDir, Equals, filepath.Join(dir, "bare"))
+	c.Assert(d.IsBare(), Equals, true)
+}
+
+func (s *RepositorySuite) TestPlainOpenCommonDir(c *C) {
+	dir, err := ioutil.TempDir("", "plain-open")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	main, err := PlainInit(filepath.Join(dir, "main"), false)
+	c.Assert(err, IsNil)
+
+	_, err = main.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"http://example.com/foo.git"},
+	})
+	c.Assert(err, IsNil)
+
+	feature := plumbing.NewHashReference("refs/heads/feature",
+		plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+	c.Assert(main.Storer.SetReference(feature), IsNil)
+
+	// a linked worktree, as created by git worktree add
+	gitDir := filepath.Join(dir, "main", GitDirName, "worktrees", "linked")
+	c.Assert(os.MkdirAll(gitDir, 0755), IsNil)
+	for name, content := range map[string]string{
+		"HEAD":      "ref: refs/heads/feature\n",
+		"commondir": "../..\n",
+	} {
+		c.Assert(ioutil.WriteFile(filepath.Join(gitDir, name), []byte(content), 0644), IsNil)
+	}
+
+	linked := filepath.Join(dir, "linked")
+	c.Assert(os.Mkdir(linked, 0755), IsNil)
+	err = ioutil.WriteFile(filepath.Join(linked, GitDirName), []byte("gitdir: "+gitDir+"\n"), 0644)
+	c.Assert(err, IsNil)
+
+	d, err := PlainDiscover(linked, &PlainOpenOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(d.GitDir, Equals, gitDir)
+	c.Assert(d.CommonDir, Equals, filepath.Join(dir, "main", GitDirName))
+	c.Assert(d.WorkTree, Equals, linked)
+
+	r, err := PlainOpen(linked)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, feature.Name())
+	c.Assert(head.Hash(), Equals, feature.Hash())
+
+	_, err = r.Remote(DefaultRemoteName)
+	c.Assert(err, IsNil)
+
+	bad := plumbing.NewHashReference("refs/bisect/bad", feature.Hash())
+	c.Assert(r.Storer.SetReference(bad), IsNil)
+
+	_, err = os.Stat(filepath.Join(gitDir, "refs", "bisect", "bad"))
+	c.Assert(err, IsNil)
+}
+
 func (s *RepositorySuite) TestPlainClone(c *C) {
 	r, err := PlainClone(c.MkDir(), false, &CloneOptions{
 		URL: s.GetBasicLocalRepositoryURL(),
@@ -1519,6 +1898,38 @@ func (s *RepositorySuite) TestLogFileForEach(c *C) {
 	c.Assert(expectedIndex, Equals, 1)
 }
 
+func (s *RepositorySuite) TestLogPathSpecs(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		pathSpecs []string
+		expected  []string
+	}{
+		{[]string{"*.php"}, []string{"918c48b83bd081e863dbe1b80f8998f058cd8294"}},
+		{[]string{":(glob)*.php"}, nil},
+		{[]string{"json", "php"}, []string{
+			"918c48b83bd081e863dbe1b80f8998f058cd8294",
+			"af2d6a6954d532f8ffb47615169c8fdf9d383a1a",
+		}},
+	} {
+		cIter, err := r.Log(&LogOptions{PathSpecs: t.pathSpecs})
+		c.Assert(err, IsNil)
+
+		var obtained []string
+		err = cIter.ForEach(func(commit *object.Commit) error {
+			obtained = append(obtained, commit.Hash.String())
+			return nil
+		})
+		c.Assert(err, IsNil)
+		c.Assert(obtained, DeepEquals, t.expected, Commentf("pathspecs: %v", t.pathSpecs))
+	}
+}
+
 func (s *RepositorySuite) TestLogNonHeadFile(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -1612,6 +2023,101 @@ func (s *RepositorySuite) TestLogFileInitialCommit(c *C) {
 	c.Assert(expectedIndex, Equals, 1)
 }
 
+func (s *RepositorySuite) TestLogFileWithCommitGraph(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	r, err := Open(filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil)
+	c.Assert(err, IsNil)
+
+	files := []string{"CHANGELOG", "LICENSE", "go/example.go", "json/short.json"}
+	expected := make(map[string][]plumbing.Hash)
+	for _, name := range files {
+		expected[name] = logFileHashes(c, r, name)
+	}
+
+	pathSpecs := [][]string{
+		{"go"},
+		{"json/*.json", "CHANGELOG"},
+		{"*.go", ":(exclude)vendor"},
+		{":(icase)changelog"},
+	}
+	expectedPathSpecs := make([][]plumbing.Hash, len(pathSpecs))
+	for i, ps := range pathSpecs {
+		expectedPathSpecs[i] = logHashes(c, r, &LogOptions{PathSpecs: ps})
+	}
+
+	writeGraph := func(empty bool) {
+		idx := commitgraph.NewMemoryIndex()
+		iter, err := r.CommitObjects()
+		c.Assert(err, IsNil)
+		err = iter.ForEach(func(commit *object.Commit) error {
+			tree, err := commit.Tree()
+			c.Assert(err, IsNil)
+
+			idx.Add(commit.Hash, &commitgraph.CommitData{
+				TreeHash:     tree.Hash,
+				ParentHashes: commit.ParentHashes,
+				When:         commit.Committer.When,
+			})
+
+			filter := commitgraph.NewBloomFilter(nil)
+			if !empty {
+				filter, err = cgobject.ComputeBloomFilter(commit)
+				c.Assert(err, IsNil)
+			}
+
+			return idx.AddBloomFilter(commit.Hash, filter)
+		})
+		c.Assert(err, IsNil)
+
+		f, err := fs.Create(fs.Join("objects", "info", "commit-graph"))
+		c.Assert(err, IsNil)
+		c.Assert(commitgraph.NewEncoder(f).Encode(idx), IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	writeGraph(false)
+	for _, name := range files {
+		c.Assert(logFileHashes(c, r, name), DeepEquals, expected[name])
+	}
+
+	for i, ps := range pathSpecs {
+		obtained := logHashes(c, r, &LogOptions{PathSpecs: ps})
+		c.Assert(obtained, DeepEquals, expectedPathSpecs[i], Commentf("%v", ps))
+	}
+
+	// The filters are trusted, so the commits are skipped when the filters
+	// are lying about the changed paths.
+	writeGraph(true)
+	obtained := logFileHashes(c, r, "CHANGELOG")
+	c.Assert(len(obtained) < len(expected["CHANGELOG"]), Equals, true)
+
+	obtained = logHashes(c, r, &LogOptions{PathSpecs: []string{"go"}})
+	c.Assert(len(obtained) < len(expectedPathSpecs[0]), Equals, true)
+
+	// the filters are not used when a pathspec may match any path, or when
+	// it ignores the case
+	c.Assert(logHashes(c, r, &LogOptions{PathSpecs: pathSpecs[2]}), DeepEquals, expectedPathSpecs[2])
+	c.Assert(logHashes(c, r, &LogOptions{PathSpecs: pathSpecs[3]}), DeepEquals, expectedPathSpecs[3])
+}
+
+func logFileHashes(c *C, r *Repository, fileName string) []plumbing.Hash {
+	return logHashes(c, r, &LogOptions{FileName: &fileName})
+}
+
+func logHashes(c *C, r *Repository, o *LogOptions) []plumbing.Hash {
+	iter, err := r.Log(o)
+	c.Assert(err, IsNil)
+
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	return hashes
+}
+
 func (s *RepositorySuite) TestLogFileWithOtherParamsFail(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -2693,6 +3199,73 @@ func (s *RepositorySuite) TestRepackObjectsWithNoDelete(c *C) {
 	s.testRepackObjects(c, time.Unix(0, 1), 3)
 }
 
+func (s *RepositorySuite) TestTransaction(c *C) {
+	fs := memfs.New()
+	st := memory.NewStorage()
+
+	r, err := Init(st, fs)
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+
+	var hash plumbing.Hash
+	err = r.Transaction(func(tx *Repository) error {
+		w, err := tx.Worktree()
+		c.Assert(err, IsNil)
+
+		_, err = w.Add("foo")
+		c.Assert(err, IsNil)
+
+		hash, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+
+		_, err = r.Head()
+		c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, hash)
+
+	_, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	idx, err := st.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 1)
+}
+
+func (s *RepositorySuite) TestReadOnlyStorage(c *C) {
+	st := filesystem.NewStorage(fixtures.Basic().One().DotGit(), cache.NewObjectLRUDefault())
+	r, err := Open(readonly.NewStorage(st), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateTag("foo", plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"), nil)
+	c.Assert(readonly.IsReadOnly(err), Equals, true)
+
+	_, err = r.Tag("foo")
+	c.Assert(err, Equals, ErrTagNotFound)
+}
+
+func (s *RepositorySuite) TestTransactionError(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	expected := errors.New("foo")
+	err = r.Transaction(func(tx *Repository) error {
+		_, err := tx.CreateTag("foo", plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"), nil)
+		c.Assert(err, IsNil)
+
+		return expected
+	})
+	c.Assert(err, Equals, expected)
+
+	_, err = r.Tag("foo")
+	c.Assert(err, Equals, ErrTagNotFound)
+}
+
 func ExecuteOnPath(c *C, path string, cmds ...string) error {
 	for _, cmd := range cmds {
 		err := executeOnPath(path, cmd)
@@ -2849,3 +3422,17 @@ func BenchmarkPlainClone(b *testing.B) {
 		b.StartTimer()
 	}
 }
+
+// setTestEnv sets an environment variable, returning the function restoring
+// its previous value.
+func setTestEnv(key, value string) (restore func()) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
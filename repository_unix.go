@@ -0,0 +1,18 @@
+// +build !plan9,!windows
+
+package git
+
+import (
+	"os"
+	"syscall"
+)
+
+func init() {
+	statOwnerAndDevice = func(fi os.FileInfo) (uint32, uint64, bool) {
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			return st.Uid, uint64(st.Dev), true
+		}
+
+		return 0, 0, false
+	}
+}
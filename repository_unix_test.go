@@ -1,11 +1,67 @@
+//go:build !plan9 && !windows
 // +build !plan9,!windows
 
 package git
 
-import "fmt"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
 
 // preReceiveHook returns the bytes of a pre-receive hook script
 // that prints m before exiting successfully
 func preReceiveHook(m string) []byte {
 	return []byte(fmt.Sprintf("#!/bin/sh\nprintf '%s'\n", m))
 }
+
+func (s *RepositorySuite) TestPlainOpenCheckOwnership(c *C) {
+	if os.Geteuid() != 0 {
+		c.Skip("changing the owner of a repository requires root")
+	}
+
+	dir, err := ioutil.TempDir("", "plain-open")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	defer setTestEnv("HOME", dir)()
+	defer setTestEnv("XDG_CONFIG_HOME", "")()
+
+	repo := filepath.Join(dir, "repo")
+	_, err = PlainInit(repo, false)
+	c.Assert(err, IsNil)
+
+	opt := &PlainOpenOptions{CheckOwnership: true}
+	_, err = PlainOpenWithOptions(repo, opt)
+	c.Assert(err, IsNil)
+
+	c.Assert(os.Chown(repo, 4242, 4242), IsNil)
+
+	_, err = PlainOpenWithOptions(repo, &PlainOpenOptions{})
+	c.Assert(err, IsNil)
+
+	_, err = PlainOpenWithOptions(repo, opt)
+	c.Assert(err, Equals, ErrUnsafeRepository)
+
+	for content, safe := range map[string]bool{
+		"[safe]\n\tdirectory = " + repo + "\n":                     true,
+		"[safe]\n\tdirectory = *\n":                                true,
+		"[safe]\n\tdirectory = " + dir + "/*\n":                    true,
+		"[safe]\n\tdirectory = " + dir + "\n":                      false,
+		"[safe]\n\tdirectory = *\n\tdirectory =\n":                 false,
+		"[safe]\n\tdirectory = /foo\n\tdirectory = " + repo + "\n": true,
+	} {
+		err = ioutil.WriteFile(filepath.Join(dir, ".gitconfig"), []byte(content), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = PlainOpenWithOptions(repo, opt)
+		if safe {
+			c.Assert(err, IsNil, Commentf(content))
+		} else {
+			c.Assert(err, Equals, ErrUnsafeRepository, Commentf(content))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package dotgit
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	branchesPath  = "branches"
+	hooksPath     = "hooks"
+	infoPath      = "info"
+	logsPath      = "logs"
+	remotesPath   = "remotes"
+	worktreesPath = "worktrees"
+)
+
+// RepositoryFilesystem is the filesystem of a git directory sharing the files
+// of a common directory, as the linked worktrees or GIT_COMMON_DIR do. The
+// objects, the refs, the config and the other shared files are in the common
+// directory, and HEAD, the index and the per-worktree refs in the git
+// directory, as described at https://git-scm.com/docs/gitrepository-layout.
+type RepositoryFilesystem struct {
+	dotGitFs       billy.Filesystem
+	commonDotGitFs billy.Filesystem
+}
+
+// NewRepositoryFilesystem returns the filesystem of the git directory dotGitFs
+// using the common directory commonDotGitFs, which can be nil when there is
+// none.
+func NewRepositoryFilesystem(dotGitFs, commonDotGitFs billy.Filesystem) *RepositoryFilesystem {
+	return &RepositoryFilesystem{
+		dotGitFs:       dotGitFs,
+		commonDotGitFs: commonDotGitFs,
+	}
+}
+
+// filesystem returns the filesystem holding the given path.
+func (fs *RepositoryFilesystem) filesystem(name string) billy.Filesystem {
+	if fs.commonDotGitFs == nil {
+		return fs.dotGitFs
+	}
+
+	name = path.Clean(filepath.ToSlash(name))
+	if name == logsPath+"/HEAD" || name == infoPath+"/sparse-checkout" {
+		return fs.dotGitFs
+	}
+
+	for _, dir := range perWorktreeRefs {
+		if isPathOrChild(name, dir) || isPathOrChild(name, logsPath+"/"+dir) {
+			return fs.dotGitFs
+		}
+	}
+
+	switch strings.SplitN(name, "/", 2)[0] {
+	case objectsPath, refsPath, packedRefsPath, configPath, branchesPath,
+		hooksPath, infoPath, remotesPath, logsPath, shallowPath, worktreesPath:
+		return fs.commonDotGitFs
+	}
+
+	return fs.dotGitFs
+}
+
+// perWorktreeRefs are the refs of each worktree, besides HEAD.
+var perWorktreeRefs = []string{
+	refsPath + "/bisect",
+	refsPath + "/rewritten",
+	refsPath + "/worktree",
+}
+
+func isPathOrChild(name, dir string) bool {
+	return name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// Create creates the named file, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Create(filename string) (billy.File, error) {
+	return fs.filesystem(filename).Create(filename)
+}
+
+// Open opens the named file for reading, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Open(filename string) (billy.File, error) {
+	return fs.filesystem(filename).Open(filename)
+}
+
+// OpenFile opens the named file, see billy.Filesystem.
+func (fs *RepositoryFilesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.filesystem(filename).OpenFile(filename, flag, perm)
+}
+
+// Stat returns the FileInfo of the named file, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Stat(filename string) (os.FileInfo, error) {
+	return fs.filesystem(filename).Stat(filename)
+}
+
+// Rename renames a file, see billy.Filesystem. The files can't be moved
+// between the git and the common directories, billy.ErrNotSupported is
+// returned instead.
+func (fs *RepositoryFilesystem) Rename(oldpath, newpath string) error {
+	from, to := fs.filesystem(oldpath), fs.filesystem(newpath)
+	if from != to {
+		return billy.ErrNotSupported
+	}
+
+	return to.Rename(oldpath, newpath)
+}
+
+// Remove removes the named file or directory, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Remove(filename string) error {
+	return fs.filesystem(filename).Remove(filename)
+}
+
+// Join joins the elements of a path, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Join(elem ...string) string {
+	return fs.dotGitFs.Join(elem...)
+}
+
+// TempFile creates a temporary file in the given directory, see
+// billy.Filesystem.
+func (fs *RepositoryFilesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.filesystem(dir).TempFile(dir, prefix)
+}
+
+// ReadDir reads the named directory, see billy.Filesystem.
+func (fs *RepositoryFilesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return fs.filesystem(dirname).ReadDir(dirname)
+}
+
+// MkdirAll creates a directory and its parents, see billy.Filesystem.
+func (fs *RepositoryFilesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.filesystem(filename).MkdirAll(filename, perm)
+}
+
+// Lstat returns the FileInfo of the named file, without following symbolic
+// links, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.filesystem(filename).Lstat(filename)
+}
+
+// Symlink creates a symbolic link, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Symlink(target, link string) error {
+	return fs.filesystem(link).Symlink(target, link)
+}
+
+// Readlink returns the target of a symbolic link, see billy.Filesystem.
+func (fs *RepositoryFilesystem) Readlink(link string) (string, error) {
+	return fs.filesystem(link).Readlink(link)
+}
+
+// Chroot returns a filesystem rooted at the given path, see
+// billy.Filesystem.
+func (fs *RepositoryFilesystem) Chroot(name string) (billy.Filesystem, error) {
+	return fs.filesystem(name).Chroot(name)
+}
+
+// Root returns the root of the git directory.
+func (fs *RepositoryFilesystem) Root() string {
+	return fs.dotGitFs.Root()
+}
+
+// Capabilities returns the capabilities of the git directory filesystem.
+func (fs *RepositoryFilesystem) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.dotGitFs)
+}
@@ -0,0 +1,84 @@
+package dotgit
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+func (s *SuiteDotGit) TestRepositoryFilesystem(c *C) {
+	dotGitFs := memfs.New()
+	commonDotGitFs := memfs.New()
+	fs := NewRepositoryFilesystem(dotGitFs, commonDotGitFs)
+
+	for name, common := range map[string]bool{
+		"HEAD":                    false,
+		"index":                   false,
+		"ORIG_HEAD":               false,
+		"logs/HEAD":               false,
+		"info/sparse-checkout":    false,
+		"refs/bisect/bad":         false,
+		"logs/refs/bisect/bad":    false,
+		"refs/worktree/foo":       false,
+		"config":                  true,
+		"packed-refs":             true,
+		"shallow":                 true,
+		"objects/info/packs":      true,
+		"refs/heads/master":       true,
+		"logs/refs/heads/master":  true,
+		"info/exclude":            true,
+		"hooks/pre-commit":        true,
+		"worktrees/foo/HEAD":      true,
+		"refs/bisectfoo/whatever": true,
+	} {
+		c.Assert(util.WriteFile(fs, name, []byte(name), 0644), IsNil)
+
+		_, err := commonDotGitFs.Stat(name)
+		c.Assert(err == nil, Equals, common, Commentf(name))
+
+		_, err = dotGitFs.Stat(name)
+		c.Assert(err == nil, Equals, !common, Commentf(name))
+	}
+}
+
+func (s *SuiteDotGit) TestRepositoryFilesystemRefs(c *C) {
+	dotGitFs := memfs.New()
+	commonDotGitFs := memfs.New()
+	dir := New(NewRepositoryFilesystem(dotGitFs, commonDotGitFs))
+	c.Assert(dir.Initialize(), IsNil)
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/feature")
+	c.Assert(dir.SetRef(head, nil), IsNil)
+
+	feature := plumbing.NewReferenceFromStrings("refs/heads/feature", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(dir.SetRef(feature, nil), IsNil)
+
+	_, err := dotGitFs.Stat("HEAD")
+	c.Assert(err, IsNil)
+	_, err = commonDotGitFs.Stat("refs/heads/feature")
+	c.Assert(err, IsNil)
+
+	ref, err := dir.Ref(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Target(), Equals, feature.Name())
+
+	ref, err = dir.Ref(feature.Name())
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, feature.Hash())
+
+	c.Assert(dir.RemoveRef(feature.Name()), IsNil)
+	_, err = commonDotGitFs.Stat("refs/heads/feature")
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteDotGit) TestRepositoryFilesystemWithoutCommonDir(c *C) {
+	dotGitFs := memfs.New()
+	fs := NewRepositoryFilesystem(dotGitFs, nil)
+
+	c.Assert(util.WriteFile(fs, "refs/heads/master", []byte("foo"), 0644), IsNil)
+
+	_, err := dotGitFs.Stat("refs/heads/master")
+	c.Assert(err, IsNil)
+}
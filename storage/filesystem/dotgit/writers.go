@@ -3,6 +3,7 @@ package dotgit
 import (
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -22,13 +23,17 @@ import (
 type PackWriter struct {
 	Notify func(plumbing.Hash, *idxfile.Writer)
 
-	fs       billy.Filesystem
-	fr, fw   billy.File
-	synced   *syncedReader
-	checksum plumbing.Hash
-	parser   *packfile.Parser
-	writer   *idxfile.Writer
-	result   chan error
+	fs        billy.Filesystem
+	fr, fw    billy.File
+	synced    *syncedReader
+	checksum  plumbing.Hash
+	parser    *packfile.Parser
+	writer    *idxfile.Writer
+	limits    packfile.Limits
+	observers []packfile.Observer
+	start     sync.Once
+	done      chan struct{}
+	err       error
 }
 
 func newPackWrite(fs billy.Filesystem) (*PackWriter, error) {
@@ -47,46 +52,67 @@ func newPackWrite(fs billy.Filesystem) (*PackWriter, error) {
 		fw:     fw,
 		fr:     fr,
 		synced: newSyncedReader(fw, fr),
-		result: make(chan error),
+		done:   make(chan struct{}),
 	}
 
-	go writer.buildIndex()
 	return writer, nil
 }
 
+// SetLimits sets the limits enforced while indexing the packfile, it must be
+// called before the first write.
+func (w *PackWriter) SetLimits(l packfile.Limits) {
+	w.limits = l
+}
+
+// AddObserver adds an observer notified while indexing the packfile, which
+// fails if the observer does. It must be called before the first write.
+func (w *PackWriter) AddObserver(o packfile.Observer) {
+	w.observers = append(w.observers, o)
+}
+
+func (w *PackWriter) startBuildIndex() {
+	w.start.Do(func() { go w.buildIndex() })
+}
+
 func (w *PackWriter) buildIndex() {
+	defer close(w.done)
+
 	s := packfile.NewScanner(w.synced)
 	w.writer = new(idxfile.Writer)
-	var err error
-	w.parser, err = packfile.NewParser(s, w.writer)
-	if err != nil {
-		w.result <- err
-		return
-	}
-
-	checksum, err := w.parser.Parse()
-	if err != nil {
-		w.result <- err
+	w.parser, w.err = packfile.NewParser(s, append([]packfile.Observer{w.writer}, w.observers...)...)
+	if w.err != nil {
 		return
 	}
 
-	w.checksum = checksum
-	w.result <- err
+	w.parser.SetLimits(w.limits)
+	w.checksum, w.err = w.parser.Parse()
 }
 
 // waitBuildIndex waits until buildIndex function finishes, this can terminate
 // with a packfile.ErrEmptyPackfile, this means that nothing was written so we
 // ignore the error
 func (w *PackWriter) waitBuildIndex() error {
-	err := <-w.result
-	if err == packfile.ErrEmptyPackfile {
+	<-w.done
+	if w.err == packfile.ErrEmptyPackfile {
 		return nil
 	}
 
-	return err
+	return w.err
 }
 
+// Write writes the given packfile data, failing once the index can't be
+// built, e.g. because the packfile exceeds the limits.
 func (w *PackWriter) Write(p []byte) (int, error) {
+	w.startBuildIndex()
+
+	select {
+	case <-w.done:
+		if w.err != nil {
+			return 0, w.err
+		}
+	default:
+	}
+
 	return w.synced.Write(p)
 }
 
@@ -97,15 +123,17 @@ func (w *PackWriter) Close() error {
 		if w.Notify != nil && w.writer != nil && w.writer.Finished() {
 			w.Notify(w.checksum, w.writer)
 		}
-
-		close(w.result)
 	}()
 
+	w.startBuildIndex()
 	if err := w.synced.Close(); err != nil {
 		return err
 	}
 
 	if err := w.waitBuildIndex(); err != nil {
+		_ = w.fr.Close()
+		_ = w.fw.Close()
+		_ = w.clean()
 		return err
 	}
 
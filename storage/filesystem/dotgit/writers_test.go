@@ -92,6 +92,29 @@ func (s *SuiteDotGit) TestNewObjectPackUnused(c *C) {
 	}
 }
 
+func (s *SuiteDotGit) TestNewObjectPackLimits(c *C) {
+	f := fixtures.Basic().One()
+
+	dir, err := ioutil.TempDir("", "example")
+	c.Assert(err, IsNil)
+
+	defer os.RemoveAll(dir)
+
+	fs := osfs.New(dir)
+	dot := New(fs)
+
+	w, err := dot.NewObjectPack()
+	c.Assert(err, IsNil)
+
+	w.SetLimits(packfile.Limits{MaxObjects: 30})
+	_, _ = io.Copy(w, f.Packfile())
+	c.Assert(w.Close(), Equals, packfile.ErrMaxObjectsExceeded)
+
+	info, err := fs.ReadDir("objects/pack")
+	c.Assert(err, IsNil)
+	c.Assert(info, HasLen, 0)
+}
+
 func (s *SuiteDotGit) TestSyncedReader(c *C) {
 	tmpw, err := ioutil.TempFile("", "example")
 	c.Assert(err, IsNil)
@@ -0,0 +1,238 @@
+// Package overlay is an implementation of git.Storer that layers a writable
+// in-memory storer over a read-only base storer. The base storer is never
+// modified, all the writes are kept in memory and can be exported as a
+// packfile plus the list of changed references.
+package overlay
+
+import (
+	"io"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/storage/readonly"
+	"gopkg.in/src-d/go-git.v4/storage/transactional"
+)
+
+// Storage is an implementation of git.Storer, the read operations are served
+// by the in-memory storer and the base storer, in that order, while the write
+// operations only reach the in-memory storer.
+type Storage struct {
+	base storage.Storer
+	top  *memory.Storage
+
+	objects    *transactional.ObjectStorage
+	references *transactional.ReferenceStorage
+	*transactional.IndexStorage
+	*transactional.ShallowStorage
+	*transactional.ConfigStorage
+}
+
+// Delta describes the references changed in a Storage over its base storer.
+type Delta struct {
+	// References contains the references created or updated, sorted by name.
+	References []*plumbing.Reference
+	// Removed contains the names of the references removed, sorted.
+	Removed []plumbing.ReferenceName
+}
+
+// NewStorage returns a new Storage over the given base storer, the base
+// storer is wrapped with a readonly.Storage.
+func NewStorage(base storage.Storer) *Storage {
+	ro := readonly.NewStorage(base)
+	top := memory.NewStorage()
+
+	return &Storage{
+		base: ro,
+		top:  top,
+
+		objects:        transactional.NewObjectStorage(ro, top),
+		references:     transactional.NewReferenceStorage(ro, top),
+		IndexStorage:   transactional.NewIndexStorage(ro, top),
+		ShallowStorage: transactional.NewShallowStorage(ro, top),
+		ConfigStorage:  transactional.NewConfigStorage(ro, top),
+	}
+}
+
+// NewEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *Storage) NewEncodedObject() plumbing.EncodedObject {
+	return s.top.NewEncodedObject()
+}
+
+// SetEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *Storage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	return s.objects.SetEncodedObject(obj)
+}
+
+// HasEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *Storage) HasEncodedObject(h plumbing.Hash) error {
+	return s.objects.HasEncodedObject(h)
+}
+
+// EncodedObjectSize honors the storer.EncodedObjectStorer interface.
+func (s *Storage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	return s.objects.EncodedObjectSize(h)
+}
+
+// EncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *Storage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	return s.objects.EncodedObject(t, h)
+}
+
+// IterEncodedObjects honors the storer.EncodedObjectStorer interface.
+func (s *Storage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	return s.objects.IterEncodedObjects(t)
+}
+
+// SetReference honors the storer.ReferenceStorer interface.
+func (s *Storage) SetReference(ref *plumbing.Reference) error {
+	return s.references.SetReference(ref)
+}
+
+// CheckAndSetReference honors the storer.ReferenceStorer interface.
+func (s *Storage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	return s.references.CheckAndSetReference(ref, old)
+}
+
+// Reference honors the storer.ReferenceStorer interface.
+func (s *Storage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	return s.references.Reference(n)
+}
+
+// IterReferences honors the storer.ReferenceStorer interface.
+func (s *Storage) IterReferences() (storer.ReferenceIter, error) {
+	return s.references.IterReferences()
+}
+
+// RemoveReference honors the storer.ReferenceStorer interface.
+func (s *Storage) RemoveReference(n plumbing.ReferenceName) error {
+	return s.references.RemoveReference(n)
+}
+
+// CountLooseRefs honors the storer.ReferenceStorer interface.
+func (s *Storage) CountLooseRefs() (int, error) {
+	return s.references.CountLooseRefs()
+}
+
+// PackRefs honors the storer.ReferenceStorer interface, since the base storer
+// can't be modified it does nothing.
+func (s *Storage) PackRefs() error {
+	return nil
+}
+
+// Config honors the config.ConfigStorer interface.
+func (s *Storage) Config() (*config.Config, error) {
+	return s.ConfigStorage.Config()
+}
+
+// Module honors the storage.ModuleStorer interface, the returned Storer is an
+// overlay over the module of the base storer.
+func (s *Storage) Module(name string) (storage.Storer, error) {
+	m, err := s.base.Module(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStorage(m), nil
+}
+
+// Delta returns the references changed in the overlay over the base storer.
+func (s *Storage) Delta() (*Delta, error) {
+	d := &Delta{}
+
+	iter, err := s.top.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		old, err := s.base.Reference(ref.Name())
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		if old == nil || old.String() != ref.String() {
+			d.References = append(d.References, ref)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err = s.base.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		_, err := s.references.Reference(ref.Name())
+		if err == plumbing.ErrReferenceNotFound {
+			d.Removed = append(d.Removed, ref.Name())
+			return nil
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(d.References, func(i, j int) bool {
+		return d.References[i].Name() < d.References[j].Name()
+	})
+
+	sort.Slice(d.Removed, func(i, j int) bool {
+		return d.Removed[i] < d.Removed[j]
+	})
+
+	return d, nil
+}
+
+// WriteDelta writes into w a packfile containing the objects written into the
+// overlay that are missing in the base storer, and returns the references
+// changed over the base storer.
+func (s *Storage) WriteDelta(w io.Writer) (*Delta, error) {
+	d, err := s.Delta()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.top.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		if err := s.base.HasEncodedObject(obj.Hash()); err == nil {
+			return nil
+		}
+
+		hashes = append(hashes, obj.Hash())
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	e := packfile.NewEncoder(w, s, false)
+	if _, err := e.Encode(hashes, cfg.Pack.Window); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
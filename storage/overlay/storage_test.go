@@ -0,0 +1,112 @@
+package overlay
+
+import (
+	"bytes"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/storage/test"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StorageSuite struct {
+	test.BaseStorageSuite
+}
+
+var _ = Suite(&StorageSuite{})
+
+func (s *StorageSuite) SetUpTest(c *C) {
+	s.BaseStorageSuite = test.NewBaseStorageSuite(NewStorage(memory.NewStorage()))
+	s.BaseStorageSuite.SetUpTest(c)
+}
+
+func (s *StorageSuite) newBase(c *C) *filesystem.Storage {
+	fs := fixtures.Basic().One().DotGit()
+	return filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+}
+
+func (s *StorageSuite) TestBaseNotModified(c *C) {
+	base := s.newBase(c)
+	st := NewStorage(base)
+
+	blob := st.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	h, err := st.SetEncodedObject(blob)
+	c.Assert(err, IsNil)
+
+	c.Assert(st.SetReference(plumbing.NewHashReference("refs/heads/foo", h)), IsNil)
+	c.Assert(st.RemoveReference("refs/heads/master"), IsNil)
+
+	cfg, err := st.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.Worktree = "foo"
+	c.Assert(st.SetConfig(cfg), IsNil)
+
+	c.Assert(st.HasEncodedObject(h), IsNil)
+	c.Assert(base.HasEncodedObject(h), Equals, plumbing.ErrObjectNotFound)
+
+	_, err = st.Reference("refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	_, err = base.Reference("refs/heads/master")
+	c.Assert(err, IsNil)
+
+	_, err = base.Reference("refs/heads/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	cfg, err = st.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "foo")
+
+	cfg, err = base.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "")
+}
+
+func (s *StorageSuite) TestWriteDelta(c *C) {
+	base := s.newBase(c)
+	st := NewStorage(base)
+
+	head, err := st.Reference("refs/heads/master")
+	c.Assert(err, IsNil)
+
+	blob := st.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	h, err := st.SetEncodedObject(blob)
+	c.Assert(err, IsNil)
+
+	// already present in the base storer, must be skipped from the packfile
+	obj, err := base.EncodedObject(plumbing.AnyObject, head.Hash())
+	c.Assert(err, IsNil)
+	_, err = st.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	c.Assert(st.SetReference(plumbing.NewHashReference("refs/heads/foo", h)), IsNil)
+	c.Assert(st.SetReference(head), IsNil)
+	c.Assert(st.RemoveReference("refs/heads/branch"), IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	d, err := st.WriteDelta(buf)
+	c.Assert(err, IsNil)
+
+	c.Assert(d.References, HasLen, 1)
+	c.Assert(d.References[0].Name(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(d.Removed, DeepEquals, []plumbing.ReferenceName{"refs/heads/branch"})
+
+	m := memory.NewStorage()
+	c.Assert(packfile.UpdateObjectStorage(m, buf), IsNil)
+	c.Assert(m.Objects, HasLen, 1)
+	c.Assert(m.HasEncodedObject(h), IsNil)
+}
@@ -0,0 +1,114 @@
+// Package readonly is a storage.Storer wrapper that rejects any operation
+// modifying the wrapped storer.
+package readonly
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/storage"
+)
+
+// ErrReadOnly is the cause of all the errors returned when a modification is
+// requested to a read-only Storage, use IsReadOnly to match them.
+var ErrReadOnly = errors.New("read-only storage")
+
+// Error is returned by the methods of Storage modifying the storer.
+type Error struct {
+	// Op is the name of the rejected operation.
+	Op string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s not allowed", ErrReadOnly, e.Op)
+}
+
+// Is returns true if target is ErrReadOnly, so the errors can also be matched
+// with errors.Is on Go 1.13 and later.
+func (e *Error) Is(target error) bool {
+	return target == ErrReadOnly
+}
+
+// IsReadOnly returns true if err is ErrReadOnly or an *Error, as returned when
+// a modification is requested to a read-only Storage.
+func IsReadOnly(err error) bool {
+	if err == ErrReadOnly {
+		return true
+	}
+
+	_, ok := err.(*Error)
+	return ok
+}
+
+// Storage is a storage.Storer wrapper, the read operations are delegated to
+// the underlying storer while the write operations return an *Error.
+type Storage struct {
+	storage.Storer
+}
+
+// NewStorage returns a new read-only Storage wrapping s.
+func NewStorage(s storage.Storer) *Storage {
+	return &Storage{Storer: s}
+}
+
+// SetEncodedObject honors the storer.EncodedObjectStorer interface, it always
+// returns an error.
+func (s *Storage) SetEncodedObject(plumbing.EncodedObject) (plumbing.Hash, error) {
+	return plumbing.ZeroHash, &Error{Op: "SetEncodedObject"}
+}
+
+// SetReference honors the storer.ReferenceStorer interface, it always returns
+// an error.
+func (s *Storage) SetReference(*plumbing.Reference) error {
+	return &Error{Op: "SetReference"}
+}
+
+// CheckAndSetReference honors the storer.ReferenceStorer interface, it always
+// returns an error.
+func (s *Storage) CheckAndSetReference(new, old *plumbing.Reference) error {
+	return &Error{Op: "CheckAndSetReference"}
+}
+
+// RemoveReference honors the storer.ReferenceStorer interface, it always
+// returns an error.
+func (s *Storage) RemoveReference(plumbing.ReferenceName) error {
+	return &Error{Op: "RemoveReference"}
+}
+
+// PackRefs honors the storer.ReferenceStorer interface, it always returns an
+// error.
+func (s *Storage) PackRefs() error {
+	return &Error{Op: "PackRefs"}
+}
+
+// SetShallow honors the storer.ShallowStorer interface, it always returns an
+// error.
+func (s *Storage) SetShallow([]plumbing.Hash) error {
+	return &Error{Op: "SetShallow"}
+}
+
+// SetIndex honors the storer.IndexStorer interface, it always returns an
+// error.
+func (s *Storage) SetIndex(*index.Index) error {
+	return &Error{Op: "SetIndex"}
+}
+
+// SetConfig honors the config.ConfigStorer interface, it always returns an
+// error.
+func (s *Storage) SetConfig(*config.Config) error {
+	return &Error{Op: "SetConfig"}
+}
+
+// Module honors the storage.ModuleStorer interface, the returned Storer is
+// also read-only.
+func (s *Storage) Module(name string) (storage.Storer, error) {
+	m, err := s.Storer.Module(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStorage(m), nil
+}
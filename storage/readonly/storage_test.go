@@ -0,0 +1,72 @@
+package readonly
+
+import (
+	"errors"
+	"testing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type StorageSuite struct{}
+
+var _ = Suite(&StorageSuite{})
+
+func (s *StorageSuite) TestRead(c *C) {
+	base := memory.NewStorage()
+	ref := plumbing.NewReferenceFromStrings("refs/heads/master", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(base.SetReference(ref), IsNil)
+
+	obj := base.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	h, err := base.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	st := NewStorage(base)
+
+	r, err := st.Reference(ref.Name())
+	c.Assert(err, IsNil)
+	c.Assert(r.Hash(), Equals, ref.Hash())
+
+	c.Assert(st.HasEncodedObject(h), IsNil)
+}
+
+func (s *StorageSuite) TestWrite(c *C) {
+	st := NewStorage(memory.NewStorage())
+
+	ref := plumbing.NewReferenceFromStrings("refs/heads/master", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	obj := st.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+
+	_, err := st.SetEncodedObject(obj)
+	s.assertReadOnly(c, err, "SetEncodedObject")
+	s.assertReadOnly(c, st.SetReference(ref), "SetReference")
+	s.assertReadOnly(c, st.CheckAndSetReference(ref, nil), "CheckAndSetReference")
+	s.assertReadOnly(c, st.RemoveReference(ref.Name()), "RemoveReference")
+	s.assertReadOnly(c, st.PackRefs(), "PackRefs")
+	s.assertReadOnly(c, st.SetShallow(nil), "SetShallow")
+	s.assertReadOnly(c, st.SetIndex(&index.Index{Version: 2}), "SetIndex")
+	s.assertReadOnly(c, st.SetConfig(config.NewConfig()), "SetConfig")
+
+	m, err := st.Module("foo")
+	c.Assert(err, IsNil)
+	s.assertReadOnly(c, m.SetReference(ref), "SetReference")
+}
+
+func (s *StorageSuite) assertReadOnly(c *C, err error, op string) {
+	c.Assert(IsReadOnly(err), Equals, true)
+	c.Assert(err, DeepEquals, &Error{Op: op})
+	c.Assert(err, ErrorMatches, "read-only storage: "+op+" not allowed")
+}
+
+func (s *StorageSuite) TestIsReadOnly(c *C) {
+	c.Assert(IsReadOnly(ErrReadOnly), Equals, true)
+	c.Assert(IsReadOnly(&Error{Op: "SetConfig"}), Equals, true)
+	c.Assert(IsReadOnly(errors.New("foo")), Equals, false)
+	c.Assert(IsReadOnly(nil), Equals, false)
+}
@@ -1,6 +1,10 @@
 package transactional
 
-import "gopkg.in/src-d/go-git.v4/config"
+import (
+	"bytes"
+
+	"gopkg.in/src-d/go-git.v4/config"
+)
 
 // ConfigStorage implements the storer.ConfigStorage for the transactional package.
 type ConfigStorage struct {
@@ -8,6 +12,9 @@ type ConfigStorage struct {
 	temporal config.ConfigStorer
 
 	set bool
+	// original, marshaled config of the base storer when it was accessed for
+	// first time, used to detect concurrent changes when commit is called.
+	original []byte
 }
 
 // NewConfigStorage returns a new ConfigStorer based on a base storer and a
@@ -18,6 +25,10 @@ func NewConfigStorage(s, temporal config.ConfigStorer) *ConfigStorage {
 
 // SetConfig honors the storer.ConfigStorer interface.
 func (c *ConfigStorage) SetConfig(cfg *config.Config) error {
+	if err := c.track(); err != nil {
+		return err
+	}
+
 	if err := c.temporal.SetConfig(cfg); err != nil {
 		return err
 	}
@@ -29,22 +40,87 @@ func (c *ConfigStorage) SetConfig(cfg *config.Config) error {
 // Config honors the storer.ConfigStorer interface.
 func (c *ConfigStorage) Config() (*config.Config, error) {
 	if !c.set {
-		return c.ConfigStorer.Config()
+		if err := c.track(); err != nil {
+			return nil, err
+		}
+
+		// a copy is returned, so changes made in-place by the caller don't
+		// reach the base storer.
+		cfg := config.NewConfig()
+		return cfg, cfg.Unmarshal(c.original)
 	}
 
 	return c.temporal.Config()
 }
 
+func (c *ConfigStorage) track() error {
+	if c.original != nil {
+		return nil
+	}
+
+	b, err := c.marshalBase()
+	if err != nil {
+		return err
+	}
+
+	c.original = b
+	return nil
+}
+
+func (c *ConfigStorage) marshalBase() ([]byte, error) {
+	cfg, err := c.ConfigStorer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Marshal()
+}
+
 // Commit it copies the config from the temporal storage into the base storage.
+// If the config of the base storage was changed since it was read for first
+// time during the transaction, ErrConfigHasChanged is returned.
 func (c *ConfigStorage) Commit() error {
 	if !c.set {
+		c.original = nil
 		return nil
 	}
 
+	if err := c.checkConflicts(); err != nil {
+		return err
+	}
+
 	cfg, err := c.temporal.Config()
 	if err != nil {
 		return err
 	}
 
-	return c.ConfigStorer.SetConfig(cfg)
+	if err := c.ConfigStorer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	return c.Rollback()
+}
+
+func (c *ConfigStorage) checkConflicts() error {
+	if !c.set {
+		return nil
+	}
+
+	current, err := c.marshalBase()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(current, c.original) {
+		return ErrConfigHasChanged
+	}
+
+	return nil
+}
+
+// Rollback discards the config set since the last Commit.
+func (c *ConfigStorage) Rollback() error {
+	c.set = false
+	c.original = nil
+	return nil
 }
@@ -80,3 +80,40 @@ func (s *ConfigSuite) TestCommit(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(baseCfg.Core.Worktree, Equals, "bar")
 }
+
+func (s *ConfigSuite) TestCommitConflict(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+	cs := NewConfigStorage(base, temporal)
+
+	cfg, err := cs.Config()
+	c.Assert(err, IsNil)
+
+	cfg.Core.Worktree = "bar"
+	c.Assert(cs.SetConfig(cfg), IsNil)
+
+	other := config.NewConfig()
+	other.Core.Worktree = "foo"
+	c.Assert(base.SetConfig(other), IsNil)
+
+	c.Assert(cs.Commit(), Equals, ErrConfigHasChanged)
+
+	cfg, err = base.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "foo")
+}
+
+func (s *ConfigSuite) TestRollback(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+	cs := NewConfigStorage(base, temporal)
+
+	cfg := config.NewConfig()
+	cfg.Core.Worktree = "bar"
+	c.Assert(cs.SetConfig(cfg), IsNil)
+	c.Assert(cs.Rollback(), IsNil)
+
+	cfg, err := cs.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "")
+}
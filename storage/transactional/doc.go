@@ -1,7 +1,15 @@
 // Package transactional is a transactional implementation of git.Storer, it
 // demux the write and read operation of two separate storers, allowing to merge
-// content calling Storage.Commit.
+// content calling Storage.Commit or discard it calling Storage.Rollback.
 //
-// The API and functionality of this package are considered EXPERIMENTAL and is
-// not considered stable nor production ready.
+// When a transaction is committed the references, the index and the config
+// modified during the transaction are checked against the base storer, if any
+// of them was changed concurrently the commit fails without modifying the base
+// storer. The objects are transferred as a single packfile when the base
+// storer is a storer.PackfileWriter. The references are updated one by one,
+// the ones already updated being restored if a later update fails, so a
+// concurrent reader of the base storer may see a partial update.
+//
+// Rollback discards the objects written during the transaction, they are not
+// readable anymore through the transactional storer.
 package transactional
@@ -1,16 +1,22 @@
 package transactional
 
 import (
+	"reflect"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
 
-// IndexStorage implements the storer.IndexStorage for the transactional package.
+// IndexStorage implements the storer.IndexStorer for the transactional package.
 type IndexStorage struct {
 	storer.IndexStorer
 	temporal storer.IndexStorer
 
 	set bool
+	// original is a copy of the index of the base storer when it was accessed
+	// for first time, used to detect concurrent changes when commit is called.
+	original *index.Index
 }
 
 // NewIndexStorage returns a new IndexStorer based on a base storer and a
@@ -24,6 +30,10 @@ func NewIndexStorage(s, temporal storer.IndexStorer) *IndexStorage {
 
 // SetIndex honors the storer.IndexStorer interface.
 func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
+	if err := s.track(); err != nil {
+		return err
+	}
+
 	if err := s.temporal.SetIndex(idx); err != nil {
 		return err
 	}
@@ -35,22 +45,121 @@ func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
 // Index honors the storer.IndexStorer interface.
 func (s *IndexStorage) Index() (*index.Index, error) {
 	if !s.set {
-		return s.IndexStorer.Index()
+		if err := s.track(); err != nil {
+			return nil, err
+		}
+
+		// a copy is returned, so changes made in-place by the caller don't
+		// reach the base storer.
+		return copyIndex(s.original), nil
 	}
 
 	return s.temporal.Index()
 }
 
+func (s *IndexStorage) track() error {
+	if s.original != nil {
+		return nil
+	}
+
+	idx, err := s.IndexStorer.Index()
+	if err != nil {
+		return err
+	}
+
+	s.original = copyIndex(idx)
+	return nil
+}
+
 // Commit it copies the index from the temporal storage into the base storage.
+// If the index of the base storage was changed since it was read for first
+// time during the transaction, ErrIndexHasChanged is returned.
 func (s *IndexStorage) Commit() error {
 	if !s.set {
+		s.original = nil
 		return nil
 	}
 
+	if err := s.checkConflicts(); err != nil {
+		return err
+	}
+
 	idx, err := s.temporal.Index()
 	if err != nil {
 		return err
 	}
 
-	return s.IndexStorer.SetIndex(idx)
+	if err := s.IndexStorer.SetIndex(idx); err != nil {
+		return err
+	}
+
+	return s.Rollback()
+}
+
+func (s *IndexStorage) checkConflicts() error {
+	if !s.set {
+		return nil
+	}
+
+	current, err := s.IndexStorer.Index()
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(current, s.original) {
+		return ErrIndexHasChanged
+	}
+
+	return nil
+}
+
+// Rollback discards the index set since the last Commit.
+func (s *IndexStorage) Rollback() error {
+	s.set = false
+	s.original = nil
+	return nil
+}
+
+// copyIndex returns a deep copy of the index, keeping its version and its
+// extensions.
+func copyIndex(idx *index.Index) *index.Index {
+	cp := *idx
+	if idx.Entries != nil {
+		cp.Entries = make([]*index.Entry, len(idx.Entries))
+		for i, e := range idx.Entries {
+			entry := *e
+			cp.Entries[i] = &entry
+		}
+	}
+
+	if idx.Cache != nil {
+		cp.Cache = &index.Tree{}
+		if idx.Cache.Entries != nil {
+			cp.Cache.Entries = make([]index.TreeEntry, len(idx.Cache.Entries))
+			copy(cp.Cache.Entries, idx.Cache.Entries)
+		}
+	}
+
+	if idx.ResolveUndo != nil {
+		cp.ResolveUndo = &index.ResolveUndo{}
+		if idx.ResolveUndo.Entries != nil {
+			cp.ResolveUndo.Entries = make([]index.ResolveUndoEntry, len(idx.ResolveUndo.Entries))
+			for i, e := range idx.ResolveUndo.Entries {
+				cp.ResolveUndo.Entries[i] = index.ResolveUndoEntry{Path: e.Path}
+				if e.Stages != nil {
+					cp.ResolveUndo.Entries[i].Stages = make(map[index.Stage]plumbing.Hash, len(e.Stages))
+					for stage, h := range e.Stages {
+						cp.ResolveUndo.Entries[i].Stages[stage] = h
+					}
+				}
+			}
+		}
+	}
+
+	if idx.EndOfIndexEntry != nil {
+		eoie := *idx.EndOfIndexEntry
+		cp.EndOfIndexEntry = &eoie
+	}
+
+	return &cp
 }
@@ -50,3 +50,53 @@ func (s *IndexSuite) TestCommit(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(baseIndex.Version, Equals, uint32(3))
 }
+
+func (s *IndexSuite) TestCommitConflict(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+	is := NewIndexStorage(base, temporal)
+
+	idx, err := is.Index()
+	c.Assert(err, IsNil)
+
+	idx.Entries = append(idx.Entries, &index.Entry{Name: "foo"})
+	c.Assert(is.SetIndex(idx), IsNil)
+
+	other := &index.Index{Version: 2}
+	other.Entries = append(other.Entries, &index.Entry{Name: "bar"})
+	c.Assert(base.SetIndex(other), IsNil)
+
+	c.Assert(is.Commit(), Equals, ErrIndexHasChanged)
+
+	idx, err = base.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 1)
+	c.Assert(idx.Entries[0].Name, Equals, "bar")
+}
+
+func (s *IndexSuite) TestCommitV4WithExtensions(c *C) {
+	base := memory.NewStorage()
+	c.Assert(base.SetIndex(&index.Index{
+		Version: 4,
+		Entries: []*index.Entry{{Name: "foo"}},
+		Cache: &index.Tree{Entries: []index.TreeEntry{
+			{Path: "", Entries: 1, Trees: 0},
+		}},
+	}), IsNil)
+
+	is := NewIndexStorage(base, memory.NewStorage())
+
+	idx, err := is.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(4))
+	c.Assert(idx.Cache.Entries, HasLen, 1)
+
+	idx.Entries[0].Name = "bar"
+	c.Assert(is.SetIndex(idx), IsNil)
+	c.Assert(is.Commit(), IsNil)
+
+	idx, err = base.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(4))
+	c.Assert(idx.Entries[0].Name, Equals, "bar")
+}
@@ -1,31 +1,50 @@
 package transactional
 
 import (
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
 // ObjectStorage implements the storer.EncodedObjectStorer for the transactional package.
 type ObjectStorage struct {
 	storer.EncodedObjectStorer
 	temporal storer.EncodedObjectStorer
+
+	// discarded, objects of the temporal storer rolled back, they are not
+	// readable anymore and are ignored by the following calls to Commit, unless
+	// they are set again.
+	discarded map[plumbing.Hash]struct{}
 }
 
 // NewObjectStorage returns a new EncodedObjectStorer based on a base storer and
 // a temporal storer.
 func NewObjectStorage(base, temporal storer.EncodedObjectStorer) *ObjectStorage {
-	return &ObjectStorage{EncodedObjectStorer: base, temporal: temporal}
+	return &ObjectStorage{
+		EncodedObjectStorer: base,
+		temporal:            temporal,
+
+		discarded: make(map[plumbing.Hash]struct{}),
+	}
 }
 
 // SetEncodedObject honors the storer.EncodedObjectStorer interface.
 func (o *ObjectStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
-	return o.temporal.SetEncodedObject(obj)
+	h, err := o.temporal.SetEncodedObject(obj)
+	if err != nil {
+		return h, err
+	}
+
+	delete(o.discarded, h)
+	return h, nil
 }
 
 // HasEncodedObject honors the storer.EncodedObjectStorer interface.
 func (o *ObjectStorage) HasEncodedObject(h plumbing.Hash) error {
 	err := o.EncodedObjectStorer.HasEncodedObject(h)
-	if err == plumbing.ErrObjectNotFound {
+	if err == plumbing.ErrObjectNotFound && !o.isDiscarded(h) {
 		return o.temporal.HasEncodedObject(h)
 	}
 
@@ -35,7 +54,7 @@ func (o *ObjectStorage) HasEncodedObject(h plumbing.Hash) error {
 // EncodedObjectSize honors the storer.EncodedObjectStorer interface.
 func (o *ObjectStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
 	sz, err := o.EncodedObjectStorer.EncodedObjectSize(h)
-	if err == plumbing.ErrObjectNotFound {
+	if err == plumbing.ErrObjectNotFound && !o.isDiscarded(h) {
 		return o.temporal.EncodedObjectSize(h)
 	}
 
@@ -45,13 +64,18 @@ func (o *ObjectStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
 // EncodedObject honors the storer.EncodedObjectStorer interface.
 func (o *ObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
 	obj, err := o.EncodedObjectStorer.EncodedObject(t, h)
-	if err == plumbing.ErrObjectNotFound {
+	if err == plumbing.ErrObjectNotFound && !o.isDiscarded(h) {
 		return o.temporal.EncodedObject(t, h)
 	}
 
 	return obj, err
 }
 
+func (o *ObjectStorage) isDiscarded(h plumbing.Hash) bool {
+	_, ok := o.discarded[h]
+	return ok
+}
+
 // IterEncodedObjects honors the storer.EncodedObjectStorer interface.
 func (o *ObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
 	baseIter, err := o.EncodedObjectStorer.IterEncodedObjects(t)
@@ -64,21 +88,106 @@ func (o *ObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.Encode
 		return nil, err
 	}
 
+	var temporal []plumbing.EncodedObject
+	err = temporalIter.ForEach(func(obj plumbing.EncodedObject) error {
+		if !o.isDiscarded(obj.Hash()) {
+			temporal = append(temporal, obj)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
 	return storer.NewMultiEncodedObjectIter([]storer.EncodedObjectIter{
 		baseIter,
-		temporalIter,
+		storer.NewEncodedObjectSliceIter(temporal),
 	}), nil
 }
 
 // Commit it copies the objects of the temporal storage into the base storage.
+// If the base storage is a storer.PackfileWriter all the objects are written
+// as a single packfile, otherwise they are copied one by one.
 func (o *ObjectStorage) Commit() error {
-	iter, err := o.temporal.IterEncodedObjects(plumbing.AnyObject)
-	if err != nil {
+	hashes, err := o.pending()
+	if err != nil || len(hashes) == 0 {
 		return err
 	}
 
-	return iter.ForEach(func(obj plumbing.EncodedObject) error {
-		_, err := o.EncodedObjectStorer.SetEncodedObject(obj)
+	if pw, ok := o.EncodedObjectStorer.(storer.PackfileWriter); ok {
+		err = o.commitPackfile(pw, hashes)
+	} else {
+		err = o.commitObjects(hashes)
+	}
+
+	return err
+}
+
+// Rollback discards the objects written into the temporal storage since the
+// last Commit, they are not readable anymore nor copied into the base storage.
+func (o *ObjectStorage) Rollback() error {
+	hashes, err := o.pending()
+	if err != nil {
 		return err
+	}
+
+	for _, h := range hashes {
+		o.discarded[h] = struct{}{}
+	}
+
+	return nil
+}
+
+func (o *ObjectStorage) pending() ([]plumbing.Hash, error) {
+	iter, err := o.temporal.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		h := obj.Hash()
+		if o.isDiscarded(h) {
+			return nil
+		}
+
+		if err := o.EncodedObjectStorer.HasEncodedObject(h); err == nil {
+			return nil
+		}
+
+		hashes = append(hashes, h)
+		return nil
 	})
+
+	return hashes, err
+}
+
+func (o *ObjectStorage) commitPackfile(pw storer.PackfileWriter, hashes []plumbing.Hash) (err error) {
+	w, err := pw.PackfileWriter()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+
+	e := packfile.NewEncoder(w, o, false)
+	_, err = e.Encode(hashes, config.DefaultPackWindow)
+	return err
+}
+
+func (o *ObjectStorage) commitObjects(hashes []plumbing.Hash) error {
+	for _, h := range hashes {
+		obj, err := o.temporal.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return err
+		}
+
+		if _, err := o.EncodedObjectStorer.SetEncodedObject(obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
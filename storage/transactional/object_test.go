@@ -1,6 +1,8 @@
 package transactional
 
 import (
+	"io"
+
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
@@ -151,3 +153,36 @@ func (s *ObjectSuite) TestCommit(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(hashes, HasLen, 2)
 }
+
+func (s *ObjectSuite) TestRollback(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+
+	os := NewObjectStorage(base, temporal)
+
+	commit := base.NewEncodedObject()
+	commit.SetType(plumbing.CommitObject)
+
+	_, err := os.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+	c.Assert(os.Rollback(), IsNil)
+
+	c.Assert(os.HasEncodedObject(commit.Hash()), Equals, plumbing.ErrObjectNotFound)
+	_, err = os.EncodedObject(plumbing.AnyObject, commit.Hash())
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+	_, err = os.EncodedObjectSize(commit.Hash())
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	iter, err := os.IterEncodedObjects(plumbing.AnyObject)
+	c.Assert(err, IsNil)
+	_, err = iter.Next()
+	c.Assert(err, Equals, io.EOF)
+
+	// set again after the rollback, it's committed
+	_, err = os.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+	c.Assert(os.HasEncodedObject(commit.Hash()), IsNil)
+	c.Assert(os.Commit(), IsNil)
+
+	c.Assert(base.HasEncodedObject(commit.Hash()), IsNil)
+}
@@ -18,6 +18,10 @@ type ReferenceStorage struct {
 	// packRefs if true PackRefs is going to be called in the based storer when
 	// commit is called.
 	packRefs bool
+	// original, value of the references at the base storer when they were
+	// modified for first time, a nil value means that the reference didn't
+	// exist. It's used to detect concurrent changes when commit is called.
+	original map[plumbing.ReferenceName]*plumbing.Reference
 }
 
 // NewReferenceStorage returns a new ReferenceStorer based on a base storer and
@@ -27,16 +31,37 @@ func NewReferenceStorage(base, temporal storer.ReferenceStorer) *ReferenceStorag
 		ReferenceStorer: base,
 		temporal:        temporal,
 
-		deleted: make(map[plumbing.ReferenceName]struct{}, 0),
+		deleted:  make(map[plumbing.ReferenceName]struct{}, 0),
+		original: make(map[plumbing.ReferenceName]*plumbing.Reference, 0),
 	}
 }
 
 // SetReference honors the storer.ReferenceStorer interface.
 func (r *ReferenceStorage) SetReference(ref *plumbing.Reference) error {
+	if err := r.track(ref.Name()); err != nil {
+		return err
+	}
+
 	delete(r.deleted, ref.Name())
 	return r.temporal.SetReference(ref)
 }
 
+// track records the current value at the base storer of the given reference,
+// if it wasn't recorded already.
+func (r *ReferenceStorage) track(n plumbing.ReferenceName) error {
+	if _, ok := r.original[n]; ok {
+		return nil
+	}
+
+	ref, err := r.ReferenceStorer.Reference(n)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	r.original[n] = ref
+	return nil
+}
+
 // SetReference honors the storer.ReferenceStorer interface.
 func (r *ReferenceStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
 	if old == nil {
@@ -85,6 +110,13 @@ func (r ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 		return nil, err
 	}
 
+	// the references modified during the transaction are skipped from the
+	// base storer, since they are removed or held by the temporal storer.
+	baseIter = storer.NewReferenceFilteredIter(func(ref *plumbing.Reference) bool {
+		_, modified := r.original[ref.Name()]
+		return !modified
+	}, baseIter)
+
 	return storer.NewMultiReferenceIter([]storer.ReferenceIter{
 		baseIter,
 		temporalIter,
@@ -107,32 +139,134 @@ func (r ReferenceStorage) CountLooseRefs() (int, error) {
 }
 
 // PackRefs honors the storer.ReferenceStorer interface.
-func (r ReferenceStorage) PackRefs() error {
+func (r *ReferenceStorage) PackRefs() error {
 	r.packRefs = true
 	return nil
 }
 
 // RemoveReference honors the storer.ReferenceStorer interface.
-func (r ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
+func (r *ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
+	if err := r.track(n); err != nil {
+		return err
+	}
+
 	r.deleted[n] = struct{}{}
 	return r.temporal.RemoveReference(n)
 }
 
 // Commit it copies the reference information of the temporal storage into the
-// base storage.
-func (r ReferenceStorage) Commit() error {
+// base storage. Before any change is made, the references modified during the
+// transaction are checked against the base storage, if any of them was changed
+// concurrently storage.ErrReferenceHasChanged is returned and nothing is
+// copied.
+//
+// The base storer has no way to update several references at once, so they
+// are updated one by one: if an update fails, the references already updated
+// are restored to their original value, but a concurrent reader of the base
+// storer may see some of the references updated and not others.
+func (r *ReferenceStorage) Commit() error {
+	if err := r.checkConflicts(); err != nil {
+		return err
+	}
+
+	var updated []plumbing.ReferenceName
 	for name := range r.deleted {
 		if err := r.ReferenceStorer.RemoveReference(name); err != nil {
+			r.restore(updated)
 			return err
 		}
+
+		updated = append(updated, name)
 	}
 
 	iter, err := r.temporal.IterReferences()
 	if err != nil {
+		r.restore(updated)
 		return err
 	}
 
-	return iter.ForEach(func(ref *plumbing.Reference) error {
-		return r.ReferenceStorer.SetReference(ref)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if _, ok := r.original[ref.Name()]; !ok {
+			return nil
+		}
+
+		err := r.ReferenceStorer.CheckAndSetReference(ref, r.original[ref.Name()])
+		if err != nil {
+			return err
+		}
+
+		updated = append(updated, ref.Name())
+		return nil
 	})
+
+	if err != nil {
+		r.restore(updated)
+		return err
+	}
+
+	if r.packRefs {
+		if err := r.ReferenceStorer.PackRefs(); err != nil {
+			return err
+		}
+	}
+
+	return r.reset()
+}
+
+// restore sets back the given references of the base storage to their value
+// before the transaction, as well as possible, after a failed Commit.
+func (r *ReferenceStorage) restore(names []plumbing.ReferenceName) {
+	for _, name := range names {
+		if old := r.original[name]; old != nil {
+			_ = r.ReferenceStorer.SetReference(old)
+		} else {
+			_ = r.ReferenceStorer.RemoveReference(name)
+		}
+	}
+}
+
+// Rollback discards all the reference changes made since the last Commit.
+func (r *ReferenceStorage) Rollback() error {
+	return r.reset()
+}
+
+// reset removes the modified references from the temporal storage, so the
+// following reads are served by the base storage, and clears the state.
+func (r *ReferenceStorage) reset() error {
+	for name := range r.original {
+		err := r.temporal.RemoveReference(name)
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+	}
+
+	r.deleted = make(map[plumbing.ReferenceName]struct{}, 0)
+	r.original = make(map[plumbing.ReferenceName]*plumbing.Reference, 0)
+	r.packRefs = false
+	return nil
+}
+
+func (r *ReferenceStorage) checkConflicts() error {
+	for name, old := range r.original {
+		ref, err := r.ReferenceStorer.Reference(name)
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		if !equalReferences(ref, old) {
+			return storage.ErrReferenceHasChanged
+		}
+	}
+
+	return nil
+}
+
+func equalReferences(a, b *plumbing.Reference) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Type() == b.Type() &&
+		a.Hash() == b.Hash() &&
+		a.Target() == b.Target()
 }
@@ -1,8 +1,12 @@
 package transactional
 
 import (
+	"errors"
+
 	. "gopkg.in/check.v1"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
@@ -155,3 +159,120 @@ func (s *ReferenceSuite) TestCommitDelete(c *C) {
 	c.Assert(ref.Hash().String(), Equals, "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
 
 }
+
+func (s *ReferenceSuite) TestCommitConflict(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+
+	refA := plumbing.NewReferenceFromStrings("refs/a", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	refB := plumbing.NewReferenceFromStrings("refs/a", "aa9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	rs := NewReferenceStorage(base, temporal)
+	c.Assert(rs.RemoveReference("refs/a"), IsNil)
+	c.Assert(base.SetReference(refA), IsNil)
+	c.Assert(rs.Commit(), Equals, storage.ErrReferenceHasChanged)
+
+	rs = NewReferenceStorage(base, temporal)
+	c.Assert(rs.SetReference(refB), IsNil)
+	c.Assert(rs.Commit(), IsNil)
+
+	ref, err := base.Reference("refs/a")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, refB.Hash())
+}
+
+func (s *ReferenceSuite) TestRollback(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+
+	refA := plumbing.NewReferenceFromStrings("refs/a", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(base.SetReference(refA), IsNil)
+
+	rs := NewReferenceStorage(base, temporal)
+	c.Assert(rs.RemoveReference("refs/a"), IsNil)
+	c.Assert(rs.SetReference(plumbing.NewReferenceFromStrings("refs/b", refA.Hash().String())), IsNil)
+	c.Assert(rs.Rollback(), IsNil)
+
+	_, err := rs.Reference("refs/a")
+	c.Assert(err, IsNil)
+
+	_, err = rs.Reference("refs/b")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	c.Assert(rs.Commit(), IsNil)
+
+	_, err = base.Reference("refs/a")
+	c.Assert(err, IsNil)
+}
+
+func (s *ReferenceSuite) TestIterReferences(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+
+	refA := plumbing.NewReferenceFromStrings("refs/a", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	refB := plumbing.NewReferenceFromStrings("refs/b", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(base.SetReference(refA), IsNil)
+	c.Assert(base.SetReference(refB), IsNil)
+
+	rs := NewReferenceStorage(base, temporal)
+	c.Assert(rs.RemoveReference("refs/a"), IsNil)
+	c.Assert(rs.SetReference(plumbing.NewReferenceFromStrings("refs/b", "aa9968d75e48de59f0870ffb71f5e160bbbdcf52")), IsNil)
+
+	iter, err := rs.IterReferences()
+	c.Assert(err, IsNil)
+
+	var refs []*plumbing.Reference
+	c.Assert(iter.ForEach(func(ref *plumbing.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	}), IsNil)
+
+	c.Assert(refs, HasLen, 1)
+	c.Assert(refs[0].Name(), Equals, plumbing.ReferenceName("refs/b"))
+	c.Assert(refs[0].Hash().String(), Equals, "aa9968d75e48de59f0870ffb71f5e160bbbdcf52")
+}
+
+// failingReferenceStorer fails to set the given reference.
+type failingReferenceStorer struct {
+	storer.ReferenceStorer
+	fail plumbing.ReferenceName
+}
+
+var errSetReference = errors.New("set reference failed")
+
+func (s *failingReferenceStorer) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	if ref.Name() == s.fail {
+		return errSetReference
+	}
+
+	return s.ReferenceStorer.CheckAndSetReference(ref, old)
+}
+
+func (s *ReferenceSuite) TestCommitRestoresOnFailure(c *C) {
+	base := memory.NewStorage()
+	temporal := memory.NewStorage()
+
+	refA := plumbing.NewReferenceFromStrings("refs/a", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	refC := plumbing.NewReferenceFromStrings("refs/c", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(base.SetReference(refA), IsNil)
+	c.Assert(base.SetReference(refC), IsNil)
+
+	rs := NewReferenceStorage(&failingReferenceStorer{base, "refs/fail"}, temporal)
+	c.Assert(rs.RemoveReference("refs/a"), IsNil)
+	c.Assert(rs.SetReference(plumbing.NewReferenceFromStrings("refs/b", refA.Hash().String())), IsNil)
+	c.Assert(rs.SetReference(plumbing.NewReferenceFromStrings("refs/c", "aa9968d75e48de59f0870ffb71f5e160bbbdcf52")), IsNil)
+	c.Assert(rs.SetReference(plumbing.NewReferenceFromStrings("refs/fail", refA.Hash().String())), IsNil)
+
+	c.Assert(rs.Commit(), Equals, errSetReference)
+
+	ref, err := base.Reference("refs/a")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, refA.Hash())
+
+	_, err = base.Reference("refs/b")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	ref, err = base.Reference("refs/c")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, refC.Hash())
+}
@@ -1,21 +1,34 @@
 package transactional
 
 import (
+	"errors"
 	"io"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+var (
+	// ErrConfigHasChanged is returned by Commit when the config of the base
+	// storer was changed concurrently during the transaction.
+	ErrConfigHasChanged = errors.New("config has changed concurrently")
+	// ErrIndexHasChanged is returned by Commit when the index of the base
+	// storer was changed concurrently during the transaction.
+	ErrIndexHasChanged = errors.New("index has changed concurrently")
 )
 
 // Storage is a transactional implementation of git.Storer, it demux the write
 // and read operation of two separate storers, allowing to merge content calling
-// Storage.Commit.
-//
-// The API and functionality of this package are considered EXPERIMENTAL and is
-// not considered stable nor production ready.
+// Storage.Commit or to discard it calling Storage.Rollback.
 type Storage interface {
 	storage.Storer
+	// Commit copies the content of the temporal storer into the base storer.
+	// If a reference, the config or the index was changed concurrently in the
+	// base storer, an error is returned and the base storer is not modified.
 	Commit() error
+	// Rollback discards all the changes made since the last Commit.
+	Rollback() error
 }
 
 // basic implements the Storage interface.
@@ -79,6 +92,16 @@ func (s *basic) Module(name string) (storage.Storer, error) {
 
 // Commit it copies the content of the temporal storage into the base storage.
 func (s *basic) Commit() error {
+	for _, c := range []interface{ checkConflicts() error }{
+		s.ReferenceStorage,
+		s.IndexStorage,
+		s.ConfigStorage,
+	} {
+		if err := c.checkConflicts(); err != nil {
+			return err
+		}
+	}
+
 	for _, c := range []interface{ Commit() error }{
 		s.ObjectStorage,
 		s.ReferenceStorage,
@@ -94,6 +117,41 @@ func (s *basic) Commit() error {
 	return nil
 }
 
+// Rollback it discards the content of the temporal storage written since the
+// last commit.
+func (s *basic) Rollback() error {
+	for _, c := range []interface{ Rollback() error }{
+		s.ObjectStorage,
+		s.ReferenceStorage,
+		s.IndexStorage,
+		s.ShallowStorage,
+		s.ConfigStorage,
+	} {
+		if err := c.Rollback(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Do runs fn inside of a transaction over the base storer, the changes are
+// kept in memory until fn returns. If fn returns an error the transaction is
+// rolled back and the error is returned, otherwise the transaction is
+// committed.
+func Do(base storage.Storer, fn func(Storage) error) error {
+	s := NewStorage(base, memory.NewStorage())
+	if err := fn(s); err != nil {
+		if rerr := s.Rollback(); rerr != nil {
+			return rerr
+		}
+
+		return err
+	}
+
+	return s.Commit()
+}
+
 // PackfileWriter honors storage.PackfileWriter.
 func (s *packageWriter) PackfileWriter() (io.WriteCloser, error) {
 	return s.pw.PackfileWriter()
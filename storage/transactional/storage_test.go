@@ -1,6 +1,7 @@
 package transactional
 
 import (
+	"fmt"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -77,3 +78,117 @@ func (s *StorageSuite) TestTransactionalPackfileWriter(c *C) {
 	_, ok := st.(storer.PackfileWriter)
 	c.Assert(ok, Equals, tmpOK)
 }
+
+func (s *StorageSuite) TestRollback(c *C) {
+	base := memory.NewStorage()
+	temporal := s.temporal()
+	st := NewStorage(base, temporal)
+
+	commit := base.NewEncodedObject()
+	commit.SetType(plumbing.CommitObject)
+
+	_, err := st.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+
+	ref := plumbing.NewHashReference("refs/a", commit.Hash())
+	c.Assert(st.SetReference(ref), IsNil)
+
+	c.Assert(st.Rollback(), IsNil)
+
+	_, err = st.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	c.Assert(st.Commit(), IsNil)
+
+	_, err = base.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = base.EncodedObject(plumbing.AnyObject, commit.Hash())
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *StorageSuite) TestCommitConflict(c *C) {
+	base := memory.NewStorage()
+	temporal := s.temporal()
+	st := NewStorage(base, temporal)
+
+	commit := base.NewEncodedObject()
+	commit.SetType(plumbing.CommitObject)
+
+	_, err := st.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+
+	c.Assert(st.SetReference(plumbing.NewHashReference("refs/a", commit.Hash())), IsNil)
+
+	other := plumbing.NewHashReference("refs/a", plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52"))
+	c.Assert(base.SetReference(other), IsNil)
+
+	err = st.Commit()
+	c.Assert(err, Equals, storage.ErrReferenceHasChanged)
+
+	ref, err := base.Reference("refs/a")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, other.Hash())
+
+	_, err = base.EncodedObject(plumbing.AnyObject, commit.Hash())
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *StorageSuite) TestCommitPackfile(c *C) {
+	base := filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+	temporal := s.temporal()
+	st := NewStorage(base, temporal)
+
+	var hashes []plumbing.Hash
+	for _, content := range []string{"foo", "bar", "qux"} {
+		blob := st.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, err := blob.Writer()
+		c.Assert(err, IsNil)
+		_, err = w.Write([]byte(content))
+		c.Assert(err, IsNil)
+		c.Assert(w.Close(), IsNil)
+
+		h, err := st.SetEncodedObject(blob)
+		c.Assert(err, IsNil)
+		hashes = append(hashes, h)
+	}
+
+	c.Assert(st.Commit(), IsNil)
+
+	packs, err := base.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+
+	for _, h := range hashes {
+		c.Assert(base.HasEncodedObject(h), IsNil)
+	}
+}
+
+func (s *StorageSuite) TestDo(c *C) {
+	base := memory.NewStorage()
+	ref := plumbing.NewReferenceFromStrings("refs/a", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	err := Do(base, func(st Storage) error {
+		return st.SetReference(ref)
+	})
+	c.Assert(err, IsNil)
+
+	_, err = base.Reference(ref.Name())
+	c.Assert(err, IsNil)
+}
+
+func (s *StorageSuite) TestDoError(c *C) {
+	base := memory.NewStorage()
+	ref := plumbing.NewReferenceFromStrings("refs/a", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+	expected := fmt.Errorf("foo")
+	err := Do(base, func(st Storage) error {
+		c.Assert(st.SetReference(ref), IsNil)
+		return expected
+	})
+	c.Assert(err, Equals, expected)
+
+	_, err = base.Reference(ref.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
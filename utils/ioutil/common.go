@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
+	"time"
 
 	"github.com/jbenet/go-context/io"
 )
@@ -17,6 +19,12 @@ type readPeeker interface {
 
 var (
 	ErrEmptyReader = errors.New("reader is empty")
+	// ErrIdleTimeout is returned by the readers created with
+	// NewIdleTimeoutReader when a read waits too long for data.
+	ErrIdleTimeout = errors.New("read timed out waiting for data")
+	// ErrReadLimitExceeded is returned by the readers created with
+	// NewMaxSizeReader when there is more data than allowed.
+	ErrReadLimitExceeded = errors.New("read limit exceeded")
 )
 
 // NonEmptyReader takes a reader and returns it if it is not empty, or
@@ -168,3 +176,183 @@ func (r *writerOnError) Write(p []byte) (n int, err error) {
 
 	return
 }
+
+type idleTimeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	buf     []byte
+	err     error
+
+	// reads sends the buffers to the reading goroutine, which sends back the
+	// results, and closes done when it stops. They are nil while it isn't
+	// running.
+	reads   chan []byte
+	results chan readResult
+	done    chan struct{}
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+// deadlineReader is a reader with read deadlines, such as a net.Conn.
+type deadlineReader interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// NewIdleTimeoutReader returns a reader failing with ErrIdleTimeout when a
+// read doesn't return within the given timeout. If r has read deadlines, as a
+// net.Conn does, they are used. Otherwise the reads are made by a single
+// goroutine, stopping once it isn't given any read during the timeout, and
+// after a timeout the blocked read keeps running until the underlying reader
+// is closed.
+func NewIdleTimeoutReader(r io.Reader, timeout time.Duration) io.Reader {
+	return &idleTimeoutReader{r: r, timeout: timeout}
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if dr, ok := r.r.(deadlineReader); ok {
+		return r.readWithDeadline(dr, p)
+	}
+
+	// the underlying read uses its own buffer, as it may finish after
+	// giving up on it.
+	if cap(r.buf) < len(p) {
+		r.buf = make([]byte, len(p))
+	}
+
+	t := time.NewTimer(r.timeout)
+	defer t.Stop()
+
+	buf := r.buf[:len(p)]
+	for sent := false; !sent; {
+		if r.reads == nil {
+			r.reads = make(chan []byte)
+			r.results = make(chan readResult, 1)
+			r.done = make(chan struct{})
+			go r.serve(r.reads, r.results, r.done)
+		}
+
+		select {
+		case r.reads <- buf:
+			sent = true
+		case <-r.done:
+			// the goroutine stopped while idle, a new one is started
+			r.reads = nil
+		}
+	}
+
+	select {
+	case res := <-r.results:
+		return copy(p, buf[:res.n]), res.err
+	case <-t.C:
+		r.err = ErrIdleTimeout
+		return 0, r.err
+	}
+}
+
+// serve reads into the received buffers, until there isn't any during the
+// timeout.
+func (r *idleTimeoutReader) serve(reads <-chan []byte, results chan<- readResult, done chan<- struct{}) {
+	defer close(done)
+
+	t := time.NewTimer(r.timeout)
+	defer t.Stop()
+
+	for {
+		select {
+		case buf := <-reads:
+			n, err := r.r.Read(buf)
+			results <- readResult{n, err}
+		case <-t.C:
+			return
+		}
+
+		// if it expired during the read, the goroutine may stop before the
+		// next read, which just starts a new one
+		t.Reset(r.timeout)
+	}
+}
+
+func (r *idleTimeoutReader) readWithDeadline(dr deadlineReader, p []byte) (int, error) {
+	if err := dr.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		return 0, err
+	}
+
+	n, err := dr.Read(p)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		r.err = ErrIdleTimeout
+		return n, r.err
+	}
+
+	return n, err
+}
+
+type maxSizeReader struct {
+	r    io.Reader
+	left int64
+}
+
+// NewMaxSizeReader returns a reader failing with ErrReadLimitExceeded when
+// r holds more than n bytes, after returning the first n bytes.
+func NewMaxSizeReader(r io.Reader, n int64) io.Reader {
+	return &maxSizeReader{r: r, left: n}
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	if r.left < 0 {
+		return 0, ErrReadLimitExceeded
+	}
+
+	if int64(len(p)) > r.left+1 {
+		p = p[:r.left+1]
+	}
+
+	n, err := r.r.Read(p)
+	if int64(n) > r.left {
+		n, r.left = int(r.left), -1
+		return n, ErrReadLimitExceeded
+	}
+
+	r.left -= int64(n)
+	return n, err
+}
+
+type rateLimitedReader struct {
+	r     io.Reader
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+// NewRateLimitedReader returns a reader reading from r at most the given
+// number of bytes per second, on average since the first read.
+func NewRateLimitedReader(r io.Reader, bytesPerSecond int64) io.Reader {
+	return &rateLimitedReader{r: r, rate: bytesPerSecond}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+
+	if int64(len(p)) > r.rate {
+		p = p[:r.rate]
+	}
+
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+
+	expected := time.Duration(float64(r.read) / float64(r.rate) * float64(time.Second))
+	if wait := expected - time.Since(r.start); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return n, err
+}
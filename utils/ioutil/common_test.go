@@ -3,9 +3,12 @@ package ioutil
 import (
 	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 )
@@ -156,6 +159,80 @@ func (s *CommonSuite) TestNewReadCloserOnError(c *C) {
 
 	c.Assert(called, NotNil)
 }
+
+func (s *CommonSuite) TestIdleTimeoutReader(c *C) {
+	r := NewIdleTimeoutReader(strings.NewReader("foo"), time.Second)
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+}
+
+func (s *CommonSuite) TestIdleTimeoutReader_Timeout(c *C) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	r := NewIdleTimeoutReader(pr, 10*time.Millisecond)
+	_, err := r.Read(make([]byte, 1))
+	c.Assert(err, Equals, ErrIdleTimeout)
+
+	_, err = r.Read(make([]byte, 1))
+	c.Assert(err, Equals, ErrIdleTimeout)
+}
+
+func (s *CommonSuite) TestIdleTimeoutReader_SlowConsumer(c *C) {
+	r := NewIdleTimeoutReader(strings.NewReader("foo"), 10*time.Millisecond)
+	b := make([]byte, 1)
+	for _, expected := range "foo" {
+		// the reading goroutine stops, and it's started again
+		time.Sleep(30 * time.Millisecond)
+
+		n, err := r.Read(b)
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, 1)
+		c.Assert(rune(b[0]), Equals, expected)
+	}
+}
+
+func (s *CommonSuite) TestIdleTimeoutReader_Deadline(c *C) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go server.Write([]byte("foo"))
+
+	r := NewIdleTimeoutReader(client, 50*time.Millisecond)
+	b := make([]byte, 3)
+	n, err := io.ReadFull(r, b)
+	c.Assert(err, IsNil)
+	c.Assert(string(b[:n]), Equals, "foo")
+
+	_, err = r.Read(b)
+	c.Assert(err, Equals, ErrIdleTimeout)
+}
+
+func (s *CommonSuite) TestMaxSizeReader(c *C) {
+	r := NewMaxSizeReader(strings.NewReader("foo"), 3)
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+}
+
+func (s *CommonSuite) TestMaxSizeReader_Exceeded(c *C) {
+	r := NewMaxSizeReader(strings.NewReader("foobar"), 3)
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, Equals, ErrReadLimitExceeded)
+	c.Assert(string(b), Equals, "foo")
+}
+
+func (s *CommonSuite) TestRateLimitedReader(c *C) {
+	start := time.Now()
+	r := NewRateLimitedReader(strings.NewReader("foobar"), 100)
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foobar")
+	c.Assert(time.Since(start) >= 60*time.Millisecond, Equals, true)
+}
+
 func ExampleCheckClose() {
 	// CheckClose is commonly used with named return values
 	f := func() (err error) {
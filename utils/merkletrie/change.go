@@ -109,18 +109,20 @@ func (l *Changes) Add(c Change) {
 // AddRecursiveInsert adds the required changes to insert all the
 // file-like noders found in root, recursively.
 func (l *Changes) AddRecursiveInsert(root noder.Path) error {
-	return l.addRecursive(root, NewInsert)
+	return l.addRecursive(root, NewInsert, nil)
 }
 
 // AddRecursiveDelete adds the required changes to delete all the
 // file-like noders found in root, recursively.
 func (l *Changes) AddRecursiveDelete(root noder.Path) error {
-	return l.addRecursive(root, NewDelete)
+	return l.addRecursive(root, NewDelete, nil)
 }
 
 type noderToChangeFn func(noder.Path) Change // NewInsert or NewDelete
 
-func (l *Changes) addRecursive(root noder.Path, ctor noderToChangeFn) error {
+// addRecursive adds the changes for all the file-like noders found in root,
+// skipping the noders rejected by filter, if not nil.
+func (l *Changes) addRecursive(root noder.Path, ctor noderToChangeFn, filter Filter) error {
 	if !root.IsDir() {
 		l.Add(ctor(root))
 		return nil
@@ -132,13 +134,21 @@ func (l *Changes) addRecursive(root noder.Path, ctor noderToChangeFn) error {
 	}
 
 	var current noder.Path
+	next := i.Step
 	for {
-		if current, err = i.Step(); err != nil {
+		if current, err = next(); err != nil {
 			if err == io.EOF {
 				break
 			}
 			return err
 		}
+
+		next = i.Step
+		if filter != nil && !filter(current) {
+			next = i.Next
+			continue
+		}
+
 		if current.IsDir() {
 			continue
 		}
@@ -272,9 +272,35 @@ func DiffTree(fromTree, toTree noder.Noder,
 // Provided context must be non nil
 func DiffTreeContext(ctx context.Context, fromTree, toTree noder.Noder,
 	hashEqual noder.Equal) (Changes, error) {
+	return DiffTreeWithOptions(ctx, fromTree, toTree, hashEqual, nil)
+}
+
+// Filter is called with the path of the noders found while walking a
+// merkletrie, when it returns false the noder is skipped and, if it is a
+// directory, its contents are not walked. For directories it must return
+// true if any of its descendants could be accepted.
+type Filter func(noder.Path) bool
+
+// DiffTreeOptions contains the options used by DiffTreeWithOptions.
+type DiffTreeOptions struct {
+	// Filter restricts the noders compared, the changes only contain noders
+	// accepted by it. If nil, all the noders are compared.
+	Filter Filter
+}
+
+// DiffTreeWithOptions calculates the list of changes between two
+// merkletries, as DiffTreeContext does, using the given options. The
+// directories rejected by the filter are pruned from the walk, so their
+// contents are never read. Provided context must be non nil, opts can be nil.
+func DiffTreeWithOptions(ctx context.Context, fromTree, toTree noder.Noder,
+	hashEqual noder.Equal, opts *DiffTreeOptions) (Changes, error) {
+	if opts == nil {
+		opts = &DiffTreeOptions{}
+	}
+
 	ret := NewChanges()
 
-	ii, err := newDoubleIter(fromTree, toTree, hashEqual)
+	ii, err := newFilteredDoubleIter(fromTree, toTree, hashEqual, opts.Filter)
 	if err != nil {
 		return nil, err
 	}
@@ -293,14 +319,14 @@ func DiffTreeContext(ctx context.Context, fromTree, toTree noder.Noder,
 		case noMoreNoders:
 			return ret, nil
 		case onlyFromRemains:
-			if err = ret.AddRecursiveDelete(from); err != nil {
+			if err = ret.addRecursive(from, NewDelete, ii.filter); err != nil {
 				return nil, err
 			}
 			if err = ii.nextFrom(); err != nil {
 				return nil, err
 			}
 		case onlyToRemains:
-			if err = ret.AddRecursiveInsert(to); err != nil {
+			if err = ret.addRecursive(to, NewInsert, ii.filter); err != nil {
 				return nil, err
 			}
 			if err = ii.nextTo(); err != nil {
@@ -324,14 +350,14 @@ func diffNodes(changes *Changes, ii *doubleIter) error {
 	// compare their full paths as strings
 	switch from.Compare(to) {
 	case -1:
-		if err = changes.AddRecursiveDelete(from); err != nil {
+		if err = changes.addRecursive(from, NewDelete, ii.filter); err != nil {
 			return err
 		}
 		if err = ii.nextFrom(); err != nil {
 			return err
 		}
 	case 1:
-		if err = changes.AddRecursiveInsert(to); err != nil {
+		if err = changes.addRecursive(to, NewInsert, ii.filter); err != nil {
 			return err
 		}
 		if err = ii.nextTo(); err != nil {
@@ -367,10 +393,10 @@ func diffNodesSameName(changes *Changes, ii *doubleIter) error {
 			return err
 		}
 	case status.fileAndDir:
-		if err = changes.AddRecursiveDelete(from); err != nil {
+		if err = changes.addRecursive(from, NewDelete, ii.filter); err != nil {
 			return err
 		}
-		if err = changes.AddRecursiveInsert(to); err != nil {
+		if err = changes.addRecursive(to, NewInsert, ii.filter); err != nil {
 			return err
 		}
 		if err = ii.nextBoth(); err != nil {
@@ -398,14 +424,14 @@ func diffDirs(changes *Changes, ii *doubleIter) error {
 
 	switch {
 	case status.fromIsEmptyDir:
-		if err = changes.AddRecursiveInsert(to); err != nil {
+		if err = changes.addRecursive(to, NewInsert, ii.filter); err != nil {
 			return err
 		}
 		if err = ii.nextBoth(); err != nil {
 			return err
 		}
 	case status.toIsEmptyDir:
-		if err = changes.AddRecursiveDelete(from); err != nil {
+		if err = changes.addRecursive(from, NewDelete, ii.filter); err != nil {
 			return err
 		}
 		if err = ii.nextBoth(); err != nil {
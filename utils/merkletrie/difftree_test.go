@@ -12,6 +12,7 @@ import (
 
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie/internal/fsnoder"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
 
 	. "gopkg.in/check.v1"
 )
@@ -519,3 +520,37 @@ func (s *DiffTreeSuite) TestCancel(c *C) {
 	c.Assert(err, ErrorMatches, "operation canceled")
 
 }
+
+func (s *DiffTreeSuite) TestDiffTreeWithOptionsFilter(c *C) {
+	a, err := fsnoder.New("(a(b<1> c<1>) d(e<1>) f<1> g(h(i<1>)))")
+	c.Assert(err, IsNil)
+	b, err := fsnoder.New("(a(b<2> c<2>) d(e<2>) f<2> j(k<1>))")
+	c.Assert(err, IsNil)
+
+	var visited []string
+	filter := func(p noder.Path) bool {
+		visited = append(visited, p.String())
+		switch p.String() {
+		case "a", "a/b", "g", "g/h", "g/h/i":
+			return true
+		}
+
+		return false
+	}
+
+	results, err := merkletrie.DiffTreeWithOptions(ctx.Background(), a, b,
+		fsnoder.HashEqual, &merkletrie.DiffTreeOptions{Filter: filter})
+	c.Assert(err, IsNil)
+
+	obtained, err := newChanges(results)
+	c.Assert(err, IsNil)
+
+	expected, err := newChangesFromString("*a/b -g/h/i")
+	c.Assert(err, IsNil)
+	c.Assert(obtained, changesEquals, expected)
+
+	for _, p := range visited {
+		c.Assert(p, Not(Equals), "d/e")
+		c.Assert(p, Not(Equals), "j/k")
+	}
+}
@@ -29,6 +29,7 @@ type doubleIter struct {
 		current noder.Path // nil if no more nodes
 	}
 	hashEqual noder.Equal
+	filter    Filter
 }
 
 // NewdoubleIter returns a new doubleIter for the merkletries "from" and
@@ -37,28 +38,56 @@ type doubleIter struct {
 // will be initialized to the first elements in each merkletrie if any.
 func newDoubleIter(from, to noder.Noder, hashEqual noder.Equal) (
 	*doubleIter, error) {
+	return newFilteredDoubleIter(from, to, hashEqual, nil)
+}
+
+// newFilteredDoubleIter returns a new doubleIter as newDoubleIter does, but
+// skipping the noders for which the filter returns false, a nil filter
+// skips nothing.
+func newFilteredDoubleIter(from, to noder.Noder, hashEqual noder.Equal,
+	filter Filter) (*doubleIter, error) {
 	var ii doubleIter
 	var err error
 
+	ii.hashEqual = hashEqual
+	ii.filter = filter
+
 	if ii.from.iter, err = NewIter(from); err != nil {
 		return nil, fmt.Errorf("from: %s", err)
 	}
-	if ii.from.current, err = ii.from.iter.Next(); turnEOFIntoNil(err) != nil {
+	if ii.from.current, err = ii.advance(ii.from.iter, false); err != nil {
 		return nil, fmt.Errorf("from: %s", err)
 	}
 
 	if ii.to.iter, err = NewIter(to); err != nil {
 		return nil, fmt.Errorf("to: %s", err)
 	}
-	if ii.to.current, err = ii.to.iter.Next(); turnEOFIntoNil(err) != nil {
+	if ii.to.current, err = ii.advance(ii.to.iter, false); err != nil {
 		return nil, fmt.Errorf("to: %s", err)
 	}
 
-	ii.hashEqual = hashEqual
-
 	return &ii, nil
 }
 
+// advance moves the given iterator to its next noder, descending into the
+// current one if step is true, and then skips the noders rejected by the
+// filter, without descending into them.
+func (d *doubleIter) advance(iter *Iter, step bool) (noder.Path, error) {
+	var p noder.Path
+	var err error
+	if step {
+		p, err = iter.Step()
+	} else {
+		p, err = iter.Next()
+	}
+
+	for err == nil && d.filter != nil && !d.filter(p) {
+		p, err = iter.Next()
+	}
+
+	return p, turnEOFIntoNil(err)
+}
+
 func turnEOFIntoNil(e error) error {
 	if e != nil && e != io.EOF {
 		return e
@@ -82,24 +111,24 @@ func (d *doubleIter) nextBoth() error {
 // NextFrom makes d advance to the next noder in the "from" merkletrie,
 // skipping its contents if it is a directory.
 func (d *doubleIter) nextFrom() (err error) {
-	d.from.current, err = d.from.iter.Next()
-	return turnEOFIntoNil(err)
+	d.from.current, err = d.advance(d.from.iter, false)
+	return err
 }
 
 // NextTo makes d advance to the next noder in the "to" merkletrie,
 // skipping its contents if it is a directory.
 func (d *doubleIter) nextTo() (err error) {
-	d.to.current, err = d.to.iter.Next()
-	return turnEOFIntoNil(err)
+	d.to.current, err = d.advance(d.to.iter, false)
+	return err
 }
 
 // StepBoth makes d advance to the next noder in both merkletries,
 // getting deeper into directories if that is the case.
 func (d *doubleIter) stepBoth() (err error) {
-	if d.from.current, err = d.from.iter.Step(); turnEOFIntoNil(err) != nil {
+	if d.from.current, err = d.advance(d.from.iter, true); err != nil {
 		return err
 	}
-	if d.to.current, err = d.to.iter.Step(); turnEOFIntoNil(err) != nil {
+	if d.to.current, err = d.advance(d.to.iter, true); err != nil {
 		return err
 	}
 	return nil
@@ -24,6 +24,7 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	fileMode   func(path string, fi os.FileInfo) (filemode.FileMode, error)
 
 	path     string
 	hash     []byte
@@ -40,7 +41,26 @@ func NewRootNode(
 	fs billy.Filesystem,
 	submodules map[string]plumbing.Hash,
 ) noder.Noder {
-	return &node{fs: fs, submodules: submodules, isDir: true}
+	return NewRootNodeWithOptions(fs, submodules, Options{})
+}
+
+// Options are the options of the nodes of a billy.Filesystem.
+type Options struct {
+	// FileMode returns the mode of the file at the given path, used in its
+	// hash instead of the one given by its os.FileInfo, so a filesystem not
+	// supporting the executable bit or the symbolic links can be compared
+	// with the index. If nil, the mode of the os.FileInfo is used.
+	FileMode func(path string, fi os.FileInfo) (filemode.FileMode, error)
+}
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem, as NewRootNode does, with the given options.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	o Options,
+) noder.Noder {
+	return &node{fs: fs, submodules: submodules, fileMode: o.FileMode, isDir: true}
 }
 
 // Hash the hash of a filesystem is the result of concatenating the computed
@@ -122,6 +142,7 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
+		fileMode:   n.fileMode,
 
 		path:  path,
 		hash:  hash,
@@ -153,7 +174,7 @@ func (n *node) calculateHash(path string, file os.FileInfo) ([]byte, error) {
 		return nil, err
 	}
 
-	mode, err := filemode.NewFromOSFileMode(file.Mode())
+	mode, err := n.mode(path, file)
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +182,14 @@ func (n *node) calculateHash(path string, file os.FileInfo) ([]byte, error) {
 	return append(hash[:], mode.Bytes()...), nil
 }
 
+func (n *node) mode(path string, file os.FileInfo) (filemode.FileMode, error) {
+	if n.fileMode != nil {
+		return n.fileMode(path, file)
+	}
+
+	return filemode.NewFromOSFileMode(file.Mode())
+}
+
 func (n *node) doCalculateHashForRegular(path string, file os.FileInfo) (plumbing.Hash, error) {
 	f, err := n.fs.Open(path)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
 )
@@ -169,6 +170,27 @@ func (s *NoderSuite) TestDiffChangeModeNotRelevant(c *C) {
 	c.Assert(ch, HasLen, 0)
 }
 
+func (s *NoderSuite) TestDiffChangeModeWithOptions(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo"), 0755)
+
+	o := Options{FileMode: func(path string, fi os.FileInfo) (filemode.FileMode, error) {
+		return filemode.Regular, nil
+	}}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNode(fsA, nil),
+		NewRootNodeWithOptions(fsB, nil, o),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 0)
+}
+
 func (s *NoderSuite) TestDiffDirectory(c *C) {
 	dir := path.Join("qux", "bar")
 	fsA := memfs.New()
@@ -7,7 +7,9 @@ import (
 	"io"
 	stdioutil "io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -16,6 +18,7 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pathspec"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
@@ -77,6 +80,10 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		Auth:       o.Auth,
 		Progress:   o.Progress,
 		Force:      o.Force,
+
+		RetryPolicy: o.RetryPolicy,
+		Limits:      o.Limits,
+		FsckObjects: o.FsckObjects,
 	})
 
 	updated := true
@@ -111,7 +118,7 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
-	if err := w.updateHEAD(ref.Hash()); err != nil {
+	if err := w.updateHEAD(ref.Hash(), o.NoHooks); err != nil {
 		return err
 	}
 
@@ -122,6 +129,9 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
+	// as git does, the exit status of post-merge is ignored
+	_ = w.r.enabledHooks(o.NoHooks).run(HookPostMerge, nil, nil, "0")
+
 	if o.RecurseSubmodules != NoRecurseSubmodules {
 		return w.updateSubmodules(&SubmoduleUpdateOptions{
 			RecurseSubmodules: o.RecurseSubmodules,
@@ -143,10 +153,19 @@ func (w *Worktree) updateSubmodules(o *SubmoduleUpdateOptions) error {
 
 // Checkout switch branches or restore working tree files.
 func (w *Worktree) Checkout(opts *CheckoutOptions) error {
+	if len(opts.PathSpecs) != 0 {
+		return w.checkoutPathSpecs(opts)
+	}
+
 	if err := opts.Validate(); err != nil {
 		return err
 	}
 
+	var previous plumbing.Hash
+	if head, err := w.r.Head(); err == nil {
+		previous = head.Hash()
+	}
+
 	if opts.Create {
 		if err := w.createBranch(opts); err != nil {
 			return err
@@ -165,18 +184,64 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		ro.Mode = SoftReset
 	}
 
+	// the paths are verified before moving HEAD, so a malicious commit is
+	// rejected as a whole
+	var (
+		t *object.Tree
+		v *pathVerifier
+	)
+	if ro.Mode != SoftReset {
+		if t, v, err = w.verifiedTree(c); err != nil {
+			return err
+		}
+	}
+
 	if !opts.Hash.IsZero() && !opts.Create {
-		err = w.setHEADToCommit(opts.Hash)
+		err = w.setHEADToCommit(opts.Hash, opts.NoHooks)
 	} else {
-		err = w.setHEADToBranch(opts.Branch, c)
+		err = w.setHEADToBranch(opts.Branch, c, opts.NoHooks)
 	}
 
 	if err != nil {
 		return err
 	}
 
-	return w.Reset(ro)
+	if err := w.reset(ro, t, v); err != nil {
+		return err
+	}
+
+	return w.r.enabledHooks(opts.NoHooks).run(HookPostCheckout, nil, nil,
+		previous.String(), c.String(), "1")
 }
+
+// checkoutPathSpecs checks out the files matching the pathspecs, as
+// `git checkout [<commit>] -- <pathspec>...` does, without removing the
+// files missing in the commit.
+func (w *Worktree) checkoutPathSpecs(opts *CheckoutOptions) error {
+	ro := &RestoreOptions{Worktree: true, PathSpecs: opts.PathSpecs}
+	if !opts.Hash.IsZero() || opts.Branch != "" {
+		c, err := w.getCommitFromCheckoutOptions(opts)
+		if err != nil {
+			return err
+		}
+
+		ro.Source = c
+		ro.Staged = true
+	}
+
+	if err := w.restore(ro, true); err != nil {
+		return err
+	}
+
+	var head plumbing.Hash
+	if ref, err := w.r.Head(); err == nil {
+		head = ref.Hash()
+	}
+
+	return w.r.enabledHooks(opts.NoHooks).run(HookPostCheckout, nil, nil,
+		head.String(), head.String(), "0")
+}
+
 func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 	_, err := w.r.Storer.Reference(opts.Branch)
 	if err == nil {
@@ -196,7 +261,7 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		opts.Hash = ref.Hash()
 	}
 
-	return w.r.Storer.SetReference(
+	return w.r.setReferences(opts.NoHooks,
 		plumbing.NewHashReference(opts.Branch, opts.Hash),
 	)
 }
@@ -234,12 +299,12 @@ func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing
 	return plumbing.ZeroHash, fmt.Errorf("unsupported tag target %q", o.Type())
 }
 
-func (w *Worktree) setHEADToCommit(commit plumbing.Hash) error {
+func (w *Worktree) setHEADToCommit(commit plumbing.Hash, noHooks bool) error {
 	head := plumbing.NewHashReference(plumbing.HEAD, commit)
-	return w.r.Storer.SetReference(head)
+	return w.r.setReferences(noHooks, head)
 }
 
-func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash) error {
+func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash, noHooks bool) error {
 	target, err := w.r.Storer.Reference(branch)
 	if err != nil {
 		return err
@@ -252,11 +317,17 @@ func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbin
 		head = plumbing.NewHashReference(plumbing.HEAD, commit)
 	}
 
-	return w.r.Storer.SetReference(head)
+	return w.r.setReferences(noHooks, head)
 }
 
 // Reset the worktree to a specified state.
 func (w *Worktree) Reset(opts *ResetOptions) error {
+	return w.reset(opts, nil, nil)
+}
+
+// reset resets the worktree as Reset does, given the tree of the commit and
+// the pathVerifier which checked it, or nil if it isn't verified yet.
+func (w *Worktree) reset(opts *ResetOptions, t *object.Tree, v *pathVerifier) error {
 	if err := opts.Validate(w.r); err != nil {
 		return err
 	}
@@ -272,16 +343,18 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		}
 	}
 
-	if err := w.setHEADCommit(opts.Commit); err != nil {
-		return err
+	if opts.Mode == SoftReset {
+		return w.setHEADCommit(opts.Commit)
 	}
 
-	if opts.Mode == SoftReset {
-		return nil
+	if t == nil {
+		var err error
+		if t, v, err = w.verifiedTree(opts.Commit); err != nil {
+			return err
+		}
 	}
 
-	t, err := w.getTreeFromCommitHash(opts.Commit)
-	if err != nil {
+	if err := w.setHEADCommit(opts.Commit); err != nil {
 		return err
 	}
 
@@ -292,7 +365,7 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 	}
 
 	if opts.Mode == MergeReset || opts.Mode == HardReset {
-		if err := w.resetWorktree(t); err != nil {
+		if err := w.resetWorktree(t, v); err != nil {
 			return err
 		}
 	}
@@ -300,6 +373,160 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 	return nil
 }
 
+// Restore restores the files matching the pathspecs in the worktree and/or
+// the index, as `git restore` does. The matching files missing in the source
+// are removed.
+func (w *Worktree) Restore(opts *RestoreOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	return w.restore(opts, false)
+}
+
+// restore restores the files as described by opts, keeping the files missing
+// in the source if overlay is true.
+func (w *Worktree) restore(opts *RestoreOptions, overlay bool) error {
+	m, err := w.pathSpecMatcher(opts.PathSpecs)
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	v, err := w.newPathVerifier()
+	if err != nil {
+		return err
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return err
+	}
+
+	// the files are restored from the index, unless there is a source
+	// commit or the index is restored too, from HEAD
+	var files map[string]*object.File
+	if !opts.Source.IsZero() || opts.Staged {
+		if files, err = w.restoreSourceFiles(opts.Source, m); err != nil {
+			return err
+		}
+	} else {
+		files = make(map[string]*object.File)
+		for _, e := range idx.Entries {
+			if e.Mode == filemode.Submodule || !m.Match(e.Name, false) {
+				continue
+			}
+
+			blob, err := object.GetBlob(w.r.Storer, e.Hash)
+			if err != nil {
+				return err
+			}
+
+			files[e.Name] = object.NewFile(e.Name, e.Mode, blob)
+		}
+	}
+
+	b := newIndexBuilder(idx)
+	if !overlay {
+		for _, e := range idx.Entries {
+			if _, ok := files[e.Name]; ok || !m.Match(e.Name, false) {
+				continue
+			}
+
+			if opts.Staged {
+				b.Remove(e.Name)
+			}
+
+			if opts.Worktree {
+				if err := v.verify(e.Name, e.Mode); err != nil {
+					return err
+				}
+
+				if err := w.deleteFromFilesystem(e.Name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for name, f := range files {
+		if err := v.verifyPath(name, f.Mode); err != nil {
+			return err
+		}
+
+		if opts.Worktree {
+			if err := v.verifyLeadingPath(name); err != nil {
+				return err
+			}
+
+			if err := w.restoreFile(f, o); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case opts.Staged && opts.Worktree:
+			err = w.addIndexFromFile(name, f.Hash, f.Mode, o, b)
+		case opts.Staged:
+			b.Remove(name)
+			b.Add(&index.Entry{Hash: f.Hash, Name: name, Mode: f.Mode})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	b.Write(idx)
+	return w.r.Storer.SetIndex(idx)
+}
+
+// restoreSourceFiles returns the files of the commit, or HEAD if it's zero,
+// matching the pathspecs.
+func (w *Worktree) restoreSourceFiles(commit plumbing.Hash, m pathspec.Matcher) (map[string]*object.File, error) {
+	files := make(map[string]*object.File)
+	if commit.IsZero() {
+		head, err := w.r.Head()
+		if err == plumbing.ErrReferenceNotFound {
+			return files, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		commit = head.Hash()
+	}
+
+	t, err := w.getTreeFromCommitHash(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.Files().ForEach(func(f *object.File) error {
+		if f.Mode != filemode.Submodule && m.Match(f.Name, false) {
+			files[f.Name] = f
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// restoreFile writes the file in the worktree, replacing the existing one.
+func (w *Worktree) restoreFile(f *object.File, o *coreOptions) error {
+	if err := w.deleteFromFilesystem(f.Name); err != nil {
+		return err
+	}
+
+	return w.checkoutFile(f, o)
+}
+
 func (w *Worktree) resetIndex(t *object.Tree) error {
 	idx, err := w.r.Storer.Index()
 	if err != nil {
@@ -307,7 +534,7 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 	}
 	b := newIndexBuilder(idx)
 
-	changes, err := w.diffTreeWithStaging(t, true)
+	changes, err := w.diffTreeWithStaging(t, true, nil)
 	if err != nil {
 		return err
 	}
@@ -349,8 +576,8 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) resetWorktree(t *object.Tree) error {
-	changes, err := w.diffStagingWithWorktree(true)
+func (w *Worktree) resetWorktree(t *object.Tree, v *pathVerifier) error {
+	changes, err := w.diffStagingWithWorktree(true, nil)
 	if err != nil {
 		return err
 	}
@@ -361,8 +588,13 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 	}
 	b := newIndexBuilder(idx)
 
+	o, err := w.coreOptions()
+	if err != nil {
+		return err
+	}
+
 	for _, ch := range changes {
-		if err := w.checkoutChange(ch, t, b); err != nil {
+		if err := w.checkoutChange(ch, t, v, o, b); err != nil {
 			return err
 		}
 	}
@@ -371,7 +603,7 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *indexBuilder) error {
+func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, v *pathVerifier, o *coreOptions, idx *indexBuilder) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -390,19 +622,37 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		}
 
 		isSubmodule = e.Mode == filemode.Submodule
+		if err := v.verify(name, e.Mode); err != nil {
+			return err
+		}
+
+		// with core.ignorecase, the file may be in the worktree with
+		// another case, as folded by foldCaseChanges
+		if from := ch.From.String(); a == merkletrie.Modify && from != name {
+			if err := w.deleteFromFilesystem(from); err != nil {
+				return err
+			}
+
+			a = merkletrie.Insert
+		}
 	case merkletrie.Delete:
-		return rmFileAndDirIfEmpty(w.Filesystem, ch.From.String())
+		name = ch.From.String()
+		if err := v.verify(name, filemode.Empty); err != nil {
+			return err
+		}
+
+		return rmFileAndDirIfEmpty(w.Filesystem, name)
 	}
 
 	if isSubmodule {
 		return w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(name, a, t, e, o, idx)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
-	ch, err := w.diffStagingWithWorktree(false)
+	ch, err := w.diffStagingWithWorktree(false, nil)
 	if err != nil {
 		return false, err
 	}
@@ -484,6 +734,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	a merkletrie.Action,
 	t *object.Tree,
 	e *object.TreeEntry,
+	o *coreOptions,
 	idx *indexBuilder,
 ) error {
 	switch a {
@@ -503,11 +754,11 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 			return err
 		}
 
-		if err := w.checkoutFile(f); err != nil {
+		if err := w.checkoutFile(f, o); err != nil {
 			return err
 		}
 
-		return w.addIndexFromFile(name, e.Hash, idx)
+		return w.addIndexFromFile(name, e.Hash, e.Mode, o, idx)
 	}
 
 	return nil
@@ -519,14 +770,14 @@ var copyBufferPool = sync.Pool{
 	},
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+func (w *Worktree) checkoutFile(f *object.File, o *coreOptions) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
 	}
 
 	if mode&os.ModeSymlink != 0 {
-		return w.checkoutFileSymlink(f)
+		return w.checkoutFileSymlink(f, o)
 	}
 
 	from, err := f.Reader()
@@ -548,7 +799,9 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 	return
 }
 
-func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
+// checkoutFileSymlink checks out a symbolic link, or a plain file holding its
+// target if core.symlinks is false.
+func (w *Worktree) checkoutFileSymlink(f *object.File, o *coreOptions) (err error) {
 	from, err := f.Reader()
 	if err != nil {
 		return
@@ -561,24 +814,25 @@ func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
 		return
 	}
 
-	err = w.Filesystem.Symlink(string(bytes), f.Name)
+	if o.symlinks {
+		err = w.Filesystem.Symlink(string(bytes), f.Name)
 
-	// On windows, this might fail.
-	// Follow Git on Windows behavior by writing the link as it is.
-	if err != nil && isSymlinkWindowsNonAdmin(err) {
-		mode, _ := f.Mode.ToOSFileMode()
-
-		to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
-		if err != nil {
-			return err
+		// On windows, this might fail.
+		// Follow Git on Windows behavior by writing the link as it is.
+		if err == nil || !isSymlinkWindowsNonAdmin(err) {
+			return
 		}
+	}
 
-		defer ioutil.CheckClose(to, &err)
-
-		_, err = to.Write(bytes)
+	to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
 		return err
 	}
-	return
+
+	defer ioutil.CheckClose(to, &err)
+
+	_, err = to.Write(bytes)
+	return err
 }
 
 func (w *Worktree) addIndexFromTreeEntry(name string, f *object.TreeEntry, idx *indexBuilder) error {
@@ -591,14 +845,14 @@ func (w *Worktree) addIndexFromTreeEntry(name string, f *object.TreeEntry, idx *
 	return nil
 }
 
-func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, idx *indexBuilder) error {
+func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, m filemode.FileMode, o *coreOptions, idx *indexBuilder) error {
 	idx.Remove(name)
 	fi, err := w.Filesystem.Lstat(name)
 	if err != nil {
 		return err
 	}
 
-	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	mode, err := o.mode(fi, m)
 	if err != nil {
 		return err
 	}
@@ -717,190 +971,184 @@ func (w *Worktree) readGitmodulesFile() (*config.Modules, error) {
 // Clean the worktree by removing untracked files.
 // An empty dir could be removed - this is what  `git clean -f -d .` does.
 func (w *Worktree) Clean(opts *CleanOptions) error {
-	s, err := w.Status()
-	if err != nil {
-		return err
+	_, err := w.CleanPaths(opts)
+	return err
+}
+
+// CleanPaths cleans the worktree as Clean does, returning the paths removed,
+// or the ones that would be removed if DryRun is set, relative to the root of
+// the worktree. The whole directories removed end with a slash.
+func (w *Worktree) CleanPaths(opts *CleanOptions) ([]string, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
 
-	root := ""
-	files, err := w.Filesystem.ReadDir(root)
+	c, err := w.newCleaner(opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if _, err := c.clean(""); err != nil {
+		return nil, err
 	}
-	return w.doClean(s, opts, root, files)
+
+	return c.paths, nil
 }
 
-func (w *Worktree) doClean(status Status, opts *CleanOptions, dir string, files []os.FileInfo) error {
-	for _, fi := range files {
-		if fi.Name() == GitDirName {
-			continue
-		}
+func (w *Worktree) newCleaner(opts *CleanOptions) (*cleaner, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
 
-		// relative path under the root
-		path := filepath.Join(dir, fi.Name())
-		if fi.IsDir() {
-			if !opts.Dir {
-				continue
-			}
+	c := &cleaner{
+		fs:      w.Filesystem,
+		opts:    opts,
+		entries: make(map[string]bool, len(idx.Entries)),
+		dirs:    make(map[string]bool),
+	}
 
-			subfiles, err := w.Filesystem.ReadDir(path)
-			if err != nil {
-				return err
-			}
-			err = w.doClean(status, opts, path, subfiles)
-			if err != nil {
-				return err
-			}
-		} else {
-			if status.IsUntracked(path) {
-				if err := w.Filesystem.Remove(path); err != nil {
-					return err
-				}
-			}
+	for _, e := range idx.Entries {
+		c.entries[e.Name] = true
+		for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+			c.dirs[dir] = true
 		}
 	}
 
-	if opts.Dir {
-		return doCleanDirectories(w.Filesystem, dir)
+	var patterns []gitignore.Pattern
+	if !opts.Ignored {
+		patterns, err = gitignore.ReadPatterns(w.Filesystem, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, w.Excludes...)
 	}
-	return nil
-}
 
-// GrepResult is structure of a grep result.
-type GrepResult struct {
-	// FileName is the name of file which contains match.
-	FileName string
-	// LineNumber is the line number of a file at which a match was found.
-	LineNumber int
-	// Content is the content of the file at the matching line.
-	Content string
-	// TreeName is the name of the tree (reference name/commit hash) at
-	// which the match was performed.
-	TreeName string
+	for _, p := range opts.Exclude {
+		patterns = append(patterns, gitignore.ParsePattern(p, nil))
+	}
+
+	c.ignore = gitignore.NewMatcher(patterns)
+	c.pathSpecs, err = w.pathSpecMatcher(opts.PathSpecs)
+	return c, err
 }
 
-func (gr GrepResult) String() string {
-	return fmt.Sprintf("%s:%s:%d:%s", gr.TreeName, gr.FileName, gr.LineNumber, gr.Content)
+type cleaner struct {
+	fs        billy.Filesystem
+	opts      *CleanOptions
+	entries   map[string]bool
+	dirs      map[string]bool
+	ignore    gitignore.Matcher
+	pathSpecs pathspec.Matcher
+	paths     []string
 }
 
-// Grep performs grep on a worktree.
-func (w *Worktree) Grep(opts *GrepOptions) ([]GrepResult, error) {
-	if err := opts.Validate(w); err != nil {
-		return nil, err
+// clean cleans the given directory, returning true if everything in it has
+// been removed.
+func (c *cleaner) clean(dir string) (bool, error) {
+	files, err := c.fs.ReadDir(dir)
+	if err != nil {
+		return false, err
 	}
 
-	// Obtain commit hash from options (CommitHash or ReferenceName).
-	var commitHash plumbing.Hash
-	// treeName contains the value of TreeName in GrepResult.
-	var treeName string
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	all := true
+	for _, fi := range files {
+		name := path.Join(dir, fi.Name())
+		if fi.Name() == GitDirName || c.entries[name] {
+			all = false
+			continue
+		}
+
+		var removed bool
+		if fi.IsDir() {
+			removed, err = c.cleanDir(name)
+		} else {
+			removed, err = c.cleanFile(name)
+		}
 
-	if opts.ReferenceName != "" {
-		ref, err := w.r.Reference(opts.ReferenceName, true)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-		commitHash = ref.Hash()
-		treeName = opts.ReferenceName.String()
-	} else if !opts.CommitHash.IsZero() {
-		commitHash = opts.CommitHash
-		treeName = opts.CommitHash.String()
+
+		all = all && removed
 	}
 
-	// Obtain a tree from the commit hash and get a tracked files iterator from
-	// the tree.
-	tree, err := w.getTreeFromCommitHash(commitHash)
-	if err != nil {
-		return nil, err
+	return all, nil
+}
+
+func (c *cleaner) cleanFile(name string) (bool, error) {
+	if !c.pathSpecs.Match(name, false) || !c.isRemovable(name, false) {
+		return false, nil
 	}
-	fileiter := tree.Files()
 
-	return findMatchInFiles(fileiter, treeName, opts)
+	return true, c.remove(name, name)
 }
 
-// findMatchInFiles takes a FileIter, worktree name and GrepOptions, and
-// returns a slice of GrepResult containing the result of regex pattern matching
-// in content of all the files.
-func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *GrepOptions) ([]GrepResult, error) {
-	var results []GrepResult
+func (c *cleaner) cleanDir(name string) (bool, error) {
+	if !c.pathSpecs.Match(name, true) {
+		return false, nil
+	}
 
-	err := fileiter.ForEach(func(file *object.File) error {
-		var fileInPathSpec bool
+	if c.dirs[name] {
+		_, err := c.clean(name)
+		return false, err
+	}
 
-		// When no pathspecs are provided, search all the files.
-		if len(opts.PathSpecs) == 0 {
-			fileInPathSpec = true
-		}
+	if !c.opts.Dir || !c.opts.OnlyIgnored && c.isIgnored(name, true) {
+		return false, nil
+	}
 
-		// Check if the file name matches with the pathspec. Break out of the
-		// loop once a match is found.
-		for _, pathSpec := range opts.PathSpecs {
-			if pathSpec != nil && pathSpec.MatchString(file.Name) {
-				fileInPathSpec = true
-				break
-			}
+	if _, err := c.fs.Lstat(path.Join(name, GitDirName)); err == nil {
+		if !c.opts.Nested || !c.pathSpecs.Match(name, false) {
+			return false, nil
 		}
 
-		// If the file does not match with any of the pathspec, skip it.
-		if !fileInPathSpec {
-			return nil
+		// another repository is removed as a whole, including its git dir
+		c.paths = append(c.paths, name+"/")
+		if c.opts.DryRun {
+			return true, nil
 		}
 
-		grepResults, err := findMatchInFile(file, treeName, opts)
-		if err != nil {
-			return err
-		}
-		results = append(results, grepResults...)
+		return true, util.RemoveAll(c.fs, name)
+	}
 
-		return nil
-	})
+	n := len(c.paths)
+	all, err := c.clean(name)
+	if err != nil || !all || !c.pathSpecs.Match(name, false) {
+		return false, err
+	}
 
-	return results, err
-}
+	if c.opts.OnlyIgnored && n == len(c.paths) && !c.isIgnored(name, true) {
+		return false, nil
+	}
 
-// findMatchInFile takes a single File, worktree name and GrepOptions,
-// and returns a slice of GrepResult containing the result of regex pattern
-// matching in the given file.
-func findMatchInFile(file *object.File, treeName string, opts *GrepOptions) ([]GrepResult, error) {
-	var grepResults []GrepResult
+	// the whole directory is removed, so it's reported instead of its content
+	c.paths = c.paths[:n]
+	return true, c.remove(name, name+"/")
+}
 
-	content, err := file.Contents()
-	if err != nil {
-		return grepResults, err
+func (c *cleaner) isRemovable(name string, isDir bool) bool {
+	if c.opts.OnlyIgnored {
+		return c.isIgnored(name, isDir)
 	}
 
-	// Split the file content and parse line-by-line.
-	contentByLine := strings.Split(content, "\n")
-	for lineNum, cnt := range contentByLine {
-		addToResult := false
+	return !c.isIgnored(name, isDir)
+}
 
-		// Match the patterns and content. Break out of the loop once a
-		// match is found.
-		for _, pattern := range opts.Patterns {
-			if pattern != nil && pattern.MatchString(cnt) {
-				// Add to result only if invert match is not enabled.
-				if !opts.InvertMatch {
-					addToResult = true
-					break
-				}
-			} else if opts.InvertMatch {
-				// If matching fails, and invert match is enabled, add to
-				// results.
-				addToResult = true
-				break
-			}
-		}
+func (c *cleaner) isIgnored(name string, isDir bool) bool {
+	return c.ignore.Match(strings.Split(name, "/"), isDir)
+}
 
-		if addToResult {
-			grepResults = append(grepResults, GrepResult{
-				FileName:   file.Name,
-				LineNumber: lineNum + 1,
-				Content:    cnt,
-				TreeName:   treeName,
-			})
-		}
+func (c *cleaner) remove(name, reported string) error {
+	c.paths = append(c.paths, reported)
+	if c.opts.DryRun {
+		return nil
 	}
 
-	return grepResults, nil
+	return c.fs.Remove(name)
 }
 
 func rmFileAndDirIfEmpty(fs billy.Filesystem, name string) error {
@@ -2,6 +2,8 @@ package git
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"path"
 	"sort"
 	"strings"
@@ -9,9 +11,13 @@ import (
 	"golang.org/x/crypto/openpgp"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie/filesystem"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie/noder"
 
 	"gopkg.in/src-d/go-billy.v4"
 )
@@ -29,6 +35,16 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		}
 	}
 
+	hooks := w.r.enabledHooks(opts.NoHooks)
+	if err := hooks.run(HookPreCommit, nil, hooks.commitEnv()); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg, err := hooks.commitMsg(msg)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -49,7 +65,27 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
-	return commit, w.updateHEAD(commit)
+	var amended plumbing.Hash
+	if opts.Amend {
+		head, err := w.r.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		amended = head.Hash()
+	}
+
+	if err := w.updateHEAD(commit, opts.NoHooks); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if opts.Amend {
+		// as git does, the exit status of post-rewrite is ignored
+		stdin := []byte(fmt.Sprintf("%s %s\n", amended, commit))
+		_ = hooks.run(HookPostRewrite, stdin, nil, "amend")
+	}
+
+	return commit, nil
 }
 
 func (w *Worktree) autoAddModifiedAndDeleted() error {
@@ -71,7 +107,138 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 	return nil
 }
 
-func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
+// SnapshotTree stores the contents of the worktree as a tree, returning its
+// hash, as `git stash create` does with the untracked files. The tracked files
+// and the untracked ones not ignored are stored, limited to the pathspecs of
+// the options, if any. Neither the index nor the references are changed.
+func (w *Worktree) SnapshotTree(opts *SnapshotOptions) (plumbing.Hash, error) {
+	m, err := w.pathSpecMatcher(opts.PathSpecs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	submodules, err := w.getSubmodulesStatus()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ignored := gitignore.NewMatcher(append(patterns, w.Excludes...))
+
+	// the tracked files are stored even if ignored, as the directories
+	// holding them
+	tracked := make(map[string]filemode.FileMode, len(idx.Entries))
+	trackedDirs := make(map[string]bool)
+	for _, e := range idx.Entries {
+		tracked[e.Name] = e.Mode
+		for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+			trackedDirs[dir] = true
+		}
+	}
+
+	root := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, filesystem.Options{
+		FileMode: o.fileModeFunc(idx),
+	})
+
+	iter, err := merkletrie.NewIter(root)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	snapshot := &index.Index{}
+	descend := true
+	for {
+		var p noder.Path
+		if descend {
+			p, err = iter.Step()
+		} else {
+			p, err = iter.Next()
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		name := p.String()
+		if p.IsDir() {
+			descend = m.Match(name, true) &&
+				(trackedDirs[name] || !ignored.Match(strings.Split(name, "/"), true))
+			continue
+		}
+
+		descend = true
+		mode, isTracked := tracked[name]
+		if !m.Match(name, false) || !isTracked && ignored.Match(strings.Split(name, "/"), false) {
+			continue
+		}
+
+		e, err := w.snapshotEntry(p, o, mode, submodules)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		snapshot.Entries = append(snapshot.Entries, e)
+	}
+
+	h := &buildTreeHelper{
+		fs: w.Filesystem,
+		s:  w.r.Storer,
+	}
+
+	return h.BuildTree(snapshot)
+}
+
+// snapshotEntry returns the entry of a file of the worktree, storing its blob
+// unless the storer already has it. The mode of its index entry, if any, is
+// indexed.
+func (w *Worktree) snapshotEntry(p noder.Path, o *coreOptions, indexed filemode.FileMode,
+	submodules map[string]plumbing.Hash) (*index.Entry, error) {
+	name := p.String()
+	if h, ok := submodules[name]; ok {
+		return &index.Entry{Name: name, Hash: h, Mode: filemode.Submodule}, nil
+	}
+
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := o.mode(fi, indexed)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hash of a file node is the one of its blob, followed by its mode
+	var h plumbing.Hash
+	copy(h[:], p.Hash())
+	if err := w.r.Storer.HasEncodedObject(h); err != nil {
+		if h, err = w.copyFileToStorage(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &index.Entry{Name: name, Hash: h, Mode: mode}, nil
+}
+
+func (w *Worktree) updateHEAD(commit plumbing.Hash, noHooks bool) error {
 	head, err := w.r.Storer.Reference(plumbing.HEAD)
 	if err != nil {
 		return err
@@ -83,7 +250,7 @@ func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
 	}
 
 	ref := plumbing.NewHashReference(name, commit)
-	return w.r.Storer.SetReference(ref)
+	return w.r.setReferences(noHooks, ref)
 }
 
 func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumbing.Hash) (plumbing.Hash, error) {
@@ -249,6 +249,118 @@ func (s *WorktreeSuite) TestCommitTreeSort(c *C) {
 	c.Assert(err, IsNil, Commentf("%s", buf.Bytes()))
 }
 
+func (s *WorktreeSuite) TestSnapshotTree(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	util.WriteFile(fs, "keep.log", []byte("keep"), 0644)
+	c.Assert(w.AddWithOptions(&AddOptions{}), IsNil)
+
+	commit, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, ".gitignore", []byte("*.log\nbuild/\n"), 0644)
+	util.WriteFile(fs, "foo", []byte("bar"), 0644)
+	util.WriteFile(fs, "bar", []byte("bar"), 0644)
+	util.WriteFile(fs, "dir/baz", []byte("baz"), 0644)
+	util.WriteFile(fs, "ignored.log", []byte("ignored"), 0644)
+	util.WriteFile(fs, "build/out", []byte("out"), 0644)
+
+	before, err := w.Status()
+	c.Assert(err, IsNil)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	entries := len(idx.Entries)
+
+	h, err := w.SnapshotTree(&SnapshotOptions{})
+	c.Assert(err, IsNil)
+
+	c.Assert(snapshotFiles(c, r, h), DeepEquals, map[string]string{
+		".gitignore": "*.log\nbuild/\n",
+		"bar":        "bar",
+		"dir/baz":    "baz",
+		"foo":        "bar",
+		"keep.log":   "keep",
+	})
+
+	after, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(after, DeepEquals, before)
+
+	idx, err = r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, entries)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, commit)
+}
+
+func (s *WorktreeSuite) TestSnapshotTreeClean(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	util.WriteFile(fs, "dir/run.sh", []byte("run"), 0755)
+	c.Assert(w.AddWithOptions(&AddOptions{}), IsNil)
+
+	hash, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+
+	h, err := w.SnapshotTree(&SnapshotOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, commit.TreeHash)
+}
+
+func (s *WorktreeSuite) TestSnapshotTreePathSpecs(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	util.WriteFile(fs, "dir/bar", []byte("bar"), 0644)
+	util.WriteFile(fs, "dir/baz", []byte("baz"), 0644)
+
+	h, err := w.SnapshotTree(&SnapshotOptions{PathSpecs: []string{"dir", ":!dir/baz"}})
+	c.Assert(err, IsNil)
+
+	c.Assert(snapshotFiles(c, r, h), DeepEquals, map[string]string{
+		"dir/bar": "bar",
+	})
+}
+
+// snapshotFiles returns the contents of the files of a tree, by path.
+func snapshotFiles(c *C, r *Repository, h plumbing.Hash) map[string]string {
+	t, err := r.TreeObject(h)
+	c.Assert(err, IsNil)
+
+	files := make(map[string]string)
+	err = t.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		files[f.Name] = content
+		return err
+	})
+	c.Assert(err, IsNil)
+
+	return files
+}
+
 func assertStorageStatus(
 	c *C, r *Repository,
 	treesCount, blobCount, commitCount int, head plumbing.Hash,
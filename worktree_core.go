@@ -0,0 +1,163 @@
+package git
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+const (
+	fileModeKey   = "filemode"
+	symlinksKey   = "symlinks"
+	ignoreCaseKey = "ignorecase"
+)
+
+// coreOptions are the core.filemode, core.symlinks and core.ignorecase
+// options, telling what the filesystem of the worktree supports.
+type coreOptions struct {
+	// fileMode is whether the executable bit of the files can be trusted.
+	fileMode bool
+	// symlinks is whether the symbolic links are supported, otherwise they
+	// are checked out as plain files holding their target.
+	symlinks bool
+	// ignoreCase is whether the filesystem is case insensitive, so the
+	// paths only differing in their case are the same file.
+	ignoreCase bool
+}
+
+// coreOptions returns the coreOptions of the worktree. As git does, the
+// executable bit and the symbolic links are trusted by default.
+func (w *Worktree) coreOptions() (*coreOptions, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := cfg.Raw.Section(coreSection).Options
+	return &coreOptions{
+		fileMode:   configBool(opts.Get(fileModeKey), true),
+		symlinks:   configBool(opts.Get(symlinksKey), true),
+		ignoreCase: configBool(opts.Get(ignoreCaseKey), false),
+	}, nil
+}
+
+// mode returns the mode of a file of the worktree with the given FileInfo,
+// whose mode in the index, or in the tree being checked out, is indexed, as
+// git does: a regular file keeps the indexed mode if the filesystem can't tell
+// whether it is executable or a symbolic link.
+func (o *coreOptions) mode(fi os.FileInfo, indexed filemode.FileMode) (filemode.FileMode, error) {
+	m, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil || !isPlainFile(m) {
+		return m, err
+	}
+
+	if !o.symlinks && indexed == filemode.Symlink {
+		return filemode.Symlink, nil
+	}
+
+	if o.fileMode {
+		return m, nil
+	}
+
+	if isPlainFile(indexed) {
+		return indexed, nil
+	}
+
+	return filemode.Regular, nil
+}
+
+// isPlainFile returns whether the mode is the one of a file, executable or
+// not, but not a symbolic link.
+func isPlainFile(m filemode.FileMode) bool {
+	return m.IsFile() && m != filemode.Symlink
+}
+
+// fileModeFunc returns the function giving the modes of the files of the
+// worktree, compared with the entries of the given index, or nil if the modes
+// of the filesystem are trusted.
+func (o *coreOptions) fileModeFunc(idx *index.Index) func(string, os.FileInfo) (filemode.FileMode, error) {
+	if o.fileMode && o.symlinks {
+		return nil
+	}
+
+	modes := make(map[string]filemode.FileMode, len(idx.Entries))
+	for _, e := range idx.Entries {
+		modes[e.Name] = e.Mode
+	}
+
+	return func(name string, fi os.FileInfo) (filemode.FileMode, error) {
+		return o.mode(fi, modes[name])
+	}
+}
+
+// indexName returns the name of the entry of the index matching name,
+// ignoring the case with core.ignorecase, or name if there is none.
+func (o *coreOptions) indexName(idx *index.Index, name string) string {
+	if !o.ignoreCase {
+		return name
+	}
+
+	if _, err := idx.Entry(name); err == nil {
+		return name
+	}
+
+	for _, e := range idx.Entries {
+		if strings.EqualFold(e.Name, name) {
+			return e.Name
+		}
+	}
+
+	return name
+}
+
+// foldCaseChanges merges the deletion and the insertion of two paths only
+// differing in their case, as a case insensitive filesystem reports a file
+// renamed in the index, into a modification, dropped if the contents are the
+// same.
+func foldCaseChanges(changes merkletrie.Changes) merkletrie.Changes {
+	inserted := make(map[string]int)
+	for i, ch := range changes {
+		if len(ch.From) == 0 && len(ch.To) != 0 {
+			inserted[strings.ToLower(ch.To.String())] = i
+		}
+	}
+
+	pairs := make(map[int]int)
+	merged := make(map[int]bool)
+	for i, ch := range changes {
+		if len(ch.From) == 0 || len(ch.To) != 0 {
+			continue
+		}
+
+		j, ok := inserted[strings.ToLower(ch.From.String())]
+		if !ok || merged[j] {
+			continue
+		}
+
+		pairs[i] = j
+		merged[j] = true
+	}
+
+	var res merkletrie.Changes
+	for i, ch := range changes {
+		if merged[i] {
+			continue
+		}
+
+		if j, ok := pairs[i]; ok {
+			to := changes[j].To
+			if diffTreeIsEquals(ch.From, to) {
+				continue
+			}
+
+			ch = merkletrie.Change{From: ch.From, To: to}
+		}
+
+		res = append(res, ch)
+	}
+
+	return res
+}
@@ -0,0 +1,252 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+// initWithCoreOption returns the worktree of a new repository with the given
+// core option.
+func initWithCoreOption(c *C, key, value string) (*Repository, *Worktree, billy.Filesystem) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption(key, value)
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	return r, w, fs
+}
+
+func (s *WorktreeSuite) TestStatusFileModeFalse(c *C) {
+	for _, trust := range []string{"true", "false"} {
+		r, w, fs := initWithCoreOption(c, "filemode", trust)
+
+		commit := storeCommit(c, r.Storer, storeTree(c, r.Storer, object.TreeEntry{
+			Name: "run.sh", Mode: filemode.Executable, Hash: storeBlob(c, r.Storer, "foo"),
+		}))
+
+		c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+		// the filesystem loses the executable bit
+		c.Assert(fs.Remove("run.sh"), IsNil)
+		c.Assert(util.WriteFile(fs, "run.sh", []byte("foo"), 0644), IsNil)
+
+		status, err := w.Status()
+		c.Assert(err, IsNil)
+		c.Assert(status.IsClean(), Equals, trust == "false", Commentf(trust))
+
+		c.Assert(util.WriteFile(fs, "run.sh", []byte("bar"), 0644), IsNil)
+		_, err = w.Add("run.sh")
+		c.Assert(err, IsNil)
+
+		idx, err := r.Storer.Index()
+		c.Assert(err, IsNil)
+
+		e, err := idx.Entry("run.sh")
+		c.Assert(err, IsNil)
+		if trust == "false" {
+			c.Assert(e.Mode, Equals, filemode.Executable)
+		} else {
+			c.Assert(e.Mode, Equals, filemode.Regular)
+		}
+	}
+}
+
+func (s *WorktreeSuite) TestCheckoutSymlinksFalse(c *C) {
+	r, w, fs := initWithCoreOption(c, "symlinks", "false")
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer,
+		object.TreeEntry{Name: "foo", Mode: filemode.Regular, Hash: storeBlob(c, r.Storer, "foo")},
+		object.TreeEntry{Name: "link", Mode: filemode.Symlink, Hash: storeBlob(c, r.Storer, "foo")},
+	))
+
+	c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+	fi, err := fs.Lstat("link")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink, Equals, os.FileMode(0))
+
+	c.Assert(string(readFile(c, fs, "link")), Equals, "foo")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry("link")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Symlink)
+
+	c.Assert(util.WriteFile(fs, "link", []byte("bar"), 0644), IsNil)
+	_, err = w.Add("link")
+	c.Assert(err, IsNil)
+
+	idx, err = r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err = idx.Entry("link")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Symlink)
+}
+
+func (s *WorktreeSuite) TestStatusIgnoreCase(c *C) {
+	for _, ignore := range []string{"true", "false"} {
+		r, w, fs := initWithCoreOption(c, "ignorecase", ignore)
+
+		commit := storeCommit(c, r.Storer, storeTree(c, r.Storer, object.TreeEntry{
+			Name: "readme", Mode: filemode.Regular, Hash: storeBlob(c, r.Storer, "foo"),
+		}))
+
+		c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+		// as a case insensitive filesystem would report it
+		c.Assert(fs.Rename("readme", "README"), IsNil)
+
+		status, err := w.Status()
+		c.Assert(err, IsNil)
+		c.Assert(status.IsClean(), Equals, ignore == "true", Commentf(ignore))
+	}
+}
+
+func (s *WorktreeSuite) TestAddRemoveIgnoreCase(c *C) {
+	r, w, fs := initWithCoreOption(c, "ignorecase", "true")
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer, object.TreeEntry{
+		Name: "readme", Mode: filemode.Regular, Hash: storeBlob(c, r.Storer, "foo"),
+	}))
+
+	c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+	c.Assert(fs.Remove("readme"), IsNil)
+	c.Assert(util.WriteFile(fs, "README", []byte("bar"), 0644), IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("README").Worktree, Equals, Modified)
+
+	h, err := w.Add("README")
+	c.Assert(err, IsNil)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 1)
+	c.Assert(idx.Entries[0].Name, Equals, "readme")
+	c.Assert(idx.Entries[0].Hash, Equals, h)
+
+	_, err = w.Remove("README")
+	c.Assert(err, IsNil)
+
+	idx, err = r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 0)
+}
+
+func (s *WorktreeSuite) TestResetIgnoreCase(c *C) {
+	r, w, fs := initWithCoreOption(c, "ignorecase", "true")
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer, object.TreeEntry{
+		Name: "readme", Mode: filemode.Regular, Hash: storeBlob(c, r.Storer, "foo"),
+	}))
+
+	c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+	c.Assert(fs.Remove("readme"), IsNil)
+	c.Assert(util.WriteFile(fs, "README", []byte("bar"), 0644), IsNil)
+
+	c.Assert(w.Reset(&ResetOptions{Commit: commit, Mode: HardReset}), IsNil)
+
+	files, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+	c.Assert(files[0].Name(), Equals, "readme")
+
+	c.Assert(string(readFile(c, fs, "readme")), Equals, "foo")
+}
+
+func (s *WorktreeSuite) TestCheckoutIgnoreCaseCollision(c *C) {
+	for _, ignore := range []string{"true", "false"} {
+		r, w, _ := initWithCoreOption(c, "ignorecase", ignore)
+
+		foo := storeBlob(c, r.Storer, "foo")
+		commit := storeCommit(c, r.Storer, storeTree(c, r.Storer,
+			object.TreeEntry{Name: "README", Mode: filemode.Regular, Hash: foo},
+			object.TreeEntry{Name: "readme", Mode: filemode.Regular, Hash: foo},
+		))
+
+		err := w.Checkout(&CheckoutOptions{Hash: commit, Force: true})
+		if ignore == "true" {
+			c.Assert(err, ErrorMatches, `invalid path "readme": collides with "README"`)
+		} else {
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *WorktreeSuite) TestCheckoutIgnoreCaseDirectories(c *C) {
+	r, w, fs := initWithCoreOption(c, "ignorecase", "true")
+
+	foo := storeBlob(c, r.Storer, "foo")
+	dir := func(name string) plumbing.Hash {
+		return storeTree(c, r.Storer, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: foo})
+	}
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer,
+		object.TreeEntry{Name: "DOCS", Mode: filemode.Dir, Hash: dir("a")},
+		object.TreeEntry{Name: "docs", Mode: filemode.Dir, Hash: dir("b")},
+	))
+
+	c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+	_, err := fs.Stat("docs/b")
+	c.Assert(err, IsNil)
+}
+
+func (s *WorktreeSuite) TestCheckoutSymlinksFalseOS(c *C) {
+	dir, err := ioutil.TempDir("", "checkout-symlinks")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("symlinks", "false")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer, object.TreeEntry{
+		Name: "link", Mode: filemode.Symlink, Hash: storeBlob(c, r.Storer, "../outside"),
+	}))
+
+	c.Assert(w.Checkout(&CheckoutOptions{Hash: commit, Force: true}), IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "link"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "../outside")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
@@ -0,0 +1,503 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pathspec"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// GrepResult is structure of a grep result.
+type GrepResult struct {
+	// FileName is the name of file which contains match.
+	FileName string
+	// LineNumber is the line number of a file at which a match was found.
+	LineNumber int
+	// Column is the 1-based byte offset of the first match in the line, it's
+	// zero for context lines and inverted matches.
+	Column int
+	// Content is the content of the file at the matching line.
+	Content string
+	// TreeName is the name of the tree (reference name/commit hash) at
+	// which the match was performed. It's empty for matches in the index or
+	// in the worktree.
+	TreeName string
+	// IsContext is true if the line doesn't match, and is returned as
+	// context of a matching line.
+	IsContext bool
+	// Count is the number of matching lines of the file, only set when
+	// GrepOptions.Count is used.
+	Count int
+}
+
+func (gr GrepResult) String() string {
+	name := gr.FileName
+	if gr.TreeName != "" {
+		name = fmt.Sprintf("%s:%s", gr.TreeName, gr.FileName)
+	}
+
+	switch {
+	case gr.Count != 0:
+		return fmt.Sprintf("%s:%d", name, gr.Count)
+	case gr.LineNumber == 0:
+		return name
+	case gr.IsContext:
+		return fmt.Sprintf("%s-%d-%s", name, gr.LineNumber, gr.Content)
+	}
+
+	return fmt.Sprintf("%s:%d:%s", name, gr.LineNumber, gr.Content)
+}
+
+// Grep performs grep on a worktree. By default the tree of the HEAD commit is
+// searched, GrepOptions allows to search other trees, the index and the
+// worktree files.
+func (w *Worktree) Grep(opts *GrepOptions) ([]GrepResult, error) {
+	if err := opts.Validate(w); err != nil {
+		return nil, err
+	}
+
+	g, err := newGrepper(w, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Do()
+}
+
+// grepFile is a file to be matched by a grepper.
+type grepFile struct {
+	n        int
+	treeName string
+	name     string
+	content  []byte
+}
+
+type grepper struct {
+	w        *Worktree
+	opts     *GrepOptions
+	patterns []*regexp.Regexp
+	attrs    gitattributes.Matcher
+	paths    pathspec.Matcher
+}
+
+func newGrepper(w *Worktree, opts *GrepOptions) (*grepper, error) {
+	g := &grepper{w: w, opts: opts}
+
+	prefix := ""
+	if opts.IgnoreCase {
+		prefix = "(?i)"
+	}
+
+	for _, p := range opts.Patterns {
+		if p == nil {
+			continue
+		}
+
+		if prefix != "" {
+			var err error
+			if p, err = regexp.Compile(prefix + p.String()); err != nil {
+				return nil, err
+			}
+		}
+
+		g.patterns = append(g.patterns, p)
+	}
+
+	for _, s := range opts.FixedStrings {
+		g.patterns = append(g.patterns, regexp.MustCompile(prefix+regexp.QuoteMeta(s)))
+	}
+
+	if len(opts.Paths) != 0 {
+		var err error
+		if g.paths, err = w.pathSpecMatcher(opts.Paths); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.Text {
+		attrs, err := gitattributes.ReadPatterns(w.Filesystem, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		g.attrs = gitattributes.NewMatcher(attrs)
+	}
+
+	return g, nil
+}
+
+// Do matches all the files, using opts.Workers goroutines, and returns the
+// results in the same order in which the files are walked.
+func (g *grepper) Do() ([]GrepResult, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[int][]GrepResult)
+		files   = make(chan *grepFile, g.opts.Workers)
+	)
+
+	for i := 0; i < g.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				r := g.match(f)
+
+				mu.Lock()
+				results[f.n] = r
+				mu.Unlock()
+			}
+		}()
+	}
+
+	n := 0
+	err := g.walk(func(f *grepFile) error {
+		f.n = n
+		n++
+
+		files <- f
+		return nil
+	})
+
+	close(files)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out []GrepResult
+	for i := 0; i < n; i++ {
+		out = append(out, results[i]...)
+	}
+
+	return out, nil
+}
+
+// walk calls cb for every file in the sources selected by the options,
+// matching the pathspecs. The files are read sequentially, since the storers
+// aren't safe for concurrent use.
+func (g *grepper) walk(cb func(*grepFile) error) error {
+	if !g.opts.CommitHash.IsZero() || g.opts.ReferenceName != "" {
+		if err := g.walkDefaultTree(cb); err != nil {
+			return err
+		}
+	}
+
+	for _, rev := range g.opts.Revisions {
+		h, err := g.w.r.ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+
+		if err := g.walkCommit(*h, string(rev), cb); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Index {
+		if err := g.walkIndex(cb); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Worktree {
+		if err := g.walkWorktree(cb); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Untracked {
+		return g.walkUntracked(cb)
+	}
+
+	return nil
+}
+
+func (g *grepper) walkDefaultTree(cb func(*grepFile) error) error {
+	if g.opts.ReferenceName != "" {
+		ref, err := g.w.r.Reference(g.opts.ReferenceName, true)
+		if err != nil {
+			return err
+		}
+
+		return g.walkCommit(ref.Hash(), g.opts.ReferenceName.String(), cb)
+	}
+
+	return g.walkCommit(g.opts.CommitHash, g.opts.CommitHash.String(), cb)
+}
+
+func (g *grepper) walkCommit(h plumbing.Hash, treeName string, cb func(*grepFile) error) error {
+	tree, err := g.w.getTreeFromCommitHash(h)
+	if err != nil {
+		return err
+	}
+
+	return tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode == filemode.Submodule || !g.inPathSpecs(f.Name) {
+			return nil
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+
+		return g.read(treeName, f.Name, r, cb)
+	})
+}
+
+func (g *grepper) walkIndex(cb func(*grepFile) error) error {
+	idx, err := g.w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Mode == filemode.Submodule || !g.inPathSpecs(e.Name) {
+			continue
+		}
+
+		blob, err := object.GetBlob(g.w.r.Storer, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		r, err := blob.Reader()
+		if err != nil {
+			return err
+		}
+
+		if err := g.read("", e.Name, r, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *grepper) walkWorktree(cb func(*grepFile) error) error {
+	idx, err := g.w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if seen[e.Name] {
+			continue
+		}
+
+		seen[e.Name] = true
+		if err := g.walkFile(e.Name, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *grepper) walkUntracked(cb func(*grepFile) error) error {
+	s, err := g.w.Status()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range s {
+		if s.IsUntracked(name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if err := g.walkFile(name, cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkFile reads the given file from the worktree filesystem, missing and
+// non regular files are skipped.
+func (g *grepper) walkFile(name string, cb func(*grepFile) error) error {
+	if !g.inPathSpecs(name) {
+		return nil
+	}
+
+	fi, err := g.w.Filesystem.Lstat(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := g.w.Filesystem.Open(name)
+	if err != nil {
+		return err
+	}
+
+	return g.read("", name, f, cb)
+}
+
+func (g *grepper) read(treeName, name string, r io.ReadCloser, cb func(*grepFile) error) (err error) {
+	defer ioutil.CheckClose(r, &err)
+
+	content, err := stdioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return cb(&grepFile{treeName: treeName, name: name, content: content})
+}
+
+func (g *grepper) inPathSpecs(name string) bool {
+	if g.paths != nil && !g.paths.Match(name, false) {
+		return false
+	}
+
+	// When no pathspecs are provided, search all the files.
+	if len(g.opts.PathSpecs) == 0 {
+		return true
+	}
+
+	for _, pathSpec := range g.opts.PathSpecs {
+		if pathSpec != nil && pathSpec.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBinary returns true if the file has the "binary" attribute, the "diff"
+// attribute unset or, with no attributes, it looks binary.
+func (g *grepper) isBinary(f *grepFile) bool {
+	if g.attrs != nil {
+		attrs, _ := g.attrs.Match(strings.Split(f.name, "/"), nil)
+		if a, ok := attrs["binary"]; ok && a.IsSet() {
+			return true
+		}
+
+		if a, ok := attrs["diff"]; ok && !a.IsUnspecified() {
+			return a.IsUnset()
+		}
+	}
+
+	bin, _ := binary.IsBinary(bytes.NewReader(f.content))
+	return bin
+}
+
+const (
+	grepNone = iota
+	grepContext
+	grepMatch
+)
+
+// match returns the results of matching the patterns against the content of
+// the given file.
+func (g *grepper) match(f *grepFile) []GrepResult {
+	if !g.opts.Text && g.isBinary(f) {
+		return nil
+	}
+
+	content := string(f.content)
+	content = strings.TrimSuffix(content, "\n")
+	lines := strings.Split(content, "\n")
+
+	kinds := make([]int, len(lines))
+	columns := make([]int, len(lines))
+
+	count := 0
+	for i, line := range lines {
+		ok, column := g.matchLine(line)
+		if !ok {
+			continue
+		}
+
+		count++
+		kinds[i] = grepMatch
+		columns[i] = column
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	if g.opts.FilesWithMatches {
+		return []GrepResult{{FileName: f.name, TreeName: f.treeName}}
+	}
+
+	if g.opts.Count {
+		return []GrepResult{{FileName: f.name, TreeName: f.treeName, Count: count}}
+	}
+
+	for i := range lines {
+		if kinds[i] != grepMatch {
+			continue
+		}
+
+		for j := i - g.opts.BeforeContext; j <= i+g.opts.AfterContext; j++ {
+			if j >= 0 && j < len(lines) && kinds[j] == grepNone {
+				kinds[j] = grepContext
+			}
+		}
+	}
+
+	var results []GrepResult
+	for i, line := range lines {
+		if kinds[i] == grepNone {
+			continue
+		}
+
+		results = append(results, GrepResult{
+			FileName:   f.name,
+			LineNumber: i + 1,
+			Column:     columns[i],
+			Content:    line,
+			TreeName:   f.treeName,
+			IsContext:  kinds[i] == grepContext,
+		})
+	}
+
+	return results
+}
+
+// matchLine returns if the line should be included in the results and the
+// column of the first match.
+func (g *grepper) matchLine(line string) (bool, int) {
+	first := -1
+	for _, p := range g.patterns {
+		loc := p.FindStringIndex(line)
+		if loc != nil && (first == -1 || loc[0] < first) {
+			first = loc[0]
+		}
+	}
+
+	if g.opts.InvertMatch {
+		return first == -1, 0
+	}
+
+	return first != -1, first + 1
+}
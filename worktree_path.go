@@ -0,0 +1,210 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/object/fsck"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	coreSection    = "core"
+	protectNTFSKey = "protectNTFS"
+	protectHFSKey  = "protectHFS"
+)
+
+// InvalidPathError is returned when a path of a tree, the index or the
+// worktree can't be written to the worktree or the index, since it could
+// overwrite the repository or files out of the worktree.
+type InvalidPathError struct {
+	// Path is the invalid path.
+	Path string
+	// Reason is why the path is invalid.
+	Reason string
+}
+
+func (e *InvalidPathError) Error() string {
+	return fmt.Sprintf("invalid path %q: %s", e.Path, e.Reason)
+}
+
+// pathVerifier checks the paths written to the worktree and the index, as
+// git does, rejecting the ".git" components, and their NTFS and HFS+ aliases
+// when the core.protectNTFS and core.protectHFS options are enabled. With
+// core.ignorecase, the paths of a tree only differing in their case collide.
+type pathVerifier struct {
+	fs         billy.Filesystem
+	ntfs       bool
+	hfs        bool
+	ignoreCase bool
+}
+
+// newPathVerifier returns the pathVerifier of the worktree. As git does,
+// core.protectNTFS is enabled by default and core.protectHFS only on macOS.
+func (w *Worktree) newPathVerifier() (*pathVerifier, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := cfg.Raw.Section(coreSection).Options
+	return &pathVerifier{
+		fs:         w.Filesystem,
+		ntfs:       configBool(opts.Get(protectNTFSKey), true),
+		hfs:        configBool(opts.Get(protectHFSKey), runtime.GOOS == "darwin"),
+		ignoreCase: configBool(opts.Get(ignoreCaseKey), false),
+	}, nil
+}
+
+// verifyPath checks the components of the path of an entry with the given
+// mode.
+func (v *pathVerifier) verifyPath(name string, mode filemode.FileMode) error {
+	if name == "" {
+		return &InvalidPathError{Path: name, Reason: "empty path"}
+	}
+
+	parts := strings.Split(name, "/")
+	for _, p := range parts {
+		var reason string
+		switch {
+		case p == "":
+			reason = "empty component"
+		case p == "." || p == "..":
+			reason = fmt.Sprintf("contains '%s'", p)
+		case fsck.IsDotGit(p, v.ntfs, v.hfs):
+			reason = "contains '.git'"
+		case v.ntfs && runtime.GOOS == "windows" && strings.ContainsRune(p, '\\'):
+			reason = "contains a backslash"
+		}
+
+		if reason != "" {
+			return &InvalidPathError{Path: name, Reason: reason}
+		}
+	}
+
+	if mode == filemode.Symlink && fsck.IsDotGitmodules(parts[len(parts)-1], v.ntfs, v.hfs) {
+		return &InvalidPathError{Path: name, Reason: gitmodulesFile + " is a symlink"}
+	}
+
+	return nil
+}
+
+// verifyTree checks the paths of all the entries of a tree, rejecting the
+// entries with the same name, such as a symlink and a directory, since the
+// first one written could change where the other one is. With ignoreCase, the
+// entries only differing in their case are rejected too, unless both are
+// directories, since they would be written to the same file.
+func (v *pathVerifier) verifyTree(t *object.Tree) error {
+	walker := object.NewTreeWalker(t, true, nil)
+	defer walker.Close()
+
+	seen := make(map[string]bool)
+	folded := make(map[string]*object.TreeEntry)
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := v.verifyPath(name, e.Mode); err != nil {
+			return err
+		}
+
+		if seen[name] {
+			return &InvalidPathError{Path: name, Reason: "duplicate entry"}
+		}
+
+		seen[name] = true
+		if !v.ignoreCase {
+			continue
+		}
+
+		key := strings.ToLower(name)
+		if other, ok := folded[key]; ok && (other.Mode != filemode.Dir || e.Mode != filemode.Dir) {
+			return &InvalidPathError{Path: name, Reason: fmt.Sprintf("collides with %q", other.Name)}
+		}
+
+		folded[key] = &object.TreeEntry{Name: name, Mode: e.Mode}
+	}
+}
+
+// verifiedTree returns the tree of a commit, once all its paths are checked,
+// and the pathVerifier used.
+func (w *Worktree) verifiedTree(commit plumbing.Hash) (*object.Tree, *pathVerifier, error) {
+	t, err := w.getTreeFromCommitHash(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v, err := w.newPathVerifier()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := v.verifyTree(t); err != nil {
+		return nil, nil, err
+	}
+
+	return t, v, nil
+}
+
+// verifyLeadingPath checks that none of the leading directories of a path is
+// a symbolic link in the worktree, so writing or removing it doesn't change
+// the files out of the worktree.
+func (v *pathVerifier) verifyLeadingPath(name string) error {
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		fi, err := v.fs.Lstat(strings.Join(parts[:i], "/"))
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return &InvalidPathError{Path: name, Reason: "beyond a symbolic link"}
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// verify checks the path of an entry with the given mode, and its leading
+// directories in the worktree.
+func (v *pathVerifier) verify(name string, mode filemode.FileMode) error {
+	if err := v.verifyPath(name, mode); err != nil {
+		return err
+	}
+
+	return v.verifyLeadingPath(name)
+}
+
+// configBool parses a boolean config value as git does, returning def if the
+// value is empty or unknown.
+func configBool(v string, def bool) bool {
+	switch strings.ToLower(v) {
+	case "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0":
+		return false
+	}
+
+	return def
+}
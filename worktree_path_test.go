@@ -0,0 +1,283 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+// storeBlob stores a blob with the given content.
+func storeBlob(c *C, s storer.EncodedObjectStorer, content string) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	h, err := s.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+// storeTree stores a tree with the given entries, in the given order, so the
+// malformed trees can be crafted.
+func storeTree(c *C, s storer.EncodedObjectStorer, entries ...object.TreeEntry) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	c.Assert((&object.Tree{Entries: entries}).Encode(obj), IsNil)
+
+	h, err := s.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+// storeCommit stores a commit of the given tree.
+func storeCommit(c *C, s storer.EncodedObjectStorer, tree plumbing.Hash) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	commit := &object.Commit{
+		Author:    *defaultSignature(),
+		Committer: *defaultSignature(),
+		Message:   "crafted\n",
+		TreeHash:  tree,
+	}
+
+	c.Assert(commit.Encode(obj), IsNil)
+
+	h, err := s.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+// commitWithDir stores a commit of a tree holding a file "foo" in a directory
+// with the given name.
+func commitWithDir(c *C, s storer.EncodedObjectStorer, name string) plumbing.Hash {
+	dir := storeTree(c, s, object.TreeEntry{
+		Name: "foo", Mode: filemode.Regular, Hash: storeBlob(c, s, "foo"),
+	})
+
+	return storeCommit(c, s, storeTree(c, s, object.TreeEntry{
+		Name: name, Mode: filemode.Dir, Hash: dir,
+	}))
+}
+
+func (s *WorktreeSuite) TestCheckoutInvalidPaths(c *C) {
+	for _, name := range []string{
+		".git", ".GIT", "..", ".", "../outside", "a/../..",
+		"git~1", ".git.", ".git::$INDEX_ALLOCATION",
+	} {
+		fs := memfs.New()
+		r, err := Init(memory.NewStorage(), fs)
+		c.Assert(err, IsNil)
+
+		w, err := r.Worktree()
+		c.Assert(err, IsNil)
+
+		commit := commitWithDir(c, r.Storer, name)
+		err = w.Checkout(&CheckoutOptions{Hash: commit, Force: true})
+		c.Assert(err, FitsTypeOf, &InvalidPathError{}, Commentf(name))
+
+		_, err = r.Head()
+		c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+		files, err := fs.ReadDir("")
+		c.Assert(err, IsNil)
+		c.Assert(files, HasLen, 0, Commentf(name))
+	}
+}
+
+func (s *WorktreeSuite) TestResetInvalidPath(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	valid := commitWithDir(c, r.Storer, "a")
+	err = w.Checkout(&CheckoutOptions{Hash: valid})
+	c.Assert(err, IsNil)
+
+	err = w.Reset(&ResetOptions{Commit: commitWithDir(c, r.Storer, ".Git"), Mode: HardReset})
+	c.Assert(err, ErrorMatches, `invalid path ".Git": contains '.git'`)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, valid)
+
+	_, err = fs.Stat("a/foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *WorktreeSuite) TestCheckoutProtectNTFS(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("protectNTFS", "false")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commit := commitWithDir(c, r.Storer, "git~1")
+	err = w.Checkout(&CheckoutOptions{Hash: commit, Force: true})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("git~1/foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *WorktreeSuite) TestCheckoutProtectHFS(c *C) {
+	for _, protect := range []string{"false", "true"} {
+		fs := memfs.New()
+		r, err := Init(memory.NewStorage(), fs)
+		c.Assert(err, IsNil)
+
+		cfg, err := r.Config()
+		c.Assert(err, IsNil)
+		cfg.Raw.Section("core").SetOption("protectHFS", protect)
+		c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+		w, err := r.Worktree()
+		c.Assert(err, IsNil)
+
+		commit := commitWithDir(c, r.Storer, ".gi\u200ct")
+		err = w.Checkout(&CheckoutOptions{Hash: commit, Force: true})
+		if protect == "true" {
+			c.Assert(err, FitsTypeOf, &InvalidPathError{})
+		} else {
+			c.Assert(err, IsNil)
+		}
+	}
+}
+
+func (s *WorktreeSuite) TestCheckoutGitmodulesSymlink(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer, object.TreeEntry{
+		Name: ".gitmodules", Mode: filemode.Symlink, Hash: storeBlob(c, r.Storer, "foo"),
+	}))
+
+	err = w.Checkout(&CheckoutOptions{Hash: commit, Force: true})
+	c.Assert(err, ErrorMatches, `invalid path ".gitmodules": .gitmodules is a symlink`)
+}
+
+func (s *WorktreeSuite) TestCheckoutDuplicateEntries(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("git doesn't support symlinks by default in windows")
+	}
+
+	dir, err := ioutil.TempDir("", "checkout-symlink")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	c.Assert(os.Mkdir(filepath.Join(dir, "outside"), 0755), IsNil)
+
+	fs := osfs.New(filepath.Join(dir, "worktree"))
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	foo := storeTree(c, r.Storer, object.TreeEntry{
+		Name: "foo", Mode: filemode.Regular, Hash: storeBlob(c, r.Storer, "foo"),
+	})
+
+	commit := storeCommit(c, r.Storer, storeTree(c, r.Storer,
+		object.TreeEntry{Name: "a", Mode: filemode.Symlink, Hash: storeBlob(c, r.Storer, "../outside")},
+		object.TreeEntry{Name: "a", Mode: filemode.Dir, Hash: foo},
+	))
+
+	err = w.Checkout(&CheckoutOptions{Hash: commit, Force: true})
+	c.Assert(err, ErrorMatches, `invalid path "a": duplicate entry`)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "outside"))
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
+func (s *WorktreeSuite) TestCheckoutPathSpecsBeyondSymlink(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("git doesn't support symlinks by default in windows")
+	}
+
+	dir, err := ioutil.TempDir("", "checkout-symlink")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	c.Assert(os.Mkdir(filepath.Join(dir, "outside"), 0755), IsNil)
+
+	fs := osfs.New(filepath.Join(dir, "worktree"))
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Symlink("../outside", "a"), IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Hash:      commitWithDir(c, r.Storer, "a"),
+		PathSpecs: []string{"a/foo"},
+	})
+	c.Assert(err, ErrorMatches, `invalid path "a/foo": beyond a symbolic link`)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "outside"))
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
+func (s *WorktreeSuite) TestAddInvalidPath(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("git doesn't support symlinks by default in windows")
+	}
+
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.MkdirAll("b", 0755), IsNil)
+	c.Assert(fs.Symlink("b", "a"), IsNil)
+	c.Assert(fs.MkdirAll("GIT~1", 0755), IsNil)
+	for _, name := range []string{"b/foo", "GIT~1/foo"} {
+		f, err := fs.Create(name)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+	}
+
+	_, err = w.Add("a/foo")
+	c.Assert(err, ErrorMatches, `invalid path "a/foo": beyond a symbolic link`)
+
+	_, err = w.Add("GIT~1/foo")
+	c.Assert(err, ErrorMatches, `invalid path "GIT~1/foo": contains '.git'`)
+
+	err = w.AddGlob("GIT~1")
+	c.Assert(err, FitsTypeOf, &InvalidPathError{})
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 0)
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
@@ -11,8 +12,10 @@ import (
 	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitattributes"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pathspec"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
@@ -28,6 +31,9 @@ var (
 	// ErrGlobNoMatches in an AddGlob if the glob pattern does not match any
 	// files in the worktree.
 	ErrGlobNoMatches = errors.New("glob pattern did not match any files")
+	// ErrPathSpecNoMatches is returned when the pathspecs of an operation
+	// don't match any file.
+	ErrPathSpecNoMatches = errors.New("pathspecs did not match any files")
 )
 
 // Status returns the working tree status.
@@ -43,13 +49,74 @@ func (w *Worktree) Status() (Status, error) {
 		hash = ref.Hash()
 	}
 
-	return w.status(hash)
+	return w.status(hash, nil)
 }
 
-func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
+// StatusWithOptions returns the working tree status, restricted to the paths
+// selected by the given options, nil meaning the default ones. The
+// directories not matching any pathspec are not walked.
+func (w *Worktree) StatusWithOptions(o *StatusOptions) (Status, error) {
+	if o == nil {
+		o = &StatusOptions{}
+	}
+
+	var hash plumbing.Hash
+
+	ref, err := w.r.Head()
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	if err == nil {
+		hash = ref.Hash()
+	}
+
+	var filter merkletrie.Filter
+	if len(o.PathSpecs) != 0 {
+		m, err := w.pathSpecMatcher(o.PathSpecs)
+		if err != nil {
+			return nil, err
+		}
+
+		filter = func(p noder.Path) bool {
+			return m.Match(p.String(), p.IsDir())
+		}
+	}
+
+	return w.status(hash, filter)
+}
+
+// pathSpecMatcher returns a pathspec.Matcher of the given pathspecs, relative
+// to the root of the worktree, reading the gitattributes of the worktree when
+// any of them has the attr magic.
+func (w *Worktree) pathSpecMatcher(pathSpecs []string) (pathspec.Matcher, error) {
+	ps := make([]*pathspec.Pathspec, 0, len(pathSpecs))
+	for _, spec := range pathSpecs {
+		p, err := pathspec.Parse(spec, "")
+		if err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, p)
+	}
+
+	var attrs gitattributes.Matcher
+	if pathspec.HasAttributes(ps) {
+		patterns, err := gitattributes.ReadPatterns(w.Filesystem, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		attrs = gitattributes.NewMatcher(patterns)
+	}
+
+	return pathspec.NewMatcher(ps, attrs), nil
+}
+
+func (w *Worktree) status(commit plumbing.Hash, filter merkletrie.Filter) (Status, error) {
 	s := make(Status)
 
-	left, err := w.diffCommitWithStaging(commit, false)
+	left, err := w.diffCommitWithStaging(commit, false, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +140,7 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		}
 	}
 
-	right, err := w.diffStagingWithWorktree(false)
+	right, err := w.diffStagingWithWorktree(false, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +179,7 @@ func nameFromAction(ch *merkletrie.Change) string {
 	return name
 }
 
-func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, error) {
+func (w *Worktree) diffStagingWithWorktree(reverse bool, filter merkletrie.Filter) (merkletrie.Changes, error) {
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return nil, err
@@ -124,19 +191,32 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	o, err := w.coreOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	to := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, filesystem.Options{
+		FileMode: o.fileModeFunc(idx),
+	})
+
+	opts := &merkletrie.DiffTreeOptions{Filter: filter}
 
 	var c merkletrie.Changes
 	if reverse {
-		c, err = merkletrie.DiffTree(to, from, diffTreeIsEquals)
+		c, err = merkletrie.DiffTreeWithOptions(context.Background(), to, from, diffTreeIsEquals, opts)
 	} else {
-		c, err = merkletrie.DiffTree(from, to, diffTreeIsEquals)
+		c, err = merkletrie.DiffTreeWithOptions(context.Background(), from, to, diffTreeIsEquals, opts)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	if o.ignoreCase {
+		c = foldCaseChanges(c)
+	}
+
 	return w.excludeIgnoredChanges(c), nil
 }
 
@@ -201,7 +281,7 @@ func (w *Worktree) getSubmodulesStatus() (map[string]plumbing.Hash, error) {
 	return o, nil
 }
 
-func (w *Worktree) diffCommitWithStaging(commit plumbing.Hash, reverse bool) (merkletrie.Changes, error) {
+func (w *Worktree) diffCommitWithStaging(commit plumbing.Hash, reverse bool, filter merkletrie.Filter) (merkletrie.Changes, error) {
 	var t *object.Tree
 	if !commit.IsZero() {
 		c, err := w.r.CommitObject(commit)
@@ -215,10 +295,10 @@ func (w *Worktree) diffCommitWithStaging(commit plumbing.Hash, reverse bool) (me
 		}
 	}
 
-	return w.diffTreeWithStaging(t, reverse)
+	return w.diffTreeWithStaging(t, reverse, filter)
 }
 
-func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie.Changes, error) {
+func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool, filter merkletrie.Filter) (merkletrie.Changes, error) {
 	var from noder.Noder
 	if t != nil {
 		from = object.NewTreeRootNode(t)
@@ -230,12 +310,13 @@ func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie
 	}
 
 	to := mindex.NewRootNode(idx)
+	opts := &merkletrie.DiffTreeOptions{Filter: filter}
 
 	if reverse {
-		return merkletrie.DiffTree(to, from, diffTreeIsEquals)
+		return merkletrie.DiffTreeWithOptions(context.Background(), to, from, diffTreeIsEquals, opts)
 	}
 
-	return merkletrie.DiffTree(from, to, diffTreeIsEquals)
+	return merkletrie.DiffTreeWithOptions(context.Background(), from, to, diffTreeIsEquals, opts)
 }
 
 var emptyNoderHash = make([]byte, 24)
@@ -275,14 +356,24 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	v, err := w.newPathVerifier()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	var h plumbing.Hash
 	var added bool
 
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(idx, s, path)
+		added, h, err = w.doAddFile(idx, s, v, o, path)
 	} else {
-		added, err = w.doAddDirectory(idx, s, path)
+		added, err = w.doAddDirectory(idx, s, v, o, path)
 	}
 
 	if err != nil {
@@ -296,7 +387,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return h, w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string) (added bool, err error) {
+func (w *Worktree) doAddDirectory(idx *index.Index, s Status, v *pathVerifier, o *coreOptions, directory string) (added bool, err error) {
 	files, err := w.Filesystem.ReadDir(directory)
 	if err != nil {
 		return false, err
@@ -311,9 +402,9 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string)
 				// ignore special git directory
 				continue
 			}
-			a, err = w.doAddDirectory(idx, s, name)
+			a, err = w.doAddDirectory(idx, s, v, o, name)
 		} else {
-			a, _, err = w.doAddFile(idx, s, name)
+			a, _, err = w.doAddFile(idx, s, v, o, name)
 		}
 
 		if err != nil {
@@ -351,6 +442,16 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
+	v, err := w.newPathVerifier()
+	if err != nil {
+		return err
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return err
+	}
+
 	var saveIndex bool
 	for _, file := range files {
 		fi, err := w.Filesystem.Lstat(file)
@@ -360,9 +461,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(idx, s, file)
+			added, err = w.doAddDirectory(idx, s, v, o, file)
 		} else {
-			added, _, err = w.doAddFile(idx, s, file)
+			added, _, err = w.doAddFile(idx, s, v, o, file)
 		}
 
 		if err != nil {
@@ -381,24 +482,95 @@ func (w *Worktree) AddGlob(pattern string) error {
 	return nil
 }
 
+// AddWithOptions adds the files matching the pathspecs to the index, the
+// modified and the untracked ones, besides the ignored ones, and removes the
+// deleted ones, as `git add` does.
+func (w *Worktree) AddWithOptions(opts *AddOptions) error {
+	s, err := w.StatusWithOptions(&StatusOptions{PathSpecs: opts.PathSpecs})
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	if len(s) == 0 && len(opts.PathSpecs) != 0 {
+		m, err := w.pathSpecMatcher(opts.PathSpecs)
+		if err != nil {
+			return err
+		}
+
+		if !matchesIndex(idx, m) {
+			return ErrPathSpecNoMatches
+		}
+	}
+
+	v, err := w.newPathVerifier()
+	if err != nil {
+		return err
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return err
+	}
+
+	var saveIndex bool
+	for name, fs := range s {
+		if fs.Worktree == Unmodified {
+			continue
+		}
+
+		added, _, err := w.doAddFile(idx, s, v, o, name)
+		if err != nil {
+			return err
+		}
+
+		saveIndex = saveIndex || added
+	}
+
+	if saveIndex {
+		return w.r.Storer.SetIndex(idx)
+	}
+
+	return nil
+}
+
+// matchesIndex returns true if any entry of the index matches m.
+func matchesIndex(idx *index.Index, m pathspec.Matcher) bool {
+	for _, e := range idx.Entries {
+		if m.Match(e.Name, false) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
-func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added bool, h plumbing.Hash, err error) {
+func (w *Worktree) doAddFile(idx *index.Index, s Status, v *pathVerifier, o *coreOptions, path string) (added bool, h plumbing.Hash, err error) {
 	if s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
 
+	if err := v.verify(path, filemode.Empty); err != nil {
+		return false, h, err
+	}
+
 	h, err = w.copyFileToStorage(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
-			h, err = w.deleteFromIndex(idx, path)
+			h, err = w.deleteFromIndex(idx, o, path)
 		}
 
 		return
 	}
 
-	if err := w.addOrUpdateFileToIndex(idx, path, h); err != nil {
+	if err := w.addOrUpdateFileToIndex(idx, o, path, h); err != nil {
 		return false, h, err
 	}
 
@@ -460,24 +632,24 @@ func (w *Worktree) fillEncodedObjectFromSymlink(dst io.Writer, path string, fi o
 	return err
 }
 
-func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h plumbing.Hash) error {
-	e, err := idx.Entry(filename)
+func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, o *coreOptions, filename string, h plumbing.Hash) error {
+	e, err := idx.Entry(o.indexName(idx, filename))
 	if err != nil && err != index.ErrEntryNotFound {
 		return err
 	}
 
 	if err == index.ErrEntryNotFound {
-		return w.doAddFileToIndex(idx, filename, h)
+		return w.doAddFileToIndex(idx, o, filename, h)
 	}
 
-	return w.doUpdateFileToIndex(e, filename, h)
+	return w.doUpdateFileToIndex(e, o, filename, h)
 }
 
-func (w *Worktree) doAddFileToIndex(idx *index.Index, filename string, h plumbing.Hash) error {
-	return w.doUpdateFileToIndex(idx.Add(filename), filename, h)
+func (w *Worktree) doAddFileToIndex(idx *index.Index, o *coreOptions, filename string, h plumbing.Hash) error {
+	return w.doUpdateFileToIndex(idx.Add(filename), o, filename, h)
 }
 
-func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbing.Hash) error {
+func (w *Worktree) doUpdateFileToIndex(e *index.Entry, o *coreOptions, filename string, h plumbing.Hash) error {
 	info, err := w.Filesystem.Lstat(filename)
 	if err != nil {
 		return err
@@ -485,7 +657,7 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 
 	e.Hash = h
 	e.ModifiedAt = info.ModTime()
-	e.Mode, err = filemode.NewFromOSFileMode(info.Mode())
+	e.Mode, err = o.mode(info, e.Mode)
 	if err != nil {
 		return err
 	}
@@ -506,13 +678,18 @@ func (w *Worktree) Remove(path string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	o, err := w.coreOptions()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	var h plumbing.Hash
 
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil || !fi.IsDir() {
-		h, err = w.doRemoveFile(idx, path)
+		h, err = w.doRemoveFile(idx, o, path)
 	} else {
-		_, err = w.doRemoveDirectory(idx, path)
+		_, err = w.doRemoveDirectory(idx, o, path)
 	}
 	if err != nil {
 		return h, err
@@ -521,7 +698,7 @@ func (w *Worktree) Remove(path string) (plumbing.Hash, error) {
 	return h, w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) doRemoveDirectory(idx *index.Index, directory string) (removed bool, err error) {
+func (w *Worktree) doRemoveDirectory(idx *index.Index, o *coreOptions, directory string) (removed bool, err error) {
 	files, err := w.Filesystem.ReadDir(directory)
 	if err != nil {
 		return false, err
@@ -532,9 +709,9 @@ func (w *Worktree) doRemoveDirectory(idx *index.Index, directory string) (remove
 
 		var r bool
 		if file.IsDir() {
-			r, err = w.doRemoveDirectory(idx, name)
+			r, err = w.doRemoveDirectory(idx, o, name)
 		} else {
-			_, err = w.doRemoveFile(idx, name)
+			_, err = w.doRemoveFile(idx, o, name)
 			if err == index.ErrEntryNotFound {
 				err = nil
 			}
@@ -566,8 +743,8 @@ func (w *Worktree) removeEmptyDirectory(path string) error {
 	return w.Filesystem.Remove(path)
 }
 
-func (w *Worktree) doRemoveFile(idx *index.Index, path string) (plumbing.Hash, error) {
-	hash, err := w.deleteFromIndex(idx, path)
+func (w *Worktree) doRemoveFile(idx *index.Index, o *coreOptions, path string) (plumbing.Hash, error) {
+	hash, err := w.deleteFromIndex(idx, o, path)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
@@ -575,8 +752,8 @@ func (w *Worktree) doRemoveFile(idx *index.Index, path string) (plumbing.Hash, e
 	return hash, w.deleteFromFilesystem(path)
 }
 
-func (w *Worktree) deleteFromIndex(idx *index.Index, path string) (plumbing.Hash, error) {
-	e, err := idx.Remove(path)
+func (w *Worktree) deleteFromIndex(idx *index.Index, o *coreOptions, path string) (plumbing.Hash, error) {
+	e, err := idx.Remove(o.indexName(idx, path))
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
@@ -607,13 +784,18 @@ func (w *Worktree) RemoveGlob(pattern string) error {
 		return err
 	}
 
+	o, err := w.coreOptions()
+	if err != nil {
+		return err
+	}
+
 	for _, e := range entries {
 		file := filepath.FromSlash(e.Name)
 		if _, err := w.Filesystem.Lstat(file); err != nil && !os.IsNotExist(err) {
 			return err
 		}
 
-		if _, err := w.doRemoveFile(idx, file); err != nil {
+		if _, err := w.doRemoveFile(idx, o, file); err != nil {
 			return err
 		}
 
@@ -626,6 +808,62 @@ func (w *Worktree) RemoveGlob(pattern string) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
+// RemoveWithOptions removes the files of the index matching the pathspecs
+// from it and from the worktree, as `git rm` does.
+func (w *Worktree) RemoveWithOptions(opts *RemoveOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	m, err := w.pathSpecMatcher(opts.PathSpecs)
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, e := range idx.Entries {
+		if m.Match(e.Name, false) {
+			names = append(names, e.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return ErrPathSpecNoMatches
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := w.deleteFromIndex(idx, o, name); err != nil {
+			return err
+		}
+
+		if opts.Cached {
+			continue
+		}
+
+		if err := w.deleteFromFilesystem(name); err != nil {
+			return err
+		}
+
+		if dir := path.Dir(name); dir != "." {
+			if err := w.removeEmptyDirectory(dir); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
 // Move moves or rename a file in the worktree and the index, directories are
 // not supported.
 func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
@@ -638,12 +876,26 @@ func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, ErrDestinationExists
 	}
 
+	v, err := w.newPathVerifier()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := v.verify(to, filemode.Empty); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	o, err := w.coreOptions()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
-	hash, err := w.deleteFromIndex(idx, from)
+	hash, err := w.deleteFromIndex(idx, o, from)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
@@ -652,7 +904,7 @@ func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
 		return hash, err
 	}
 
-	if err := w.addOrUpdateFileToIndex(idx, to, hash); err != nil {
+	if err := w.addOrUpdateFileToIndex(idx, o, to, hash); err != nil {
 		return hash, err
 	}
 
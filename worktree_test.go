@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -293,6 +294,105 @@ func (s *WorktreeSuite) TestCheckout(c *C) {
 	c.Assert(idx.Entries, HasLen, 9)
 }
 
+func (s *WorktreeSuite) TestCheckoutPathSpecs(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "go/example.go", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+
+	err = w.Checkout(&CheckoutOptions{PathSpecs: []string{"go"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Modified)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+
+	// the files missing in the commit are kept
+	err = w.Checkout(&CheckoutOptions{
+		Hash:      plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+		PathSpecs: []string{"LICENSE", "json"},
+	})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 0)
+
+	ref, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, head.Hash())
+}
+
+func (s *WorktreeSuite) TestRestore(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "go/example.go", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "LICENSE", []byte("foo"), 0644), IsNil)
+	_, err = w.Add("LICENSE")
+	c.Assert(err, IsNil)
+
+	err = w.Restore(&RestoreOptions{PathSpecs: []string{"go", "LICENSE"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("LICENSE").Staging, Equals, Modified)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Unmodified)
+
+	err = w.Restore(&RestoreOptions{Staged: true, PathSpecs: []string{"LICENSE"}})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("LICENSE").Staging, Equals, Unmodified)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Modified)
+
+	err = w.Restore(&RestoreOptions{Staged: true, Worktree: true, PathSpecs: []string{"LICENSE"}})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	// the matching files missing in the source are removed
+	err = w.Restore(&RestoreOptions{
+		Source:    plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+		Staged:    true,
+		Worktree:  true,
+		PathSpecs: []string{"json"},
+	})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("json/long.json").Staging, Equals, Deleted)
+	c.Assert(status.File("json/short.json").Staging, Equals, Deleted)
+
+	err = w.Restore(&RestoreOptions{})
+	c.Assert(err, Equals, ErrMissingPathSpecs)
+}
+
 func (s *WorktreeSuite) TestCheckoutForce(c *C) {
 	w := &Worktree{
 		r:          s.Repository,
@@ -978,6 +1078,59 @@ func (s *WorktreeSuite) TestStatusModified(c *C) {
 	c.Assert(status.File(".gitignore").Worktree, Equals, Modified)
 }
 
+func (s *WorktreeSuite) TestStatusWithOptions(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(fs, ".gitignore", []byte("foo"), 0755)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(fs, "go/example.go", []byte("foo"), 0755)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(fs, "go/new.go", []byte("foo"), 0755)
+	c.Assert(err, IsNil)
+	err = util.WriteFile(fs, "vendor/new.go", []byte("foo"), 0755)
+	c.Assert(err, IsNil)
+
+	status, err := w.StatusWithOptions(&StatusOptions{PathSpecs: []string{"go"}})
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("go/example.go").Worktree, Equals, Modified)
+	c.Assert(status.IsUntracked("go/new.go"), Equals, true)
+
+	status, err = w.StatusWithOptions(&StatusOptions{
+		PathSpecs: []string{".gitignore", "vendor/"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File(".gitignore").Worktree, Equals, Modified)
+	c.Assert(status.IsUntracked("vendor/new.go"), Equals, true)
+
+	status, err = w.StatusWithOptions(&StatusOptions{
+		PathSpecs: []string{":(glob)**/*.go", ":!vendor"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("go/example.go").Worktree, Equals, Modified)
+	c.Assert(status.IsUntracked("go/new.go"), Equals, true)
+
+	status, err = w.StatusWithOptions(&StatusOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 4)
+
+	status, err = w.StatusWithOptions(nil)
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 4)
+
+	_, err = w.StatusWithOptions(&StatusOptions{PathSpecs: []string{":(foo)bar"}})
+	c.Assert(err, NotNil)
+}
+
 func (s *WorktreeSuite) TestStatusIgnored(c *C) {
 	fs := memfs.New()
 	w := &Worktree{
@@ -1423,6 +1576,55 @@ func (s *WorktreeSuite) TestAddGlob(c *C) {
 	c.Assert(file.Worktree, Equals, Unmodified)
 }
 
+func (s *WorktreeSuite) TestAddWithOptions(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "go/new.go", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "json/new.json", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "vendor/bar.go", []byte("foo"), 0644), IsNil)
+	c.Assert(fs.Remove("LICENSE"), IsNil)
+
+	err = w.AddWithOptions(&AddOptions{PathSpecs: []string{"*.go", ":!vendor"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 4)
+	c.Assert(status.File("go/new.go").Staging, Equals, Added)
+	c.Assert(status.IsUntracked("json/new.json"), Equals, true)
+	c.Assert(status.IsUntracked("vendor/bar.go"), Equals, true)
+	c.Assert(status.File("LICENSE").Worktree, Equals, Deleted)
+
+	err = w.AddWithOptions(&AddOptions{PathSpecs: []string{"LICENSE"}})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("LICENSE").Staging, Equals, Deleted)
+
+	err = w.AddWithOptions(&AddOptions{PathSpecs: []string{"CHANGELOG"}})
+	c.Assert(err, IsNil)
+
+	err = w.AddWithOptions(&AddOptions{PathSpecs: []string{"unknown"}})
+	c.Assert(err, Equals, ErrPathSpecNoMatches)
+
+	err = w.AddWithOptions(&AddOptions{})
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 4)
+	c.Assert(status.File("json/new.json").Staging, Equals, Added)
+	c.Assert(status.File("vendor/bar.go").Staging, Equals, Added)
+}
+
 func (s *WorktreeSuite) TestAddGlobErrorNoMatches(c *C) {
 	r, _ := Init(memory.NewStorage(), memfs.New())
 	w, _ := r.Worktree()
@@ -1560,6 +1762,39 @@ func (s *WorktreeSuite) TestRemoveGlob(c *C) {
 	c.Assert(status.File("json/long.json").Staging, Equals, Deleted)
 }
 
+func (s *WorktreeSuite) TestRemoveWithOptions(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	err = w.RemoveWithOptions(&RemoveOptions{PathSpecs: []string{"json"}})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("json")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	err = w.RemoveWithOptions(&RemoveOptions{PathSpecs: []string{"*.php"}, Cached: true})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 3)
+	c.Assert(status.File("json/long.json").Staging, Equals, Deleted)
+	c.Assert(status.File("json/short.json").Staging, Equals, Deleted)
+	c.Assert(status.IsUntracked("php/crappy.php"), Equals, true)
+
+	err = w.RemoveWithOptions(&RemoveOptions{PathSpecs: []string{"json"}})
+	c.Assert(err, Equals, ErrPathSpecNoMatches)
+
+	err = w.RemoveWithOptions(&RemoveOptions{})
+	c.Assert(err, Equals, ErrMissingPathSpecs)
+}
+
 func (s *WorktreeSuite) TestRemoveGlobDirectory(c *C) {
 	fs := memfs.New()
 	w := &Worktree{
@@ -1706,6 +1941,67 @@ func (s *WorktreeSuite) TestClean(c *C) {
 
 }
 
+func (s *WorktreeSuite) TestCleanWithOptions(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{
+		"new.txt", "keep.txt", "go/new.go", "build/out.o", "tmp/a.txt",
+		"tmp/b.log", "nested/file", "nested/.git/HEAD",
+	} {
+		c.Assert(util.WriteFile(fs, name, []byte("foo"), 0644), IsNil)
+	}
+
+	c.Assert(util.WriteFile(fs, ".gitignore", []byte("*.log\nbuild/\n"), 0644), IsNil)
+
+	paths, err := w.CleanPaths(&CleanOptions{DryRun: true, Exclude: []string{"keep.txt"}})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"go/new.go", "new.txt"})
+
+	paths, err = w.CleanPaths(&CleanOptions{DryRun: true, Dir: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"go/new.go", "keep.txt", "new.txt", "tmp/a.txt"})
+
+	paths, err = w.CleanPaths(&CleanOptions{DryRun: true, Dir: true, Nested: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"go/new.go", "keep.txt", "nested/", "new.txt", "tmp/a.txt"})
+
+	paths, err = w.CleanPaths(&CleanOptions{DryRun: true, Dir: true, OnlyIgnored: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"build/", "tmp/b.log"})
+
+	_, err = w.CleanPaths(&CleanOptions{Ignored: true, OnlyIgnored: true})
+	c.Assert(err, Equals, ErrIgnoredAndOnlyIgnored)
+
+	_, err = fs.Lstat("new.txt")
+	c.Assert(err, IsNil)
+
+	paths, err = w.CleanPaths(&CleanOptions{
+		Dir:       true,
+		Ignored:   true,
+		PathSpecs: []string{"build", "tmp", "*.txt"},
+		Exclude:   []string{"keep.txt"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"build/", "new.txt", "tmp/"})
+
+	for _, name := range []string{"build", "tmp", "new.txt"} {
+		_, err = fs.Lstat(name)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+
+	for _, name := range []string{"keep.txt", "go/new.go", "nested/file", "go/example.go"} {
+		_, err = fs.Lstat(name)
+		c.Assert(err, IsNil)
+	}
+}
+
 func (s *WorktreeSuite) TestAlternatesRepo(c *C) {
 	fs := fixtures.ByTag("alternates").One().Worktree()
 
@@ -1753,12 +2049,14 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
 				{
 					FileName:   "vendor/foo.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import \"fmt\"",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1772,12 +2070,14 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
 				{
 					FileName:   "vendor/foo.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import \"fmt\"",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1792,12 +2092,14 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     0,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
 				{
 					FileName:   "vendor/foo.go",
 					LineNumber: 3,
+					Column:     0,
 					Content:    "import \"fmt\"",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1812,6 +2114,7 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "LICENSE",
 					LineNumber: 1,
+					Column:     1,
 					Content:    "The MIT License (MIT)",
 					TreeName:   "b029517f6300c2da0f4b651b8642506cd6aaf45d",
 				},
@@ -1820,6 +2123,7 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1834,6 +2138,7 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1842,6 +2147,7 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "vendor/foo.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import \"fmt\"",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1856,6 +2162,7 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "refs/heads/master",
 				},
@@ -1880,18 +2187,21 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
 				{
 					FileName:   "vendor/foo.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import \"fmt\"",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
 				{
 					FileName:   "LICENSE",
 					LineNumber: 1,
+					Column:     9,
 					Content:    "The MIT License (MIT)",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1909,12 +2219,14 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 				{
 					FileName:   "go/example.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import (",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
 				{
 					FileName:   "vendor/foo.go",
 					LineNumber: 3,
+					Column:     1,
 					Content:    "import \"fmt\"",
 					TreeName:   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
 				},
@@ -1971,6 +2283,178 @@ func (s *WorktreeSuite) TestGrep(c *C) {
 	}
 }
 
+func (s *WorktreeSuite) TestGrepWorktreeAndIndex(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, ".gitignore", []byte("ignored\n"), 0644)
+	util.WriteFile(fs, "foo", []byte("foo\nbar\n"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("qux\nbar\n"), 0644)
+	util.WriteFile(fs, "untracked", []byte("bar\n"), 0644)
+	util.WriteFile(fs, "ignored", []byte("bar\n"), 0644)
+
+	pattern := []*regexp.Regexp{regexp.MustCompile("foo|qux")}
+
+	gr, err := w.Grep(&GrepOptions{Patterns: pattern, Index: true})
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{
+		{FileName: "foo", LineNumber: 1, Column: 1, Content: "foo"},
+	})
+
+	gr, err = w.Grep(&GrepOptions{Patterns: pattern, Worktree: true})
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{
+		{FileName: "foo", LineNumber: 1, Column: 1, Content: "qux"},
+	})
+
+	gr, err = w.Grep(&GrepOptions{
+		FixedStrings:     []string{"bar"},
+		Worktree:         true,
+		Untracked:        true,
+		FilesWithMatches: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{
+		{FileName: "foo"},
+		{FileName: "untracked"},
+	})
+	c.Assert(gr[0].String(), Equals, "foo")
+
+	gr, err = w.Grep(&GrepOptions{
+		FixedStrings:     []string{"bar"},
+		Worktree:         true,
+		Untracked:        true,
+		FilesWithMatches: true,
+		Paths:            []string{":!foo"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{{FileName: "untracked"}})
+}
+
+func (s *WorktreeSuite) TestGrepContextAndCount(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("a\nb\nFoo.bar\nc\nd\ne\nfoo.bar\nf\n"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	gr, err := w.Grep(&GrepOptions{
+		FixedStrings:  []string{"o.b"},
+		IgnoreCase:    true,
+		Worktree:      true,
+		BeforeContext: 1,
+		AfterContext:  2,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{
+		{FileName: "foo", LineNumber: 2, Content: "b", IsContext: true},
+		{FileName: "foo", LineNumber: 3, Column: 3, Content: "Foo.bar"},
+		{FileName: "foo", LineNumber: 4, Content: "c", IsContext: true},
+		{FileName: "foo", LineNumber: 5, Content: "d", IsContext: true},
+		{FileName: "foo", LineNumber: 6, Content: "e", IsContext: true},
+		{FileName: "foo", LineNumber: 7, Column: 3, Content: "foo.bar"},
+		{FileName: "foo", LineNumber: 8, Content: "f", IsContext: true},
+	})
+	c.Assert(gr[0].String(), Equals, "foo-2-b")
+	c.Assert(gr[1].String(), Equals, "foo:3:Foo.bar")
+
+	gr, err = w.Grep(&GrepOptions{
+		FixedStrings: []string{"foo."},
+		Worktree:     true,
+		Count:        true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{{FileName: "foo", Count: 1}})
+	c.Assert(gr[0].String(), Equals, "foo:1")
+
+	_, err = w.Grep(&GrepOptions{Count: true, FilesWithMatches: true})
+	c.Assert(err, Equals, ErrGrepOutputMode)
+}
+
+func (s *WorktreeSuite) TestGrepBinary(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, ".gitattributes", []byte("*.dat binary\n*.txt diff\n"), 0644)
+	util.WriteFile(fs, "foo.dat", []byte("foo\n"), 0644)
+	util.WriteFile(fs, "foo.bin", []byte("foo\x00\n"), 0644)
+	util.WriteFile(fs, "foo.txt", []byte("foo\x00\n"), 0644)
+	util.WriteFile(fs, "foo", []byte("foo\n"), 0644)
+
+	opts := &GrepOptions{
+		Patterns:         []*regexp.Regexp{regexp.MustCompile("foo")},
+		Untracked:        true,
+		FilesWithMatches: true,
+	}
+
+	gr, err := w.Grep(opts)
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, []GrepResult{
+		{FileName: "foo"},
+		{FileName: "foo.txt"},
+	})
+
+	opts.Text = true
+	gr, err = w.Grep(opts)
+	c.Assert(err, IsNil)
+	c.Assert(gr, HasLen, 4)
+}
+
+func (s *WorktreeSuite) TestGrepRevisionsAndWorkers(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	var commits []plumbing.Hash
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 10; j++ {
+			content := fmt.Sprintf("foo %d %d\n", i, j)
+			util.WriteFile(w.Filesystem, fmt.Sprintf("file%d", j), []byte(content), 0644)
+		}
+
+		_, err = w.Add(".")
+		c.Assert(err, IsNil)
+
+		h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+		commits = append(commits, h)
+	}
+
+	opts := &GrepOptions{
+		Patterns:  []*regexp.Regexp{regexp.MustCompile("foo")},
+		Revisions: []plumbing.Revision{"HEAD~1", "HEAD"},
+	}
+
+	expected, err := w.Grep(opts)
+	c.Assert(err, IsNil)
+	c.Assert(expected, HasLen, 20)
+	c.Assert(expected[0].String(), Equals, "HEAD~1:file0:1:foo 0 0")
+	c.Assert(expected[19].String(), Equals, "HEAD:file9:1:foo 1 9")
+
+	opts.Workers = 4
+	gr, err := w.Grep(opts)
+	c.Assert(err, IsNil)
+	c.Assert(gr, DeepEquals, expected)
+}
+
 func (s *WorktreeSuite) TestAddAndCommit(c *C) {
 	dir, err := ioutil.TempDir("", "plain-repo")
 	c.Assert(err, IsNil)